@@ -2,9 +2,11 @@ package controller
 
 import (
 	"ModVerse/domain"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
 type CategoriesController struct {
@@ -36,8 +38,15 @@ func (cc *CategoriesController) CreateCategory(c fiber.Ctx) error {
 	}
 
 	categories := domain.Categories{
-		Name:   requestBody.Name,
-		Status: requestBody.Status,
+		Name:      requestBody.Name,
+		Slug:      requestBody.Slug,
+		Status:    requestBody.Status,
+		ParentID:  requestBody.ParentID,
+		SortOrder: requestBody.SortOrder,
+	}
+
+	for _, gid := range requestBody.GameIDs {
+		categories.Games = append(categories.Games, domain.Game{Model: gorm.Model{ID: gid}})
 	}
 
 	if err := cc.CategoriesService.AddCategories(c.Context(), &categories); err != nil {
@@ -77,7 +86,102 @@ func (cc *CategoriesController) UpdateCategory(c fiber.Ctx) error {
 
 	categories.ID = uint(parseID)
 
-	if err := cc.CategoriesService.UpdateCategories(c.Context(), &categories); err != nil {
+	if err := cc.CategoriesService.UpdateCategories(c.Context(), &categories, requestBody.GameIDs); err != nil {
+		return err
+	}
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// MergeCategory 合并分类(管理员)
+func (cc *CategoriesController) MergeCategory(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	var requestBody domain.MergeCategoriesRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	if err := cc.CategoriesService.MergeCategories(c.Context(), c.Params("id"), requestBody.TargetID); err != nil {
+		return err
+	}
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// CreateAlias 添加分类别名(管理员)
+func (cc *CategoriesController) CreateAlias(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	var requestBody domain.CreateCategoryAliasRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	alias := domain.Categories{
+		Name: requestBody.Name,
+		Slug: requestBody.Slug,
+	}
+
+	if err := cc.CategoriesService.AddAlias(c.Context(), c.Params("id"), &alias); err != nil {
+		return err
+	}
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// GetCategoryTree 分类树及各节点模组数
+func (cc *CategoriesController) GetCategoryTree(c fiber.Ctx) error {
+	var queryBody domain.CategoryTreeQuery
+	if err := c.Bind().Query(&queryBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	tree, err := cc.CategoriesService.GetCategoryTree(c.Context(), &queryBody)
+	if err != nil {
+		return err
+	}
+	return c.JSON(domain.SuccessResponse(tree))
+}
+
+// MoveCategory 移动分类子树(管理员)
+func (cc *CategoriesController) MoveCategory(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	var requestBody domain.MoveCategoryRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	if err := cc.CategoriesService.MoveCategory(c.Context(), c.Params("id"), &requestBody); err != nil {
 		return err
 	}
 	return c.JSON(domain.SuccessResponse(nil))
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/utils"
 	"errors"
 	"strconv"
 
@@ -74,15 +75,19 @@ func (cc *CommentController) GetAllComments(c fiber.Ctx) error {
 		return err
 	}
 
-	comments, total, err := cc.CommentService.GetAllComments(c.Context(), queryBody)
+	comments, total, nextCursor, err := cc.CommentService.GetAllComments(c.Context(), queryBody)
 
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			c.Status(fiber.StatusBadRequest)
+		}
 		return err
 	}
 
 	return c.JSON(domain.SuccessResponse(fiber.Map{
-		"list":  comments,
-		"total": total,
+		"list":        comments,
+		"total":       total,
+		"next_cursor": nextCursor,
 	}))
 }
 
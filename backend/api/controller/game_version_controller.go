@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"ModVerse/domain"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type GameVersionController struct {
+	GameVersionService domain.GameVersionService
+}
+
+func (gc *GameVersionController) GetGameVersions(c fiber.Ctx) error {
+	versions, err := gc.GameVersionService.GetGameVersions(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(fiber.Map{
+		"list":  versions,
+		"total": len(*versions),
+	}))
+}
+
+// CreateGameVersion 添加游戏版本(管理员)
+func (gc *GameVersionController) CreateGameVersion(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	var requestBody domain.CreateGameVersionRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	gameVersion := domain.GameVersion{
+		Name:        requestBody.Name,
+		ReleaseDate: requestBody.ReleaseDate,
+	}
+
+	if err := gc.GameVersionService.CreateGameVersion(c.Context(), c.Params("id"), &gameVersion); err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// DeleteGameVersion 删除游戏版本(管理员),同时移除模组版本的相关声明与反馈
+func (gc *GameVersionController) DeleteGameVersion(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	if err := gc.GameVersionService.DeleteGameVersion(c.Context(), c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// SetModVersionGameVersions 替换模组版本支持的游戏版本
+func (gc *GameVersionController) SetModVersionGameVersions(c fiber.Ctx) error {
+	if err := checkVersionOwner(c, gc.GameVersionService.CheckVersionOwner); err != nil {
+		return err
+	}
+
+	var requestBody domain.SetGameVersionsRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	if err := gc.GameVersionService.SetModVersionGameVersions(c.Context(), c.Params("id"), requestBody.GameVersionIDs); err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+func (gc *GameVersionController) GetCompatibilityMatrix(c fiber.Ctx) error {
+	matrix, err := gc.GameVersionService.GetCompatibilityMatrix(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(matrix))
+}
+
+// ReportCompatibility 提交兼容性反馈
+func (gc *GameVersionController) ReportCompatibility(c fiber.Ctx) error {
+	id, ok := c.Locals("id").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	var requestBody domain.CompatibilityReportRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	if err := gc.GameVersionService.ReportCompatibility(c.Context(), c.Params("id"), id, &requestBody); err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(nil))
+}
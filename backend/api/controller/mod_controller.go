@@ -2,6 +2,8 @@ package controller
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"ModVerse/internal/utils"
 	"errors"
 	"strconv"
 	"time"
@@ -37,24 +39,40 @@ func (mc *ModController) CreateMod(c fiber.Ctx) error {
 		Name:        requestBody.Name,
 		Description: requestBody.Description,
 		Content:     requestBody.Content,
-		Category:    requestBody.Category,
 		CoverID:     requestBody.CoverID,
 		UserID:      parseID,
 		GameID:      requestBody.GameID,
 		LastUpdate:  time.Now(),
 	}
 
+	version, err := utils.NormalizeVersion(requestBody.Version, requestBody.LenientVersion)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
 	modVersion := domain.ModVersion{
-		FileID:    requestBody.FileID,
-		Version:   requestBody.Version,
+		Version:   version,
 		ChangeLog: "首次发布",
 	}
 
-	if err := mc.ModService.CreateMod(c.Context(), &mod, &modVersion, id); err != nil {
-		return err
+	files := requestBody.Files
+	if len(files) == 0 && requestBody.FileID != 0 {
+		files = []domain.ModVersionFileRequest{{FileID: requestBody.FileID}}
 	}
 
-	return c.JSON(domain.SuccessResponse(nil))
+	var categoryNames []string
+	if requestBody.Category != "" {
+		categoryNames = []string{requestBody.Category}
+	}
+
+	if err := mc.ModService.CreateMod(c.Context(), &mod, &modVersion, files, requestBody.CategoryIDs, categoryNames); err != nil {
+		return modCategoryErrorStatus(c, err)
+	}
+
+	return c.JSON(domain.SuccessResponse(fiber.Map{
+		"manifest_mismatches": modVersion.Mismatches,
+	}))
 }
 
 func (mc *ModController) GetMod(c fiber.Ctx) error {
@@ -73,16 +91,30 @@ func (mc *ModController) GetMods(c fiber.Ctx) error {
 		return err
 	}
 
-	mods, total, err := mc.ModService.GetMods(c.Context(), &queryBody)
+	mods, total, nextCursor, err := mc.ModService.GetMods(c.Context(), &queryBody)
 	if err != nil {
+		var parseErr *utils.SearchParseError
+		if errors.As(err, &parseErr) || errors.Is(err, utils.ErrInvalidCursor) {
+			c.Status(fiber.StatusBadRequest)
+		}
 		return err
 	}
 
-	return c.JSON(domain.SuccessResponse(fiber.Map{
-		"list":  mods,
-		"total": total,
-	},
-	))
+	result := fiber.Map{
+		"list":        mods,
+		"total":       total,
+		"next_cursor": nextCursor,
+	}
+
+	if queryBody.Facets {
+		facets, err := mc.ModService.GetModFacets(c.Context(), &queryBody)
+		if err != nil {
+			return err
+		}
+		result["facets"] = facets
+	}
+
+	return c.JSON(domain.SuccessResponse(result))
 }
 
 func (mc *ModController) DeleteMod(c fiber.Ctx) error {
@@ -115,9 +147,20 @@ func (mc *ModController) UpdateMod(c fiber.Ctx) error {
 
 	mod.ID = uint(parseID)
 
-	if err := mc.ModService.UpdateMod(c.Context(), &mod); err != nil {
-		return err
+	if err := mc.ModService.UpdateMod(c.Context(), &mod, requestBody.CategoryIDs); err != nil {
+		return modCategoryErrorStatus(c, err)
 	}
 
 	return c.JSON(domain.SuccessResponse(nil))
 }
+
+// 分类不存在或不属于模组所在游戏时返回4xx
+func modCategoryErrorStatus(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrCategoryNotForGame):
+		c.Status(fiber.StatusBadRequest)
+	case errors.Is(err, custom.DataNotExistError):
+		c.Status(fiber.StatusNotFound)
+	}
+	return err
+}
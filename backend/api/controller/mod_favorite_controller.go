@@ -2,6 +2,7 @@ package controller
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/utils"
 	"errors"
 	"strconv"
 
@@ -70,14 +71,18 @@ func (mfc *ModFavoriteController) GetModFavorites(c fiber.Ctx) error {
 		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
 	}
 
-	modVersion, total, err := mfc.ModFavoriteService.GetModFavorites(c.Context(), id, &queryBody)
+	modVersion, total, nextCursor, err := mfc.ModFavoriteService.GetModFavorites(c.Context(), id, &queryBody)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			c.Status(fiber.StatusBadRequest)
+		}
 		return err
 	}
 
 	return c.JSON(domain.SuccessResponse(fiber.Map{
-		"list":  modVersion,
-		"total": total,
+		"list":        modVersion,
+		"total":       total,
+		"next_cursor": nextCursor,
 	}))
 }
 
@@ -2,6 +2,12 @@ package controller
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"ModVerse/internal/utils"
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
 
 	"github.com/gofiber/fiber/v3"
 )
@@ -11,8 +17,18 @@ type ModVersionController struct {
 }
 
 func (mc *ModVersionController) GetModVersions(c fiber.Ctx) error {
-	modVersion, total, err := mc.ModVersionService.GetModVersions(c.Context(), c.Params("mod_id"))
+	var queryBody domain.ModVersionQuery
+	if err := c.Bind().Query(&queryBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	modVersion, total, err := mc.ModVersionService.GetModVersions(c.Context(), c.Params("mod_id"), &queryBody)
 	if err != nil {
+		var rangeErr *utils.RangeParseError
+		if errors.As(err, &rangeErr) || errors.Is(err, domain.ErrUnknownChannel) {
+			c.Status(fiber.StatusBadRequest)
+		}
 		return err
 	}
 
@@ -30,34 +46,369 @@ func (mc *ModVersionController) CreateModVersion(c fiber.Ctx) error {
 		return err
 	}
 
+	version, err := utils.NormalizeVersion(requestBody.Version, requestBody.LenientVersion)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
 	modVersion := domain.ModVersion{
 		ModID:     requestBody.ModID,
-		Version:   requestBody.Version,
+		Version:   version,
 		ChangeLog: requestBody.ChangeLog,
-		FileID:    requestBody.FileID,
+		Channel:   requestBody.Channel,
+	}
+
+	files := requestBody.Files
+	if len(files) == 0 && requestBody.FileID != 0 {
+		files = []domain.ModVersionFileRequest{{FileID: requestBody.FileID}}
 	}
 
-	if err := mc.ModVersionService.CreateModVersion(c.Context(), &modVersion); err != nil {
+	if err := mc.ModVersionService.CreateModVersion(c.Context(), &modVersion, files, requestBody.Dependencies, requestBody.GameVersionIDs); err != nil {
+		var rangeErr *utils.RangeParseError
+		if errors.As(err, &rangeErr) || errors.Is(err, domain.ErrUnknownChannel) {
+			c.Status(fiber.StatusBadRequest)
+		}
+		if errors.Is(err, custom.VersionExistError) {
+			c.Status(fiber.StatusConflict)
+		}
+		if errors.Is(err, custom.DataNotExistError) {
+			c.Status(fiber.StatusNotFound)
+		}
+		if errors.Is(err, domain.ErrFileInfected) {
+			c.Status(fiber.StatusUnprocessableEntity)
+		}
 		return err
 	}
 
-	return c.JSON(domain.SuccessResponse(nil))
+	return c.JSON(domain.SuccessResponse(fiber.Map{
+		"manifest_mismatches": modVersion.Mismatches,
+	}))
 }
 
+// DeleteModVersion 删除版本,其他模组必需该版本时需传 force=true
 func (mc *ModVersionController) DeleteModVersion(c fiber.Ctx) error {
-	if err := mc.ModVersionService.DeleteModVersion(c.Context(), c.Params("id")); err != nil {
+	if err := mc.checkVersionOwner(c); err != nil {
+		return err
+	}
+
+	force := fiber.Query[bool](c, "force")
+
+	if err := mc.ModVersionService.DeleteModVersion(c.Context(), c.Params("id"), force); err != nil {
+		var requiredErr *domain.VersionRequiredError
+		if errors.As(err, &requiredErr) {
+			c.Status(fiber.StatusConflict)
+			resp := domain.ErrorResponse(err)
+			(*resp)["data"] = requiredErr.Dependents
+			return c.JSON(resp)
+		}
 		return err
 	}
 
 	return c.JSON(domain.SuccessResponse(nil))
 }
 
+// UpdateCount 记录下载,可通过 file_id 指定下载的版本文件(版本文件ID)
+// 供旧客户端使用,下载地址需通过 DownloadModVersion 获取
+// 已撤回的版本仍可下载但在响应头中给出警告,未通过扫描的版本不可下载
 func (mc *ModVersionController) UpdateCount(c fiber.Ctx) error {
-	count, err := mc.ModVersionService.UpdateCount(c.Context(), c.Params("id"), c.Params("mod_id"))
+	modVersion, err := mc.ModVersionService.GetModVersion(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if err := checkScanStatus(c, modVersion); err != nil {
+		return err
+	}
+	setYankWarning(c, modVersion)
+
+	fileID := c.Query("file_id")
+	if fileID != "" && !hasVersionFile(modVersion, fileID) {
+		c.Status(fiber.StatusNotFound)
+		return custom.DataNotExistError
+	}
+
+	count, err := mc.ModVersionService.UpdateCount(c.Context(), c.Params("id"), c.Params("mod_id"), fileID)
 
 	if err != nil {
 		return err
 	}
 
 	return c.JSON(domain.SuccessResponse(count))
-}
\ No newline at end of file
+}
+
+// DownloadModVersion 记录下载后重定向到限时签名的下载地址,可通过 file_id 指定下载的版本文件(版本文件ID)
+// 登录用户与作者的下载地址使用更高的限速等级
+func (mc *ModVersionController) DownloadModVersion(c fiber.Ctx) error {
+	modVersion, err := mc.ModVersionService.GetModVersion(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if err := checkScanStatus(c, modVersion); err != nil {
+		return err
+	}
+	setYankWarning(c, modVersion)
+
+	userID, _ := c.Locals("id").(string)
+	location, err := mc.ModVersionService.GetDownloadURL(c.Context(), modVersion, c.Query("file_id"), c.IP(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom.DataNotExistError):
+			c.Status(fiber.StatusNotFound)
+		case errors.Is(err, domain.ErrFileInfected):
+			c.Status(fiber.StatusForbidden)
+		}
+		return err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Redirect().Status(fiber.StatusFound).To(location)
+}
+
+// SetDependencies 替换版本的依赖声明
+func (mc *ModVersionController) SetDependencies(c fiber.Ctx) error {
+	if err := mc.checkVersionOwner(c); err != nil {
+		return err
+	}
+
+	var requestBody domain.SetDependenciesRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	if err := mc.ModVersionService.SetDependencies(c.Context(), c.Params("id"), requestBody.Dependencies); err != nil {
+		var rangeErr *utils.RangeParseError
+		if errors.As(err, &rangeErr) {
+			c.Status(fiber.StatusBadRequest)
+		}
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// ResolveDependencies 解析版本的完整依赖树
+func (mc *ModVersionController) ResolveDependencies(c fiber.Ctx) error {
+	resolution, err := mc.ModVersionService.ResolveDependencies(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(resolution))
+}
+
+// GetManifestPrefill 按上传文件的清单预填版本号、依赖与支持的游戏版本
+func (mc *ModVersionController) GetManifestPrefill(c fiber.Ctx) error {
+	id, _ := c.Locals("id").(string)
+	role, _ := c.Locals("role").(string)
+
+	prefill, err := mc.ModVersionService.GetManifestPrefill(c.Context(), c.Params("file_id"), id, role)
+	if err != nil {
+		if errors.Is(err, custom.DataNotExistError) {
+			c.Status(fiber.StatusNotFound)
+		}
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(prefill))
+}
+
+// GetVersionContents 版本各文件的压缩包内容清单,未经检查的文件archive为空
+func (mc *ModVersionController) GetVersionContents(c fiber.Ctx) error {
+	contents, err := mc.ModVersionService.GetVersionContents(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(fiber.Map{
+		"list":  contents,
+		"total": len(*contents),
+	}))
+}
+
+// GetDependents 依赖该模组的其他模组版本
+func (mc *ModVersionController) GetDependents(c fiber.Ctx) error {
+	dependents, err := mc.ModVersionService.GetDependents(c.Context(), c.Params("mod_id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(fiber.Map{
+		"list":  dependents,
+		"total": len(*dependents),
+	}))
+}
+
+// GetLatestVersion 满足范围的最新版本,供启动器选择下载文件
+func (mc *ModVersionController) GetLatestVersion(c fiber.Ctx) error {
+	var queryBody domain.ModVersionQuery
+	if err := c.Bind().Query(&queryBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	modVersion, err := mc.ModVersionService.GetLatestVersion(c.Context(), c.Params("id"), &queryBody)
+	if err != nil {
+		var rangeErr *utils.RangeParseError
+		if errors.As(err, &rangeErr) || errors.Is(err, domain.ErrUnknownChannel) {
+			c.Status(fiber.StatusBadRequest)
+		}
+		if errors.Is(err, custom.DataNotExistError) {
+			c.Status(fiber.StatusNotFound)
+		}
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(modVersion))
+}
+
+// CheckUpdates 批量检查已安装模组的更新,供模组管理器使用
+func (mc *ModVersionController) CheckUpdates(c fiber.Ctx) error {
+	var requestBody domain.UpdateCheckRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	results, err := mc.ModVersionService.CheckUpdates(c.Context(), &requestBody)
+	if err != nil {
+		if errors.Is(err, domain.ErrUnknownChannel) || errors.Is(err, domain.ErrTooManyUpdateItems) {
+			c.Status(fiber.StatusBadRequest)
+		}
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(fiber.Map{
+		"list":  results,
+		"total": len(*results),
+	}))
+}
+
+func hasVersionFile(modVersion *domain.ModVersionResponse, fileID string) bool {
+	for _, f := range modVersion.Files {
+		if strconv.FormatUint(uint64(f.ID), 10) == fileID {
+			return true
+		}
+	}
+	return false
+}
+
+// 扫描中的版本返回423,已隔离或无法扫描的返回403
+func checkScanStatus(c fiber.Ctx, modVersion *domain.ModVersionResponse) error {
+	switch modVersion.ScanStatus {
+	case domain.ScanStatusScanning:
+		c.Status(fiber.StatusLocked)
+		return domain.ErrFileScanning
+	case domain.ScanStatusInfected:
+		c.Status(fiber.StatusForbidden)
+		return domain.ErrFileInfected
+	case domain.ScanStatusFailed:
+		c.Status(fiber.StatusForbidden)
+		return domain.ErrFileUnscannable
+	}
+	return nil
+}
+
+// 已撤回版本的下载警告,原因经URL编码放在 X-Yank-Reason 中
+func setYankWarning(c fiber.Ctx, modVersion *domain.ModVersionResponse) {
+	if !modVersion.Yanked {
+		return
+	}
+	c.Set(fiber.HeaderWarning, domain.YankWarning)
+	c.Set("X-Yank-Reason", url.QueryEscape(modVersion.YankReason))
+}
+
+// YankModVersion 撤回版本,需填写原因,只有模组的上传者或管理员可以操作;其他模组必需该版本时需传 force=true
+func (mc *ModVersionController) YankModVersion(c fiber.Ctx) error {
+	id, ok := c.Locals("id").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if err := mc.checkVersionOwner(c); err != nil {
+		return err
+	}
+
+	var requestBody domain.YankModVersionRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	force := fiber.Query[bool](c, "force")
+
+	if err := mc.ModVersionService.YankModVersion(c.Context(), c.Params("id"), id, requestBody.Reason, force); err != nil {
+		var requiredErr *domain.VersionRequiredError
+		if errors.As(err, &requiredErr) {
+			c.Status(fiber.StatusConflict)
+			resp := domain.ErrorResponse(err)
+			(*resp)["data"] = requiredErr.Dependents
+			return c.JSON(resp)
+		}
+		return yankErrorStatus(c, err)
+	}
+
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// UnyankModVersion 恢复已撤回的版本,需填写原因,只有模组的上传者或管理员可以操作
+func (mc *ModVersionController) UnyankModVersion(c fiber.Ctx) error {
+	id, ok := c.Locals("id").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if err := mc.checkVersionOwner(c); err != nil {
+		return err
+	}
+
+	var requestBody domain.YankModVersionRequest
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	if err := mc.ModVersionService.UnyankModVersion(c.Context(), c.Params("id"), id, requestBody.Reason); err != nil {
+		return yankErrorStatus(c, err)
+	}
+
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// 撤回/恢复的状态冲突返回409,原因过长返回400
+func yankErrorStatus(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrVersionYanked), errors.Is(err, domain.ErrVersionNotYanked):
+		c.Status(fiber.StatusConflict)
+	case errors.Is(err, domain.ErrYankReasonTooLong):
+		c.Status(fiber.StatusBadRequest)
+	case errors.Is(err, custom.DataNotExistError):
+		c.Status(fiber.StatusNotFound)
+	}
+	return err
+}
+
+// 只有模组的上传者或管理员可以修改版本
+func (mc *ModVersionController) checkVersionOwner(c fiber.Ctx) error {
+	return checkVersionOwner(c, mc.ModVersionService.CheckVersionOwner)
+}
+
+// 按路径参数id检查当前用户是否可以修改该版本,不是上传者返回403,版本不存在返回404
+func checkVersionOwner(c fiber.Ctx, check func(c context.Context, id string, userID string, role string) error) error {
+	id, _ := c.Locals("id").(string)
+	role, _ := c.Locals("role").(string)
+
+	if err := check(c.Context(), c.Params("id"), id, role); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotVersionOwner):
+			c.Status(fiber.StatusForbidden)
+		case errors.Is(err, custom.DataNotExistError):
+			c.Status(fiber.StatusNotFound)
+		}
+		return err
+	}
+
+	return nil
+}
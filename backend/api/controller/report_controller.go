@@ -2,6 +2,8 @@ package controller
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v3"
@@ -76,14 +78,18 @@ func (h *ReportController) GetReports(c fiber.Ctx) error {
 		return err
 	}
 
-	reports, total, err := h.ReportService.GetReports(c.Context(), &params)
+	reports, total, nextCursor, err := h.ReportService.GetReports(c.Context(), &params)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			c.Status(fiber.StatusBadRequest)
+		}
 		return err
 	}
 
 	return c.JSON(domain.SuccessResponse(fiber.Map{
-		"list":  reports,
-		"total": total,
+		"list":        reports,
+		"total":       total,
+		"next_cursor": nextCursor,
 	}))
 }
 
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"ModVerse/domain"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type SearchController struct {
+	SearchService domain.SearchService
+}
+
+// Suggest 搜索联想
+func (sc *SearchController) Suggest(c fiber.Ctx) error {
+	var queryBody domain.SuggestQuery
+	if err := c.Bind().Query(&queryBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	suggestions, err := sc.SearchService.Suggest(c.Context(), &queryBody)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(suggestions))
+}
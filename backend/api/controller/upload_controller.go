@@ -2,13 +2,19 @@ package controller
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/custom"
 	"errors"
+	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 )
 
 type UploadController struct {
-	UploadService domain.UploadService
+	UploadService    domain.UploadService
+	StorageGCService domain.StorageGCService
+	QuotaService     domain.QuotaService
 }
 
 func (uc *UploadController) UploadPostImage(c fiber.Ctx) error {
@@ -26,7 +32,7 @@ func (uc *UploadController) UploadPostImage(c fiber.Ctx) error {
 
 	fileURL, err := uc.UploadService.UploadPostImage(c.Context(), file, id)
 	if err != nil {
-		return err
+		return uploadErrorStatus(c, err)
 	}
 
 	return c.JSON(domain.SuccessResponse(fileURL))
@@ -51,17 +57,36 @@ func (uc *UploadController) UploadFile(c fiber.Ctx) error {
 		return c.JSON(domain.ErrorResponse(errors.New("type is required")))
 	}
 
-	fileID, err := uc.UploadService.UploadFile(c.Context(), file, id, uploadType)
+	// 模组文件可指定游戏,按该游戏的清单格式提取版本与依赖等信息用于预填
+	gameID := fiber.Query[uint](c, "game_id")
+
+	sf, err := uc.UploadService.UploadFile(c.Context(), file, id, uploadType, gameID)
 	if err != nil {
-		return err
+		return uploadErrorStatus(c, err)
 	}
 
 	return c.JSON(domain.SuccessResponse(fiber.Map{
-		"file_id": fileID,
+		"file_id":  sf.ID,
+		"manifest": sf.Manifest,
 	}),
 	)
 }
 
+// 上传内容不符合要求时返回4xx
+func uploadErrorStatus(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidUploadType), errors.Is(err, domain.ErrUnsupportedImage):
+		c.Status(fiber.StatusBadRequest)
+	case errors.Is(err, domain.ErrImageTooLarge), errors.Is(err, domain.ErrFileTooLarge), errors.Is(err, domain.ErrQuotaExceeded):
+		c.Status(fiber.StatusRequestEntityTooLarge)
+	case errors.Is(err, domain.ErrFileTypeNotAllowed):
+		c.Status(fiber.StatusUnsupportedMediaType)
+	case errors.Is(err, domain.ErrArchiveRejected), errors.Is(err, domain.ErrFileInfected):
+		c.Status(fiber.StatusUnprocessableEntity)
+	}
+	return err
+}
+
 func (uc *UploadController) GetFile(c fiber.Ctx) error {
 	sf, err := uc.UploadService.GetFile(c.Context(), c.Params("id"))
 	if err != nil {
@@ -85,3 +110,331 @@ func (uc *UploadController) DeleteFile(c fiber.Ctx) error {
 	return c.JSON(domain.SuccessResponse(nil))
 
 }
+
+// StartVerifyFiles 启动文件完整性校验任务(管理员)
+func (uc *UploadController) StartVerifyFiles(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	if err := uc.UploadService.StartVerifyFiles(c.Context()); err != nil {
+		if errors.Is(err, domain.ErrFileVerifyRunning) {
+			c.Status(fiber.StatusConflict)
+		}
+		return err
+	}
+
+	c.Status(fiber.StatusAccepted)
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// GetVerifyReport 最近一次文件校验的报告(管理员)
+func (uc *UploadController) GetVerifyReport(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	report, err := uc.UploadService.GetVerifyReport(c.Context())
+	if err != nil {
+		if errors.Is(err, custom.DataNotExistError) {
+			c.Status(fiber.StatusNotFound)
+		}
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(report))
+}
+
+// GetUsage 当前用户的存储用量与配额
+func (uc *UploadController) GetUsage(c fiber.Ctx) error {
+	id, ok := c.Locals("id").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	usage, err := uc.QuotaService.GetUsage(c.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(usage))
+}
+
+// GetTopConsumers 存储用量最多的用户(管理员)
+func (uc *UploadController) GetTopConsumers(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	usages, err := uc.QuotaService.GetTopConsumers(c.Context(), fiber.Query[int](c, "limit"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(usages))
+}
+
+// SetUserQuota 单独设置用户的存储配额(管理员)
+func (uc *UploadController) SetUserQuota(c fiber.Ctx) error {
+	var requestBody domain.UpdateUserQuotaRequest
+
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	if err := c.Bind().Body(&requestBody); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return err
+	}
+
+	if err := uc.QuotaService.SetUserQuota(c.Context(), c.Params("id"), requestBody.Quota); err != nil {
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// StartGC 启动存储清理任务(管理员),默认只生成报告,dry_run=false时实际删除
+func (uc *UploadController) StartGC(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	dryRun := fiber.Query[bool](c, "dry_run", true)
+
+	if err := uc.StorageGCService.StartGC(c.Context(), dryRun); err != nil {
+		if errors.Is(err, domain.ErrGCRunning) {
+			c.Status(fiber.StatusConflict)
+		}
+		return err
+	}
+
+	c.Status(fiber.StatusAccepted)
+	return c.JSON(domain.SuccessResponse(nil))
+}
+
+// GetGCReport 最近一次存储清理的报告(管理员)
+func (uc *UploadController) GetGCReport(c fiber.Ctx) error {
+	role, ok := c.Locals("role").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if role != "admin" {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("unauthorized")))
+	}
+
+	report, err := uc.StorageGCService.GetGCReport(c.Context())
+	if err != nil {
+		if errors.Is(err, custom.DataNotExistError) {
+			c.Status(fiber.StatusNotFound)
+		}
+		return err
+	}
+
+	return c.JSON(domain.SuccessResponse(report))
+}
+
+// tus协议要求所有响应带上协议版本
+func setTusHeaders(c fiber.Ctx) {
+	c.Set("Tus-Resumable", domain.TusVersion)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+}
+
+// 检查客户端使用的协议版本,不支持时返回412
+func checkTusVersion(c fiber.Ctx) error {
+	setTusHeaders(c)
+	if c.Get("Tus-Resumable") != domain.TusVersion {
+		c.Set("Tus-Version", domain.TusVersion)
+		c.Status(fiber.StatusPreconditionFailed)
+		return errors.New("unsupported tus version")
+	}
+	return nil
+}
+
+// 协议错误对应的状态码
+func tusErrorStatus(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, custom.DataNotExistError):
+		c.Status(fiber.StatusNotFound)
+	case errors.Is(err, domain.ErrTusOffsetMismatch), errors.Is(err, domain.ErrTusUploadFinished):
+		c.Status(fiber.StatusConflict)
+	case errors.Is(err, domain.ErrTusUploadLocked):
+		c.Status(fiber.StatusLocked)
+	case errors.Is(err, domain.ErrTusSizeExceeded):
+		c.Status(fiber.StatusRequestEntityTooLarge)
+	case errors.Is(err, domain.ErrTusChecksumMismatch):
+		c.Status(460)
+	case errors.Is(err, domain.ErrTusInvalidMetadata):
+		c.Status(fiber.StatusBadRequest)
+	default:
+		return uploadErrorStatus(c, err)
+	}
+	return err
+}
+
+func setTusUploadHeaders(c fiber.Ctx, upload *domain.TusUpload) {
+	c.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if upload.FileID != 0 {
+		c.Set("Upload-File-Id", strconv.FormatUint(uint64(upload.FileID), 10))
+	}
+}
+
+// TusOptions 返回服务端支持的协议版本与扩展
+func (uc *UploadController) TusOptions(c fiber.Ctx) error {
+	setTusHeaders(c)
+	c.Set("Tus-Version", domain.TusVersion)
+	c.Set("Tus-Extension", domain.TusExtensions)
+	c.Set("Tus-Max-Size", strconv.FormatInt(domain.TusMaxSize, 10))
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateTusUpload 创建断点续传会话,上传类型通过元数据type或查询参数type指定
+func (uc *UploadController) CreateTusUpload(c fiber.Ctx) error {
+	if err := checkTusVersion(c); err != nil {
+		return err
+	}
+
+	id, ok := c.Locals("id").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	// 不支持Upload-Defer-Length,创建时必须声明长度
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.Status(fiber.StatusBadRequest)
+		return errors.New("invalid Upload-Length")
+	}
+
+	upload, err := uc.UploadService.CreateTusUpload(c.Context(), id, &domain.CreateTusUploadRequest{
+		Length:     length,
+		UploadType: c.Query("type"),
+		GameID:     fiber.Query[uint](c, "game_id"),
+		Metadata:   c.Get("Upload-Metadata"),
+	})
+	if err != nil {
+		return tusErrorStatus(c, err)
+	}
+
+	setTusUploadHeaders(c, upload)
+	c.Location(strings.TrimSuffix(c.Path(), "/") + "/" + upload.ID)
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// GetTusUpload 查询已上传的字节数,用于断线后恢复
+func (uc *UploadController) GetTusUpload(c fiber.Ctx) error {
+	if err := checkTusVersion(c); err != nil {
+		return err
+	}
+
+	id, ok := c.Locals("id").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	upload, err := uc.UploadService.GetTusUpload(c.Context(), c.Params("id"), id)
+	if err != nil {
+		return tusErrorStatus(c, err)
+	}
+
+	setTusUploadHeaders(c, upload)
+	c.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		c.Set("Upload-Metadata", upload.Metadata)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// PatchTusUpload 追加分片,请求体为原始字节
+func (uc *UploadController) PatchTusUpload(c fiber.Ctx) error {
+	if err := checkTusVersion(c); err != nil {
+		return err
+	}
+
+	id, ok := c.Locals("id").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if c.Get(fiber.HeaderContentType) != "application/offset+octet-stream" {
+		c.Status(fiber.StatusUnsupportedMediaType)
+		return errors.New("content type must be application/offset+octet-stream")
+	}
+
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.Status(fiber.StatusBadRequest)
+		return errors.New("invalid Upload-Offset")
+	}
+
+	upload, err := uc.UploadService.WriteTusChunk(c.Context(), c.Params("id"), id, offset, c.Body())
+	if err != nil {
+		return tusErrorStatus(c, err)
+	}
+
+	setTusUploadHeaders(c, upload)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// DeleteTusUpload 终止上传
+func (uc *UploadController) DeleteTusUpload(c fiber.Ctx) error {
+	if err := checkTusVersion(c); err != nil {
+		return err
+	}
+
+	id, ok := c.Locals("id").(string)
+	if !ok {
+		c.Status(fiber.StatusUnauthorized)
+		return c.JSON(domain.ErrorResponse(errors.New("assertion failed")))
+	}
+
+	if err := uc.UploadService.DeleteTusUpload(c.Context(), c.Params("id"), id); err != nil {
+		return tusErrorStatus(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
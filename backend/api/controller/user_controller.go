@@ -2,6 +2,7 @@ package controller
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/utils"
 	"errors"
 	"strconv"
 
@@ -92,14 +93,18 @@ func (uc *UserController) GetUsers(c fiber.Ctx) error {
 		return err
 	}
 
-	users, total, err := uc.UserService.GetUsers(c.Context(), &queryBody)
+	users, total, nextCursor, err := uc.UserService.GetUsers(c.Context(), &queryBody)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			c.Status(fiber.StatusBadRequest)
+		}
 		return err
 	}
 
 	return c.JSON(domain.SuccessResponse(fiber.Map{
-		"list":  users,
-		"total": total,
+		"list":        users,
+		"total":       total,
+		"next_cursor": nextCursor,
 	}))
 }
 
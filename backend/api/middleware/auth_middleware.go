@@ -28,3 +28,22 @@ func AuthMiddleware(env *bootstrap.Env) fiber.Handler {
 		return c.Next()
 	}
 }
+
+// OptionalAuthMiddleware 携带有效token时与 AuthMiddleware 一样存储用户信息,未携带或无效时按未登录继续处理
+func OptionalAuthMiddleware(env *bootstrap.Env) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		token := c.Get("Authorization")
+		if token == "" {
+			return c.Next()
+		}
+
+		id, role, err := utils.ParseJWT(token, env.App.TokenSecret)
+		if err != nil {
+			return c.Next()
+		}
+
+		c.Locals("id", id)
+		c.Locals("role", role)
+		return c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"ModVerse/internal/utils"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// FileDigestMiddleware 为静态文件响应添加 ETag、Digest 与 Repr-Digest 头
+// prefix为挂载路径,其后的路径即存储文件的FileKey;哈希取自内容寻址的存储路径,不查询数据库,
+// 尚未迁移到 blobs/ 下的旧文件不做处理
+func FileDigestMiddleware(prefix string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status != fiber.StatusOK && status != fiber.StatusPartialContent && status != fiber.StatusNotModified {
+			return nil
+		}
+
+		path, err := url.PathUnescape(strings.TrimPrefix(c.Path(), prefix))
+		if err != nil {
+			return nil
+		}
+
+		hash, ok := utils.BlobKeyHash(strings.TrimPrefix(path, "/"))
+		if !ok {
+			return nil
+		}
+		etag := setDigestHeaders(c, hash)
+
+		// 内容未变化时返回304
+		if status == fiber.StatusOK && c.Get(fiber.HeaderIfNoneMatch) == etag {
+			c.Status(fiber.StatusNotModified)
+			c.Response().ResetBody()
+		}
+
+		return nil
+	}
+}
+
+// 按十六进制SHA-256设置 ETag、Digest 与 Repr-Digest 头,返回ETag
+// Digest 与 Repr-Digest 均为完整文件的哈希,分段响应时客户端可在合并后校验
+func setDigestHeaders(c fiber.Ctx, sha256Hex string) string {
+	etag := `"` + sha256Hex + `"`
+	c.Set(fiber.HeaderETag, etag)
+
+	if digest, err := utils.DigestHeader(sha256Hex); err == nil {
+		c.Set("Digest", digest)
+		c.Set("Repr-Digest", strings.Replace(digest, "=", "=:", 1)+":")
+	}
+
+	return etag
+}
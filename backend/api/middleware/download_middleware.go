@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"context"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// DownloadNameMiddleware 按下载地址中的 filename 参数设置下载文件名
+// 存储文件以内容哈希命名,原始文件名随下载地址一起下发
+func DownloadNameMiddleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status != fiber.StatusOK && status != fiber.StatusPartialContent {
+			return nil
+		}
+
+		if name := filepath.Base(c.Query("filename")); name != "." && name != "/" {
+			c.Attachment(name)
+		}
+
+		return nil
+	}
+}
+
+// SignedDownloadMiddleware 只允许通过限时签名地址下载,地址由 /mod_version/:id/download 生成
+// prefix为挂载路径,其后的路径即存储文件的FileKey,签名地址中的下载身份存储在上下文 download_grant 中
+// 下载已撤回版本的文件时在响应头中给出警告
+func SignedDownloadMiddleware(prefix string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		key, err := url.PathUnescape(strings.TrimPrefix(c.Path(), prefix))
+		if err != nil {
+			c.Status(fiber.StatusBadRequest)
+			return err
+		}
+		key = strings.TrimPrefix(key, "/")
+
+		grant, ok := utils.VerifyDownload(key, func(name string) string { return c.Query(name) })
+		if !ok {
+			c.Status(fiber.StatusForbidden)
+			return domain.ErrDownloadSignature
+		}
+		if grant.Yanked {
+			c.Set(fiber.HeaderWarning, domain.YankWarning)
+		}
+
+		c.Locals("download_grant", grant)
+		return c.Next()
+	}
+}
+
+// ModFileGuardMiddleware 模组文件与其他文件在同一存储中,拒绝以静态文件路径访问模组文件,
+// 使其只能通过 SignedDownloadMiddleware 保护的签名地址下载;prefix为挂载路径,其后的路径即存储文件的FileKey
+func ModFileGuardMiddleware(repo domain.StorageFileRepository, prefix string, timeout time.Duration) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		key, err := url.PathUnescape(strings.TrimPrefix(c.Path(), prefix))
+		if err != nil {
+			c.Status(fiber.StatusBadRequest)
+			return err
+		}
+		key = strings.TrimPrefix(key, "/")
+
+		ctx, cancel := context.WithTimeout(c.Context(), timeout)
+		defer cancel()
+
+		modFile, err := repo.IsModFileKey(ctx, key)
+		if err != nil {
+			c.Status(fiber.StatusServiceUnavailable)
+			return err
+		}
+		if modFile {
+			c.Status(fiber.StatusForbidden)
+			return domain.ErrDownloadSignature
+		}
+
+		return c.Next()
+	}
+}
+
+// HotlinkMiddleware 防盗链:请求带有Origin或Referer时只允许来自allowed中的站点
+// 启动器等直接请求的客户端不带这两个请求头,不受限制
+func HotlinkMiddleware(allowed []string) fiber.Handler {
+	sites := make(map[string]bool, len(allowed))
+	for _, site := range allowed {
+		sites[strings.ToLower(strings.TrimSuffix(site, "/"))] = true
+	}
+
+	return func(c fiber.Ctx) error {
+		source := c.Get(fiber.HeaderOrigin)
+		if source == "" || source == "null" {
+			source = c.Get(fiber.HeaderReferer)
+		}
+		if source == "" {
+			return c.Next()
+		}
+
+		u, err := url.Parse(source)
+		if err != nil || !sites[strings.ToLower(u.Scheme+"://"+u.Host)] {
+			c.Status(fiber.StatusForbidden)
+			return domain.ErrHotlinkDenied
+		}
+
+		return c.Next()
+	}
+}
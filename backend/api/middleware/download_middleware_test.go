@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/storage"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/static"
+)
+
+func TestHotlinkMiddleware(t *testing.T) {
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c fiber.Ctx, err error) error {
+			return c.SendString(err.Error())
+		},
+	})
+	app.Get("/download", func(c fiber.Ctx) error {
+		return c.SendString("ok")
+	}, HotlinkMiddleware([]string{"http://localhost:3000", "https://ModVerse.example/"}))
+
+	for _, tt := range []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"launcher", nil, fiber.StatusOK},
+		{"own site", map[string]string{fiber.HeaderReferer: "http://localhost:3000/mod/1"}, fiber.StatusOK},
+		{"frontend origin", map[string]string{fiber.HeaderOrigin: "https://modverse.example"}, fiber.StatusOK},
+		{"other site", map[string]string{fiber.HeaderReferer: "https://mirror.example/mod/1"}, fiber.StatusForbidden},
+		{"other port", map[string]string{fiber.HeaderReferer: "http://localhost:8080/"}, fiber.StatusForbidden},
+		{"null origin", map[string]string{fiber.HeaderOrigin: "null", fiber.HeaderReferer: "https://mirror.example/"}, fiber.StatusForbidden},
+		{"origin wins", map[string]string{fiber.HeaderOrigin: "https://mirror.example", fiber.HeaderReferer: "http://localhost:3000/"}, fiber.StatusForbidden},
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, "/download", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+
+}
+
+// 签名地址的路径不能改由静态文件路径访问
+func TestModFileGuardMiddleware(t *testing.T) {
+	root := t.TempDir()
+	store := storage.NewLocalStorage(root, "")
+	const modKey, imageKey = "blobs/ab/mod.zip", "blobs/cd/cover.webp"
+	for _, key := range []string{modKey, imageKey} {
+		if err := store.Put(context.Background(), key, strings.NewReader("content"), 7, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := &fakeFileRepo{files: map[string]domain.StorageFile{
+		modKey:   {FileKey: modKey, UploadType: domain.UploadModFile},
+		imageKey: {FileKey: imageKey, UploadType: domain.UploadModCover},
+	}}
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c fiber.Ctx, err error) error {
+			return c.SendString(err.Error())
+		},
+	})
+	app.Use("/api/data", ModFileGuardMiddleware(repo, "/api/data", time.Second))
+	app.Use("/api/data", static.New(root))
+
+	for key, status := range map[string]int{modKey: fiber.StatusForbidden, imageKey: fiber.StatusOK} {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/data/"+key, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Errorf("GET /api/data/%s status = %d, want %d", key, resp.StatusCode, status)
+		}
+	}
+}
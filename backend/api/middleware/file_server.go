@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// 同时进行的下载按下载者记录在Redis的有序集合中,多个进程共享;进程异常退出未释放的名额在该时间后失效
+const downloadSlotKeyPrefix = "downloads:active:"
+const downloadSlotTTL = 6 * time.Hour
+
+// 下载名额已满时建议的重试间隔(秒)
+const downloadRetryAfter = "10"
+
+// FileServer 提供存储文件的下载,支持 Range/If-Range 断点续传,响应头包含 ETag、Digest 与 Repr-Digest
+// 按签名地址中的下载等级限制速度与同时进行的下载数,匿名下载按IP、登录用户按用户计算,需在 SignedDownloadMiddleware 之后注册
+// 速度上限由同一下载者的所有下载共享;非本地存储同样经由此处读取,不重定向到存储后端
+// prefix为挂载路径,其后的路径即存储文件的FileKey
+func FileServer(store domain.Storage, repo domain.StorageFileRepository, redisRepo domain.RedisRepository,
+	tiers map[string]domain.DownloadTier, prefix string, timeout time.Duration) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			c.Set(fiber.HeaderAllow, "GET, HEAD")
+			return fiber.ErrMethodNotAllowed
+		}
+
+		key, err := url.PathUnescape(strings.TrimPrefix(c.Path(), prefix))
+		if err != nil {
+			c.Status(fiber.StatusBadRequest)
+			return err
+		}
+		key = strings.TrimPrefix(key, "/")
+		if key == "" || strings.HasSuffix(key, "/") {
+			return fiber.ErrNotFound
+		}
+
+		ctx, cancel := context.WithTimeout(c.Context(), timeout)
+		defer cancel()
+
+		obj, err := store.Stat(ctx, key)
+		if errors.Is(err, domain.ErrObjectNotExist) {
+			return fiber.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		etag := setFileHeaders(c, ctx, repo, key, obj)
+
+		// 内容未变化时返回304
+		if etag != "" && c.Get(fiber.HeaderIfNoneMatch) == etag {
+			c.Status(fiber.StatusNotModified)
+			return nil
+		}
+
+		start, length, status := int64(0), obj.Size, fiber.StatusOK
+		if rangeHeader := c.Get(fiber.HeaderRange); rangeHeader != "" && ifRangeMatches(c.Get(fiber.HeaderIfRange), etag, obj.ModTime) {
+			rangeStart, rangeLength, ok, err := utils.ParseRange(rangeHeader, obj.Size)
+			if err != nil {
+				c.Set(fiber.HeaderContentRange, "bytes */"+strconv.FormatInt(obj.Size, 10))
+				c.Status(fiber.StatusRequestedRangeNotSatisfiable)
+				return err
+			}
+			if ok {
+				start, length, status = rangeStart, rangeLength, fiber.StatusPartialContent
+				c.Set(fiber.HeaderContentRange, "bytes "+strconv.FormatInt(start, 10)+"-"+
+					strconv.FormatInt(start+length-1, 10)+"/"+strconv.FormatInt(obj.Size, 10))
+			}
+		}
+
+		c.Status(status)
+		if c.Method() == fiber.MethodHead {
+			c.Response().Header.SetContentLength(int(length))
+			c.Response().SkipBody = true
+			return nil
+		}
+
+		grant, _ := c.Locals("download_grant").(*domain.DownloadGrant)
+		tier, subject := downloadTier(tiers, grant, c.IP())
+
+		rate, release, err := acquireDownloadSlot(ctx, redisRepo, subject, tier)
+		if err != nil {
+			if errors.Is(err, domain.ErrTooManyDownloads) {
+				c.Set(fiber.HeaderRetryAfter, downloadRetryAfter)
+				c.Status(fiber.StatusTooManyRequests)
+			}
+			return err
+		}
+
+		// 下载内容在处理函数返回后才发送,不能使用请求的上下文
+		body, err := openRange(store, key, start, length)
+		if err != nil {
+			release()
+			return err
+		}
+
+		return c.SendStream(utils.NewThrottledReader(body, rate, release), int(length))
+	}
+}
+
+// 设置文件的类型、修改时间与哈希相关的响应头,返回ETag,没有哈希记录时为空
+func setFileHeaders(c fiber.Ctx, ctx context.Context, repo domain.StorageFileRepository, key string, obj *domain.StorageObject) string {
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	c.Set(fiber.HeaderLastModified, obj.ModTime.UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+
+	sf, err := repo.GetStorageFileByKey(ctx, key)
+	if err != nil {
+		return ""
+	}
+	if sf.MIMEType != "" {
+		c.Set(fiber.HeaderContentType, sf.MIMEType)
+	}
+	if sf.SHA256 == "" {
+		return ""
+	}
+
+	return setDigestHeaders(c, sf.SHA256)
+}
+
+// If-Range 为ETag时需与当前ETag完全一致(弱ETag不匹配),为时间时需与修改时间一致,不匹配时忽略Range返回完整内容
+func ifRangeMatches(ifRange string, etag string, modTime time.Time) bool {
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etag != "" && ifRange == etag
+	}
+
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	return modTime.Truncate(time.Second).Equal(t)
+}
+
+// 按签名地址中的下载等级选择限制,未知等级按匿名处理;返回限制与计算并发数的下载者
+func downloadTier(tiers map[string]domain.DownloadTier, grant *domain.DownloadGrant, ip string) (domain.DownloadTier, string) {
+	if grant == nil || grant.UserID == 0 {
+		return tiers[domain.DownloadTierAnonymous], "ip:" + ip
+	}
+
+	tier, ok := tiers[grant.Tier]
+	if !ok {
+		tier = tiers[domain.DownloadTierAnonymous]
+	}
+	return tier, "user:" + strconv.FormatUint(grant.UserID, 10)
+}
+
+// 占用一个下载名额,超出并发上限时返回 domain.ErrTooManyDownloads,下载结束时调用返回的release释放
+// 返回的rate为下载者的速度上限按其当前下载数平分后的速度
+func acquireDownloadSlot(ctx context.Context, redisRepo domain.RedisRepository, subject string, tier domain.DownloadTier) (rate func() int64, release func(), err error) {
+	if tier.Concurrency <= 0 && tier.Rate <= 0 {
+		return func() int64 { return 0 }, func() {}, nil
+	}
+
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return nil, nil, err
+	}
+	key, member := downloadSlotKeyPrefix+subject, hex.EncodeToString(token)
+
+	active, err := redisRepo.AddSlot(ctx, key, member, downloadSlotTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	release = func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := redisRepo.RemoveSlot(releaseCtx, key, member); err != nil {
+			log.Printf("release download slot %s: %v", key, err)
+		}
+	}
+
+	if tier.Concurrency > 0 && active > int64(tier.Concurrency) {
+		release()
+		return nil, nil, domain.ErrTooManyDownloads
+	}
+
+	// 获取下载数失败时沿用上一次的结果
+	rate = func() int64 {
+		if tier.Rate <= 0 {
+			return 0
+		}
+		rateCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if n, err := redisRepo.CountSlots(rateCtx, key, downloadSlotTTL); err == nil {
+			active = n
+		}
+		return max(tier.Rate/max(active, 1), 1)
+	}
+
+	return rate, release, nil
+}
+
+// 打开文件并定位到start,最多读取length字节,存储后端不支持定位时跳过前面的内容
+func openRange(store domain.Storage, key string, start int64, length int64) (io.ReadCloser, error) {
+	r, err := store.Get(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	if start > 0 {
+		if seeker, ok := r.(io.Seeker); ok {
+			_, err = seeker.Seek(start, io.SeekStart)
+		} else {
+			_, err = io.CopyN(io.Discard, r, start)
+		}
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(r, length), r}, nil
+}
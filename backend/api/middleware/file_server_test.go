@@ -0,0 +1,249 @@
+package middleware
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"ModVerse/internal/storage"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// 按键记录名额的Redis仓库
+type fakeSlotRepo struct {
+	domain.RedisRepository
+	slots map[string]map[string]bool
+}
+
+func (f *fakeSlotRepo) AddSlot(c context.Context, key string, member string, ttl time.Duration) (int64, error) {
+	if f.slots[key] == nil {
+		f.slots[key] = map[string]bool{}
+	}
+	f.slots[key][member] = true
+	return int64(len(f.slots[key])), nil
+}
+
+func (f *fakeSlotRepo) RemoveSlot(c context.Context, key string, member string) error {
+	delete(f.slots[key], member)
+	return nil
+}
+
+func (f *fakeSlotRepo) CountSlots(c context.Context, key string, ttl time.Duration) (int64, error) {
+	return int64(len(f.slots[key])), nil
+}
+
+type fakeFileRepo struct {
+	domain.StorageFileRepository
+	files map[string]domain.StorageFile
+	err   error
+}
+
+func (f *fakeFileRepo) GetStorageFileByKey(c context.Context, key string) (*domain.StorageFile, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	sf, ok := f.files[key]
+	if !ok {
+		return nil, custom.DataNotExistError
+	}
+	return &sf, nil
+}
+
+func (f *fakeFileRepo) IsModFileKey(c context.Context, key string) (bool, error) {
+	sf, ok := f.files[key]
+	return ok && sf.UploadType == domain.UploadModFile, nil
+}
+
+const (
+	testKey     = "blobs/ab/abc.zip"
+	testContent = "0123456789abcdefghij"
+	testSHA256  = "6bc14bdc4517a7a682c6910de2e2946eb8e1ecd04090728fef6d092a7ceb62c5"
+)
+
+func newFileServerApp(t *testing.T, tiers map[string]domain.DownloadTier) (*fiber.App, *fakeSlotRepo) {
+	t.Helper()
+	store := storage.NewLocalStorage(t.TempDir(), "")
+	if err := store.Put(context.Background(), testKey, strings.NewReader(testContent), int64(len(testContent)), ""); err != nil {
+		t.Fatal(err)
+	}
+	repo := &fakeFileRepo{files: map[string]domain.StorageFile{
+		testKey: {FileKey: testKey, SHA256: testSHA256, MIMEType: "application/zip"},
+	}}
+	slots := &fakeSlotRepo{slots: map[string]map[string]bool{}}
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c fiber.Ctx, err error) error {
+			if c.Response().StatusCode() == fiber.StatusOK {
+				c.Status(fiber.StatusInternalServerError)
+			}
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				c.Status(fe.Code)
+			}
+			return c.SendString(err.Error())
+		},
+	})
+	app.Use("/api/download", FileServer(store, repo, slots, tiers, "/api/download", time.Second))
+	return app, slots
+}
+
+func serve(t *testing.T, app *fiber.App, method string, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/api/download/"+testKey, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestFileServerRanges(t *testing.T) {
+	app, slots := newFileServerApp(t, nil)
+	etag := `"` + testSHA256 + `"`
+
+	resp, body := serve(t, app, fiber.MethodGet, nil)
+	if resp.StatusCode != fiber.StatusOK || body != testContent || resp.Header.Get(fiber.HeaderETag) != etag ||
+		resp.Header.Get(fiber.HeaderContentType) != "application/zip" || resp.Header.Get(fiber.HeaderAcceptRanges) != "bytes" {
+		t.Errorf("full download = %d %q, headers %v", resp.StatusCode, body, resp.Header)
+	}
+
+	resp, body = serve(t, app, fiber.MethodGet, map[string]string{fiber.HeaderRange: "bytes=5-9"})
+	if resp.StatusCode != fiber.StatusPartialContent || body != "56789" || resp.Header.Get(fiber.HeaderContentRange) != "bytes 5-9/20" {
+		t.Errorf("range download = %d %q, Content-Range %q", resp.StatusCode, body, resp.Header.Get(fiber.HeaderContentRange))
+	}
+
+	resp, body = serve(t, app, fiber.MethodGet, map[string]string{fiber.HeaderRange: "bytes=-3"})
+	if resp.StatusCode != fiber.StatusPartialContent || body != "hij" {
+		t.Errorf("suffix range = %d %q", resp.StatusCode, body)
+	}
+
+	resp, _ = serve(t, app, fiber.MethodGet, map[string]string{fiber.HeaderRange: "bytes=20-"})
+	if resp.StatusCode != fiber.StatusRequestedRangeNotSatisfiable || resp.Header.Get(fiber.HeaderContentRange) != "bytes */20" {
+		t.Errorf("unsatisfiable range = %d, Content-Range %q", resp.StatusCode, resp.Header.Get(fiber.HeaderContentRange))
+	}
+
+	resp, _ = serve(t, app, fiber.MethodGet, map[string]string{fiber.HeaderIfNoneMatch: etag})
+	if resp.StatusCode != fiber.StatusNotModified {
+		t.Errorf("If-None-Match status = %d, want 304", resp.StatusCode)
+	}
+
+	resp, body = serve(t, app, fiber.MethodHead, map[string]string{fiber.HeaderRange: "bytes=0-3"})
+	if resp.StatusCode != fiber.StatusPartialContent || body != "" || resp.ContentLength != 4 {
+		t.Errorf("HEAD range = %d %q, length %d", resp.StatusCode, body, resp.ContentLength)
+	}
+
+	// 无限制时不占用名额
+	if len(slots.slots) != 0 {
+		t.Errorf("slots = %v, want none without limits", slots.slots)
+	}
+}
+
+func TestFileServerIfRange(t *testing.T) {
+	app, _ := newFileServerApp(t, nil)
+	etag := `"` + testSHA256 + `"`
+
+	for _, tt := range []struct {
+		ifRange string
+		status  int
+		body    string
+	}{
+		{etag, fiber.StatusPartialContent, "01"},
+		{`"other"`, fiber.StatusOK, testContent},
+		{"W/" + etag, fiber.StatusOK, testContent},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), fiber.StatusOK, testContent},
+		{"not a date", fiber.StatusOK, testContent},
+	} {
+		resp, body := serve(t, app, fiber.MethodGet, map[string]string{fiber.HeaderRange: "bytes=0-1", fiber.HeaderIfRange: tt.ifRange})
+		if resp.StatusCode != tt.status || body != tt.body {
+			t.Errorf("If-Range %q = %d %q, want %d %q", tt.ifRange, resp.StatusCode, body, tt.status, tt.body)
+		}
+	}
+}
+
+func TestIfRangeMatchesModTime(t *testing.T) {
+	modTime := time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC)
+	if !ifRangeMatches(modTime.Format(http.TimeFormat), "", modTime) {
+		t.Error("If-Range with the modification time did not match")
+	}
+	if ifRangeMatches(modTime.Add(time.Second).Format(http.TimeFormat), "", modTime) {
+		t.Error("If-Range with another time matched")
+	}
+	if ifRangeMatches(`"abc"`, "", modTime) {
+		t.Error("If-Range ETag matched a file without ETag")
+	}
+}
+
+func TestFileServerConcurrencyLimit(t *testing.T) {
+	app, slots := newFileServerApp(t, map[string]domain.DownloadTier{
+		domain.DownloadTierAnonymous: {Concurrency: 1},
+	})
+
+	// 同一IP已有一个进行中的下载
+	slots.slots[downloadSlotKeyPrefix+"ip:0.0.0.0"] = map[string]bool{"active": true}
+	resp, _ := serve(t, app, fiber.MethodGet, nil)
+	if resp.StatusCode != fiber.StatusTooManyRequests || resp.Header.Get(fiber.HeaderRetryAfter) == "" {
+		t.Errorf("status = %d, Retry-After %q, want 429", resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+	if n := len(slots.slots[downloadSlotKeyPrefix+"ip:0.0.0.0"]); n != 1 {
+		t.Errorf("rejected download kept its slot: %d slots", n)
+	}
+
+	delete(slots.slots[downloadSlotKeyPrefix+"ip:0.0.0.0"], "active")
+	resp, body := serve(t, app, fiber.MethodGet, nil)
+	if resp.StatusCode != fiber.StatusOK || body != testContent {
+		t.Errorf("download after release = %d %q", resp.StatusCode, body)
+	}
+	if n := len(slots.slots[downloadSlotKeyPrefix+"ip:0.0.0.0"]); n != 0 {
+		t.Errorf("finished download kept its slot: %d slots", n)
+	}
+}
+
+// 速度上限由同一下载者的下载平分
+func TestDownloadRateIsShared(t *testing.T) {
+	slots := &fakeSlotRepo{slots: map[string]map[string]bool{}}
+	tier := domain.DownloadTier{Rate: 1000, Concurrency: 4}
+	ctx := context.Background()
+
+	rate1, release1, err := acquireDownloadSlot(ctx, slots, "user:1", tier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rate1(); got != 1000 {
+		t.Errorf("single download rate = %d, want 1000", got)
+	}
+
+	rate2, release2, err := acquireDownloadSlot(ctx, slots, "user:1", tier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got1, got2 := rate1(), rate2(); got1 != 500 || got2 != 500 {
+		t.Errorf("shared rates = %d, %d, want 500 each", got1, got2)
+	}
+
+	// 其他下载者不受影响
+	rate3, release3, err := acquireDownloadSlot(ctx, slots, "ip:10.0.0.1", tier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rate3(); got != 1000 {
+		t.Errorf("other downloader rate = %d, want 1000", got)
+	}
+
+	release2()
+	if got := rate1(); got != 1000 {
+		t.Errorf("rate after release = %d, want 1000", got)
+	}
+	release1()
+	release3()
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ScanGuardMiddleware 拒绝下载尚未扫描完成、已隔离或无法扫描的文件,需在静态文件中间件之前注册
+// prefix为挂载路径,其后的路径即存储文件的FileKey;没有记录的文件不做处理,查询失败时无法确认状态,返回503
+func ScanGuardMiddleware(repo domain.StorageFileRepository, prefix string, timeout time.Duration) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		key, err := url.PathUnescape(strings.TrimPrefix(c.Path(), prefix))
+		if err != nil {
+			return c.Next()
+		}
+		key = strings.TrimPrefix(key, "/")
+
+		if strings.HasPrefix(key, domain.QuarantinePrefix) {
+			c.Status(fiber.StatusForbidden)
+			return domain.ErrFileInfected
+		}
+
+		ctx, cancel := context.WithTimeout(c.Context(), timeout)
+		defer cancel()
+
+		sf, err := repo.GetStorageFileByKey(ctx, key)
+		if errors.Is(err, custom.DataNotExistError) {
+			return c.Next()
+		}
+		if err != nil {
+			c.Status(fiber.StatusServiceUnavailable)
+			return err
+		}
+
+		switch sf.ScanStatus {
+		case domain.ScanStatusScanning:
+			c.Status(fiber.StatusLocked)
+			return domain.ErrFileScanning
+		case domain.ScanStatusInfected:
+			c.Status(fiber.StatusForbidden)
+			return domain.ErrFileInfected
+		case domain.ScanStatusFailed:
+			c.Status(fiber.StatusForbidden)
+			return domain.ErrFileUnscannable
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/storage"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/static"
+)
+
+// 没有记录的文件放行,查询失败时不放行
+func TestScanGuardMiddleware(t *testing.T) {
+	root := t.TempDir()
+	store := storage.NewLocalStorage(root, "")
+	const cleanKey, scanningKey, untrackedKey = "blobs/ab/clean.zip", "blobs/cd/scanning.zip", "files/untracked.png"
+	for _, key := range []string{cleanKey, scanningKey, untrackedKey} {
+		if err := store.Put(context.Background(), key, strings.NewReader("content"), 7, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := &fakeFileRepo{files: map[string]domain.StorageFile{
+		cleanKey:    {FileKey: cleanKey, ScanStatus: domain.ScanStatusClean},
+		scanningKey: {FileKey: scanningKey, ScanStatus: domain.ScanStatusScanning},
+	}}
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c fiber.Ctx, err error) error {
+			return c.SendString(err.Error())
+		},
+	})
+	app.Use("/api/data", ScanGuardMiddleware(repo, "/api/data", time.Second))
+	app.Use("/api/data", static.New(root))
+
+	get := func(key string) int {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/data/"+key, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	for key, status := range map[string]int{
+		cleanKey:     fiber.StatusOK,
+		scanningKey:  fiber.StatusLocked,
+		untrackedKey: fiber.StatusOK,
+	} {
+		if got := get(key); got != status {
+			t.Errorf("GET /api/data/%s status = %d, want %d", key, got, status)
+		}
+	}
+
+	repo.err = errors.New("connection refused")
+	if got := get(scanningKey); got != fiber.StatusServiceUnavailable {
+		t.Errorf("GET with repository error status = %d, want %d", got, fiber.StatusServiceUnavailable)
+	}
+}
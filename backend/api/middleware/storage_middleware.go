@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"ModVerse/domain"
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// StorageRedirectMiddleware 文件不在本地时,将静态文件请求重定向到存储后端的限时地址
+// prefix为挂载路径,其后的路径即存储文件的FileKey,只为有记录的文件生成地址
+// 模组文件下载需要限速与限制并发,由 FileServer 提供,不经过此处
+func StorageRedirectMiddleware(store domain.Storage, repo domain.StorageFileRepository, prefix string, expiry time.Duration,
+	timeout time.Duration) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return c.Next()
+		}
+
+		key, err := url.PathUnescape(strings.TrimPrefix(c.Path(), prefix))
+		if err != nil {
+			c.Status(fiber.StatusBadRequest)
+			return err
+		}
+		key = strings.TrimPrefix(key, "/")
+		if key == "" {
+			return fiber.ErrNotFound
+		}
+
+		ctx, cancel := context.WithTimeout(c.Context(), timeout)
+		defer cancel()
+
+		// 存储桶中可能有不属于任何记录的对象,如未完成的上传与隔离文件,不对外提供
+		exists, err := repo.HasKey(ctx, key)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fiber.ErrNotFound
+		}
+
+		location, err := store.PresignGet(ctx, key, expiry, "")
+		if err != nil {
+			return err
+		}
+
+		return c.Redirect().Status(fiber.StatusFound).To(location)
+	}
+}
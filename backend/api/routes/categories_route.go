@@ -2,6 +2,7 @@ package routes
 
 import (
 	"ModVerse/api/controller"
+	"ModVerse/api/middleware"
 	"ModVerse/bootstrap"
 	"ModVerse/repository"
 	"ModVerse/service"
@@ -23,7 +24,11 @@ func NewCategoriesRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, timeou
 	categories := r.Group("/categories")
 
 	categories.Get("/", cc.GetCategories)
+	categories.Get("/tree", cc.GetCategoryTree)
 	categories.Post("/", cc.CreateCategory)
 	categories.Put("/:id", cc.UpdateCategory)
 	categories.Delete("/:id", cc.DeleteCategory)
+	categories.Post("/:id/merge", cc.MergeCategory, middleware.AuthMiddleware(env))
+	categories.Post("/:id/alias", cc.CreateAlias, middleware.AuthMiddleware(env))
+	categories.Post("/:id/move", cc.MoveCategory, middleware.AuthMiddleware(env))
 }
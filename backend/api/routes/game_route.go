@@ -2,6 +2,7 @@ package routes
 
 import (
 	"ModVerse/api/controller"
+	"ModVerse/domain"
 	"ModVerse/repository"
 	"ModVerse/service"
 	"time"
@@ -11,12 +12,12 @@ import (
 	"gorm.io/gorm"
 )
 
-func NewGameRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, timeout time.Duration) {
+func NewGameRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, store domain.Storage, timeout time.Duration) {
 	gr := repository.NewGameRepository(db)
 	rr := repository.NewRedisRepository(redis)
 	sf := repository.NewStorageFileRepository(db)
 
-	gs := service.NewGameService(gr, rr, sf, timeout)
+	gs := service.NewGameService(gr, rr, sf, store, timeout)
 
 	gc := controller.GameController{
 		GameService: gs,
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"ModVerse/api/controller"
+	"ModVerse/api/middleware"
+	"ModVerse/bootstrap"
+	"ModVerse/repository"
+	"ModVerse/service"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+func NewGameVersionRoute(r fiber.Router, db *gorm.DB, timeout time.Duration, env *bootstrap.Env) {
+	gvr := repository.NewGameVersionRepository(db)
+	mvr := repository.NewModVersionRepository(db)
+	gs := service.NewGameVersionService(gvr, mvr, timeout)
+	gc := controller.GameVersionController{
+		GameVersionService: gs,
+	}
+
+	game := r.Group("/game")
+	game.Get("/:id/versions", gc.GetGameVersions)
+	game.Post("/:id/versions", gc.CreateGameVersion, middleware.AuthMiddleware(env))
+	game.Delete("/versions/:id", gc.DeleteGameVersion, middleware.AuthMiddleware(env))
+
+	modVersion := r.Group("/mod_version")
+	modVersion.Put("/:id/game_versions", gc.SetModVersionGameVersions, middleware.AuthMiddleware(env))
+	modVersion.Post("/:id/compatibility", gc.ReportCompatibility, middleware.AuthMiddleware(env))
+
+	r.Get("/mod/:id/compatibility", gc.GetCompatibilityMatrix)
+}
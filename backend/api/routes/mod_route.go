@@ -4,6 +4,7 @@ import (
 	"ModVerse/api/controller"
 	"ModVerse/api/middleware"
 	"ModVerse/bootstrap"
+	"ModVerse/domain"
 	"ModVerse/repository"
 	"ModVerse/service"
 	"time"
@@ -13,11 +14,15 @@ import (
 	"gorm.io/gorm"
 )
 
-func NewModRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, time time.Duration, env *bootstrap.Env) {
+func NewModRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, store domain.Storage, time time.Duration, env *bootstrap.Env) {
 	mr := repository.NewModRepository(db)
 	rr := repository.NewRedisRepository(redis)
 	mvr := repository.NewModVersionRepository(db)
-	ms := service.NewModService(mr, mvr, rr, time)
+	car := repository.NewCategoriesRepository(db)
+	gvr := repository.NewGameVersionRepository(db)
+	mfr := repository.NewManifestRepository(db)
+	sfr := repository.NewStorageFileRepository(db)
+	ms := service.NewModService(mr, mvr, car, gvr, mfr, rr, sfr, store, time)
 	mc := controller.ModController{
 		ModService: ms,
 	}
@@ -4,6 +4,7 @@ import (
 	"ModVerse/api/controller"
 	"ModVerse/api/middleware"
 	"ModVerse/bootstrap"
+	"ModVerse/domain"
 	"ModVerse/repository"
 	"ModVerse/service"
 	"time"
@@ -13,10 +14,14 @@ import (
 	"gorm.io/gorm"
 )
 
-func NewModVersionRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, timeout time.Duration, env *bootstrap.Env) {
+func NewModVersionRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, store domain.Storage, timeout time.Duration, env *bootstrap.Env) {
 	mr := repository.NewModVersionRepository(db)
+	dr := repository.NewModDependencyRepository(db)
 	rr := repository.NewRedisRepository(redis)
-	ms := service.NewModVersionService(mr, rr, timeout)
+	gvr := repository.NewGameVersionRepository(db)
+	mfr := repository.NewManifestRepository(db)
+	sfr := repository.NewStorageFileRepository(db)
+	ms := service.NewModVersionService(mr, dr, gvr, mfr, rr, sfr, store, env, timeout)
 	mc := controller.ModVersionController{
 		ModVersionService: ms,
 	}
@@ -24,7 +29,19 @@ func NewModVersionRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, timeou
 	modVersion := r.Group("/mod_version")
 
 	modVersion.Get("/:mod_id", mc.GetModVersions)
+	modVersion.Post("/updates", mc.CheckUpdates)
+	modVersion.Get("/dependents/:mod_id", mc.GetDependents)
+	modVersion.Get("/prefill/:file_id", mc.GetManifestPrefill, middleware.AuthMiddleware(env))
+	modVersion.Get("/:id/dependencies/resolve", mc.ResolveDependencies)
+	modVersion.Get("/:id/contents", mc.GetVersionContents)
+	modVersion.Get("/:id/download", mc.DownloadModVersion, middleware.HotlinkMiddleware(bootstrap.DownloadReferers(env)), middleware.OptionalAuthMiddleware(env))
+	modVersion.Put("/:id/dependencies", mc.SetDependencies, middleware.AuthMiddleware(env))
+	modVersion.Post("/:id/yank", mc.YankModVersion, middleware.AuthMiddleware(env))
+	modVersion.Post("/:id/unyank", mc.UnyankModVersion, middleware.AuthMiddleware(env))
 	modVersion.Post("/", mc.CreateModVersion, middleware.AuthMiddleware(env))
 	modVersion.Delete("/:id", mc.DeleteModVersion, middleware.AuthMiddleware(env))
 	modVersion.Post("count/:mod_id/:id", mc.UpdateCount)
+
+	// 启动器按模组获取最新版本
+	r.Get("/mod/:id/versions/latest", mc.GetLatestVersion)
 }
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"ModVerse/bootstrap"
+	"ModVerse/domain"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -10,21 +11,23 @@ import (
 	"gorm.io/gorm"
 )
 
-func Setup(r *fiber.App, db *gorm.DB, redis *redis.Client,
-	mail *mail.SMTPClient, env *bootstrap.Env, timeout time.Duration) {
+func Setup(r *fiber.App, db *gorm.DB, redis *redis.Client, store domain.Storage, scanner domain.Scanner,
+	mr domain.MetadataRegistry, mail *mail.SMTPClient, env *bootstrap.Env, timeout time.Duration) {
 
 	api := r.Group("/api")
 
-	NewGameRoute(api, db, redis, timeout)
-	NewUserRoute(api, db, redis, timeout, env)
+	NewGameRoute(api, db, redis, store, timeout)
+	NewGameVersionRoute(api, db, timeout, env)
+	NewUserRoute(api, db, redis, store, timeout, env)
 	NewCategoriesRoute(api, db, redis, timeout, env)
-	NewUploadRoute(api, db, timeout, env)
+	NewUploadRoute(api, db, redis, store, scanner, mr, timeout, env)
 	NewAuthRoute(api, db, redis, timeout, env, mail)
-	NewModRoute(api, db, redis, timeout, env)
+	NewModRoute(api, db, redis, store, timeout, env)
 	NewCommentRoute(api, db, redis, timeout, env)
-	NewModVersionRoute(api, db, redis, timeout, env)
+	NewModVersionRoute(api, db, redis, store, timeout, env)
 	NewModFavoriteRoute(api, db, redis, timeout, env)
 	NewModLikeRoute(api, db, redis, timeout, env)
 	NewReportRoute(api, db, redis, timeout, env)
 	NewCaptchaRoute(api, redis)
+	NewSearchRoute(r, api, db, timeout)
 }
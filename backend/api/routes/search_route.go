@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"ModVerse/api/controller"
+	"ModVerse/repository"
+	"ModVerse/service"
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+func NewSearchRoute(app *fiber.App, r fiber.Router, db *gorm.DB, timeout time.Duration) {
+	sr := repository.NewSearchRepository(db)
+	ss := service.NewSearchService(sr, timeout)
+	//后台定时刷新联想词索引
+	ss.Start(context.Background())
+	app.Hooks().OnShutdown(func() error {
+		ss.Stop()
+		return nil
+	})
+
+	sc := controller.SearchController{
+		SearchService: ss,
+	}
+
+	search := r.Group("/search")
+	search.Get("/suggest", sc.Suggest)
+}
@@ -4,26 +4,51 @@ import (
 	"ModVerse/api/controller"
 	"ModVerse/api/middleware"
 	"ModVerse/bootstrap"
+	"ModVerse/domain"
 	"ModVerse/repository"
 	"ModVerse/service"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
-func NewUploadRoute(r fiber.Router, db *gorm.DB, timeout time.Duration, env *bootstrap.Env) {
+func NewUploadRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, store domain.Storage, scanner domain.Scanner, mr domain.MetadataRegistry, timeout time.Duration, env *bootstrap.Env) {
 	ur := repository.NewStorageFileRepository(db)
-	us := service.NewUploadService(ur, env, timeout)
+	rr := repository.NewRedisRepository(redis)
+	var ss domain.ScanService
+	if scanner != nil {
+		ss = service.NewScanService(ur, repository.NewReportRepository(db), rr, store, scanner, timeout)
+	}
+	qs := service.NewQuotaService(repository.NewQuotaRepository(db), env, timeout)
+	us := service.NewUploadService(ur, rr, store, ss, qs, mr, env, timeout)
+	gs := service.NewStorageGCService(repository.NewStorageGCRepository(db), ur, rr, store, bootstrap.GCGracePeriod(env), timeout)
 
 	uc := controller.UploadController{
-		UploadService: us,
+		UploadService:    us,
+		StorageGCService: gs,
+		QuotaService:     qs,
 	}
 
 	upload := r.Group("/upload")
 
 	upload.Post("/post_image", uc.UploadPostImage, middleware.AuthMiddleware(env))
 	upload.Post("/file", uc.UploadFile, middleware.AuthMiddleware(env))
+	upload.Post("/verify", uc.StartVerifyFiles, middleware.AuthMiddleware(env))
+	upload.Get("/verify", uc.GetVerifyReport, middleware.AuthMiddleware(env))
+	upload.Get("/usage", uc.GetUsage, middleware.AuthMiddleware(env))
+	upload.Get("/usage/top", uc.GetTopConsumers, middleware.AuthMiddleware(env))
+	upload.Put("/quota/:id", uc.SetUserQuota, middleware.AuthMiddleware(env))
+	upload.Post("/gc", uc.StartGC, middleware.AuthMiddleware(env))
+	upload.Get("/gc", uc.GetGCReport, middleware.AuthMiddleware(env))
+
+	//tus断点续传
+	upload.Options("/tus", uc.TusOptions)
+	upload.Post("/tus", uc.CreateTusUpload, middleware.AuthMiddleware(env))
+	upload.Head("/tus/:id", uc.GetTusUpload, middleware.AuthMiddleware(env))
+	upload.Patch("/tus/:id", uc.PatchTusUpload, middleware.AuthMiddleware(env))
+	upload.Delete("/tus/:id", uc.DeleteTusUpload, middleware.AuthMiddleware(env))
 
 	upload.Get("/:id", uc.GetFile)
 	upload.Get("/", uc.GetFiles)
@@ -4,6 +4,7 @@ import (
 	"ModVerse/api/controller"
 	"ModVerse/api/middleware"
 	"ModVerse/bootstrap"
+	"ModVerse/domain"
 	"ModVerse/repository"
 	"ModVerse/service"
 	"time"
@@ -13,13 +14,13 @@ import (
 	"gorm.io/gorm"
 )
 
-func NewUserRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, timeout time.Duration, env *bootstrap.Env) {
+func NewUserRoute(r fiber.Router, db *gorm.DB, redis *redis.Client, store domain.Storage, timeout time.Duration, env *bootstrap.Env) {
 	ur := repository.NewUserRepository(db)
 	rr := repository.NewRedisRepository(redis)
 	pr := repository.NewUserProfileRepository(db)
 	sfr := repository.NewStorageFileRepository(db)
 
-	us := service.NewUserService(ur, rr, pr, timeout, env, sfr)
+	us := service.NewUserService(ur, rr, pr, timeout, env, sfr, store)
 
 	uc := controller.UserController{
 		UserService: us,
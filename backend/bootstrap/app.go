@@ -1,16 +1,21 @@
 package bootstrap
 
 import (
+	"ModVerse/domain"
+
 	"github.com/redis/go-redis/v9"
 	mail "github.com/xhit/go-simple-mail/v2"
 	"gorm.io/gorm"
 )
 
 type Application struct {
-	Env   *Env
-	DB    *gorm.DB
-	Redis *redis.Client
-	Mail  *mail.SMTPClient
+	Env      *Env
+	DB       *gorm.DB
+	Redis    *redis.Client
+	Mail     *mail.SMTPClient
+	Storage  domain.Storage
+	Scanner  domain.Scanner
+	Metadata domain.MetadataRegistry
 }
 
 func App() Application {
@@ -20,6 +25,9 @@ func App() Application {
 	app.DB = NewDataBase(app.Env)
 	app.Redis = NewRedis(app.Env)
 	app.Mail = NewMail(app.Env)
+	app.Storage = NewStorage(app.Env)
+	app.Scanner = NewScanner(app.Env)
+	app.Metadata = NewMetadataRegistry(app.Env)
 
 	return app
 }
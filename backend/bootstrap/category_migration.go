@@ -0,0 +1,132 @@
+package bootstrap
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// MigrateCategorySlugs 为旧分类表补充slug列并回填,需在AutoMigrate建立唯一索引前执行
+func MigrateCategorySlugs(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&domain.Categories{}) || m.HasColumn(&domain.Categories{}, "Slug") {
+		return nil
+	}
+
+	if err := db.Exec("ALTER TABLE categories ADD COLUMN slug VARCHAR(128) NOT NULL DEFAULT ''").Error; err != nil {
+		return err
+	}
+
+	var categories []domain.Categories
+	if err := db.Unscoped().Order("id").Find(&categories).Error; err != nil {
+		return err
+	}
+
+	used := make(map[string]struct{}, len(categories))
+	for _, ca := range categories {
+		base := utils.Slugify(ca.Name)
+		if base == "" {
+			base = "category"
+		}
+
+		slug := base
+		for i := 2; ; i++ {
+			if _, ok := used[slug]; !ok {
+				break
+			}
+			slug = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[slug] = struct{}{}
+
+		if err := db.Unscoped().Model(&domain.Categories{}).Where("id = ?", ca.ID).Update("slug", slug).Error; err != nil {
+			return err
+		}
+	}
+
+	log.Printf("category migration: backfilled %d slugs", len(categories))
+	return nil
+}
+
+// MigrateModCategories 将mods.category字符串迁移到mod_categories关联表,完成后删除旧列
+// 需在Mod、Categories的AutoMigrate之后执行
+func MigrateModCategories(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasColumn("mods", "category") {
+		return nil
+	}
+
+	var names []string
+	if err := db.Table("mods").Distinct("category").Where("category <> ''").Pluck("category", &names).Error; err != nil {
+		return err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range names {
+			var ca domain.Categories
+			err := tx.Where("name = ?", name).Order("alias_of_id, id").First(&ca).Error
+			if err == gorm.ErrRecordNotFound {
+				ca = domain.Categories{Name: name, Slug: uniqueMigrationSlug(tx, name)}
+				err = tx.Create(&ca).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			// 别名归并到原分类
+			target := ca.ID
+			if ca.AliasOfID != 0 {
+				target = ca.AliasOfID
+			}
+
+			if err := tx.Exec("INSERT IGNORE INTO mod_categories (mod_id, categories_id) SELECT id, ? FROM mods WHERE category = ?", target, name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// DDL会隐式提交,放在事务外执行
+	if err := m.DropColumn("mods", "category"); err != nil {
+		return err
+	}
+
+	log.Printf("category migration: moved %d categories to mod_categories", len(names))
+	return nil
+}
+
+func uniqueMigrationSlug(tx *gorm.DB, name string) string {
+	base := utils.Slugify(name)
+	if base == "" {
+		base = "category"
+	}
+
+	slug := base
+	for i := 2; ; i++ {
+		var count int64
+		tx.Unscoped().Model(&domain.Categories{}).Where("slug = ?", slug).Count(&count)
+		if count == 0 {
+			return slug
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// MigrateCategoryTree 为旧分类回填层级路径,旧分类均作为根分类
+// 需在Categories的AutoMigrate之后执行
+func MigrateCategoryTree(db *gorm.DB) error {
+	result := db.Exec("UPDATE categories SET path = CONCAT('/', id, '/'), depth = 1, parent_id = 0 WHERE path = ''")
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		log.Printf("category migration: backfilled %d category paths", result.RowsAffected)
+	}
+	return nil
+}
@@ -13,6 +13,8 @@ func NewDataBase(env *Env) *gorm.DB {
 	//连接数据库
 	db, err := gorm.Open(mysql.Open(dbConfig.DSN), &gorm.Config{
 		DisableForeignKeyConstraintWhenMigrating: true,
+		// 唯一索引冲突等错误转换为 gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 	if err != nil {
 		log.Fatal("Failed to connect to database!", err)
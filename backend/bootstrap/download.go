@@ -0,0 +1,57 @@
+package bootstrap
+
+import (
+	"ModVerse/domain"
+	"time"
+)
+
+const defaultDownloadExpiry = 10 * time.Minute
+
+// 默认匿名下载2MB/s、同时2个,登录用户5MB/s、同时4个,作者不限速、同时8个
+var defaultDownloadTiers = map[string]domain.DownloadTier{
+	domain.DownloadTierAnonymous: {Rate: 2 << 20, Concurrency: 2},
+	domain.DownloadTierUser:      {Rate: 5 << 20, Concurrency: 4},
+	domain.DownloadTierAuthor:    {Rate: 0, Concurrency: 8},
+}
+
+// 前端站点,跨域配置与下载防盗链共用
+var FrontendOrigins = []string{"http://localhost:5173", "http://localhost:5175"}
+
+// DownloadReferers 允许发起下载的站点,未配置时为本站与前端地址
+func DownloadReferers(env *Env) []string {
+	if len(env.Download.Referers) > 0 {
+		return env.Download.Referers
+	}
+	return append([]string{env.App.Host + ":" + env.App.Port}, FrontendOrigins...)
+}
+
+// DownloadSecret 下载地址签名密钥
+func DownloadSecret(env *Env) string {
+	if env.Download.Secret == "" {
+		return env.App.TokenSecret
+	}
+	return env.Download.Secret
+}
+
+// DownloadExpiry 签名下载地址的有效期
+func DownloadExpiry(env *Env) time.Duration {
+	if env.Download.Expiry <= 0 {
+		return defaultDownloadExpiry
+	}
+	return time.Duration(env.Download.Expiry) * time.Second
+}
+
+// DownloadTiers 各下载等级的限速与并发配置,未配置的等级使用默认值
+func DownloadTiers(env *Env) map[string]domain.DownloadTier {
+	tiers := make(map[string]domain.DownloadTier, len(defaultDownloadTiers))
+	for name, tier := range defaultDownloadTiers {
+		tiers[name] = tier
+	}
+	for name, tier := range env.Download.Tiers {
+		if tier.Rate < 0 || tier.Concurrency < 0 {
+			continue
+		}
+		tiers[name] = tier
+	}
+	return tiers
+}
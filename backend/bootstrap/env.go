@@ -1,6 +1,7 @@
 package bootstrap
 
 import (
+	"ModVerse/domain"
 	"log"
 
 	"github.com/spf13/viper"
@@ -17,6 +18,8 @@ type Env struct {
 		AccessTokenExpiryHour  int
 		RefreshTokenExpiryHour int
 		TokenSecret            string
+		ProxyHeader            string   //部署在反向代理后时读取客户端IP的请求头,如 X-Forwarded-For
+		TrustedProxies         []string //可信的代理地址(IP或CIDR),只有来自这些地址的请求才读取ProxyHeader
 	}
 	//数据库配置
 	Database struct {
@@ -39,6 +42,47 @@ type Env struct {
 		ConnectTimeout int
 		SendTimeout    int
 	}
+	//文件存储配置
+	Storage struct {
+		Driver string //存储后端: local(默认) 或 s3
+		Root   string //本地存储目录,s3后端仍用于存放上传临时文件
+		S3     struct {
+			Endpoint  string //不含协议,如 127.0.0.1:9000
+			AccessKey string
+			SecretKey string
+			Bucket    string
+			Region    string
+			UseSSL    bool
+		}
+	}
+	//各上传类型的限制,键为上传类型,未配置的字段使用默认值
+	Upload map[string]domain.UploadPolicy
+	//压缩包检查限制,未配置的字段使用默认值
+	Archive domain.ArchiveLimits
+	//模组文件下载配置
+	Download struct {
+		Secret string //下载地址签名密钥,为空时使用TokenSecret
+		Expiry int    //签名地址的有效期(秒),默认600
+		//各下载等级(anonymous/user/author)每个下载者的限速(字节/秒)与并发数,0为不限制
+		Tiers map[string]domain.DownloadTier
+		//允许发起下载的站点(如 https://modverse.example),为空时为本站与前端地址
+		Referers []string
+	}
+	//恶意文件扫描配置,未配置driver时不扫描
+	Scanner struct {
+		Driver  string //扫描后端: clamd
+		Address string //clamd地址,如 unix:///var/run/clamav/clamd.ctl 或 tcp://127.0.0.1:3310
+		Timeout int    //单个文件的扫描超时(秒)
+	}
+	//各角色的存储配额(字节),键为角色,0为不限制,未配置的角色使用默认值
+	Quota map[string]int64
+	//存储清理配置
+	GC struct {
+		Interval    int //定时清理间隔(秒),为0时不定时清理,只能由管理员手动执行
+		GracePeriod int //未被引用的上传文件与存储对象的保留时间(秒),默认24小时
+	}
+	//各游戏使用的清单提取器,键为游戏ID,值为按顺序尝试的提取器: fabric/forge/factorio/bethesda
+	Metadata map[string][]string
 }
 
 func NewEnv() *Env {
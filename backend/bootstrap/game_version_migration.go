@@ -0,0 +1,25 @@
+package bootstrap
+
+import (
+	"ModVerse/domain"
+
+	"gorm.io/gorm"
+)
+
+// MigrateDeletedGameVersions 彻底删除此前软删除的游戏版本及其关联与兼容性反馈,释放占用的版本名称
+// 需在ModVersion与CompatibilityReport的AutoMigrate之后执行
+func MigrateDeletedGameVersions(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Model(&domain.GameVersion{}).Unscoped().Where("deleted_at IS NOT NULL").Select("id")
+
+		if err := tx.Exec("DELETE FROM mod_version_game_versions WHERE game_version_id IN (?)", deleted).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("game_version_id IN (?)", deleted).Delete(&domain.CompatibilityReport{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Where("deleted_at IS NOT NULL").Delete(&domain.GameVersion{}).Error
+	})
+}
@@ -0,0 +1,21 @@
+package bootstrap
+
+import "time"
+
+const defaultGCGracePeriod = 24 * time.Hour
+
+// GCGracePeriod 上传后未被引用的文件、没有记录的存储对象在该时间后才会被清理,避免删除正在使用中的上传
+func GCGracePeriod(env *Env) time.Duration {
+	if env.GC.GracePeriod <= 0 {
+		return defaultGCGracePeriod
+	}
+	return time.Duration(env.GC.GracePeriod) * time.Second
+}
+
+// GCInterval 定时清理间隔,为0时不定时清理
+func GCInterval(env *Env) time.Duration {
+	if env.GC.Interval <= 0 {
+		return 0
+	}
+	return time.Duration(env.GC.Interval) * time.Second
+}
@@ -0,0 +1,30 @@
+package bootstrap
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/metadata"
+	"log"
+	"strconv"
+)
+
+// NewMetadataRegistry 按配置为各游戏注册内置的清单提取器
+func NewMetadataRegistry(env *Env) domain.MetadataRegistry {
+	registry := metadata.NewRegistry()
+
+	for key, names := range env.Metadata {
+		gameID, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			log.Fatal("Invalid game id in metadata config: ", key)
+		}
+
+		for _, name := range names {
+			extractor, err := metadata.Builtin(name)
+			if err != nil {
+				log.Fatal("Failed to init metadata extractor:", err)
+			}
+			registry.Register(uint(gameID), extractor)
+		}
+	}
+
+	return registry
+}
@@ -0,0 +1,80 @@
+package bootstrap
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"log"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MigrateModVersionFiles 将mod_versions.file_id迁移为版本的主文件,完成后删除旧列
+// 需在ModVersionFile的AutoMigrate之后执行,版本原有下载量计入主文件
+func MigrateModVersionFiles(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasColumn("mod_versions", "file_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(`INSERT INTO mod_version_files
+			(created_at, updated_at, mod_version_id, file_id, role, display_name, sort_order, downloads)
+			SELECT NOW(), NOW(), mv.id, mv.file_id, ?, '', 0, mv.downloads
+			FROM mod_versions mv
+			WHERE mv.file_id <> 0
+			AND NOT EXISTS (SELECT 1 FROM mod_version_files f WHERE f.mod_version_id = mv.id)`, domain.FileRoleMain)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if err := tx.Migrator().DropColumn("mod_versions", "file_id"); err != nil {
+			return err
+		}
+
+		log.Printf("mod version file migration: attached %d main files", result.RowsAffected)
+		return nil
+	})
+}
+
+// MigrateDuplicateModVersions 为同一模组下重复的版本号追加构建元数据(如 1.0.0+dup.42),以便建立唯一索引
+// 保留最早的版本原样,构建元数据不影响版本比较;需在ModVersion的AutoMigrate之前执行
+func MigrateDuplicateModVersions(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&domain.ModVersion{}) || m.HasIndex(&domain.ModVersion{}, "idx_mod_versions_mod_version") {
+		return nil
+	}
+
+	var duplicates []domain.ModVersion
+	if err := db.Unscoped().Raw(`SELECT mv.id, mv.mod_id, mv.version FROM mod_versions mv
+		WHERE EXISTS (SELECT 1 FROM mod_versions o WHERE o.mod_id = mv.mod_id AND o.version = mv.version AND o.id < mv.id)`).
+		Scan(&duplicates).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, mv := range duplicates {
+			suffix := "dup." + strconv.FormatUint(uint64(mv.ID), 10)
+			if strings.Contains(mv.Version, "+") {
+				suffix = "." + suffix
+			} else {
+				suffix = "+" + suffix
+			}
+			version := mv.Version
+			if len(version)+len(suffix) > utils.MaxVersionLength {
+				version = version[:utils.MaxVersionLength-len(suffix)]
+			}
+
+			if err := tx.Unscoped().Model(&domain.ModVersion{}).Where("id = ?", mv.ID).
+				Update("version", version+suffix).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(duplicates) > 0 {
+			log.Printf("mod version migration: renamed %d duplicate versions", len(duplicates))
+		}
+		return nil
+	})
+}
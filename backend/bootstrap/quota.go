@@ -0,0 +1,18 @@
+package bootstrap
+
+// 默认每个普通用户1G,管理员不限制
+var defaultRoleQuotas = map[string]int64{
+	"user":  1 << 30,
+	"admin": 0,
+}
+
+// RoleQuota 角色的存储配额(字节),0为不限制,未知角色按普通用户处理
+func RoleQuota(env *Env, role string) int64 {
+	if quota, ok := env.Quota[role]; ok && quota >= 0 {
+		return quota
+	}
+	if quota, ok := defaultRoleQuotas[role]; ok {
+		return quota
+	}
+	return RoleQuota(env, "user")
+}
@@ -0,0 +1,29 @@
+package bootstrap
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/scanner"
+	"log"
+	"time"
+)
+
+const defaultScanTimeout = 10 * time.Minute
+
+// NewScanner 按配置创建恶意文件扫描后端,未配置时返回nil,上传的文件不经扫描直接可用
+func NewScanner(env *Env) domain.Scanner {
+	timeout := defaultScanTimeout
+	if env.Scanner.Timeout > 0 {
+		timeout = time.Duration(env.Scanner.Timeout) * time.Second
+	}
+
+	switch env.Scanner.Driver {
+	case "":
+		log.Println("Malware scanning is disabled")
+		return nil
+	case domain.ScannerClamd:
+		return scanner.NewClamdScanner(env.Scanner.Address, timeout)
+	default:
+		log.Fatal("Unknown scanner driver: ", env.Scanner.Driver)
+		return nil
+	}
+}
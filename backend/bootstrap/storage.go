@@ -0,0 +1,58 @@
+package bootstrap
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/storage"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const defaultStorageRoot = "../../storage"
+
+// StorageRoot 本地存储目录
+func StorageRoot(env *Env) string {
+	if env.Storage.Root == "" {
+		return defaultStorageRoot
+	}
+	return env.Storage.Root
+}
+
+// NewStorage 按配置创建文件存储后端
+func NewStorage(env *Env) domain.Storage {
+	driver := env.Storage.Driver
+	if driver == "" {
+		driver = domain.StorageLocal
+	}
+
+	store, err := NewStorageDriver(env, driver)
+	if err != nil {
+		log.Fatal("Failed to init storage:", err)
+	}
+
+	return store
+}
+
+// NewStorageDriver 创建指定的存储后端,供存储迁移时同时打开两个后端
+func NewStorageDriver(env *Env, driver string) (domain.Storage, error) {
+	switch driver {
+	case domain.StorageLocal:
+		return storage.NewLocalStorage(StorageRoot(env), env.App.Host+":"+env.App.Port+"/api/download/"), nil
+	case domain.StorageS3:
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		s3 := env.Storage.S3
+		return storage.NewS3Storage(ctx, storage.S3Config{
+			Endpoint:  s3.Endpoint,
+			AccessKey: s3.AccessKey,
+			SecretKey: s3.SecretKey,
+			Bucket:    s3.Bucket,
+			Region:    s3.Region,
+			UseSSL:    s3.UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", driver)
+	}
+}
@@ -0,0 +1,195 @@
+package bootstrap
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"context"
+	"errors"
+	"log"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MigrateStorageFileKeyIndex 内容寻址后多个文件记录可共享同一路径,删除旧的file_key唯一索引
+// 需在StorageFile的AutoMigrate之前执行
+func MigrateStorageFileKeyIndex(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&domain.StorageFile{}) || !m.HasIndex(&domain.StorageFile{}, "idx_storage_files_file_key") {
+		return nil
+	}
+
+	return m.DropIndex(&domain.StorageFile{}, "idx_storage_files_file_key")
+}
+
+// 旧版本记录的FileKey为相对于程序目录的本地路径
+const legacyStoragePrefix = "../../storage/"
+
+// MigrateStorageKeys 将FileKey改为与存储后端无关的相对路径,如 blobs/ab/<sha256>.zip
+func MigrateStorageKeys(db *gorm.DB) error {
+	expr := gorm.Expr("SUBSTRING(file_key, ?)", len(legacyStoragePrefix)+1)
+	like := legacyStoragePrefix + "%"
+
+	if err := db.Model(&domain.StorageFile{}).Unscoped().
+		Where("file_key LIKE ?", like).
+		UpdateColumn("file_key", expr).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&domain.StorageBlob{}).
+		Where("file_key LIKE ?", like).
+		UpdateColumn("file_key", expr).Error
+}
+
+// MigrateStorageUploadTypes 为旧的文件记录补充上传类型,被模组版本引用或通过下载地址提供的为模组文件
+// 其余旧记录无法区分图片类型,保持为空;需在StorageFile与ModVersionFile的AutoMigrate之后执行
+func MigrateStorageUploadTypes(db *gorm.DB) error {
+	return db.Model(&domain.StorageFile{}).Unscoped().
+		Where("upload_type = '' OR upload_type IS NULL").
+		Where("url LIKE ? OR id IN (?)", "%/api/download/%", db.Model(&domain.ModVersionFile{}).Unscoped().Select("file_id")).
+		UpdateColumn("upload_type", domain.UploadModFile).Error
+}
+
+// DedupeStorage 将本地存储中的已有文件按内容哈希移动到blobs目录,内容相同的文件只保留一份,并重建引用计数
+// 会改写文件记录的路径与访问地址,以及模组介绍中引用的图片地址,应在停止服务后执行
+func DedupeStorage(db *gorm.DB, root string) error {
+	var moved, deduped, missing int
+
+	var files []domain.StorageFile
+	err := db.Order("id").FindInBatches(&files, 200, func(tx *gorm.DB, batch int) error {
+		for _, sf := range files {
+			path := filepath.Join(root, sf.FileKey)
+			hash, err := utils.HashFile(path)
+			if err != nil {
+				log.Printf("storage dedupe: skip file %d (%s): %v", sf.ID, sf.FileKey, err)
+				missing++
+				continue
+			}
+
+			key := utils.BlobKey(hash, filepath.Ext(sf.FileKey))
+			if key != sf.FileKey {
+				created, err := utils.MoveBlob(path, filepath.Join(root, key))
+				if err != nil {
+					return err
+				}
+				if created {
+					moved++
+				} else {
+					deduped++
+				}
+				// 旧的上传目录为空时一并删除
+				os.Remove(filepath.Dir(path))
+			}
+
+			if err := db.Model(&domain.StorageFile{}).Where("id = ?", sf.ID).UpdateColumns(map[string]any{
+				"file_key": key,
+				"sha256":   hash,
+				"url":      migratedURL(sf.URL, sf.FileKey, key, sf.FileName),
+			}).Error; err != nil {
+				return err
+			}
+
+			if key != sf.FileKey {
+				if err := migrateContentURLs(db, sf.FileKey, key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&domain.StorageBlob{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`INSERT INTO storage_blobs (file_key, sha256, file_size, ref_count, created_at, updated_at)
+			SELECT file_key, MAX(sha256), MAX(file_size), COUNT(*), NOW(), NOW()
+			FROM storage_files
+			WHERE deleted_at IS NULL AND sha256 <> ''
+			GROUP BY file_key`).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("storage dedupe: moved %d, deduplicated %d, missing %d", moved, deduped, missing)
+	return nil
+}
+
+// 模组介绍中插入的图片地址指向旧路径,替换为新路径
+func migrateContentURLs(db *gorm.DB, oldKey string, newKey string) error {
+	oldPath, newPath := "/api/data/"+oldKey, "/api/data/"+newKey
+	return db.Model(&domain.Mod{}).Unscoped().
+		Where("LOCATE(?, content) > 0", oldPath).
+		UpdateColumn("content", gorm.Expr("REPLACE(content, ?, ?)", oldPath, newPath)).Error
+}
+
+// 替换访问地址中的文件路径,下载地址补充原始文件名
+func migratedURL(fileURL string, oldKey string, newKey string, fileName string) string {
+	fileURL = strings.Replace(fileURL, oldKey, newKey, 1)
+	if strings.Contains(fileURL, "/api/download/") && !strings.Contains(fileURL, "?filename=") {
+		fileURL += "?filename=" + url.QueryEscape(fileName)
+	}
+	return fileURL
+}
+
+// CopyStorage 将仍被引用的文件从一个存储后端复制到另一个,目标已存在且大小一致的文件跳过
+// 可重复执行,复制完成后修改配置中的存储后端即可切换
+func CopyStorage(db *gorm.DB, from domain.Storage, to domain.Storage) error {
+	var keys []string
+	if err := db.Model(&domain.StorageFile{}).Distinct().Pluck("file_key", &keys).Error; err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var copied, skipped, missing int
+	for _, key := range keys {
+		src, err := from.Stat(ctx, key)
+		if errors.Is(err, domain.ErrObjectNotExist) {
+			log.Printf("storage copy: missing %s", key)
+			missing++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if dst, err := to.Stat(ctx, key); err == nil && dst.Size == src.Size {
+			skipped++
+			continue
+		} else if err != nil && !errors.Is(err, domain.ErrObjectNotExist) {
+			return err
+		}
+
+		if err := copyObject(ctx, from, to, key, src.Size); err != nil {
+			return err
+		}
+		copied++
+	}
+
+	log.Printf("storage copy: copied %d, skipped %d, missing %d", copied, skipped, missing)
+	return nil
+}
+
+func copyObject(ctx context.Context, from domain.Storage, to domain.Storage, key string, size int64) error {
+	r, err := from.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return to.Put(ctx, key, r, size, contentType)
+}
@@ -0,0 +1,81 @@
+package bootstrap
+
+import "ModVerse/domain"
+
+var imagePolicy = domain.UploadPolicy{
+	MaxSize:    10 << 20,
+	Types:      []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+	Extensions: []string{".jpg", ".jpeg", ".png", ".gif", ".webp"},
+}
+
+// 默认上传限制
+var defaultUploadPolicies = map[string]domain.UploadPolicy{
+	domain.UploadModFile: {
+		MaxSize: 2 << 30,
+		// zip、tar、tar.gz逐个解压检查;7z、rar无法检查内容,识别类型后直接保存
+		Types: []string{"application/zip", "application/x-7z-compressed", "application/x-rar-compressed",
+			"application/gzip", "application/x-tar"},
+		Extensions: []string{".zip", ".jar", ".7z", ".rar", ".tar.gz", ".tgz", ".tar"},
+	},
+	domain.UploadModCover:   imagePolicy,
+	domain.UploadGameLogo:   imagePolicy,
+	domain.UploadUserAvatar: imagePolicy,
+	domain.UploadPostImage:  imagePolicy,
+}
+
+// UploadPolicy 上传类型的限制,配置文件中未填写的字段使用默认值;未知类型返回false
+func UploadPolicy(env *Env, uploadType string) (domain.UploadPolicy, bool) {
+	policy, ok := defaultUploadPolicies[uploadType]
+	if !ok {
+		return domain.UploadPolicy{}, false
+	}
+
+	custom, ok := env.Upload[uploadType]
+	if !ok {
+		return policy, true
+	}
+	if custom.MaxSize > 0 {
+		policy.MaxSize = custom.MaxSize
+	}
+	if len(custom.Types) > 0 {
+		policy.Types = custom.Types
+	}
+	if len(custom.Extensions) > 0 {
+		policy.Extensions = custom.Extensions
+	}
+
+	return policy, true
+}
+
+// 默认压缩包检查限制
+var defaultArchiveLimits = domain.ArchiveLimits{
+	MaxTotalSize:  4 << 30,
+	MaxRatio:      100,
+	MaxEntries:    20000,
+	MaxDepth:      3,
+	MaxNestedSize: 64 << 20,
+}
+
+// ArchiveLimits 压缩包检查限制,配置文件中未填写的字段使用默认值
+func ArchiveLimits(env *Env) domain.ArchiveLimits {
+	limits := defaultArchiveLimits
+	custom := env.Archive
+
+	if custom.MaxTotalSize > 0 {
+		limits.MaxTotalSize = custom.MaxTotalSize
+	}
+	if custom.MaxRatio > 0 {
+		limits.MaxRatio = custom.MaxRatio
+	}
+	if custom.MaxEntries > 0 {
+		limits.MaxEntries = custom.MaxEntries
+	}
+	if custom.MaxDepth > 0 {
+		limits.MaxDepth = custom.MaxDepth
+	}
+	if custom.MaxNestedSize > 0 {
+		limits.MaxNestedSize = custom.MaxNestedSize
+	}
+
+	return limits
+}
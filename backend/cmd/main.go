@@ -1,14 +1,20 @@
 package main
 
 import (
+	"ModVerse/api/middleware"
 	"ModVerse/api/routes"
 	"ModVerse/bootstrap"
 	"ModVerse/domain"
 	"ModVerse/internal/utils"
+	"ModVerse/repository"
+	"ModVerse/service"
 	"context"
+	"flag"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -18,10 +24,15 @@ import (
 	"github.com/gofiber/fiber/v3/middleware/etag"
 	"github.com/gofiber/fiber/v3/middleware/idempotency"
 	"github.com/gofiber/fiber/v3/middleware/static"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 func main() {
+	dedupeStorage := flag.Bool("dedupe-storage", false, "按内容哈希整理并去重已有存储文件后退出")
+	copyStorage := flag.String("copy-storage", "", "在存储后端之间复制文件后退出,格式为 源:目标,如 local:s3")
+	flag.Parse()
+
 	config := bootstrap.App()
 
 	SyncConfig := &bootstrap.SyncConfig{
@@ -42,18 +53,46 @@ func main() {
 	db := config.DB
 	redis := config.Redis
 	mail := config.Mail
+	store := config.Storage
+	scanner := config.Scanner
 	timeout := time.Duration(env.App.ContextTimeout) * time.Second
+	//分页游标签名密钥
+	utils.SetCursorSecret(env.App.TokenSecret)
+	//下载地址签名密钥与有效期
+	utils.SetDownloadSigning(bootstrap.DownloadSecret(env), bootstrap.DownloadExpiry(env))
 
 	//服务器配置
-	app := newServer()
+	app := newServer(db, redis, store, env, timeout)
 	//初始化数据库表
 	initTable(db)
+	//按内容哈希整理已有存储文件后退出
+	if *dedupeStorage {
+		if err := bootstrap.DedupeStorage(db, bootstrap.StorageRoot(env)); err != nil {
+			log.Fatal("Dedupe Storage Error:", err)
+		}
+		return
+	}
+	//在存储后端之间复制文件后退出
+	if *copyStorage != "" {
+		if err := runCopyStorage(db, env, *copyStorage); err != nil {
+			log.Fatal("Copy Storage Error:", err)
+		}
+		return
+	}
 	//初始化同步服务
 	sync := bootstrap.NewCounterSync(db, redis, SyncConfig)
 	sync.Start(context.Background())
 	defer sync.Stop()
+	//定时补扫未完成扫描的上传文件
+	if scanner != nil {
+		go runScanWorker(db, redis, store, scanner, timeout)
+	}
+	//定时清理未被引用的上传文件与存储对象
+	if interval := bootstrap.GCInterval(env); interval > 0 {
+		go runGCWorker(db, redis, store, bootstrap.GCGracePeriod(env), interval, timeout)
+	}
 	//初始化路由
-	routes.Setup(app, db, redis, mail, env, timeout)
+	routes.Setup(app, db, redis, store, scanner, config.Metadata, mail, env, timeout)
 
 	//启动协程，监听端口
 	go func() {
@@ -73,11 +112,16 @@ func main() {
 	app.Shutdown()
 }
 
-func newServer() *fiber.App {
+func newServer(db *gorm.DB, rdb *redis.Client, store domain.Storage, env *bootstrap.Env, timeout time.Duration) *fiber.App {
 	//服务器配置
 	app := fiber.New(fiber.Config{
 		StructValidator: &utils.StructValidator{Validator: validator.New()}, //使用的验证器
 		BodyLimit:       102 * 1024 * 1024,                                  //限制请求体大小
+		//部署在反向代理后时,只信任来自代理的客户端IP请求头,下载限制与计数按该IP计算
+		ProxyHeader:        env.App.ProxyHeader,
+		TrustProxy:         env.App.ProxyHeader != "",
+		TrustProxyConfig:   fiber.TrustProxyConfig{Proxies: env.App.TrustedProxies},
+		EnableIPValidation: true,
 		//自定义错误处理
 		ErrorHandler: func(c fiber.Ctx, err error) error {
 			//判断c.Status是否为200,是200改为默认错误500
@@ -91,30 +135,107 @@ func newServer() *fiber.App {
 
 	//使用服务器跨域配置
 	app.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:5175"},
-		AllowMethods:     []string{fiber.MethodGet, fiber.MethodPost, fiber.MethodPut, fiber.MethodDelete},
-		AllowHeaders:     []string{"Content-Type", "Authorization", "isRefreshToken", "cache-control", "x-requested-with"},
-		ExposeHeaders:    []string{"Content-Length", "Authorization", "RefreshToken"},
+		AllowOrigins: bootstrap.FrontendOrigins,
+		AllowMethods: []string{fiber.MethodGet, fiber.MethodPost, fiber.MethodPut, fiber.MethodDelete, fiber.MethodHead, fiber.MethodPatch},
+		AllowHeaders: []string{"Content-Type", "Authorization", "isRefreshToken", "cache-control", "x-requested-with",
+			"Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset"},
+		ExposeHeaders: []string{"Content-Length", "Authorization", "RefreshToken", "ETag", "Digest", "Repr-Digest",
+			"Location", "Warning", "X-Yank-Reason", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+			"Upload-Offset", "Upload-Length", "Upload-Metadata", "Upload-Expires", "Upload-File-Id"},
 		AllowCredentials: true,
 		MaxAge:           10800,
 	}))
 	app.Use(idempotency.New()) //使用idempotency中间件,防止网路问题多次请求
-	app.Use(etag.New())        //使用etag中间件,内容无变化不发送数据,节省带宽
-	app.Use(compress.New())    //压缩中间件,压缩传输数据,节省带宽
+	//模组文件下载由 FileServer 流式发送,自行处理ETag与分段,不经过etag与压缩中间件
+	skipDownload := func(c fiber.Ctx) bool {
+		return strings.HasPrefix(c.Path(), "/api/download/")
+	}
+	app.Use(etag.New(etag.Config{Next: skipDownload}))         //使用etag中间件,内容无变化不发送数据,节省带宽
+	app.Use(compress.New(compress.Config{Next: skipDownload})) //压缩中间件,压缩传输数据,节省带宽
 
-	//静态文件
-	app.Use("/api/data", static.New("../../storage", static.Config{
-		CacheDuration: 2 * time.Second,
-	}))
+	//模组文件只能通过限时签名地址下载,不能以静态文件访问
+	fileRepo := repository.NewStorageFileRepository(db)
+	app.Use("/api/download", middleware.SignedDownloadMiddleware("/api/download"))
+	app.Use("/api/data", middleware.ModFileGuardMiddleware(fileRepo, "/api/data", timeout))
 
-	//下载文件
-	app.Use("/api/download", static.New("../../storage", static.Config{
-		Download: true,
-	}))
+	//未扫描完成或已隔离的文件不提供下载
+	app.Use("/api/data", middleware.ScanGuardMiddleware(fileRepo, "/api/data", timeout))
+	app.Use("/api/download", middleware.ScanGuardMiddleware(fileRepo, "/api/download", timeout))
+
+	//存储文件的哈希响应头,需在静态文件中间件之前注册
+	app.Use("/api/data", middleware.FileDigestMiddleware("/api/data"))
+
+	//静态文件,非本地存储时重定向到存储后端的限时地址
+	if env.Storage.Driver != "" && env.Storage.Driver != domain.StorageLocal {
+		app.Use("/api/data", middleware.StorageRedirectMiddleware(store, fileRepo, "/api/data", storagePresignExpiry, timeout))
+	} else {
+		app.Use("/api/data", static.New(bootstrap.StorageRoot(env), static.Config{
+			CacheDuration: 2 * time.Second,
+		}))
+	}
+
+	app.Use("/api/download", middleware.DownloadNameMiddleware())
+
+	//下载文件,支持断点续传,按下载等级限速与限制并发;非本地存储同样由服务器转发,使限制对所有存储后端生效
+	app.Use("/api/download", middleware.FileServer(store, fileRepo, repository.NewRedisRepository(rdb),
+		bootstrap.DownloadTiers(env), "/api/download", timeout))
 
 	return app
 }
 
+// 存储后端限时地址的有效期
+const storagePresignExpiry = time.Hour
+
+// 补扫间隔
+const scanPendingInterval = time.Minute
+
+func runScanWorker(db *gorm.DB, rdb *redis.Client, store domain.Storage, scanner domain.Scanner, timeout time.Duration) {
+	ss := service.NewScanService(repository.NewStorageFileRepository(db), repository.NewReportRepository(db),
+		repository.NewRedisRepository(rdb), store, scanner, timeout)
+
+	ticker := time.NewTicker(scanPendingInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ss.ScanPending(context.Background()); err != nil {
+			log.Printf("scan: %v", err)
+		}
+	}
+}
+
+func runGCWorker(db *gorm.DB, rdb *redis.Client, store domain.Storage, gracePeriod time.Duration, interval time.Duration, timeout time.Duration) {
+	gs := service.NewStorageGCService(repository.NewStorageGCRepository(db), repository.NewStorageFileRepository(db),
+		repository.NewRedisRepository(rdb), store, gracePeriod, timeout)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := gs.RunGC(context.Background()); err != nil {
+			log.Printf("storage gc: %v", err)
+		}
+	}
+}
+
+// 按 源:目标 格式打开两个存储后端并复制文件
+func runCopyStorage(db *gorm.DB, env *bootstrap.Env, spec string) error {
+	fromDriver, toDriver, ok := strings.Cut(spec, ":")
+	if !ok || fromDriver == toDriver {
+		return fmt.Errorf("invalid copy-storage value: %s", spec)
+	}
+
+	from, err := bootstrap.NewStorageDriver(env, fromDriver)
+	if err != nil {
+		return err
+	}
+	to, err := bootstrap.NewStorageDriver(env, toDriver)
+	if err != nil {
+		return err
+	}
+
+	return bootstrap.CopyStorage(db, from, to)
+}
+
 func initTable(db *gorm.DB) {
 	if err := db.AutoMigrate(&domain.Game{}); err != nil {
 		panic(err)
@@ -128,31 +249,95 @@ func initTable(db *gorm.DB) {
 		panic(err)
 	}
 
+	if err := bootstrap.MigrateStorageFileKeyIndex(db); err != nil {
+		panic(err)
+	}
+
 	if err := db.AutoMigrate(&domain.StorageFile{}); err != nil {
 		panic(err)
 	}
 
+	if err := db.AutoMigrate(&domain.StorageBlob{}); err != nil {
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(&domain.StorageFileArchive{}); err != nil {
+		panic(err)
+	}
+
+	if err := bootstrap.MigrateStorageKeys(db); err != nil {
+		panic(err)
+	}
+
+	if err := bootstrap.MigrateCategorySlugs(db); err != nil {
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(&domain.Categories{}); err != nil {
+		panic(err)
+	}
+
 	if err := db.AutoMigrate(&domain.Mod{}); err != nil {
 		panic(err)
 	}
 
+	if err := bootstrap.MigrateModCategories(db); err != nil {
+		panic(err)
+	}
+
+	if err := bootstrap.MigrateCategoryTree(db); err != nil {
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(&domain.GameVersion{}); err != nil {
+		panic(err)
+	}
+
+	if err := bootstrap.MigrateDuplicateModVersions(db); err != nil {
+		panic(err)
+	}
+
 	if err := db.AutoMigrate(&domain.ModVersion{}); err != nil {
 		panic(err)
 	}
 
-	if err := db.AutoMigrate(&domain.Comment{}); err != nil {
+	if err := db.AutoMigrate(&domain.ModVersionFile{}); err != nil {
 		panic(err)
 	}
 
-	if err := db.AutoMigrate(&domain.ModFavorite{}); err != nil {
+	if err := bootstrap.MigrateModVersionFiles(db); err != nil {
 		panic(err)
 	}
 
-	if err := db.AutoMigrate(&domain.ModLike{}); err != nil {
+	if err := bootstrap.MigrateStorageUploadTypes(db); err != nil {
 		panic(err)
 	}
 
-	if err := db.AutoMigrate(&domain.Categories{}); err != nil {
+	if err := db.AutoMigrate(&domain.ModVersionYankLog{}); err != nil {
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(&domain.CompatibilityReport{}); err != nil {
+		panic(err)
+	}
+
+	if err := bootstrap.MigrateDeletedGameVersions(db); err != nil {
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(&domain.ModDependency{}); err != nil {
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(&domain.Comment{}); err != nil {
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(&domain.ModFavorite{}); err != nil {
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(&domain.ModLike{}); err != nil {
 		panic(err)
 	}
 
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// 可检查的压缩包格式
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+var ErrArchiveRejected = errors.New("archive rejected")
+
+// ArchiveLimits 压缩包检查限制,超出任一限制的压缩包拒绝上传
+type ArchiveLimits struct {
+	MaxTotalSize  int64   // 解压后总字节数,含嵌套压缩包的内容
+	MaxRatio      float64 // 解压后大小与压缩后大小之比
+	MaxEntries    int     // 文件数,含嵌套压缩包内的文件
+	MaxDepth      int     // 嵌套压缩包层数,最外层为0
+	MaxNestedSize int64   // 单个嵌套压缩包的字节数,检查时需读入内存
+}
+
+// ArchiveEntry 压缩包内的文件,嵌套压缩包内的文件路径以"!/"连接,如 META-INF/jars/lib.jar!/fabric.mod.json
+type ArchiveEntry struct {
+	Path           string `json:"path"`
+	Size           int64  `json:"size"`
+	CompressedSize int64  `json:"compressed_size,omitempty"`
+}
+
+// StorageFileArchive 上传时检查压缩包得到的文件清单,不支持检查的格式没有该记录
+type StorageFileArchive struct {
+	ID            uint           `gorm:"primarykey" json:"-"`
+	StorageFileID uint           `gorm:"uniqueIndex;not null;comment:文件ID" json:"-"`
+	Format        string         `gorm:"size:16;not null;comment:压缩格式" json:"format"`
+	EntryCount    int            `gorm:"comment:文件数" json:"entry_count"`
+	TotalSize     int64          `gorm:"comment:解压后字节数" json:"total_size"`
+	Ratio         float64        `gorm:"comment:压缩比" json:"ratio"`
+	Depth         int            `gorm:"comment:嵌套层数" json:"depth"`
+	Entries       []ArchiveEntry `gorm:"type:json;serializer:json;comment:文件清单" json:"entries"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// 模组版本文件的内容清单,Archive为空表示该文件未经检查
+type ModVersionContents struct {
+	FileID      uint                `json:"file_id"`
+	FileName    string              `json:"file_name"`
+	Role        string              `json:"role"`
+	DisplayName string              `json:"display_name"`
+	Archive     *StorageFileArchive `json:"archive"`
+}
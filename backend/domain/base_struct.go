@@ -18,4 +18,21 @@ type Paging struct {
 	PageSize int    `query:"page_size"`
 	Sort     string `query:"sort"`
 	Order    string `query:"order"`
+	Cursor   string `query:"cursor"` // 上一页返回的next_cursor,指定后忽略page
+	Total    string `query:"total"`  // exact/none,是否统计总数
+}
+
+// 未统计总数时返回的total
+const TotalUnknown int64 = -1
+
+// 整数区间(闭区间,nil表示不限)
+type IntRange struct {
+	Min *int64
+	Max *int64
+}
+
+// 时间区间(左闭右开,nil表示不限)
+type TimeRange struct {
+	From *time.Time
+	To   *time.Time
 }
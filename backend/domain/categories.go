@@ -2,38 +2,109 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// 分类最大层级,根分类为第1层
+const CategoryMaxDepth = 3
+
+var ErrCategoryNotForGame = errors.New("category is not available for this game")
+
+// 分类(标签)表,模组通过 mod_categories 关联多个分类
+// 别名分类的AliasOfID指向规范分类,按名称/slug查找时会解析到规范分类
+// 分类可嵌套,Path为从根到自身的ID路径(如 /1/5/),用于查询子树
+// 未关联游戏的分类对所有游戏可见
 type Categories struct {
 	gorm.Model
-	Name   string `gorm:"index;size:128;not null" json:"name"`
-	Status string `gorm:"default:'enable';index;size:32;not null" json:"status"`
+	Name      string `gorm:"index;size:128;not null" json:"name"`
+	Slug      string `gorm:"uniqueIndex;size:128;not null;comment:固定标识" json:"slug"`
+	Status    string `gorm:"default:'enable';index;size:32;not null" json:"status"`
+	AliasOfID uint   `gorm:"index;default:0;comment:别名指向的分类ID" json:"alias_of_id"`
+	ParentID  uint   `gorm:"index;default:0;comment:父分类ID" json:"parent_id"`
+	Path      string `gorm:"index;size:255;not null;default:'';comment:ID路径" json:"-"`
+	Depth     int    `gorm:"default:1;not null;comment:层级" json:"depth"`
+	SortOrder int    `gorm:"default:0;not null;comment:同级排序" json:"sort_order"`
+	Games     []Game `gorm:"many2many:category_games" json:"-"`
 }
 
 type CreateCategoriesRequest struct {
-	Name   string `json:"name" validate:"required"`
-	Status string `json:"status"`
+	Name      string `json:"name" validate:"required"`
+	Slug      string `json:"slug"`
+	Status    string `json:"status"`
+	ParentID  uint   `json:"parent_id"`
+	SortOrder int    `json:"sort_order"`
+	GameIDs   []uint `json:"game_ids"`
 }
 
 type UpdateCategoriesRequest struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	GameIDs []uint `json:"game_ids"` // 不传则不修改,传空数组表示对所有游戏可见
+}
+
+// 移动分类及其子树
+type MoveCategoryRequest struct {
+	ParentID  uint `json:"parent_id"` // 0 表示移动到根
+	SortOrder int  `json:"sort_order"`
+}
+
+type MergeCategoriesRequest struct {
+	TargetID uint `json:"target_id" validate:"required"`
+}
+
+type CreateCategoryAliasRequest struct {
+	Name string `json:"name" validate:"required"`
+	Slug string `json:"slug"`
 }
 
 type CategoriesResponse struct {
 	ID        uint      `json:"id"`
 	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
 	Status    string    `json:"status"`
+	AliasOfID uint      `json:"alias_of_id,omitempty"`
+	ParentID  uint      `json:"parent_id"`
+	Depth     int       `json:"depth"`
+	SortOrder int       `json:"sort_order"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// 分类树节点,ModCount为直接归入该分类的模组数,TotalModCount为含子分类的去重模组数
+type CategoryTreeNode struct {
+	ID            uint                `json:"id"`
+	Name          string              `json:"name"`
+	Slug          string              `json:"slug"`
+	Status        string              `json:"status"`
+	ParentID      uint                `json:"parent_id"`
+	Path          string              `json:"-"`
+	Depth         int                 `json:"depth"`
+	SortOrder     int                 `json:"sort_order"`
+	GameIDs       []uint              `gorm:"-" json:"game_ids"`
+	ModCount      int64               `gorm:"-" json:"mod_count"`
+	TotalModCount int64               `gorm:"-" json:"total_mod_count"`
+	Children      []*CategoryTreeNode `gorm:"-" json:"children"`
+}
+
+type CategoryTreeQuery struct {
+	GameID uint `query:"game_id"`
+}
+
+// 模组上展示的分类
+type ModCategoryResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
 type CategoriesQuery struct {
 	Paging
-	Name   string `json:"name"`
-	Status string `json:"status"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	WithAlias bool   `query:"with_alias"` // 是否包含别名
+	GameID    uint   `query:"game_id"`    // 仅返回该游戏可用的分类
 }
 
 type CategoriesRepository interface {
@@ -41,12 +112,24 @@ type CategoriesRepository interface {
 	GetCategoriesByID(c context.Context, id string) (*CategoriesResponse, error)
 	AddCategories(c context.Context, ca *Categories) error
 	DeleteCategories(c context.Context, id string) error
-	UpdateCategories(c context.Context, ca *Categories) error
+	// UpdateCategories 更新分类,gameIDs不为nil时在同一事务中替换关联的游戏
+	UpdateCategories(c context.Context, ca *Categories, gameIDs []uint) error
+	SlugExists(c context.Context, slug string) (bool, error)
+	ResolveCategories(c context.Context, ids []uint, names []string) (*[]Categories, error)
+	MergeCategories(c context.Context, sourceID uint, targetID uint) error
+	// CheckCategoriesForGame 检查分类及其各级父分类均对该游戏可见,否则返回 ErrCategoryNotForGame
+	CheckCategoriesForGame(c context.Context, categories []Categories, gameID uint) error
+	GetCategoryTree(c context.Context, gameID uint) (*[]CategoryTreeNode, error)
+	MoveCategory(c context.Context, id uint, parentID uint, sortOrder int) error
 }
 
 type CategoriesService interface {
 	GetCategories(c context.Context, params *CategoriesQuery) (*[]CategoriesResponse, int64, error)
 	AddCategories(c context.Context, ca *Categories) error
 	DeleteCategories(c context.Context, id string) error
-	UpdateCategories(c context.Context, ca *Categories) error
+	UpdateCategories(c context.Context, ca *Categories, gameIDs []uint) error
+	MergeCategories(c context.Context, sourceID string, targetID uint) error
+	AddAlias(c context.Context, id string, alias *Categories) error
+	GetCategoryTree(c context.Context, params *CategoryTreeQuery) ([]*CategoryTreeNode, error)
+	MoveCategory(c context.Context, id string, req *MoveCategoryRequest) error
 }
@@ -43,7 +43,7 @@ type CommentRepository interface {
 	CreateComment(c context.Context, comment *Comment) error
 	GetCommentsWithReplies(c context.Context, modID string) (*[]CommentResponse, int64, error)
 	DeleteComment(c context.Context, id string) error
-	GetAllComments(c context.Context, params CommentQuery) (*[]CommentResponse, int64, error)
+	GetAllComments(c context.Context, params CommentQuery) (*[]CommentResponse, int64, string, error)
 	GetCommentByID(c context.Context, id string) (*CommentResponse, error)
 }
 
@@ -51,6 +51,6 @@ type CommentService interface {
 	CreateComment(c context.Context, comment *Comment) error
 	GetCommentsWithReplies(c context.Context, modID string) (*[]CommentResponse, int64, error)
 	DeleteComment(c context.Context, id string) error
-	GetAllComments(c context.Context, params CommentQuery) (*[]CommentResponse, int64, error)
+	GetAllComments(c context.Context, params CommentQuery) (*[]CommentResponse, int64, string, error)
 	GetCommentByID(c context.Context, id string) (*CommentResponse, error)
 }
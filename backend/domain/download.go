@@ -0,0 +1,29 @@
+package domain
+
+import "errors"
+
+var ErrDownloadSignature = errors.New("download link is invalid or has expired")
+
+var ErrHotlinkDenied = errors.New("downloads must be started from an allowed site")
+
+var ErrTooManyDownloads = errors.New("too many concurrent downloads, try again later")
+
+// 下载限速等级,生成签名地址时按下载者确定,随签名地址下发
+const (
+	DownloadTierAnonymous = "anonymous" // 未登录,按IP限制
+	DownloadTierUser      = "user"      // 登录用户,按用户限制
+	DownloadTierAuthor    = "author"    // 发布过模组的用户
+)
+
+// DownloadTier 下载限速等级的配置,均为0时不限制
+type DownloadTier struct {
+	Rate        int64 // 同一下载者所有下载合计的速度上限(字节/秒)
+	Concurrency int   // 同时进行的下载数上限
+}
+
+// DownloadGrant 签名地址中的下载身份,UserID为0表示匿名下载
+type DownloadGrant struct {
+	Tier   string
+	UserID uint64
+	Yanked bool // 下载的版本已撤回,文件响应中给出警告
+}
@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GameVersion 游戏版本(补丁或正式发布),同一游戏内名称唯一
+type GameVersion struct {
+	gorm.Model
+	GameID      uint      `gorm:"uniqueIndex:idx_game_version_name;not null;comment:游戏ID" json:"game_id"`
+	Name        string    `gorm:"uniqueIndex:idx_game_version_name;size:64;not null;comment:版本名称" json:"name"`
+	ReleaseDate time.Time `gorm:"index;comment:发布日期" json:"release_date"`
+}
+
+// CompatibilityReport 用户提交的兼容性反馈,每个用户对同一组合只保留最新一条
+type CompatibilityReport struct {
+	gorm.Model
+	ModVersionID  uint `gorm:"uniqueIndex:idx_compat_report;not null;comment:模组版本ID" json:"mod_version_id"`
+	GameVersionID uint `gorm:"uniqueIndex:idx_compat_report;not null;comment:游戏版本ID" json:"game_version_id"`
+	UserID        uint `gorm:"uniqueIndex:idx_compat_report;not null;comment:用户ID" json:"user_id"`
+	Works         bool `gorm:"not null;comment:是否可用" json:"works"`
+}
+
+type CreateGameVersionRequest struct {
+	Name        string    `json:"name" validate:"required"`
+	ReleaseDate time.Time `json:"release_date"`
+}
+
+type SetGameVersionsRequest struct {
+	GameVersionIDs []uint `json:"game_version_ids"`
+}
+
+type CompatibilityReportRequest struct {
+	GameVersionID uint `json:"game_version_id" validate:"required"`
+	Works         bool `json:"works"`
+}
+
+type GameVersionResponse struct {
+	ID          uint      `json:"id"`
+	GameID      uint      `json:"-"`
+	Name        string    `json:"name"`
+	ReleaseDate time.Time `json:"release_date"`
+}
+
+// 兼容性矩阵单元格: Declared为作者声明支持,Works/Broken为用户反馈计数
+type CompatibilityCell struct {
+	GameVersionID uint  `json:"game_version_id"`
+	Declared      bool  `json:"declared"`
+	Works         int64 `json:"works"`
+	Broken        int64 `json:"broken"`
+}
+
+type CompatibilityRow struct {
+	ModVersionID uint                `json:"mod_version_id"`
+	Version      string              `json:"version"`
+	Cells        []CompatibilityCell `json:"cells"`
+}
+
+// 模组兼容性矩阵,行为模组版本(从新到旧),列为游戏版本(从新到旧)
+type CompatibilityMatrix struct {
+	GameVersions []GameVersionResponse `json:"game_versions"`
+	Rows         []CompatibilityRow    `json:"rows"`
+}
+
+// 兼容性统计原始数据
+type CompatibilityPair struct {
+	ModVersionID  uint
+	GameVersionID uint
+	Declared      bool
+	Works         int64
+	Broken        int64
+}
+
+type GameVersionRepository interface {
+	CreateGameVersion(c context.Context, gv *GameVersion) error
+	GetGameVersions(c context.Context, gameID uint) (*[]GameVersionResponse, error)
+	DeleteGameVersion(c context.Context, id string) error
+	SetModVersionGameVersions(c context.Context, modVersionID uint, gameVersionIDs []uint) error
+	GetCompatibilityPairs(c context.Context, modID uint) (*[]CompatibilityPair, error)
+	GetModGameID(c context.Context, modID uint) (uint, error)
+	SaveCompatibilityReport(c context.Context, report *CompatibilityReport) error
+}
+
+type GameVersionService interface {
+	CreateGameVersion(c context.Context, gameID string, gv *GameVersion) error
+	GetGameVersions(c context.Context, gameID string) (*[]GameVersionResponse, error)
+	DeleteGameVersion(c context.Context, id string) error
+	// CheckVersionOwner 同 ModVersionService.CheckVersionOwner
+	CheckVersionOwner(c context.Context, id string, userID string, role string) error
+	SetModVersionGameVersions(c context.Context, modVersionID string, gameVersionIDs []uint) error
+	GetCompatibilityMatrix(c context.Context, modID string) (*CompatibilityMatrix, error)
+	ReportCompatibility(c context.Context, modVersionID string, userID string, req *CompatibilityReportRequest) error
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 存储清理列出整个存储(含旧版本的上传路径),跳过以下目录:
+// 隔离的文件留给管理员处理,tmp为分片上传的临时目录,由其自身的清理任务处理
+var GCExcludedPrefixes = []string{QuarantinePrefix, "tmp/"}
+
+var ErrGCRunning = errors.New("storage gc is already running")
+
+// GCFile 清理或对账发现的文件记录,FileKey为相关的文件路径(可能是图片版本的路径)
+type GCFile struct {
+	ID        uint      `json:"id"`
+	UserID    uint64    `json:"user_id"`
+	FileKey   string    `json:"file_key"`
+	FileName  string    `json:"file_name"`
+	FileSize  int64     `json:"file_size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GCObject 清理或对账发现的存储对象
+type GCObject struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// GCReport 存储清理报告,状态值与文件校验相同,任务运行中会持续更新
+// DryRun为true时只列出将被清理的内容,不做任何删除
+type GCReport struct {
+	Status     string     `json:"status"`
+	DryRun     bool       `json:"dry_run"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// 超过保留期仍未被任何记录引用的临时上传
+	TempFiles []GCFile `json:"temp_files"`
+	// 没有文件记录使用的blob
+	Blobs []GCObject `json:"blobs"`
+	// 存储中存在但没有任何记录的对象
+	StrayObjects []GCObject `json:"stray_objects"`
+	// 记录存在但存储中已没有的文件,只报告不处理
+	MissingObjects []GCFile `json:"missing_objects"`
+	FreedBytes     int64    `json:"freed_bytes"` // 按记录与对象的大小合计,DryRun时为预计释放的字节数
+	Error          string   `json:"error,omitempty"`
+}
+
+type StorageGCRepository interface {
+	// GetExpiredTempFiles 按ID顺序分批读取before之前上传、仍未被引用的临时文件
+	GetExpiredTempFiles(c context.Context, before time.Time, afterID uint, limit int) (*[]StorageFile, error)
+	// ReleaseTempFile 确认文件仍未被引用后删除记录,返回引用归零、需要从存储删除的文件路径
+	ReleaseTempFile(c context.Context, id uint) ([]string, error)
+	GetBlobsAfter(c context.Context, afterID uint, limit int) (*[]StorageBlob, error)
+	// DeleteUnreferencedBlob 确认没有文件记录使用后删除blob记录,返回是否已删除
+	DeleteUnreferencedBlob(c context.Context, key string) (bool, error)
+	// IsKeyReferenced 是否有blob或文件记录(含图片版本)使用该路径
+	IsKeyReferenced(c context.Context, key string) (bool, error)
+}
+
+type StorageGCService interface {
+	StartGC(c context.Context, dryRun bool) error
+	RunGC(c context.Context) error
+	GetGCReport(c context.Context) (*GCReport, error)
+}
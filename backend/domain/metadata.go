@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"context"
+	"io/fs"
+)
+
+// ModManifest 从压缩包内的清单文件(如 fabric.mod.json、mods.toml)提取的模组信息
+// 版本范围均转换为与依赖声明相同的npm语法,无法转换时保留原文
+type ModManifest struct {
+	GameID           uint                 `json:"game_id"`
+	Extractor        string               `json:"extractor"`
+	Source           string               `json:"source"` // 清单文件在压缩包内的路径
+	ModID            string               `json:"mod_id,omitempty"`
+	Name             string               `json:"name,omitempty"`
+	Version          string               `json:"version,omitempty"`
+	Description      string               `json:"description,omitempty"`
+	Authors          []string             `json:"authors,omitempty"`
+	GameVersionRange string               `json:"game_version_range,omitempty"`
+	Dependencies     []ManifestDependency `json:"dependencies,omitempty"`
+}
+
+// ManifestDependency 清单声明的依赖,ModID为清单中的标识,与站内模组按名称匹配
+type ManifestDependency struct {
+	ModID        string `json:"mod_id"`
+	VersionRange string `json:"version_range"`
+	Type         string `json:"type"` // required/optional/incompatible
+}
+
+// ManifestMismatch 作者填写的内容与清单不一致之处,仅作提示,不阻止发布
+type ManifestMismatch struct {
+	Field    string `json:"field"` // name/version/game_versions/dependencies
+	Manifest string `json:"manifest"`
+	Entered  string `json:"entered"`
+}
+
+// ManifestPrefill 按清单预填的发布信息,依赖与游戏版本已匹配为站内ID
+type ManifestPrefill struct {
+	Manifest       *ModManifest         `json:"manifest"`
+	Name           string               `json:"name"`
+	Version        string               `json:"version"`
+	GameVersionIDs []uint               `json:"game_version_ids"`
+	Dependencies   []DependencyRequest  `json:"dependencies"`
+	Unmatched      []ManifestDependency `json:"unmatched"` // 站内找不到对应模组的依赖
+}
+
+// MetadataExtractor 某类清单的提取器,压缩包内没有对应清单时返回nil
+type MetadataExtractor interface {
+	Name() string
+	Extract(fsys fs.FS) (*ModManifest, error)
+}
+
+// MetadataRegistry 按游戏ID注册的提取器,同一游戏的提取器按注册顺序尝试,取第一个结果
+type MetadataRegistry interface {
+	Register(gameID uint, extractors ...MetadataExtractor)
+	Extract(gameID uint, path string, format string) (*ModManifest, error)
+}
+
+type ManifestRepository interface {
+	GetFileManifests(c context.Context, fileIDs []uint) ([]ModManifest, error)
+	// MatchModNames 按忽略大小写与符号的名称匹配游戏下的模组,返回名称到模组ID的映射
+	MatchModNames(c context.Context, gameID uint, names []string) (map[string]uint, error)
+}
@@ -13,7 +13,7 @@ type Mod struct {
 	Name           string       `gorm:"index;size:128;not null;comment:模组名称" json:"name"`
 	Description    string       `gorm:"type:text;not null;comment:模组描述" json:"description"`
 	Content        string       `gorm:"type:json;not null;comment:模组内容" json:"content"`
-	Category       string       `gorm:"size:64;not null;comment:分类" json:"category"`
+	Categories     []Categories `gorm:"many2many:mod_categories" json:"categories"`
 	CoverID        uint         `gorm:"not null;comment:封面文件ID" json:"-"`
 	CoverFile      StorageFile  `json:"cover_file" gorm:"foreignKey:CoverID"`
 	UserID         uint64       `gorm:"index;not null;comment:上传者ID" json:"-"`
@@ -29,78 +29,123 @@ type Mod struct {
 }
 
 type CreateModRequest struct {
-	Name        string `json:"name" validate:"required"`
-	Description string `json:"description" validate:"required"`
-	Content     string `json:"content" validate:"required"`
-	Category    string `json:"category" validate:"required"`
-	CoverID     uint   `json:"cover_id" validate:"required"`
-	GameID      uint   `json:"game_id" validate:"required"`
-	FileID      uint   `json:"file_id" validate:"required"`
-	Version     string `json:"version" validate:"required"`
+	Name        string                  `json:"name" validate:"required"`
+	Description string                  `json:"description" validate:"required"`
+	Content     string                  `json:"content" validate:"required"`
+	CategoryIDs []uint                  `json:"category_ids"`
+	Category    string                  `json:"category"` // 兼容旧客户端,按名称或slug匹配分类
+	CoverID     uint                    `json:"cover_id" validate:"required"`
+	GameID      uint                    `json:"game_id" validate:"required"`
+	Files       []ModVersionFileRequest `json:"files" validate:"dive"` // 首个版本的文件
+	FileID      uint                    `json:"file_id"`               // 兼容旧客户端,未提供files时作为主文件
+	Version     string                  `json:"version" validate:"required"`
+
+	LenientVersion bool `json:"lenient_version"` // 接受历史版本号格式
 }
 
 type UpdateModRequest struct {
 	Description string `json:"description"`
 	Content     string `json:"content"`
 	Status      string `json:"status"`
+	CategoryIDs []uint `json:"category_ids"` // 为空时不修改分类
 }
 
 type ModResponse struct {
-	ID             uint                `json:"id"`
-	Name           string              `json:"name"`
-	Description    string              `json:"description"`
-	Category       string              `json:"category"`
-	UserID         uint64              `json:"-"`
-	User           UserResponse        `gorm:"foreignKey:UserID" json:"user"`
-	GameID         uint                `json:"-"`
-	Game           GameResponse        `gorm:"foreignKey:GameID" json:"game"`
-	CoverID        uint                `json:"-"`
-	CoverFile      StorageFileResponse `gorm:"foreignKey:CoverID" json:"cover_file"`
-	Likes          uint                `json:"likes"`
-	TotalDownloads uint                `json:"total_downloads"`
-	Status         string              `json:"status"`
-	Content        string              `json:"content"`
-	LastUpdate     time.Time           `json:"last_update"`
-	CreatedAt      time.Time           `json:"created_at"`
+	ID             uint                  `json:"id"`
+	Name           string                `json:"name"`
+	Description    string                `json:"description"`
+	Categories     []ModCategoryResponse `gorm:"many2many:mod_categories;joinForeignKey:ModID;joinReferences:CategoriesID" json:"categories"`
+	UserID         uint64                `json:"-"`
+	User           UserResponse          `gorm:"foreignKey:UserID" json:"user"`
+	GameID         uint                  `json:"-"`
+	Game           GameResponse          `gorm:"foreignKey:GameID" json:"game"`
+	CoverID        uint                  `json:"-"`
+	CoverFile      StorageFileResponse   `gorm:"foreignKey:CoverID" json:"cover_file"`
+	Likes          uint                  `json:"likes"`
+	TotalDownloads uint                  `json:"total_downloads"`
+	Status         string                `json:"status"`
+	Content        string                `json:"content"`
+	LastUpdate     time.Time             `json:"last_update"`
+	CreatedAt      time.Time             `json:"created_at"`
 }
 
 type ModResponseWithUser struct {
-	ID             uint                `json:"id"`
-	Name           string              `json:"name"`
-	Description    string              `json:"description"`
-	Category       string              `json:"category"`
-	UserID         uint64              `json:"-"`
-	GameID         uint                `json:"-"`
-	Game           GameResponse        `gorm:"foreignKey:GameID" json:"game"`
-	CoverID        uint                `json:"cover_id"`
-	CoverFile      StorageFileResponse `gorm:"foreignKey:CoverID" json:"cover_file"`
-	TotalDownloads uint                `json:"total_downloads"`
-	Likes          uint                `json:"likes"`
-	LastUpdate     time.Time           `json:"last_update"`
+	ID             uint                  `json:"id"`
+	Name           string                `json:"name"`
+	Description    string                `json:"description"`
+	Categories     []ModCategoryResponse `gorm:"many2many:mod_categories;joinForeignKey:ModID;joinReferences:CategoriesID" json:"categories"`
+	UserID         uint64                `json:"-"`
+	GameID         uint                  `json:"-"`
+	Game           GameResponse          `gorm:"foreignKey:GameID" json:"game"`
+	CoverID        uint                  `json:"cover_id"`
+	CoverFile      StorageFileResponse   `gorm:"foreignKey:CoverID" json:"cover_file"`
+	TotalDownloads uint                  `json:"total_downloads"`
+	Likes          uint                  `json:"likes"`
+	LastUpdate     time.Time             `json:"last_update"`
 }
 
 type ModQuery struct {
 	Paging
+	Q        string   `json:"q" query:"q"`      // 结构化查询语句,见 utils.ParseModSearch
+	Facets   bool     `json:"-" query:"facets"` // 是否返回分面统计
 	Name     string   `json:"name"`
-	Category []string `json:"categories"`
+	Category []string `json:"categories"` // 分类名称或slug,命中任一即可
 	GameID   uint     `json:"game_id"`
 	UserID   string   `json:"user_id"`
 	UserName string   `json:"user_name"`
 	Status   string   `json:"status"`
+
+	GameVersion string `json:"game_version" query:"game_version"` // 有版本支持该游戏版本(名称或ID)
+
+	// 以下条件由查询语句解析得到
+	Keywords         []string  `json:"-" query:"-"` // 名称或描述需包含的关键词
+	ExcludeKeywords  []string  `json:"-" query:"-"` // 名称/描述/分类不得命中的关键词
+	GameName         string    `json:"-" query:"-"`
+	ExcludeGames     []string  `json:"-" query:"-"`
+	ExcludeUserNames []string  `json:"-" query:"-"`
+	ExcludeCategory  []string  `json:"-" query:"-"`
+	ExcludeStatus    []string  `json:"-" query:"-"`
+	Downloads        IntRange  `json:"-" query:"-"`
+	Likes            IntRange  `json:"-" query:"-"`
+	Updated          TimeRange `json:"-" query:"-"`
+	Created          TimeRange `json:"-" query:"-"`
+}
+
+// 分面统计
+const (
+	FacetGame     = "game"
+	FacetCategory = "category"
+	FacetStatus   = "status"
+	FacetLimit    = 50 // 每个分面最多返回的取值数
+)
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int64  `json:"count"`
+}
+
+type ModFacets struct {
+	Game     []FacetCount `json:"game"`
+	Category []FacetCount `json:"category"`
+	Status   []FacetCount `json:"status"`
 }
 
 type ModRepository interface {
 	CreateMod(c context.Context, mod *Mod, modVersion *ModVersion) error
-	DeleteMod(c context.Context, id uint) error
-	UpdateMod(c context.Context, mod *Mod) error
+	DeleteMod(c context.Context, id uint) ([]string, error)
+	// UpdateMod 更新模组,categories不为nil时在同一事务中替换模组的分类
+	UpdateMod(c context.Context, mod *Mod, categories []Categories) error
 	GetMod(c context.Context, id string) (*Mod, error)
-	GetMods(c context.Context, params *ModQuery) (*[]ModResponse, int64, error)
+	GetMods(c context.Context, params *ModQuery) (*[]ModResponse, int64, string, error)
+	GetModFacets(c context.Context, params *ModQuery) (*ModFacets, error)
 }
 
 type ModService interface {
-	CreateMod(c context.Context, mod *Mod, modVersion *ModVersion, userID string) error
-	UpdateMod(c context.Context, mod *Mod) error
+	CreateMod(c context.Context, mod *Mod, modVersion *ModVersion, files []ModVersionFileRequest, categoryIDs []uint, categoryNames []string) error
+	UpdateMod(c context.Context, mod *Mod, categoryIDs []uint) error
 	GetMod(c context.Context, id string) (*Mod, error)
-	GetMods(c context.Context, params *ModQuery) (*[]ModResponse, int64, error)
+	GetMods(c context.Context, params *ModQuery) (*[]ModResponse, int64, string, error)
+	GetModFacets(c context.Context, params *ModQuery) (*ModFacets, error)
 	DeleteMod(c context.Context, id string) error
 }
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 依赖类型
+const (
+	DependencyRequired     = "required"     // 必需
+	DependencyOptional     = "optional"     // 可选
+	DependencyIncompatible = "incompatible" // 不兼容
+)
+
+// ModDependency 模组版本声明的依赖,VersionRange为被依赖模组的语义化版本范围
+type ModDependency struct {
+	gorm.Model
+	ModVersionID   uint   `gorm:"index;not null;comment:声明依赖的版本ID" json:"mod_version_id"`
+	DependsOnModID uint   `gorm:"index;not null;comment:被依赖的模组ID" json:"depends_on_mod_id"`
+	VersionRange   string `gorm:"size:128;not null;default:'*';comment:版本范围" json:"version_range"`
+	Type           string `gorm:"size:16;index;not null;default:'required';comment:依赖类型" json:"type"`
+}
+
+type DependencyRequest struct {
+	ModID        uint   `json:"mod_id" validate:"required"`
+	VersionRange string `json:"version_range"`
+	Type         string `json:"type"`
+}
+
+type SetDependenciesRequest struct {
+	Dependencies []DependencyRequest `json:"dependencies"`
+}
+
+type ModDependencyResponse struct {
+	ID             uint   `json:"id"`
+	ModVersionID   uint   `json:"-"`
+	DependsOnModID uint   `json:"mod_id"`
+	ModName        string `json:"mod_name"`
+	VersionRange   string `json:"version_range"`
+	Type           string `json:"type"`
+}
+
+// 依赖解析用的版本信息
+type VersionNode struct {
+	ID        uint   `json:"version_id"`
+	ModID     uint   `json:"mod_id"`
+	ModName   string `json:"mod_name"`
+	ModStatus string `json:"-"`
+	Version   string `json:"version"`
+	Yanked    bool   `json:"-"`
+}
+
+// 解析结果中选中的版本,RequiredBy为要求该模组的版本ID
+type ResolvedDependency struct {
+	VersionNode
+	Optional   bool   `json:"optional"`
+	Depth      int    `json:"depth"`
+	RequiredBy []uint `json:"required_by"`
+}
+
+// 依赖冲突: 约束无法同时满足、被标记不兼容或找不到可用版本
+type DependencyConflict struct {
+	ModID        uint   `json:"mod_id"`
+	ModName      string `json:"mod_name"`
+	Type         string `json:"type"` // unsatisfiable / incompatible / missing
+	VersionRange string `json:"version_range"`
+	RequiredBy   uint   `json:"required_by"`
+	Selected     string `json:"selected,omitempty"`
+}
+
+// 依赖冲突类型
+const (
+	ConflictUnsatisfiable = "unsatisfiable"
+	ConflictIncompatible  = "incompatible"
+	ConflictMissing       = "missing"
+)
+
+type DependencyResolution struct {
+	Root      VersionNode          `json:"root"`
+	Resolved  []ResolvedDependency `json:"resolved"`
+	Conflicts []DependencyConflict `json:"conflicts"`
+	Cycles    [][]uint             `json:"cycles"` // 每个环为模组ID序列,首尾相同
+	OK        bool                 `json:"ok"`
+}
+
+// 依赖某模组的版本
+type DependentResponse struct {
+	ModID        uint   `json:"mod_id"`
+	ModName      string `json:"mod_name"`
+	ModVersionID uint   `json:"mod_version_id"`
+	Version      string `json:"version"`
+	VersionRange string `json:"version_range"`
+	Type         string `json:"type"`
+}
+
+type ModDependencyRepository interface {
+	GetDependencies(c context.Context, versionIDs []uint) (*[]ModDependencyResponse, error)
+	SetDependencies(c context.Context, versionID uint, deps []ModDependency) error
+	GetVersionNode(c context.Context, versionID uint) (*VersionNode, error)
+	GetModVersionNodes(c context.Context, modIDs []uint) (*[]VersionNode, error)
+	GetDependents(c context.Context, modID uint, types []string) (*[]DependentResponse, error)
+	CountMods(c context.Context, modIDs []uint) (int64, error)
+}
+
+// 删除或撤回版本时,仍有其他模组必需该版本
+type VersionRequiredError struct {
+	Dependents []DependentResponse
+}
+
+func (e *VersionRequiredError) Error() string {
+	return fmt.Sprintf("version is required by %d other mod version(s), use force to override", len(e.Dependents))
+}
@@ -19,6 +19,7 @@ type CreateModFavoriteRequest struct {
 }
 
 type ModFavoriteResponse struct {
+	ID        uint                `json:"-"`
 	CreatedAt time.Time           `json:"created_at"`
 	ModID     uint                `json:"-"`
 	Mod       ModResponseWithUser `gorm:"foreignKey:ModID;" json:"mod"`
@@ -32,14 +33,14 @@ type ModFavoriteQuery struct {
 
 type ModFavoriteRepository interface {
 	CreateModFavorite(c context.Context, modFavorite *ModFavorite) error
-	GetModFavorites(c context.Context, userID string, params *ModFavoriteQuery) (*[]ModFavoriteResponse, int64, error)
+	GetModFavorites(c context.Context, userID string, params *ModFavoriteQuery) (*[]ModFavoriteResponse, int64, string, error)
 	CheckIsFavorite(c context.Context, userID string, modID string) (bool, error)
 	DeleteModFavorite(c context.Context, userID string, modID string) error
 }
 
 type ModFavoriteService interface {
 	CreateModFavorite(c context.Context, modFavorite *ModFavorite) error
-	GetModFavorites(c context.Context, userID string, params *ModFavoriteQuery) (*[]ModFavoriteResponse, int64, error)
+	GetModFavorites(c context.Context, userID string, params *ModFavoriteQuery) (*[]ModFavoriteResponse, int64, string, error)
 	CheckIsFavorite(c context.Context, userID string, modID string) (bool, error)
 	DeleteModFavorite(c context.Context, userID string, modID string) error
 }
@@ -2,50 +2,247 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// 发布渠道,稳定性从高到低
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+	ChannelAlpha  = "alpha"
+	ChannelAll    = "all" // 仅用于查询
+)
+
+var ErrUnknownChannel = errors.New("unknown release channel")
+
+var ErrTooManyUpdateItems = errors.New("too many items in one update check")
+
+var ErrNotVersionOwner = errors.New("only the mod author or an admin can modify this version")
+
+var (
+	ErrVersionYanked     = errors.New("version is already yanked")
+	ErrVersionNotYanked  = errors.New("version is not yanked")
+	ErrYankReasonTooLong = errors.New("reason is too long")
+)
+
+// MaxYankReasonLength 撤回/恢复原因的最大字节数,与 yank_reason 列一致
+const MaxYankReasonLength = 255
+
+// YankWarning 下载已撤回版本时的 Warning 响应头
+const YankWarning = `299 ModVerse "this version has been yanked"`
+
+// ChannelRank 渠道稳定性等级,数值越小越稳定;未知渠道返回-1
+// 按渠道查询时包含更稳定的渠道,如 beta 包含 stable 与 beta
+func ChannelRank(channel string) int {
+	switch channel {
+	case ChannelStable:
+		return 0
+	case ChannelBeta:
+		return 1
+	case ChannelAlpha:
+		return 2
+	case ChannelAll:
+		return 3
+	default:
+		return -1
+	}
+}
+
 // ModVersion 模组版本
+// 撤回(yank)的版本保留记录与文件,不参与列表与依赖解析,已有链接仍可下载
+// 文件扫描完成且未发现恶意内容前(ScanStatus不为clean)不提供下载
+// 同一模组的版本号唯一,删除的版本号仍保留,不可重新发布
 type ModVersion struct {
 	gorm.Model
-	ModID     uint        `gorm:"index;not null;comment:模组ID" json:"mod_id"`
-	Downloads uint        `gorm:"default:0;comment:下载量" json:"downloads"`
-	Version   string      `gorm:"size:32;not null;comment:版本号" json:"version"`
-	ChangeLog string      `gorm:"type:text;comment:更新日志" json:"change_log"`
-	FileID    uint        `gorm:"index;not null;comment:文件ID" json:"file_id"`
-	File      StorageFile `gorm:"foreignKey:FileID" json:"file"`
+	ModID        uint               `gorm:"index;uniqueIndex:idx_mod_versions_mod_version;not null;comment:模组ID" json:"mod_id"`
+	Downloads    uint               `gorm:"default:0;comment:下载量" json:"downloads"`
+	Version      string             `gorm:"size:32;uniqueIndex:idx_mod_versions_mod_version;not null;comment:版本号" json:"version"`
+	ChangeLog    string             `gorm:"type:text;comment:更新日志" json:"change_log"`
+	Channel      string             `gorm:"size:16;index;not null;default:'stable';comment:发布渠道" json:"channel"`
+	Yanked       bool               `gorm:"index;not null;default:false;comment:是否已撤回" json:"yanked"`
+	YankReason   string             `gorm:"size:255;comment:最近一次撤回/恢复的原因" json:"yank_reason"`
+	ScanStatus   string             `gorm:"size:16;index;not null;default:'clean';comment:文件扫描状态(scanning/clean/infected/failed)" json:"scan_status"`
+	Mismatches   []ManifestMismatch `gorm:"type:json;serializer:json;comment:与文件清单不一致之处" json:"manifest_mismatches,omitempty"`
+	Files        []ModVersionFile   `gorm:"foreignKey:ModVersionID" json:"files"`
+	Dependencies []ModDependency    `gorm:"foreignKey:ModVersionID" json:"dependencies"`
+	GameVersions []GameVersion      `gorm:"many2many:mod_version_game_versions" json:"game_versions"`
+}
+
+// 版本文件角色
+const (
+	FileRoleMain     = "main"     // 主文件
+	FileRoleOptional = "optional" // 可选附加内容,如语言包
+	FileRolePatch    = "patch"    // 补丁
+	FileRoleDocs     = "docs"     // 文档
+)
+
+// ModVersionFile 版本包含的文件,每个版本至少有一个主文件
+type ModVersionFile struct {
+	gorm.Model
+	ModVersionID uint        `gorm:"index;not null;comment:模组版本ID" json:"mod_version_id"`
+	FileID       uint        `gorm:"index;not null;comment:文件ID" json:"file_id"`
+	File         StorageFile `gorm:"foreignKey:FileID" json:"file"`
+	Role         string      `gorm:"size:16;not null;default:'main';comment:文件角色(main/optional/patch/docs)" json:"role"`
+	DisplayName  string      `gorm:"size:128;comment:显示名称" json:"display_name"`
+	SortOrder    int         `gorm:"default:0;comment:排序" json:"sort_order"`
+	Downloads    uint        `gorm:"default:0;comment:下载量" json:"downloads"`
+}
+
+type ModVersionFileRequest struct {
+	FileID      uint   `json:"file_id" validate:"required"`
+	Role        string `json:"role"` // 为空时为main
+	DisplayName string `json:"display_name"`
+}
+
+type ModVersionFileResponse struct {
+	ID           uint                 `json:"id"`
+	ModVersionID uint                 `json:"-"`
+	Role         string               `json:"role"`
+	DisplayName  string               `json:"display_name"`
+	SortOrder    int                  `json:"sort_order"`
+	Downloads    uint                 `json:"downloads"`
+	FileID       uint                 `json:"-"`
+	File         DownloadFileResponse `gorm:"foreignKey:FileID" json:"file"`
+}
+
+// ModVersionYankLog 版本撤回/恢复记录
+type ModVersionYankLog struct {
+	gorm.Model
+	ModVersionID uint   `gorm:"index;not null;comment:模组版本ID" json:"mod_version_id"`
+	UserID       uint   `gorm:"index;not null;comment:操作用户ID" json:"user_id"`
+	Yanked       bool   `gorm:"not null;comment:true为撤回,false为恢复" json:"yanked"`
+	Reason       string `gorm:"size:255;not null;comment:原因" json:"reason"`
+}
+
+type YankModVersionRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+type YankLogResponse struct {
+	ModVersionID uint      `json:"-"`
+	UserID       uint      `json:"user_id"`
+	Yanked       bool      `json:"yanked"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type CreateModVersionRequest struct {
-	ModID     uint   `json:"mod_id" validate:"required"`
-	Version   string `json:"version" validate:"required"`
-	ChangeLog string `json:"change_log"`
-	FileID    uint   `json:"file_id" validate:"required"`
+	ModID          uint                    `json:"mod_id" validate:"required"`
+	Version        string                  `json:"version" validate:"required"`
+	ChangeLog      string                  `json:"change_log"`
+	Files          []ModVersionFileRequest `json:"files" validate:"dive"` // 按顺序排列
+	FileID         uint                    `json:"file_id"`               // 兼容旧客户端,未提供files时作为主文件
+	Dependencies   []DependencyRequest     `json:"dependencies"`
+	GameVersionIDs []uint                  `json:"game_version_ids"` // 支持的游戏版本
+	Channel        string                  `json:"channel"`          // 为空时按版本号的预发布标识推断
+
+	LenientVersion bool `json:"lenient_version"` // 接受 1.2、v2、1.0b 等历史版本号格式
+}
+
+// 版本列表查询,Range为语义化版本范围
+type ModVersionQuery struct {
+	Range         string `query:"range"`
+	Prerelease    bool   `query:"prerelease"`     // 是否包含预发布版本
+	GameVersion   string `query:"game_version"`   // 支持的游戏版本名称或ID
+	Channel       string `query:"channel"`        // stable(默认)/beta/alpha/all
+	IncludeYanked bool   `query:"include_yanked"` // 是否包含已撤回的版本
 }
 
 type ModVersionResponse struct {
-	ID        uint                 `json:"id"`
-	Downloads uint                 `json:"downloads"`
-	Version   string               `json:"version"`
-	ChangeLog string               `json:"change_log"`
-	FileID    uint                 `json:"-"`
-	File      DownloadFileResponse `gorm:"foreignKey:FileID" json:"file"`
-	CreatedAt time.Time            `json:"created_at"`
+	ID         uint      `json:"id"`
+	ModID      uint      `json:"-"`
+	Downloads  uint      `json:"downloads"`
+	Version    string    `json:"version"`
+	ChangeLog  string    `json:"change_log"`
+	Channel    string    `json:"channel"`
+	Yanked     bool      `json:"yanked"`
+	YankReason string    `json:"yank_reason,omitempty"`
+	ScanStatus string    `json:"scan_status"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	Mismatches []ManifestMismatch `gorm:"serializer:json" json:"manifest_mismatches,omitempty"`
+
+	Files        []ModVersionFileResponse `gorm:"foreignKey:ModVersionID" json:"files"`
+	File         *DownloadFileResponse    `gorm:"-" json:"file,omitempty"` // 兼容旧客户端,即主文件
+	Dependencies []ModDependencyResponse  `gorm:"foreignKey:ModVersionID" json:"dependencies"`
+	GameVersions []GameVersionResponse    `gorm:"many2many:mod_version_game_versions;joinForeignKey:ModVersionID;joinReferences:GameVersionID" json:"game_versions"`
+	YankLogs     []YankLogResponse        `gorm:"foreignKey:ModVersionID" json:"yank_logs,omitempty"`
+}
+
+// 批量更新检查单次最多条目数,超出时返回ErrTooManyUpdateItems
+const UpdateCheckMaxItems = 500
+
+// 已安装的模组,通过版本号或任一文件的SHA-256识别当前版本,两者都有时优先使用哈希
+// 早期上传的文件由文件校验任务补全哈希,补全前只能按版本号识别
+type UpdateCheckItem struct {
+	ModID    uint   `json:"mod_id" validate:"required"`
+	Version  string `json:"version"`
+	FileHash string `json:"file_hash"`
+}
+
+type UpdateCheckRequest struct {
+	Items       []UpdateCheckItem `json:"items" validate:"required,min=1,dive"`
+	GameVersion string            `json:"game_version"` // 游戏版本名称或ID,为空时不限制
+	Channel     string            `json:"channel"`      // stable(默认)/beta/alpha/all
+}
+
+// 可更新到的版本,ChangeLog为更新日志摘要
+type UpdateCandidate struct {
+	ID        uint                     `json:"id"`
+	Version   string                   `json:"version"`
+	Channel   string                   `json:"channel"`
+	ChangeLog string                   `json:"change_log"`
+	CreatedAt time.Time                `json:"created_at"`
+	Files     []ModVersionFileResponse `json:"files"`
+}
+
+// 单个模组的检查结果,与请求条目一一对应
+type UpdateCheckResult struct {
+	ModID            uint             `json:"mod_id"`
+	InstalledID      uint             `json:"installed_id,omitempty"`
+	InstalledVersion string           `json:"installed_version,omitempty"`
+	InstalledYanked  bool             `json:"installed_yanked"`
+	UpdateAvailable  bool             `json:"update_available"`
+	Latest           *UpdateCandidate `json:"latest"`
+	Error            string           `json:"error,omitempty"`
 }
 
 type ModVersionRepository interface {
 	CreateModVersion(c context.Context, mv *ModVersion) error
 	GetModVersions(c context.Context, modID string) (*[]ModVersionResponse, int64, error)
-	DeleteModVersion(c context.Context, id string) error
+	DeleteModVersion(c context.Context, id string) ([]string, error)
 	GetModVersion(c context.Context, id string) (*ModVersionResponse, error)
+	GetVersionNumbers(c context.Context, modID uint) ([]string, error)
+	GetUpdateVersions(c context.Context, modIDs []uint) (*[]ModVersionResponse, error)
+	SetYanked(c context.Context, id uint, log *ModVersionYankLog) error
+	GetVersionContents(c context.Context, id string) (*[]ModVersionContents, error)
+	// GetVersionOwner 版本所属模组的上传者ID,版本不存在时返回 custom.DataNotExistError
+	GetVersionOwner(c context.Context, id string) (uint64, error)
+	// HasPublishedMods 用户是否发布过模组,用于确定下载等级
+	HasPublishedMods(c context.Context, userID uint64) (bool, error)
 	GetDB() *gorm.DB
 }
 
 type ModVersionService interface {
-	CreateModVersion(c context.Context, mv *ModVersion) error
-	GetModVersions(c context.Context, modID string) (*[]ModVersionResponse, int64, error)
-	DeleteModVersion(c context.Context, id string) error
-	UpdateCount(c context.Context, id string, modID string) (int64, error)
+	CreateModVersion(c context.Context, mv *ModVersion, files []ModVersionFileRequest, deps []DependencyRequest, gameVersionIDs []uint) error
+	GetModVersions(c context.Context, modID string, params *ModVersionQuery) (*[]ModVersionResponse, int64, error)
+	GetLatestVersion(c context.Context, modID string, params *ModVersionQuery) (*ModVersionResponse, error)
+	DeleteModVersion(c context.Context, id string, force bool) error
+	UpdateCount(c context.Context, id string, modID string, fileID string) (int64, error)
+	// GetDownloadURL 记录下载并返回限时签名的下载地址,fileID为空时下载主文件,userID为空表示未登录
+	GetDownloadURL(c context.Context, modVersion *ModVersionResponse, fileID string, clientIP string, userID string) (string, error)
+	SetDependencies(c context.Context, id string, deps []DependencyRequest) error
+	// CheckVersionOwner 只有模组的上传者或管理员可以修改版本,否则返回 ErrNotVersionOwner
+	CheckVersionOwner(c context.Context, id string, userID string, role string) error
+	ResolveDependencies(c context.Context, id string) (*DependencyResolution, error)
+	GetDependents(c context.Context, modID string) (*[]DependentResponse, error)
+	GetModVersion(c context.Context, id string) (*ModVersionResponse, error)
+	YankModVersion(c context.Context, id string, userID string, reason string, force bool) error
+	UnyankModVersion(c context.Context, id string, userID string, reason string) error
+	CheckUpdates(c context.Context, req *UpdateCheckRequest) (*[]UpdateCheckResult, error)
+	GetVersionContents(c context.Context, id string) (*[]ModVersionContents, error)
+	GetManifestPrefill(c context.Context, fileID string, userID string, role string) (*ManifestPrefill, error)
 }
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// 管理员查看用量排行时每页的默认与最大人数
+const (
+	QuotaTopDefault = 20
+	QuotaTopMax     = 100
+)
+
+// StorageUsage 用户的存储用量,同一用户多次上传相同内容只计一次
+// Quota为生效的配额,0表示不限制;QuotaOverride为单独设置的配额,为空时按角色配置
+type StorageUsage struct {
+	UserID        uint64 `json:"user_id"`
+	UserName      string `json:"user_name,omitempty"`
+	Role          string `json:"role,omitempty"`
+	Used          int64  `json:"used"`
+	Files         int64  `json:"files"`
+	Quota         int64  `json:"quota"`
+	QuotaOverride *int64 `gorm:"column:storage_quota" json:"quota_override,omitempty"`
+}
+
+type UpdateUserQuotaRequest struct {
+	Quota *int64 `json:"quota" validate:"omitempty,gte=0"` // 为空时恢复按角色配置,0表示不限制
+}
+
+type QuotaRepository interface {
+	GetUserUsage(c context.Context, userID uint64) (*StorageUsage, error)
+	GetTopUsage(c context.Context, limit int) (*[]StorageUsage, error)
+	SetUserQuota(c context.Context, userID uint64, quota *int64) error
+}
+
+type QuotaService interface {
+	GetUsage(c context.Context, userID string) (*StorageUsage, error)
+	// CheckQuota 检查用户再上传size字节后是否超过配额,返回生效的配额供创建记录时再次检查
+	CheckQuota(c context.Context, userID uint64, size int64) (int64, error)
+	GetTopConsumers(c context.Context, limit int) (*[]StorageUsage, error)
+	SetUserQuota(c context.Context, userID string, quota *int64) error
+}
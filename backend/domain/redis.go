@@ -10,7 +10,14 @@ import (
 type RedisRepository interface {
 	GetValue(c context.Context, key string) (string, error)
 	SetValue(c context.Context, key string, value any, time time.Duration) error
+	SetValueNX(c context.Context, key string, value any, time time.Duration) (bool, error)
 	DeleteValue(c context.Context, key string) error
+	// ExtendValue/DeleteValueIf 仅在键的值仍为value时续期或删除,用于只由持有者操作的锁
+	ExtendValue(c context.Context, key string, value string, time time.Duration) (bool, error)
+	DeleteValueIf(c context.Context, key string, value string) error
+	AddSlot(c context.Context, key string, member string, ttl time.Duration) (int64, error)
+	RemoveSlot(c context.Context, key string, member string) error
+	CountSlots(c context.Context, key string, ttl time.Duration) (int64, error)
 	AddItem(c context.Context, key string, value any) error
 	RemoveItem(c context.Context, key string, value any) error
 	GetAllItems(c context.Context, key string) ([]string, error)
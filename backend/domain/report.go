@@ -7,9 +7,10 @@ import (
 	"gorm.io/gorm"
 )
 
+// Report 举报,恶意文件扫描自动创建的举报ReporterID为0
 type Report struct {
 	gorm.Model
-	Type         string `gorm:"index;not null;size:32;comment:举报类型(mod/comment)" json:"type"`
+	Type         string `gorm:"index;not null;size:32;comment:举报类型(mod/comment/file)" json:"type"`
 	Target       uint   `gorm:"index;not null;comment:举报目标ID" json:"target"`
 	ReporterID   uint64 `gorm:"index;not null;comment:举报者ID" json:"reporter_id"`
 	Reporter     User   `gorm:"foreignKey:ReporterID" json:"reporter"`
@@ -56,7 +57,7 @@ type ReportRepository interface {
 	Create(c context.Context, report *Report) error
 	Update(c context.Context, report *Report) error
 	GetByID(c context.Context, id string) (*ReportResponse, error)
-	List(c context.Context, params *ReportQuery) (*[]ReportResponse, int64, error)
+	List(c context.Context, params *ReportQuery) (*[]ReportResponse, int64, string, error)
 	DeleteReport(c context.Context, id string) error
 }
 
@@ -64,6 +65,6 @@ type ReportService interface {
 	CreateReport(c context.Context, report *Report) error
 	UpdateReport(c context.Context, id string, req *UpdateReportRequest) error
 	GetReport(c context.Context, id string) (*ReportResponse, error)
-	GetReports(c context.Context, params *ReportQuery) (*[]ReportResponse, int64, error)
+	GetReports(c context.Context, params *ReportQuery) (*[]ReportResponse, int64, string, error)
 	DeleteReport(c context.Context, id string) error
 }
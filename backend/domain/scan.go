@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// 扫描后端
+const ScannerClamd = "clamd"
+
+// 文件扫描状态,模组版本的状态由其文件汇总,按感染、无法扫描、扫描中、正常的顺序取最严重的
+// 无法扫描(如超过clamd的StreamMaxLength)的文件不再重试,举报给管理员处理,处理前不提供下载
+const (
+	ScanStatusScanning = "scanning"
+	ScanStatusClean    = "clean"
+	ScanStatusInfected = "infected"
+	ScanStatusFailed   = "failed"
+)
+
+// 感染文件移入该目录,不再通过原地址提供下载
+const QuarantinePrefix = "quarantine/"
+
+// 扫描发现恶意文件时自动创建的举报类型,目标为文件ID;文件已属于模组版本时举报该模组
+const ReportTypeFile = "file"
+
+var (
+	ErrFileScanning = errors.New("file is being scanned for malware")
+	ErrFileInfected = errors.New("file is quarantined as malware")
+	// ErrFileUnscannable 文件无法完成扫描,等待管理员处理
+	ErrFileUnscannable = errors.New("file could not be scanned for malware")
+	// ErrScanTooLarge 文件超过扫描后端的大小限制,重试不会成功
+	ErrScanTooLarge = errors.New("file exceeds the scanner size limit")
+)
+
+// ScanResult 扫描结果,Infected为true时Signature为命中的特征名
+type ScanResult struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner 恶意文件扫描后端,无法完成扫描时返回error,调用方稍后重试
+type Scanner interface {
+	Scan(c context.Context, r io.Reader) (*ScanResult, error)
+}
+
+// 扫描发现感染文件时受影响的模组版本
+type ScanAffectedVersion struct {
+	ID      uint
+	ModID   uint
+	Version string
+}
+
+type ScanService interface {
+	ScanFile(c context.Context, fileKey string) error
+	ScanPending(c context.Context) error
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+)
+
+// 联想词来源: 模组名、游戏名、作者名
+const (
+	SuggestTypeMod    = "mod"
+	SuggestTypeGame   = "game"
+	SuggestTypeAuthor = "author"
+)
+
+// 联想词原始数据,Popularity为热度(下载/点赞/模组数等折算)
+type SuggestSource struct {
+	Type       string `json:"type"`
+	ID         uint64 `json:"id"`
+	Name       string `json:"name"`
+	Popularity int64  `json:"-"`
+}
+
+type SuggestResponse struct {
+	Type  string  `json:"type"`
+	ID    uint64  `json:"id"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+type SuggestQuery struct {
+	Q     string `query:"q"`
+	Limit int    `query:"limit"`
+}
+
+type SearchRepository interface {
+	GetSuggestSources(c context.Context) (*[]SuggestSource, error)
+}
+
+type SearchService interface {
+	Suggest(c context.Context, params *SuggestQuery) (*[]SuggestResponse, error)
+	Refresh(c context.Context) error
+	Start(c context.Context)
+	Stop()
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// 存储后端
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+var ErrObjectNotExist = errors.New("object does not exist")
+
+type StorageObject struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage 文件存储后端,key为与后端无关的相对路径,如 blobs/ab/<sha256>.zip
+type Storage interface {
+	Put(c context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(c context.Context, key string) (io.ReadCloser, error)
+	Delete(c context.Context, key string) error
+	Stat(c context.Context, key string) (*StorageObject, error)
+	// PresignGet 生成限时下载地址,fileName不为空时作为下载文件名
+	PresignGet(c context.Context, key string, expiry time.Duration, fileName string) (string, error)
+	// List 遍历prefix下的全部对象,fn返回错误时停止遍历
+	List(c context.Context, prefix string, fn func(obj *StorageObject) error) error
+}
@@ -2,25 +2,90 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"mime/multipart"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// StorageFile 用户上传的文件记录,相同内容的记录共享同一个StorageBlob
+// 只记录SHA-256:它同时用作存储key与 Digest/Repr-Digest 头,HTTP摘要算法中没有BLAKE3,另算一份哈希没有使用方
 type StorageFile struct {
 	gorm.Model
 	UserID   uint64 `gorm:"index;comment:用户ID" json:"user_id"`
-	FileKey  string `gorm:"size:255;uniqueIndex;comment:文件路径" json:"file_key"`
+	FileKey  string `gorm:"size:255;index:idx_storage_files_blob_key;comment:文件路径" json:"file_key"`
 	FileName string `gorm:"size:128;comment:原始文件名" json:"file_name"`
 	FileSize int64  `gorm:"comment:字节数" json:"file_size"`
 	MIMEType string `gorm:"size:64;comment:MIME类型" json:"mime_type"`
-	URL      string `gorm:"size:512;comment:访问地址" json:"url"`
-	IsTemp   bool   `gorm:"default:false;comment:临时标记" json:"is_temp"`
+	SHA256   string `gorm:"size:64;index;comment:SHA-256" json:"sha256"`
+	// 上传类型,模组文件只能通过签名地址下载
+	UploadType string               `gorm:"size:16;index;comment:上传类型" json:"upload_type"`
+	URL        string               `gorm:"size:512;comment:访问地址" json:"url"`
+	IsTemp     bool                 `gorm:"index;default:false;comment:临时标记,被引用前为true" json:"is_temp"`
+	Variants   []StorageFileVariant `gorm:"type:json;serializer:json;comment:图片尺寸版本" json:"variants,omitempty"`
+	Archive    *StorageFileArchive  `gorm:"foreignKey:StorageFileID" json:"archive,omitempty"`
+	Manifest   *ModManifest         `gorm:"type:json;serializer:json;comment:清单信息" json:"manifest,omitempty"`
+
+	ScanStatus    string     `gorm:"size:16;index;not null;default:'clean';comment:扫描状态(scanning/clean/infected/failed)" json:"scan_status"`
+	ScanSignature string     `gorm:"size:255;comment:命中的恶意特征" json:"scan_signature,omitempty"`
+	ScannedAt     *time.Time `gorm:"comment:扫描时间" json:"scanned_at,omitempty"`
+}
+
+// 图片版本名称,按最大宽高缩放,不放大
+const (
+	ImageVariantThumb = "thumb"
+	ImageVariantCard  = "card"
+	ImageVariantFull  = "full"
+)
+
+// 图片解码后不超过4000万像素,字节数限制见各上传类型的UploadPolicy
+const ImageMaxPixels = 40_000_000
+
+var (
+	ErrUnsupportedImage = errors.New("unsupported image format")
+	ErrImageTooLarge    = errors.New("image exceeds size limit")
+)
+
+// UploadPolicy 上传类型的限制,Types为允许的MIME类型(按文件内容识别),Extensions为允许的扩展名
+type UploadPolicy struct {
+	MaxSize    int64
+	Types      []string
+	Extensions []string
+}
+
+var (
+	ErrFileTooLarge       = errors.New("file too large")
+	ErrFileTypeNotAllowed = errors.New("file type not allowed")
+)
+
+// StorageFileVariant 图片处理后生成的版本,与原记录一样按内容哈希存储并参与引用计数
+type StorageFileVariant struct {
+	Name     string `json:"name"`
+	Format   string `json:"format"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileKey  string `json:"file_key"`
+	FileSize int64  `json:"file_size"`
+	SHA256   string `json:"sha256"`
+	URL      string `json:"url"`
+}
+
+// StorageBlob 按内容哈希存储的文件实体,RefCount为引用它的StorageFile数量,归零时删除
+type StorageBlob struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	FileKey   string    `gorm:"size:255;uniqueIndex;not null;comment:文件路径" json:"file_key"`
+	SHA256    string    `gorm:"size:64;index;not null;comment:SHA-256" json:"sha256"`
+	FileSize  int64     `gorm:"comment:字节数" json:"file_size"`
+	RefCount  int       `gorm:"not null;default:0;comment:引用数" json:"ref_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type StorageFileResponse struct {
-	ID  uint   `json:"id"`
-	URL string `json:"url"`
+	ID       uint                 `json:"id"`
+	URL      string               `json:"url"`
+	Variants []StorageFileVariant `gorm:"serializer:json" json:"variants,omitempty"`
 }
 
 type DownloadFileResponse struct {
@@ -28,20 +93,94 @@ type DownloadFileResponse struct {
 	FileKey  string `json:"-"`
 	FileName string `json:"file_name"`
 	FileSize int64  `json:"file_size"`
+	SHA256   string `json:"sha256,omitempty"`
 	URL      string `json:"url"`
 }
 
+// 文件校验任务状态
+const (
+	FileVerifyRunning  = "running"
+	FileVerifyFinished = "finished"
+	FileVerifyFailed   = "failed"
+)
+
+var ErrFileVerifyRunning = errors.New("file verification is already running")
+
+var ErrInvalidUploadType = errors.New("invalid upload type")
+
+// 上传类型
+const (
+	UploadModFile    = "mod_file"
+	UploadModCover   = "mod_cover"
+	UploadGameLogo   = "game_logo"
+	UploadUserAvatar = "user_avatar"
+	UploadPostImage  = "post_image"
+)
+
+// 校验发现的问题文件,Actual为空表示文件已不存在
+// ID为blob的ID,未记录哈希的旧文件为0
+type FileVerifyIssue struct {
+	ID       uint   `json:"id"`
+	FileKey  string `json:"file_key"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// FileVerifyReport 文件完整性校验报告,任务运行中会持续更新
+// 按blob逐个校验,图片版本同样校验,被多条记录引用的内容只计算一次;没有记录哈希的旧文件计算后补录,计入Backfilled
+type FileVerifyReport struct {
+	Status     string            `json:"status"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Checked    int               `json:"checked"`
+	Backfilled int               `json:"backfilled"`
+	Missing    []FileVerifyIssue `json:"missing"`
+	Mismatched []FileVerifyIssue `json:"mismatched"`
+	Error      string            `json:"error,omitempty"`
+}
+
 type StorageFileRepository interface {
-	CreateStorageFile(c context.Context, sf *StorageFile) error
+	// CreateStorageFile quota大于0时在创建记录的事务中锁定用户并检查用量,超出时返回ErrQuotaExceeded
+	CreateStorageFile(c context.Context, sf *StorageFile, quota int64) error
 	GetStorageFile(c context.Context, id string) (*StorageFile, error)
+	GetStorageFileByKey(c context.Context, fileKey string) (*StorageFile, error)
+	HasKey(c context.Context, key string) (bool, error)
+	// IsModFileKey 该路径是否被模组文件记录使用
+	IsModFileKey(c context.Context, key string) (bool, error)
 	GetStorageFiles(c context.Context) (*[]StorageFile, error)
-	DeleteStorageFile(c context.Context, id string) error
+	GetStorageFilesAfter(c context.Context, afterID uint, limit int) (*[]StorageFile, error)
+	// GetStorageBlobsAfter 按ID顺序分批读取blob,每个内容只出现一次
+	GetStorageBlobsAfter(c context.Context, afterID uint, limit int) (*[]StorageBlob, error)
+	// GetUnhashedKeysAfter 按路径顺序分批读取未记录哈希的旧文件路径,同一路径只返回一次
+	GetUnhashedKeysAfter(c context.Context, afterKey string, limit int) ([]string, error)
+	// UpdateStorageFileHash 补录同一路径下全部未记录哈希的记录
+	UpdateStorageFileHash(c context.Context, fileKey string, sha256 string) error
+	DeleteStorageFile(c context.Context, id string) ([]string, error) // 返回需要从存储删除的文件路径,仍被引用的不返回
+	ClaimStorageFiles(c context.Context, ids []uint) error
+	GetScanningKeys(c context.Context, limit int) ([]string, error)
+	GetStorageFilesByKey(c context.Context, fileKey string) (*[]StorageFile, error)
+	// GetInfectedFileByHash 相同内容已被隔离的文件记录,没有时返回DataNotExistError
+	GetInfectedFileByHash(c context.Context, sha256 string) (*StorageFile, error)
+	// SetScanResult 记录同一文件路径下全部记录的扫描结果,newKey为文件移动后的路径,返回受影响的模组版本
+	SetScanResult(c context.Context, fileKey string, newKey string, status string, signature string) (*[]ScanAffectedVersion, error)
+	// ReserveBlob/ReleaseBlob 写入对象期间占用一个引用,防止判断对象已存在后被并发的删除回收
+	ReserveBlob(c context.Context, key string, sha256 string, size int64) error
+	ReleaseBlob(c context.Context, key string) ([]string, error)
+	// DeleteUnreferencedObject 锁定后确认路径未被重新引用时执行del
+	DeleteUnreferencedObject(c context.Context, key string, del func() error) error
 }
 
 type UploadService interface {
 	UploadPostImage(c context.Context, file *multipart.FileHeader, id string) (string, error)
-	UploadFile(c context.Context, file *multipart.FileHeader, id string, uploadType string) (uint, error)
+	UploadFile(c context.Context, file *multipart.FileHeader, id string, uploadType string, gameID uint) (*StorageFile, error)
 	GetFile(c context.Context, id string) (*StorageFile, error)
 	GetFiles(c context.Context) (*[]StorageFile, error)
 	RemoveFile(c context.Context, id string) error
+	StartVerifyFiles(c context.Context) error
+	GetVerifyReport(c context.Context) (*FileVerifyReport, error)
+	CreateTusUpload(c context.Context, userID string, req *CreateTusUploadRequest) (*TusUpload, error)
+	GetTusUpload(c context.Context, id string, userID string) (*TusUpload, error)
+	WriteTusChunk(c context.Context, id string, userID string, offset int64, chunk []byte) (*TusUpload, error)
+	DeleteTusUpload(c context.Context, id string, userID string) error
 }
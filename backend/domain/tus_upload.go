@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// tus 断点续传协议,实现 1.0 核心与 creation、termination、expiration 扩展
+const (
+	TusVersion    = "1.0.0"
+	TusExtensions = "creation,termination,expiration"
+	TusMaxSize    = int64(2 << 30) // 单个文件上限2GB
+)
+
+var (
+	ErrTusOffsetMismatch   = errors.New("upload offset does not match")
+	ErrTusUploadLocked     = errors.New("upload is being written by another request")
+	ErrTusSizeExceeded     = errors.New("upload exceeds declared length")
+	ErrTusChecksumMismatch = errors.New("upload checksum mismatch")
+	ErrTusUploadFinished   = errors.New("upload is already finished")
+	ErrTusInvalidMetadata  = errors.New("invalid Upload-Metadata")
+)
+
+// TusUpload 上传会话,保存在Redis中,分片内容追加写入临时目录
+// 写满Length后转为StorageFile,FileID为生成的文件ID
+type TusUpload struct {
+	ID         string    `json:"id"`
+	UserID     uint64    `json:"user_id"`
+	UploadType string    `json:"upload_type"`
+	GameID     uint      `json:"game_id,omitempty"` // 模组文件所属的游戏,用于提取清单
+	FileName   string    `json:"file_name"`
+	SHA256     string    `json:"sha256,omitempty"` // 客户端声明的哈希,完成时校验
+	Metadata   string    `json:"metadata"`         // 原始Upload-Metadata,查询时原样返回
+	Length     int64     `json:"length"`
+	Offset     int64     `json:"offset"`
+	FileID     uint      `json:"file_id,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type CreateTusUploadRequest struct {
+	Length     int64
+	UploadType string
+	GameID     uint
+	Metadata   string
+}
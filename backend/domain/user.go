@@ -8,14 +8,15 @@ import (
 // 用户表
 type User struct {
 	Model
-	UserName    string      `gorm:"size:64;uniqueIndex;not null;comment:用户名" json:"user_name"`
-	Password    string      `gorm:"size:255;not null;comment:密码哈希值" json:"-"` // 禁止 JSON 输出
-	Email       string      `gorm:"size:128;uniqueIndex;not null;comment:邮箱" json:"-"`
-	Status      string      `gorm:"size:32;default:'enable';comment:状态(enable/disable)" json:"status"`
-	Role        string      `gorm:"size:20;default:'user';comment:角色(user/admin)" json:"role"`
-	LastLogin   time.Time   `gorm:"comment:上次登录时间;default:null" json:"last_login"`
-	UserProfile UserProfile `json:"user_profile"`
-	Mod         []Mod       `json:"mod"`
+	UserName     string      `gorm:"size:64;uniqueIndex;not null;comment:用户名" json:"user_name"`
+	Password     string      `gorm:"size:255;not null;comment:密码哈希值" json:"-"` // 禁止 JSON 输出
+	Email        string      `gorm:"size:128;uniqueIndex;not null;comment:邮箱" json:"-"`
+	Status       string      `gorm:"size:32;default:'enable';comment:状态(enable/disable)" json:"status"`
+	Role         string      `gorm:"size:20;default:'user';comment:角色(user/admin)" json:"role"`
+	LastLogin    time.Time   `gorm:"comment:上次登录时间;default:null" json:"last_login"`
+	StorageQuota *int64      `gorm:"comment:存储配额(字节),为空时按角色配置,0为不限制" json:"-"`
+	UserProfile  UserProfile `json:"user_profile"`
+	Mod          []Mod       `json:"mod"`
 }
 
 type UserResponse struct {
@@ -59,7 +60,7 @@ type UserRepository interface {
 	ReadUserWithMod(c context.Context, id string) (*UserResponse, error)
 	ReadUserByNameOrEmail(c context.Context, name string) (*User, error)
 	ReadUserByNameWithMod(c context.Context, name string) (*UserResponse, error)
-	ReadUsers(c context.Context, params *UserQuery) (*[]UsersResponse, int64, error)
+	ReadUsers(c context.Context, params *UserQuery) (*[]UsersResponse, int64, string, error)
 	DeleteUser(c context.Context, id string) error
 	UpdateUserState(c context.Context, user *User) error
 	ReadUserAllInfo(c context.Context, id string) (*User, error)
@@ -70,7 +71,7 @@ type UserService interface {
 	GetUserWithMod(c context.Context, id string) (*UserResponse, error)
 	GetUserByNameWithMod(c context.Context, name string) (*UserResponse, error)
 	UpdateProfile(c context.Context, profile *UpdateUserProfileRequest, id string) error
-	GetUsers(c context.Context, params *UserQuery) (*[]UsersResponse, int64, error)
+	GetUsers(c context.Context, params *UserQuery) (*[]UsersResponse, int64, string, error)
 	DeleteUser(c context.Context, id string) error
 	UpdateUserState(c context.Context, user *User) error
 }
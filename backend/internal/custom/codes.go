@@ -24,4 +24,6 @@ const (
 	OriginPassword //原始密码错误
 
 	UserDisabled //用户被禁用
+
+	VersionExist //版本号已存在
 )
@@ -31,4 +31,5 @@ var (
 	LoginRepeatError    = newCustomError(LoginRepeat, "重复登录")
 	OriginPasswordError = newCustomError(OriginPassword, "原密码错误")
 	UserDisabledError   = newCustomError(UserDisabled, "用户已被禁用")
+	VersionExistError   = newCustomError(VersionExist, "版本号已存在")
 )
@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"ModVerse/domain"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// 游戏本体与官方DLC的主文件,不作为模组依赖
+var bethesdaOfficialMasters = map[string]bool{
+	"oblivion.esm": true, "fallout3.esm": true, "falloutnv.esm": true,
+	"skyrim.esm": true, "update.esm": true, "dawnguard.esm": true, "hearthfires.esm": true, "dragonborn.esm": true,
+	"fallout4.esm": true, "dlcrobot.esm": true, "dlcworkshop01.esm": true, "dlccoast.esm": true,
+	"dlcworkshop02.esm": true, "dlcworkshop03.esm": true, "dlcnukaworld.esm": true, "starfield.esm": true,
+}
+
+// 插件头只读取前64K
+const bethesdaHeaderSize = 64 << 10
+
+// Bethesda游戏(上古卷轴、辐射)插件 .esp/.esm/.esl 的TES4文件头
+// CNAM为作者,SNAM为描述,每个MAST为一个依赖的主文件;插件没有版本号
+type bethesdaExtractor struct{}
+
+func (bethesdaExtractor) Name() string {
+	return "bethesda"
+}
+
+func (bethesdaExtractor) Extract(fsys fs.FS) (*domain.ModManifest, error) {
+	source, err := findManifest(fsys, 2, func(name string) bool {
+		switch strings.ToLower(path.Ext(name)) {
+		case ".esp", ".esm", ".esl":
+			return true
+		}
+		return false
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fsys.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header, err := io.ReadAll(io.LimitReader(f, bethesdaHeaderSize))
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := parseTES4(header)
+	if err != nil {
+		return nil, err
+	}
+
+	name := path.Base(source)
+	manifest := &domain.ModManifest{
+		Source:      source,
+		ModID:       name,
+		Name:        strings.TrimSuffix(name, path.Ext(name)),
+		Description: fields["SNAM"].first(),
+	}
+	if author := fields["CNAM"].first(); author != "" {
+		manifest.Authors = []string{author}
+	}
+	for _, master := range fields["MAST"] {
+		if bethesdaOfficialMasters[strings.ToLower(master)] {
+			continue
+		}
+		manifest.Dependencies = append(manifest.Dependencies, domain.ManifestDependency{
+			ModID:        master,
+			VersionRange: "*",
+			Type:         domain.DependencyRequired,
+		})
+	}
+
+	return manifest, nil
+}
+
+type tes4Values []string
+
+func (v tes4Values) first() string {
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+// TES4记录头为20字节(Oblivion)或24字节(之后的游戏),之后是 类型(4) 长度(2) 数据 形式的子记录
+func parseTES4(data []byte) (map[string]tes4Values, error) {
+	if len(data) < 24 || string(data[:4]) != "TES4" {
+		return nil, errors.New("not a TES4 plugin")
+	}
+
+	size := int(binary.LittleEndian.Uint32(data[4:8]))
+	offset := 24
+	if string(data[20:24]) == "HEDR" {
+		offset = 20
+	}
+	end := offset + size
+	if end > len(data) {
+		end = len(data)
+	}
+
+	fields := make(map[string]tes4Values)
+	for offset+6 <= end {
+		typ := string(data[offset : offset+4])
+		n := int(binary.LittleEndian.Uint16(data[offset+4 : offset+6]))
+		offset += 6
+		if offset+n > end {
+			break
+		}
+		value := data[offset : offset+n]
+		offset += n
+
+		switch typ {
+		case "CNAM", "SNAM", "MAST":
+			if i := bytes.IndexByte(value, 0); i >= 0 {
+				value = value[:i]
+			}
+			fields[typ] = append(fields[typ], string(value))
+		}
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"ModVerse/domain"
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// 按 类型 长度 数据 拼接子记录,headerSize为20(Oblivion)或24
+func tes4Plugin(headerSize int, subrecords ...[2]string) []byte {
+	var body bytes.Buffer
+	for _, sr := range subrecords {
+		body.WriteString(sr[0])
+		binary.Write(&body, binary.LittleEndian, uint16(len(sr[1])))
+		body.WriteString(sr[1])
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, "TES4")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(body.Len()))
+	return append(header, body.Bytes()...)
+}
+
+func TestBethesdaExtractor(t *testing.T) {
+	plugin := tes4Plugin(24,
+		[2]string{"HEDR", "\x9a\x99\xd9\x3f\x00\x00\x00\x00\x00\x08\x00\x00"},
+		[2]string{"CNAM", "Alice\x00"},
+		[2]string{"SNAM", "Better lighting.\x00"},
+		[2]string{"MAST", "Skyrim.esm\x00"},
+		[2]string{"DATA", "\x00\x00\x00\x00\x00\x00\x00\x00"},
+		[2]string{"MAST", "SkyUI_SE.esp\x00"},
+		[2]string{"DATA", "\x00\x00\x00\x00\x00\x00\x00\x00"},
+	)
+	fsys := fstest.MapFS{
+		"Data/BetterLights.esp": {Data: plugin},
+		"readme.txt":            {Data: []byte("x")},
+	}
+
+	manifest, err := bethesdaExtractor{}.Extract(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &domain.ModManifest{
+		Source:      "Data/BetterLights.esp",
+		ModID:       "BetterLights.esp",
+		Name:        "BetterLights",
+		Description: "Better lighting.",
+		Authors:     []string{"Alice"},
+		Dependencies: []domain.ManifestDependency{
+			{ModID: "SkyUI_SE.esp", VersionRange: "*", Type: domain.DependencyRequired},
+		},
+	}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("Extract = %+v\nwant %+v", manifest, want)
+	}
+}
+
+// Oblivion的记录头为20字节
+func TestParseTES4Oblivion(t *testing.T) {
+	plugin := tes4Plugin(20,
+		[2]string{"HEDR", "\x00\x00\x80\x3f\x00\x00\x00\x00\x00\x08\x00\x00"},
+		[2]string{"CNAM", "Bob\x00"},
+		[2]string{"MAST", "Oblivion.esm\x00"},
+	)
+
+	fields, err := parseTES4(plugin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields["CNAM"].first() != "Bob" || fields["MAST"].first() != "Oblivion.esm" {
+		t.Errorf("parseTES4 = %v", fields)
+	}
+}
+
+func TestParseTES4Invalid(t *testing.T) {
+	if _, err := parseTES4([]byte("TES3 not a tes4 plugin at all")); err == nil {
+		t.Error("parseTES4 accepted a non-TES4 file")
+	}
+
+	// 子记录长度超出记录时停止解析,不越界
+	plugin := tes4Plugin(24, [2]string{"CNAM", "Alice\x00"})
+	plugin = append(plugin, "MAST\xff\x00x"...)
+	binary.LittleEndian.PutUint32(plugin[4:8], uint32(len(plugin)-24))
+	fields, err := parseTES4(plugin)
+	if err != nil || fields["CNAM"].first() != "Alice" || len(fields["MAST"]) != 0 {
+		t.Errorf("parseTES4 truncated = %v, %v", fields, err)
+	}
+}
+
+func TestBethesdaExtractorNoPlugin(t *testing.T) {
+	fsys := fstest.MapFS{"a/b/c/deep.esp": {Data: tes4Plugin(24)}}
+	if manifest, err := (bethesdaExtractor{}).Extract(fsys); manifest != nil || err != nil {
+		t.Errorf("Extract = %+v, %v, want nil", manifest, err)
+	}
+}
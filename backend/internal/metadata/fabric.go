@@ -0,0 +1,128 @@
+package metadata
+
+import (
+	"ModVerse/domain"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+const fabricManifest = "fabric.mod.json"
+
+// 加载器与运行环境,不作为模组依赖
+var fabricPlatformIDs = map[string]bool{"fabricloader": true, "java": true, "quilt_loader": true}
+
+// fabric.mod.json https://fabricmc.net/wiki/documentation:fabric_mod_json
+type fabricExtractor struct{}
+
+type fabricModJSON struct {
+	ID          string                      `json:"id"`
+	Version     string                      `json:"version"`
+	Name        string                      `json:"name"`
+	Description string                      `json:"description"`
+	Authors     []json.RawMessage           `json:"authors"`
+	Depends     map[string]fabricVersionAny `json:"depends"`
+	Recommends  map[string]fabricVersionAny `json:"recommends"`
+	Suggests    map[string]fabricVersionAny `json:"suggests"`
+	Breaks      map[string]fabricVersionAny `json:"breaks"`
+}
+
+// 版本要求可以是字符串或字符串数组,数组中任一满足即可
+type fabricVersionAny []string
+
+func (v *fabricVersionAny) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*v = fabricVersionAny{one}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*v = many
+	return nil
+}
+
+func (v fabricVersionAny) String() string {
+	if len(v) == 0 {
+		return "*"
+	}
+	return strings.Join(v, " || ")
+}
+
+func (fabricExtractor) Name() string {
+	return "fabric"
+}
+
+func (fabricExtractor) Extract(fsys fs.FS) (*domain.ModManifest, error) {
+	data, err := readManifest(fsys, fabricManifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var mod fabricModJSON
+	if err := json.Unmarshal(data, &mod); err != nil {
+		return nil, err
+	}
+
+	manifest := &domain.ModManifest{
+		Source:      fabricManifest,
+		ModID:       mod.ID,
+		Name:        mod.Name,
+		Description: mod.Description,
+	}
+	if !placeholder(mod.Version) {
+		manifest.Version = mod.Version
+	}
+
+	// 作者可以是名称或 {"name": ...} 对象
+	for _, raw := range mod.Authors {
+		var name string
+		if err := json.Unmarshal(raw, &name); err != nil {
+			var person struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(raw, &person); err != nil {
+				continue
+			}
+			name = person.Name
+		}
+		if name != "" {
+			manifest.Authors = append(manifest.Authors, name)
+		}
+	}
+
+	if mc, ok := mod.Depends["minecraft"]; ok {
+		manifest.GameVersionRange = mc.String()
+	}
+
+	addDeps := func(deps map[string]fabricVersionAny, depType string) {
+		ids := make([]string, 0, len(deps))
+		for id := range deps {
+			if id != "minecraft" && !fabricPlatformIDs[id] {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			manifest.Dependencies = append(manifest.Dependencies, domain.ManifestDependency{
+				ModID:        id,
+				VersionRange: deps[id].String(),
+				Type:         depType,
+			})
+		}
+	}
+	addDeps(mod.Depends, domain.DependencyRequired)
+	addDeps(mod.Recommends, domain.DependencyOptional)
+	addDeps(mod.Suggests, domain.DependencyOptional)
+	addDeps(mod.Breaks, domain.DependencyIncompatible)
+
+	return manifest, nil
+}
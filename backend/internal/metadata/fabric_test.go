@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"ModVerse/domain"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFabricExtractor(t *testing.T) {
+	fsys := fstest.MapFS{"fabric.mod.json": {Data: []byte(`{
+		"schemaVersion": 1,
+		"id": "examplemod",
+		"version": "1.2.0",
+		"name": "Example Mod",
+		"description": "An example mod.",
+		"authors": ["Alice", {"name": "Bob", "contact": {}}, 42],
+		"depends": {
+			"fabricloader": ">=0.15",
+			"java": ">=17",
+			"minecraft": ["1.20.1", "1.20.2"],
+			"fabric-api": "*"
+		},
+		"recommends": {"modmenu": ">=7"},
+		"breaks": {"optifabric": "*"}
+	}`)}}
+
+	manifest, err := fabricExtractor{}.Extract(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &domain.ModManifest{
+		Source:           "fabric.mod.json",
+		ModID:            "examplemod",
+		Name:             "Example Mod",
+		Version:          "1.2.0",
+		Description:      "An example mod.",
+		Authors:          []string{"Alice", "Bob"},
+		GameVersionRange: "1.20.1 || 1.20.2",
+		Dependencies: []domain.ManifestDependency{
+			{ModID: "fabric-api", VersionRange: "*", Type: domain.DependencyRequired},
+			{ModID: "modmenu", VersionRange: ">=7", Type: domain.DependencyOptional},
+			{ModID: "optifabric", VersionRange: "*", Type: domain.DependencyIncompatible},
+		},
+	}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("Extract = %+v\nwant %+v", manifest, want)
+	}
+}
+
+func TestFabricExtractorPlaceholderVersion(t *testing.T) {
+	fsys := fstest.MapFS{"fabric.mod.json": {Data: []byte(`{"id": "m", "version": "${version}"}`)}}
+
+	manifest, err := fabricExtractor{}.Extract(fsys)
+	if err != nil || manifest.Version != "" {
+		t.Errorf("Extract = %+v, %v, want empty version", manifest, err)
+	}
+}
+
+func TestFabricExtractorMissingOrInvalid(t *testing.T) {
+	if manifest, err := (fabricExtractor{}).Extract(fstest.MapFS{}); manifest != nil || err != nil {
+		t.Errorf("Extract without fabric.mod.json = %+v, %v", manifest, err)
+	}
+
+	fsys := fstest.MapFS{"fabric.mod.json": {Data: []byte(`{"id": "m", "depends": {"x": 1}}`)}}
+	if _, err := (fabricExtractor{}).Extract(fsys); err == nil {
+		t.Error("Extract with invalid depends succeeded")
+	}
+}
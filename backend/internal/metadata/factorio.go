@@ -0,0 +1,97 @@
+package metadata
+
+import (
+	"ModVerse/domain"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+// info.json https://wiki.factorio.com/Tutorial:Mod_structure#info.json
+// 模组压缩包内通常有一层 名称_版本 目录
+type factorioExtractor struct{}
+
+type factorioInfoJSON struct {
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	Title           string   `json:"title"`
+	Author          string   `json:"author"`
+	Description     string   `json:"description"`
+	FactorioVersion string   `json:"factorio_version"`
+	Dependencies    []string `json:"dependencies"`
+}
+
+// 依赖格式: [前缀] 名称 [比较符 版本],前缀 ! 不兼容、? 与 (?) 可选、~ 必需但不影响加载顺序
+var factorioDependency = regexp.MustCompile(`^(!|\?|\(\?\)|~)?\s*(.+?)(?:\s*(<=|>=|<|>|=)\s*(\S+))?$`)
+
+func (factorioExtractor) Name() string {
+	return "factorio"
+}
+
+func (factorioExtractor) Extract(fsys fs.FS) (*domain.ModManifest, error) {
+	source, err := findManifest(fsys, 1, func(name string) bool { return name == "info.json" })
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readManifest(fsys, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var info factorioInfoJSON
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+
+	manifest := &domain.ModManifest{
+		Source:      source,
+		ModID:       info.Name,
+		Name:        info.Title,
+		Version:     info.Version,
+		Description: info.Description,
+	}
+	if info.Author != "" {
+		manifest.Authors = []string{info.Author}
+	}
+	// factorio_version只有主次版本号,如 1.1 表示 1.1.x
+	if info.FactorioVersion != "" {
+		manifest.GameVersionRange = "~" + info.FactorioVersion
+	}
+
+	for _, dep := range info.Dependencies {
+		m := factorioDependency.FindStringSubmatch(strings.TrimSpace(dep))
+		if m == nil || m[2] == "base" {
+			continue
+		}
+
+		depType := domain.DependencyRequired
+		switch m[1] {
+		case "!":
+			depType = domain.DependencyIncompatible
+		case "?", "(?)":
+			depType = domain.DependencyOptional
+		}
+
+		versionRange := "*"
+		if m[3] != "" {
+			versionRange = m[3] + m[4]
+			if m[3] == "=" {
+				versionRange = m[4]
+			}
+		}
+
+		manifest.Dependencies = append(manifest.Dependencies, domain.ManifestDependency{
+			ModID:        m[2],
+			VersionRange: versionRange,
+			Type:         depType,
+		})
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"ModVerse/domain"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFactorioExtractor(t *testing.T) {
+	fsys := fstest.MapFS{"examplemod_1.1.0/info.json": {Data: []byte(`{
+		"name": "examplemod",
+		"version": "1.1.0",
+		"title": "Example Mod",
+		"author": "Alice",
+		"factorio_version": "1.1",
+		"dependencies": [
+			"base >= 1.1",
+			"flib >= 0.12.0",
+			"? helmod",
+			"(?) space-exploration",
+			"! bobsmods",
+			"~ stdlib = 1.0.8"
+		]
+	}`)}}
+
+	manifest, err := factorioExtractor{}.Extract(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &domain.ModManifest{
+		Source:           "examplemod_1.1.0/info.json",
+		ModID:            "examplemod",
+		Name:             "Example Mod",
+		Version:          "1.1.0",
+		Authors:          []string{"Alice"},
+		GameVersionRange: "~1.1",
+		Dependencies: []domain.ManifestDependency{
+			{ModID: "flib", VersionRange: ">=0.12.0", Type: domain.DependencyRequired},
+			{ModID: "helmod", VersionRange: "*", Type: domain.DependencyOptional},
+			{ModID: "space-exploration", VersionRange: "*", Type: domain.DependencyOptional},
+			{ModID: "bobsmods", VersionRange: "*", Type: domain.DependencyIncompatible},
+			{ModID: "stdlib", VersionRange: "1.0.8", Type: domain.DependencyRequired},
+		},
+	}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("Extract = %+v\nwant %+v", manifest, want)
+	}
+}
+
+// 只查找第一层目录,更深的info.json不是模组清单
+func TestFactorioExtractorDepth(t *testing.T) {
+	fsys := fstest.MapFS{"mod/data/info.json": {Data: []byte(`{"name": "x"}`)}}
+	if manifest, err := (factorioExtractor{}).Extract(fsys); manifest != nil || err != nil {
+		t.Errorf("Extract = %+v, %v, want nil", manifest, err)
+	}
+}
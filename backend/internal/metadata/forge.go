@@ -0,0 +1,132 @@
+package metadata
+
+import (
+	"ModVerse/domain"
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// NeoForge使用独立的清单文件名,优先读取
+var forgeManifests = []string{"META-INF/neoforge.mods.toml", "META-INF/mods.toml"}
+
+// 加载器本身,不作为模组依赖
+var forgePlatformIDs = map[string]bool{"forge": true, "neoforge": true, "javafml": true}
+
+// mods.toml https://docs.minecraftforge.net/en/latest/gettingstarted/modfiles/
+type forgeExtractor struct{}
+
+type forgeModsTOML struct {
+	Mods []struct {
+		ModID       string `toml:"modId"`
+		Version     string `toml:"version"`
+		DisplayName string `toml:"displayName"`
+		Description string `toml:"description"`
+		Authors     string `toml:"authors"`
+	} `toml:"mods"`
+	Dependencies map[string][]struct {
+		ModID        string `toml:"modId"`
+		Mandatory    *bool  `toml:"mandatory"` // Forge
+		Type         string `toml:"type"`      // NeoForge: required/optional/incompatible/discouraged
+		VersionRange string `toml:"versionRange"`
+	} `toml:"dependencies"`
+}
+
+func (forgeExtractor) Name() string {
+	return "forge"
+}
+
+func (forgeExtractor) Extract(fsys fs.FS) (*domain.ModManifest, error) {
+	var source string
+	var data []byte
+	for _, name := range forgeManifests {
+		d, err := readManifest(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		source, data = name, d
+		break
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var mods forgeModsTOML
+	if err := toml.Unmarshal(data, &mods); err != nil {
+		return nil, err
+	}
+	if len(mods.Mods) == 0 {
+		return nil, errors.New(source + " declares no mods")
+	}
+
+	// 一个jar可以包含多个模组,以第一个为准
+	mod := mods.Mods[0]
+	manifest := &domain.ModManifest{
+		Source:      source,
+		ModID:       mod.ModID,
+		Name:        mod.DisplayName,
+		Description: strings.TrimSpace(mod.Description),
+		Version:     mod.Version,
+	}
+	if placeholder(manifest.Version) {
+		manifest.Version = jarImplementationVersion(fsys)
+	}
+	for _, author := range strings.Split(mod.Authors, ",") {
+		if author = strings.TrimSpace(author); author != "" {
+			manifest.Authors = append(manifest.Authors, author)
+		}
+	}
+
+	for _, dep := range mods.Dependencies[mod.ModID] {
+		if dep.ModID == "minecraft" {
+			manifest.GameVersionRange = mavenRange(dep.VersionRange)
+			continue
+		}
+		if forgePlatformIDs[dep.ModID] {
+			continue
+		}
+
+		depType := domain.DependencyRequired
+		switch {
+		case dep.Type == "optional", dep.Type == "" && dep.Mandatory != nil && !*dep.Mandatory:
+			depType = domain.DependencyOptional
+		case dep.Type == "incompatible", dep.Type == "discouraged":
+			depType = domain.DependencyIncompatible
+		}
+
+		manifest.Dependencies = append(manifest.Dependencies, domain.ManifestDependency{
+			ModID:        dep.ModID,
+			VersionRange: mavenRange(dep.VersionRange),
+			Type:         depType,
+		})
+	}
+
+	return manifest, nil
+}
+
+// 清单中的版本为 ${file.jarVersion} 时取jar清单的Implementation-Version
+func jarImplementationVersion(fsys fs.FS) string {
+	data, err := readManifest(fsys, "META-INF/MANIFEST.MF")
+	if err != nil {
+		return ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "Implementation-Version:"); ok {
+			v = strings.TrimSpace(v)
+			if !placeholder(v) {
+				return v
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"ModVerse/domain"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+const forgeModsTOMLData = `
+modLoader = "javafml"
+loaderVersion = "[47,)"
+
+[[mods]]
+modId = "examplemod"
+version = "${file.jarVersion}"
+displayName = "Example Mod"
+authors = "Alice, Bob"
+description = '''
+An example mod.
+'''
+
+[[dependencies.examplemod]]
+modId = "forge"
+mandatory = true
+versionRange = "[47,)"
+
+[[dependencies.examplemod]]
+modId = "minecraft"
+mandatory = true
+versionRange = "[1.20.1,1.21)"
+
+[[dependencies.examplemod]]
+modId = "jei"
+mandatory = false
+versionRange = "[15.2,)"
+
+[[dependencies.examplemod]]
+modId = "curios"
+mandatory = true
+versionRange = "5.4.0"
+`
+
+func TestForgeExtractor(t *testing.T) {
+	fsys := fstest.MapFS{
+		"META-INF/mods.toml":   {Data: []byte(forgeModsTOMLData)},
+		"META-INF/MANIFEST.MF": {Data: []byte("Manifest-Version: 1.0\nImplementation-Version: 2.1.0\n")},
+	}
+
+	manifest, err := forgeExtractor{}.Extract(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &domain.ModManifest{
+		Source:           "META-INF/mods.toml",
+		ModID:            "examplemod",
+		Name:             "Example Mod",
+		Version:          "2.1.0",
+		Description:      "An example mod.",
+		Authors:          []string{"Alice", "Bob"},
+		GameVersionRange: ">=1.20.1 <1.21",
+		Dependencies: []domain.ManifestDependency{
+			{ModID: "jei", VersionRange: ">=15.2", Type: domain.DependencyOptional},
+			{ModID: "curios", VersionRange: ">=5.4.0", Type: domain.DependencyRequired},
+		},
+	}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("Extract = %+v\nwant %+v", manifest, want)
+	}
+}
+
+// NeoForge的清单优先,依赖类型使用type字段
+func TestForgeExtractorNeoForge(t *testing.T) {
+	fsys := fstest.MapFS{
+		"META-INF/mods.toml": {Data: []byte(forgeModsTOMLData)},
+		"META-INF/neoforge.mods.toml": {Data: []byte(`
+[[mods]]
+modId = "neomod"
+version = "1.0.0"
+
+[[dependencies.neomod]]
+modId = "neoforge"
+type = "required"
+versionRange = "[20.4,)"
+
+[[dependencies.neomod]]
+modId = "badmod"
+type = "incompatible"
+
+[[dependencies.neomod]]
+modId = "oldmod"
+type = "discouraged"
+versionRange = "[1.0]"
+`)},
+	}
+
+	manifest, err := forgeExtractor{}.Extract(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Source != "META-INF/neoforge.mods.toml" || manifest.ModID != "neomod" || manifest.Version != "1.0.0" {
+		t.Errorf("Extract = %+v", manifest)
+	}
+	want := []domain.ManifestDependency{
+		{ModID: "badmod", VersionRange: "*", Type: domain.DependencyIncompatible},
+		{ModID: "oldmod", VersionRange: "1.0", Type: domain.DependencyIncompatible},
+	}
+	if !reflect.DeepEqual(manifest.Dependencies, want) {
+		t.Errorf("Dependencies = %+v, want %+v", manifest.Dependencies, want)
+	}
+}
+
+func TestForgeExtractorMissingOrInvalid(t *testing.T) {
+	if manifest, err := (forgeExtractor{}).Extract(fstest.MapFS{}); manifest != nil || err != nil {
+		t.Errorf("Extract without mods.toml = %+v, %v", manifest, err)
+	}
+
+	fsys := fstest.MapFS{"META-INF/mods.toml": {Data: []byte(`modLoader = "javafml"`)}}
+	if _, err := (forgeExtractor{}).Extract(fsys); err == nil {
+		t.Error("Extract without [[mods]] succeeded")
+	}
+}
@@ -0,0 +1,79 @@
+package metadata
+
+import (
+	"ModVerse/domain"
+	"archive/zip"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// 清单文件的大小上限,超过的视为无效
+const maxManifestSize = 1 << 20
+
+type registry struct {
+	mu         sync.RWMutex
+	extractors map[uint][]domain.MetadataExtractor
+}
+
+func NewRegistry() domain.MetadataRegistry {
+	return &registry{
+		extractors: make(map[uint][]domain.MetadataExtractor),
+	}
+}
+
+// Builtin 按名称获取内置提取器
+func Builtin(name string) (domain.MetadataExtractor, error) {
+	switch name {
+	case "fabric":
+		return fabricExtractor{}, nil
+	case "forge":
+		return forgeExtractor{}, nil
+	case "factorio":
+		return factorioExtractor{}, nil
+	case "bethesda":
+		return bethesdaExtractor{}, nil
+	default:
+		return nil, fmt.Errorf("unknown metadata extractor: %s", name)
+	}
+}
+
+func (r *registry) Register(gameID uint, extractors ...domain.MetadataExtractor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.extractors[gameID] = append(r.extractors[gameID], extractors...)
+}
+
+// Extract 打开已检查过的压缩包并依次尝试该游戏的提取器,目前只支持zip格式
+// 清单格式错误时记录日志并尝试下一个提取器,不影响上传
+func (r *registry) Extract(gameID uint, path string, format string) (*domain.ModManifest, error) {
+	r.mu.RLock()
+	extractors := r.extractors[gameID]
+	r.mu.RUnlock()
+
+	if len(extractors) == 0 || format != domain.ArchiveZip {
+		return nil, nil
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	for _, e := range extractors {
+		manifest, err := e.Extract(zr)
+		if err != nil {
+			log.Printf("metadata: %s: %v", e.Name(), err)
+			continue
+		}
+		if manifest != nil {
+			manifest.GameID = gameID
+			manifest.Extractor = e.Name()
+			return manifest, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+var errManifestTooLarge = errors.New("manifest too large")
+
+// 读取清单文件,文件不存在时返回fs.ErrNotExist
+func readManifest(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxManifestSize {
+		return nil, errManifestTooLarge
+	}
+
+	return data, nil
+}
+
+// 查找文件名匹配的清单,只查找maxDepth层目录以内,浅层优先
+func findManifest(fsys fs.FS, maxDepth int, match func(name string) bool) (string, error) {
+	var found string
+	foundDepth := maxDepth + 1
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		depth := strings.Count(p, "/")
+		if d.IsDir() {
+			if p != "." && depth >= maxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if depth < foundDepth && match(path.Base(p)) {
+			found, foundDepth = p, depth
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", fs.ErrNotExist
+	}
+
+	return found, nil
+}
+
+// 构建工具未替换的占位符(如 ${version})视为未填写
+func placeholder(s string) bool {
+	return strings.Contains(s, "${")
+}
+
+// 将Maven版本范围转换为npm语法: [1.0,2.0) → >=1.0 <2.0,[1.0] → 1.0,1.0 → >=1.0
+// 多个范围以 || 连接,无法转换时返回原文
+func mavenRange(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "*" {
+		return "*"
+	}
+	if !strings.ContainsAny(s, "[(") {
+		return ">=" + s
+	}
+
+	var sets []string
+	rest := s
+	for rest != "" {
+		rest = strings.TrimLeft(rest, ", ")
+		if rest == "" {
+			break
+		}
+		end := strings.IndexAny(rest, "])")
+		if end < 0 || (rest[0] != '[' && rest[0] != '(') {
+			return s
+		}
+		set, ok := mavenSet(rest[:end+1])
+		if !ok {
+			return s
+		}
+		sets = append(sets, set)
+		rest = rest[end+1:]
+	}
+
+	return strings.Join(sets, " || ")
+}
+
+func mavenSet(s string) (string, bool) {
+	open, close := s[0], s[len(s)-1]
+	body := s[1 : len(s)-1]
+
+	lower, upper, hasComma := strings.Cut(body, ",")
+	lower, upper = strings.TrimSpace(lower), strings.TrimSpace(upper)
+	if !hasComma {
+		if open != '[' || close != ']' || lower == "" {
+			return "", false
+		}
+		return lower, true
+	}
+
+	var parts []string
+	if lower != "" {
+		if open == '[' {
+			parts = append(parts, ">="+lower)
+		} else {
+			parts = append(parts, ">"+lower)
+		}
+	}
+	if upper != "" {
+		if close == ']' {
+			parts = append(parts, "<="+upper)
+		} else {
+			parts = append(parts, "<"+upper)
+		}
+	}
+	if len(parts) == 0 {
+		return "*", true
+	}
+
+	return strings.Join(parts, " "), true
+}
@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestMavenRange(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "*"},
+		{"*", "*"},
+		{"1.20.1", ">=1.20.1"},
+		{"[1.20.1]", "1.20.1"},
+		{"[1.20,1.21)", ">=1.20 <1.21"},
+		{"(1.20,1.21]", ">1.20 <=1.21"},
+		{"[47,)", ">=47"},
+		{"(,1.0]", "<=1.0"},
+		{"(,)", "*"},
+		{"[1.0,1.2),[1.3,)", ">=1.0 <1.2 || >=1.3"},
+		{" [ 1.0 , 2.0 ) ", ">=1.0 <2.0"},
+		// 无法转换时返回原文
+		{"(1.0)", "(1.0)"},
+		{"[1.0", "[1.0"},
+		{"[1.0,2.0) junk", "[1.0,2.0) junk"},
+	}
+
+	for _, tt := range tests {
+		if got := mavenRange(tt.in); got != tt.want {
+			t.Errorf("mavenRange(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFindManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b/info.json":     {Data: []byte("{}")},
+		"mod_1.0/info.json": {Data: []byte("{}")},
+		"readme.txt":        {Data: []byte("x")},
+	}
+	match := func(name string) bool { return name == "info.json" }
+
+	if got, err := findManifest(fsys, 1, match); err != nil || got != "mod_1.0/info.json" {
+		t.Errorf("findManifest depth 1 = %q, %v", got, err)
+	}
+	if _, err := findManifest(fsys, 0, match); err == nil {
+		t.Error("findManifest depth 0 found a nested manifest")
+	}
+}
+
+func TestReadManifestTooLarge(t *testing.T) {
+	fsys := fstest.MapFS{"big.json": {Data: make([]byte, maxManifestSize+1)}}
+	if _, err := readManifest(fsys, "big.json"); err != errManifestTooLarge {
+		t.Errorf("readManifest error = %v, want errManifestTooLarge", err)
+	}
+}
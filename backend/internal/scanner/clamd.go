@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"ModVerse/domain"
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// 每次发送给clamd的数据块大小
+const clamdChunkSize = 64 << 10
+
+// 通过clamd的INSTREAM命令扫描,文件内容经套接字发送,clamd无需访问存储目录
+// 注意clamd的StreamMaxLength默认只有25M,需调整到不小于模组文件的上传限制
+type clamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner address为 unix:///var/run/clamav/clamd.ctl 或 tcp://127.0.0.1:3310,不带协议时按tcp处理
+// timeout为单个文件的扫描时间上限
+func NewClamdScanner(address string, timeout time.Duration) domain.Scanner {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		network, address = "unix", path
+	} else {
+		address = strings.TrimPrefix(address, "tcp://")
+	}
+
+	return &clamdScanner{
+		network: network,
+		address: address,
+		timeout: timeout,
+	}
+}
+
+func (s *clamdScanner) Scan(c context.Context, r io.Reader) (*domain.ScanResult, error) {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+
+	// 每块前加4字节大端长度,以长度为0的块结束
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := conn.Write(buf[:4+n]); werr != nil {
+				// clamd超过StreamMaxLength时会先回复错误再断开,优先返回其回复
+				if reply, rerr := readClamdReply(conn); rerr == nil {
+					return parseClamdReply(reply)
+				}
+				return nil, werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseClamdReply(reply)
+}
+
+func readClamdReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", err
+	}
+	return strings.TrimRight(reply, "\x00\n"), nil
+}
+
+// 回复格式: "stream: OK"、"stream: <特征名> FOUND" 或 "<原因> ERROR"
+// 超过StreamMaxLength时回复 "INSTREAM size limit exceeded. ERROR"
+func parseClamdReply(reply string) (*domain.ScanResult, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case strings.Contains(result, "size limit exceeded"):
+		return nil, fmt.Errorf("%w: clamd: %s", domain.ErrScanTooLarge, result)
+	case result == "OK":
+		return &domain.ScanResult{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &domain.ScanResult{
+			Infected:  true,
+			Signature: strings.TrimSuffix(result, " FOUND"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", result)
+	}
+}
@@ -0,0 +1,117 @@
+package scanner
+
+import (
+	"ModVerse/domain"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// 模拟clamd的INSTREAM协议,内容含EICAR时报告感染,超过maxLength时与clamd一样回复错误并断开
+func startFakeClamd(t *testing.T, maxLength int) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeClamd(conn, maxLength)
+		}
+	}()
+
+	return "tcp://" + ln.Addr().String()
+}
+
+func serveFakeClamd(conn net.Conn, maxLength int) {
+	defer conn.Close()
+
+	cmd := make([]byte, len("zINSTREAM\x00"))
+	if _, err := io.ReadFull(conn, cmd); err != nil || string(cmd) != "zINSTREAM\x00" {
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var data bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&data, conn, int64(size)); err != nil {
+			return
+		}
+		if data.Len() > maxLength {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			return
+		}
+	}
+
+	if bytes.Contains(data.Bytes(), []byte(eicar)) {
+		conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		return
+	}
+	conn.Write([]byte("stream: OK\x00"))
+}
+
+func TestClamdScanner(t *testing.T) {
+	s := NewClamdScanner(startFakeClamd(t, 1<<20), 5*time.Second)
+
+	result, err := s.Scan(context.Background(), strings.NewReader("harmless mod content"))
+	if err != nil || result.Infected {
+		t.Errorf("Scan(clean) = %+v, %v", result, err)
+	}
+
+	// 特征跨越两个数据块
+	content := strings.Repeat("a", clamdChunkSize-10) + eicar
+	result, err = s.Scan(context.Background(), strings.NewReader(content))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Errorf("Scan(eicar) = %+v, %v", result, err)
+	}
+}
+
+func TestClamdScannerSizeLimit(t *testing.T) {
+	s := NewClamdScanner(startFakeClamd(t, 128<<10), 5*time.Second)
+
+	_, err := s.Scan(context.Background(), bytes.NewReader(make([]byte, 4<<20)))
+	if !errors.Is(err, domain.ErrScanTooLarge) {
+		t.Errorf("Scan over StreamMaxLength error = %v, want ErrScanTooLarge", err)
+	}
+}
+
+func TestClamdScannerUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = NewClamdScanner(addr, time.Second).Scan(context.Background(), strings.NewReader("x"))
+	if err == nil || errors.Is(err, domain.ErrScanTooLarge) {
+		t.Errorf("Scan without clamd error = %v, want a retryable error", err)
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	if _, err := parseClamdReply("Can't allocate memory ERROR"); err == nil || errors.Is(err, domain.ErrScanTooLarge) {
+		t.Errorf("parseClamdReply(ERROR) error = %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"context"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 本地文件系统存储,文件通过静态文件服务访问
+type localStorage struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalStorage root为存储目录,baseURL为静态下载地址前缀,如 http://localhost:3000/api/download/
+func NewLocalStorage(root string, baseURL string) domain.Storage {
+	return &localStorage{
+		root:    root,
+		baseURL: baseURL,
+	}
+}
+
+// 将key限制在存储目录内
+func (s *localStorage) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+// Put 先写入同目录的临时文件再重命名,读取方不会看到写了一半的文件
+func (s *localStorage) Put(c context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+func (s *localStorage) Get(c context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, domain.ErrObjectNotExist
+	}
+	return f, err
+}
+
+// Delete 文件不存在时视为成功
+func (s *localStorage) Delete(c context.Context, key string) error {
+	path := s.path(key)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	return utils.DeleteFile(path)
+}
+
+func (s *localStorage) Stat(c context.Context, key string) (*domain.StorageObject, error) {
+	info, err := os.Stat(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, domain.ErrObjectNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.StorageObject{
+		Key:     key,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// PresignGet 本地存储没有签名机制,返回静态下载地址
+func (s *localStorage) PresignGet(c context.Context, key string, expiry time.Duration, fileName string) (string, error) {
+	u := s.baseURL + strings.TrimPrefix(key, "/")
+	if fileName != "" {
+		u += "?filename=" + url.QueryEscape(fileName)
+	}
+	return u, nil
+}
+
+// List prefix对应的目录不存在时视为没有对象
+func (s *localStorage) List(c context.Context, prefix string, fn func(obj *domain.StorageObject) error) error {
+	root := s.path(prefix)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return c.Err()
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+
+		return fn(&domain.StorageObject{
+			Key:     filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	})
+}
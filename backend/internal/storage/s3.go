@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"ModVerse/domain"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3兼容存储,支持AWS S3、MinIO等
+type s3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+type S3Config struct {
+	Endpoint  string // 不含协议,如 127.0.0.1:9000
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+}
+
+// NewS3Storage 创建S3存储,bucket不存在时自动创建
+func NewS3Storage(c context.Context, config S3Config) (domain.Storage, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(c, config.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(c, config.Bucket, minio.MakeBucketOptions{Region: config.Region}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &s3Storage{
+		client: client,
+		bucket: config.Bucket,
+	}, nil
+}
+
+func isNotExist(err error) bool {
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) {
+		return resp.Code == "NoSuchKey" || resp.StatusCode == 404
+	}
+	return false
+}
+
+func (s *s3Storage) Put(c context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(c, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *s3Storage) Get(c context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(c, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// GetObject不会立即请求,先Stat确认对象存在
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if isNotExist(err) {
+			return nil, domain.ErrObjectNotExist
+		}
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// Delete 对象不存在时视为成功
+func (s *s3Storage) Delete(c context.Context, key string) error {
+	return s.client.RemoveObject(c, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Storage) Stat(c context.Context, key string) (*domain.StorageObject, error) {
+	info, err := s.client.StatObject(c, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotExist(err) {
+			return nil, domain.ErrObjectNotExist
+		}
+		return nil, err
+	}
+
+	return &domain.StorageObject{
+		Key:     key,
+		Size:    info.Size,
+		ModTime: info.LastModified,
+	}, nil
+}
+
+func (s *s3Storage) PresignGet(c context.Context, key string, expiry time.Duration, fileName string) (string, error) {
+	params := url.Values{}
+	if fileName != "" {
+		params.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	}
+
+	u, err := s.client.PresignedGetObject(c, s.bucket, key, expiry, params)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+func (s *s3Storage) List(c context.Context, prefix string, fn func(obj *domain.StorageObject) error) error {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return info.Err
+		}
+		if err := fn(&domain.StorageObject{
+			Key:     info.Key,
+			Size:    info.Size,
+			ModTime: info.LastModified,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
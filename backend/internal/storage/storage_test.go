@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"ModVerse/domain"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 各存储后端共用的行为测试
+func testStorage(t *testing.T, store domain.Storage, presignFetch bool) {
+	ctx := context.Background()
+	const key = "blobs/ab/abcdef.zip"
+	content := "hello storage"
+
+	if _, err := store.Stat(ctx, key); !errors.Is(err, domain.ErrObjectNotExist) {
+		t.Fatalf("Stat missing object error = %v, want ErrObjectNotExist", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, domain.ErrObjectNotExist) {
+		t.Fatalf("Get missing object error = %v, want ErrObjectNotExist", err)
+	}
+
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "application/zip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "blobs/cd/other.png", strings.NewReader("x"), 1, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "quarantine/blobs/ef/bad.zip", strings.NewReader("y"), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := store.Stat(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Key != key || obj.Size != int64(len(content)) || obj.ModTime.IsZero() {
+		t.Errorf("Stat = %+v", obj)
+	}
+
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != content {
+		t.Errorf("Get = %q, %v", data, err)
+	}
+
+	var keys []string
+	if err := store.List(ctx, "blobs/", func(obj *domain.StorageObject) error {
+		keys = append(keys, obj.Key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if fmt.Sprint(keys) != fmt.Sprint([]string{key, "blobs/cd/other.png"}) {
+		t.Errorf("List(blobs/) = %v", keys)
+	}
+	if err := store.List(ctx, "missing/", func(obj *domain.StorageObject) error {
+		t.Errorf("List(missing/) returned %s", obj.Key)
+		return nil
+	}); err != nil {
+		t.Errorf("List(missing/) error = %v", err)
+	}
+
+	location, err := store.PresignGet(ctx, key, time.Minute, "my mod.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presignFetch {
+		resp, err := http.Get(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != content {
+			t.Errorf("GET presigned URL = %d %q", resp.StatusCode, body)
+		}
+		if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, "my mod.zip") {
+			t.Errorf("Content-Disposition = %q", cd)
+		}
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete missing object error = %v", err)
+	}
+	if _, err := store.Stat(ctx, key); !errors.Is(err, domain.ErrObjectNotExist) {
+		t.Errorf("Stat deleted object error = %v", err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, NewLocalStorage(t.TempDir(), "http://localhost/api/download/"), false)
+}
+
+// 需要S3兼容服务,未设置 MODVERSE_TEST_S3_ENDPOINT 时跳过,本地可使用MinIO:
+//
+//	docker run -d -p 9000:9000 minio/minio server /data
+//	MODVERSE_TEST_S3_ENDPOINT=127.0.0.1:9000 MODVERSE_TEST_S3_ACCESS_KEY=minioadmin \
+//	MODVERSE_TEST_S3_SECRET_KEY=minioadmin go test ./internal/storage/
+//
+// 每次使用新的存储桶,测试结束后删除
+func TestS3Storage(t *testing.T) {
+	endpoint := os.Getenv("MODVERSE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MODVERSE_TEST_S3_ENDPOINT not set")
+	}
+
+	ctx := context.Background()
+	bucket := fmt.Sprintf("modverse-test-%d", time.Now().UnixNano())
+	store, err := NewS3Storage(ctx, S3Config{
+		Endpoint:  endpoint,
+		AccessKey: os.Getenv("MODVERSE_TEST_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("MODVERSE_TEST_S3_SECRET_KEY"),
+		Bucket:    bucket,
+		Region:    os.Getenv("MODVERSE_TEST_S3_REGION"),
+		UseSSL:    os.Getenv("MODVERSE_TEST_S3_SSL") == "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		s := store.(*s3Storage)
+		s.List(ctx, "", func(obj *domain.StorageObject) error {
+			return s.Delete(ctx, obj.Key)
+		})
+		if err := s.client.RemoveBucket(ctx, bucket); err != nil {
+			t.Logf("remove bucket %s: %v", bucket, err)
+		}
+	})
+
+	testStorage(t, store, true)
+}
@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"ModVerse/domain"
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// 单个文件超过该大小时才检查压缩比,避免小文件的高压缩比误判
+const archiveRatioMinSize = 1 << 20
+
+// InspectArchive 逐个解压压缩包内的文件,生成文件清单并检查路径、大小、压缩比与嵌套层数
+// mimeType为识别出的类型,不支持检查的格式返回nil
+func InspectArchive(filePath string, mimeType string, fileName string, limits domain.ArchiveLimits) (*domain.StorageFileArchive, error) {
+	format := archiveFormat(mimeType, fileName)
+	if format == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &archiveWalker{limits: limits}
+	if err := w.walk(format, f, info.Size(), "", 0); err != nil {
+		return nil, err
+	}
+
+	archive := &domain.StorageFileArchive{
+		Format:     format,
+		EntryCount: len(w.entries),
+		TotalSize:  w.size,
+		Depth:      w.depth,
+		Entries:    w.entries,
+	}
+	if info.Size() > 0 {
+		archive.Ratio = float64(w.size) / float64(info.Size())
+	}
+	if archive.Ratio > limits.MaxRatio {
+		return nil, fmt.Errorf("%w: %q expands to %d bytes, %.0f times its size, the limit is %.0f",
+			domain.ErrArchiveRejected, fileName, w.size, archive.Ratio, limits.MaxRatio)
+	}
+
+	return archive, nil
+}
+
+// 按识别出的类型确定压缩格式,gzip只支持打包为tar的情况
+func archiveFormat(mimeType string, fileName string) string {
+	m := mimetype.Lookup(mimeType)
+	if m == nil {
+		return ""
+	}
+
+	switch {
+	case m.Is("application/zip") || m.Is("application/jar"):
+		return domain.ArchiveZip
+	case m.Is("application/x-tar"):
+		return domain.ArchiveTar
+	case m.Is("application/gzip"):
+		name := strings.ToLower(fileName)
+		if strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") {
+			return domain.ArchiveTarGz
+		}
+	}
+
+	return ""
+}
+
+// 按文件名判断压缩包内的文件是否为可检查的嵌套压缩包
+func nestedArchiveFormat(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"), strings.HasSuffix(name, ".jar"):
+		return domain.ArchiveZip
+	case strings.HasSuffix(name, ".tar"):
+		return domain.ArchiveTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return domain.ArchiveTarGz
+	default:
+		return ""
+	}
+}
+
+// 压缩包内的路径不能是绝对路径,也不能通过 .. 跳出解压目录
+func checkArchivePath(name string) error {
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	if name == "" || strings.ContainsRune(name, 0) {
+		return fmt.Errorf("%w: entry %q has an invalid path", domain.ErrArchiveRejected, name)
+	}
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return fmt.Errorf("%w: entry %q has an absolute path", domain.ErrArchiveRejected, name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return fmt.Errorf("%w: entry %q escapes the extraction directory", domain.ErrArchiveRejected, name)
+		}
+	}
+
+	return nil
+}
+
+type archiveWalker struct {
+	limits  domain.ArchiveLimits
+	entries []domain.ArchiveEntry
+	total   int64 // 已解压的字节数,含嵌套压缩包的内容
+	size    int64 // 最外层文件解压后的字节数
+	depth   int
+}
+
+func (w *archiveWalker) walk(format string, r io.ReaderAt, size int64, prefix string, depth int) error {
+	if depth > w.depth {
+		w.depth = depth
+	}
+
+	switch format {
+	case domain.ArchiveZip:
+		return w.walkZip(r, size, prefix, depth)
+	case domain.ArchiveTarGz:
+		gz, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return w.readError(prefix, err)
+		}
+		defer gz.Close()
+		return w.walkTar(gz, prefix, depth)
+	default:
+		return w.walkTar(io.NewSectionReader(r, 0, size), prefix, depth)
+	}
+}
+
+func (w *archiveWalker) walkZip(r io.ReaderAt, size int64, prefix string, depth int) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return w.readError(prefix, err)
+	}
+
+	for _, f := range zr.File {
+		if err := checkArchivePath(f.Name); err != nil {
+			return err
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: entry %q is a symbolic link", domain.ErrArchiveRejected, prefix+f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return w.readError(prefix+f.Name, err)
+		}
+		err = w.addEntry(rc, prefix, f.Name, int64(f.CompressedSize64), depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *archiveWalker) walkTar(r io.Reader, prefix string, depth int) error {
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return w.readError(prefix, err)
+		}
+
+		if err := checkArchivePath(hdr.Name); err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			if err := w.addEntry(tr, prefix, hdr.Name, 0, depth); err != nil {
+				return err
+			}
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+		case tar.TypeSymlink, tar.TypeLink:
+			return fmt.Errorf("%w: entry %q is a link", domain.ErrArchiveRejected, prefix+hdr.Name)
+		default:
+			return fmt.Errorf("%w: entry %q is not a regular file", domain.ErrArchiveRejected, prefix+hdr.Name)
+		}
+	}
+}
+
+// 解压单个文件并计入清单,不信任压缩包中记录的大小,按实际解压的字节数计算
+// 嵌套压缩包在大小不超过限制时读入内存继续检查
+func (w *archiveWalker) addEntry(r io.Reader, prefix string, name string, compressed int64, depth int) error {
+	entryPath := prefix + strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+
+	if len(w.entries) >= w.limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", domain.ErrArchiveRejected, w.limits.MaxEntries)
+	}
+
+	nested := nestedArchiveFormat(name)
+	if nested != "" && depth+1 > w.limits.MaxDepth {
+		return fmt.Errorf("%w: entry %q nests archives deeper than %d levels", domain.ErrArchiveRejected, entryPath, w.limits.MaxDepth)
+	}
+
+	var dst io.Writer = io.Discard
+	var buf *cappedBuffer
+	if nested != "" {
+		buf = &cappedBuffer{limit: w.limits.MaxNestedSize}
+		dst = buf
+	}
+
+	budget := w.limits.MaxTotalSize - w.total
+	n, err := io.Copy(dst, io.LimitReader(r, budget+1))
+	if err != nil {
+		return w.readError(entryPath, err)
+	}
+	if n > budget {
+		return fmt.Errorf("%w: expands to more than %d bytes", domain.ErrArchiveRejected, w.limits.MaxTotalSize)
+	}
+	w.total += n
+	if depth == 0 {
+		w.size += n
+	}
+
+	if compressed > 0 && n > archiveRatioMinSize && float64(n)/float64(compressed) > w.limits.MaxRatio {
+		return fmt.Errorf("%w: entry %q expands to %d bytes from %d, the ratio limit is %.0f",
+			domain.ErrArchiveRejected, entryPath, n, compressed, w.limits.MaxRatio)
+	}
+
+	w.entries = append(w.entries, domain.ArchiveEntry{
+		Path:           entryPath,
+		Size:           n,
+		CompressedSize: compressed,
+	})
+
+	// 超过大小限制的嵌套压缩包只记录,不检查其内容
+	if buf != nil && !buf.overflow {
+		data := buf.Bytes()
+		return w.walk(nested, bytes.NewReader(data), int64(len(data)), entryPath+"!/", depth+1)
+	}
+
+	return nil
+}
+
+// 压缩包损坏或格式不受支持时拒绝
+func (w *archiveWalker) readError(entryPath string, err error) error {
+	if entryPath == "" {
+		return fmt.Errorf("%w: archive could not be read: %v", domain.ErrArchiveRejected, err)
+	}
+	return fmt.Errorf("%w: %q could not be read: %v", domain.ErrArchiveRejected, entryPath, err)
+}
+
+// 写入超过limit后丢弃已写入的内容,只记录溢出
+type cappedBuffer struct {
+	bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow {
+		return len(p), nil
+	}
+	if int64(b.Len()+len(p)) > b.limit {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
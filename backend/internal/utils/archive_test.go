@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"ModVerse/domain"
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var testArchiveLimits = domain.ArchiveLimits{
+	MaxTotalSize:  64 << 20,
+	MaxRatio:      100,
+	MaxEntries:    100,
+	MaxDepth:      2,
+	MaxNestedSize: 8 << 20,
+}
+
+type testEntry struct {
+	name string
+	data []byte
+}
+
+func zipOf(t *testing.T, entries ...testEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func inspect(t *testing.T, data []byte, mimeType string, fileName string, limits domain.ArchiveLimits) (*domain.StorageFileArchive, error) {
+	t.Helper()
+	return InspectArchive(writeTestFile(t, data), mimeType, fileName, limits)
+}
+
+func TestInspectArchive(t *testing.T) {
+	inner := zipOf(t, testEntry{"fabric.mod.json", []byte(`{"id":"lib"}`)})
+	data := zipOf(t,
+		testEntry{"mod/readme.txt", []byte("hello")},
+		testEntry{"META-INF/jars/lib.jar", inner},
+	)
+
+	archive, err := inspect(t, data, "application/zip", "mod.zip", testArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.Format != domain.ArchiveZip || archive.Depth != 1 || archive.EntryCount != 3 {
+		t.Errorf("archive = %+v", archive)
+	}
+	if got := archive.Entries[2].Path; got != "META-INF/jars/lib.jar!/fabric.mod.json" {
+		t.Errorf("nested entry path = %q", got)
+	}
+
+	archive, err = inspect(t, testTarGz(t), "application/gzip", "mod.tgz", testArchiveLimits)
+	if err != nil || archive.Format != domain.ArchiveTarGz || archive.EntryCount != 1 {
+		t.Errorf("InspectArchive(tgz) = %+v, %v", archive, err)
+	}
+}
+
+// 无法检查内容的格式直接放行
+func TestInspectArchiveSkipsUninspectable(t *testing.T) {
+	for _, tt := range []struct {
+		data     []byte
+		mimeType string
+		fileName string
+	}{
+		{test7z, "application/x-7z-compressed", "mod.7z"},
+		{testRar, "application/x-rar-compressed", "mod.rar"},
+	} {
+		archive, err := inspect(t, tt.data, tt.mimeType, tt.fileName, testArchiveLimits)
+		if err != nil || archive != nil {
+			t.Errorf("InspectArchive(%s) = %+v, %v, want nil, nil", tt.fileName, archive, err)
+		}
+	}
+}
+
+func TestInspectArchiveRejectsUnsafePaths(t *testing.T) {
+	for _, name := range []string{"../evil.sh", "mods/../../evil.sh", `..\evil.bat`, "/etc/passwd", `C:\evil.bat`} {
+		_, err := inspect(t, zipOf(t, testEntry{name, []byte("x")}), "application/zip", "mod.zip", testArchiveLimits)
+		if !errors.Is(err, domain.ErrArchiveRejected) {
+			t.Errorf("zip entry %q error = %v, want ErrArchiveRejected", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "mod/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.Close()
+	if _, err := inspect(t, buf.Bytes(), "application/x-tar", "mod.tar", testArchiveLimits); !errors.Is(err, domain.ErrArchiveRejected) {
+		t.Errorf("tar symlink error = %v, want ErrArchiveRejected", err)
+	}
+}
+
+func TestInspectArchiveRejectsCompressionRatio(t *testing.T) {
+	// 8MB的0压缩后只有几KB
+	bomb := zipOf(t, testEntry{"zeros.bin", make([]byte, 8<<20)})
+	_, err := inspect(t, bomb, "application/zip", "mod.zip", testArchiveLimits)
+	if !errors.Is(err, domain.ErrArchiveRejected) || !strings.Contains(err.Error(), "ratio") {
+		t.Errorf("high ratio entry error = %v, want ratio rejection", err)
+	}
+
+	// 小文件不检查单个文件的压缩比,但整体压缩比仍受限制
+	var entries []testEntry
+	for i := 0; i < 50; i++ {
+		entries = append(entries, testEntry{fmt.Sprintf("data/%d.bin", i), make([]byte, 512<<10)})
+	}
+	_, err = inspect(t, zipOf(t, entries...), "application/zip", "mod.zip", testArchiveLimits)
+	if !errors.Is(err, domain.ErrArchiveRejected) || !strings.Contains(err.Error(), "times its size") {
+		t.Errorf("high overall ratio error = %v, want ErrArchiveRejected", err)
+	}
+
+	limits := testArchiveLimits
+	limits.MaxTotalSize = 4 << 20
+	limits.MaxRatio = 1e6
+	if _, err := inspect(t, bomb, "application/zip", "mod.zip", limits); !errors.Is(err, domain.ErrArchiveRejected) {
+		t.Errorf("over total size error = %v, want ErrArchiveRejected", err)
+	}
+}
+
+func TestInspectArchiveRejectsDeepNesting(t *testing.T) {
+	data := zipOf(t, testEntry{"readme.txt", []byte("innermost")})
+	for i := 0; i < 3; i++ {
+		data = zipOf(t, testEntry{"nested.zip", data})
+	}
+
+	_, err := inspect(t, data, "application/zip", "mod.zip", testArchiveLimits)
+	if !errors.Is(err, domain.ErrArchiveRejected) || !strings.Contains(err.Error(), "nests") {
+		t.Errorf("deep nesting error = %v, want nesting rejection", err)
+	}
+
+	limits := testArchiveLimits
+	limits.MaxDepth = 3
+	archive, err := inspect(t, data, "application/zip", "mod.zip", limits)
+	if err != nil || archive.Depth != 3 {
+		t.Errorf("InspectArchive within depth = %+v, %v", archive, err)
+	}
+}
+
+func TestInspectArchiveRejectsTooManyEntries(t *testing.T) {
+	limits := testArchiveLimits
+	limits.MaxEntries = 2
+	data := zipOf(t, testEntry{"a", nil}, testEntry{"b", nil}, testEntry{"c", nil})
+	if _, err := inspect(t, data, "application/zip", "mod.zip", limits); !errors.Is(err, domain.ErrArchiveRejected) {
+		t.Errorf("too many entries error = %v, want ErrArchiveRejected", err)
+	}
+}
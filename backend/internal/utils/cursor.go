@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// 游标分页工具
+// 游标为 base64(内容).base64(HMAC签名),内容记录排序字段、方向、最后一行的排序值与ID
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const defaultCursorPageSize = 20
+
+var cursorSecret []byte
+
+// SetCursorSecret 设置游标签名密钥,启动时调用
+func SetCursorSecret(secret string) {
+	cursorSecret = []byte(secret)
+}
+
+type Cursor struct {
+	Sort  string          `json:"s"`
+	Order string          `json:"o"`
+	Kind  string          `json:"k"` // 排序值类型: time/number/string
+	Value json.RawMessage `json:"v"`
+	ID    uint64          `json:"i"`
+}
+
+func signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write([]byte("cursor:"))
+	mac.Write(payload)
+	return mac.Sum(nil)[:16]
+}
+
+func encodeCursor(c *Cursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(signCursor(payload)), nil
+}
+
+// DecodeCursor 校验签名并解析游标,排序字段须在白名单内;token为空时返回nil
+func DecodeCursor(token string, allowedFields map[string]struct{}) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	p, s, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || !hmac.Equal(sig, signCursor(payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if SafeSortField(c.Sort, allowedFields, "") != c.Sort || SafeOrderDirection(c.Order) != c.Order {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// 还原游标中的排序值
+func (c *Cursor) value() (any, error) {
+	switch c.Kind {
+	case "time":
+		var t time.Time
+		err := json.Unmarshal(c.Value, &t)
+		return t, err
+	case "number":
+		var n json.Number
+		if err := json.Unmarshal(c.Value, &n); err != nil {
+			return nil, err
+		}
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		return n.Float64()
+	default:
+		var s string
+		err := json.Unmarshal(c.Value, &s)
+		return s, err
+	}
+}
+
+// NeedTotal 判断是否需要统计总数
+// total=exact 始终统计,total=none 不统计;未指定时偏移分页统计、游标分页不统计
+func NeedTotal(total string, cursor *Cursor) bool {
+	switch total {
+	case "exact":
+		return true
+	case "none":
+		return false
+	default:
+		return cursor == nil
+	}
+}
+
+// ApplyCursorPaging 有游标时按 (排序列, ID) 做键集分页,否则退回偏移分页
+// 调用方需先按 sortColumn、idColumn 同向排序
+func ApplyCursorPaging(query *gorm.DB, cursor *Cursor, page, pageSize int, sortColumn, idColumn string) (*gorm.DB, error) {
+	if cursor == nil {
+		return ApplyPaging(query, page, pageSize), nil
+	}
+
+	value, err := cursor.value()
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	op := "<"
+	if cursor.Order == "asc" {
+		op = ">"
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultCursorPageSize
+	}
+
+	return query.
+		Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", sortColumn, op, sortColumn, idColumn, op), value, value, cursor.ID).
+		Limit(pageSize), nil
+}
+
+// NextCursor 根据本页最后一行生成下一页游标,本页未满时返回空
+// 排序值按列名在行结构体中查找,顶层没有时再查找嵌套的关联结构体
+func NextCursor(rows any, sortField, order string, pageSize int, cursorMode bool) (string, error) {
+	if pageSize <= 0 {
+		if !cursorMode {
+			return "", nil
+		}
+		pageSize = defaultCursorPageSize
+	}
+
+	list := reflect.Indirect(reflect.ValueOf(rows))
+	if list.Kind() != reflect.Slice || list.Len() < pageSize {
+		return "", nil
+	}
+	last := reflect.Indirect(list.Index(list.Len() - 1))
+
+	idField := last.FieldByName("ID")
+	if !idField.IsValid() {
+		return "", fmt.Errorf("cursor: row has no ID field")
+	}
+
+	field := fieldByColumn(last, sortField)
+	if !field.IsValid() {
+		return "", fmt.Errorf("cursor: sort field %q not found in row", sortField)
+	}
+
+	c := Cursor{Sort: sortField, Order: order}
+	switch v := field.Interface().(type) {
+	case time.Time:
+		c.Kind = "time"
+	default:
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			c.Kind = "number"
+		case reflect.String:
+			c.Kind = "string"
+		default:
+			return "", fmt.Errorf("cursor: unsupported sort value type %T", v)
+		}
+	}
+
+	value, err := json.Marshal(field.Interface())
+	if err != nil {
+		return "", err
+	}
+	c.Value = value
+	c.ID = reflect.Indirect(idField).Convert(reflect.TypeOf(uint64(0))).Uint()
+
+	return encodeCursor(&c)
+}
+
+var cursorNaming = schema.NamingStrategy{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// 按数据库列名查找结构体字段
+func fieldByColumn(v reflect.Value, column string) reflect.Value {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.EqualFold(cursorNaming.ColumnName("", t.Field(i).Name), column) {
+			return v.Field(i)
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.Struct && f.Type() != timeType {
+			if found := fieldByColumn(f, column); found.IsValid() {
+				return found
+			}
+		}
+	}
+	return reflect.Value{}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"ModVerse/domain"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func verifyURL(t *testing.T, key string, rawURL string) (*domain.DownloadGrant, bool) {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return VerifyDownload(key, u.Query().Get)
+}
+
+func TestSignDownloadURL(t *testing.T) {
+	SetDownloadSigning("secret", time.Minute)
+	const key = "blobs/ab/abc.zip"
+
+	signed, err := SignDownloadURL("http://localhost:3000/api/download/"+key+"?filename=mod.zip", key,
+		domain.DownloadGrant{Tier: domain.DownloadTierUser, UserID: 7, Yanked: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grant, ok := verifyURL(t, key, signed)
+	if !ok || grant.Tier != domain.DownloadTierUser || grant.UserID != 7 || !grant.Yanked {
+		t.Fatalf("VerifyDownload = %+v, %t", grant, ok)
+	}
+	if _, ok := verifyURL(t, "blobs/cd/other.zip", signed); ok {
+		t.Error("signature accepted for another file")
+	}
+
+	for name, value := range map[string]string{
+		"filename": "evil.exe",
+		"tier":     domain.DownloadTierAuthor,
+		"uid":      "8",
+		"yanked":   "",
+	} {
+		u, _ := url.Parse(signed)
+		query := u.Query()
+		query.Set(name, value)
+		u.RawQuery = query.Encode()
+		if _, ok := verifyURL(t, key, u.String()); ok {
+			t.Errorf("signature accepted with %s changed", name)
+		}
+	}
+}
+
+func TestVerifyDownloadExpired(t *testing.T) {
+	SetDownloadSigning("secret", time.Minute)
+	const key = "blobs/ab/abc.zip"
+
+	expires := "1"
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("tier", domain.DownloadTierAnonymous)
+	query.Set("uid", "0")
+	query.Set("signature", signDownload(key, expires, domain.DownloadTierAnonymous, "0", "", ""))
+	if _, ok := VerifyDownload(key, query.Get); ok {
+		t.Error("expired signature accepted")
+	}
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"ModVerse/domain"
+	"fmt"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// 扩展名对应的内容类型,同一类型可能有多个扩展名(如 .jpg/.jpeg、.tar.gz/.tgz)
+// 以zip为容器的格式缺少特征文件(如jar的MANIFEST.MF)时内容只能识别为zip
+// 未列出的扩展名按识别结果的标准扩展名比较
+var extensionTypes = map[string][]string{
+	".jpg":    {"image/jpeg"},
+	".jpeg":   {"image/jpeg"},
+	".png":    {"image/png"},
+	".gif":    {"image/gif"},
+	".webp":   {"image/webp"},
+	".zip":    {"application/zip"},
+	".jar":    {"application/zip"},
+	".tar.gz": {"application/gzip"},
+	".tgz":    {"application/gzip"},
+	".tar":    {"application/x-tar"},
+}
+
+// CheckUploadSize 检查文件大小是否超过上传类型的限制
+func CheckUploadSize(policy domain.UploadPolicy, uploadType string, size int64) error {
+	if size > policy.MaxSize {
+		return fmt.Errorf("%w: %d bytes exceeds the %d byte limit for %s", domain.ErrFileTooLarge, size, policy.MaxSize, uploadType)
+	}
+	return nil
+}
+
+// CheckUploadExtension 检查文件扩展名是否在允许范围内,支持 .tar.gz 这类多段扩展名
+func CheckUploadExtension(policy domain.UploadPolicy, uploadType string, fileName string) error {
+	name := strings.ToLower(fileName)
+	for _, ext := range policy.Extensions {
+		if strings.HasSuffix(name, strings.ToLower(ext)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: extension of %q is not allowed for %s, allowed: %s",
+		domain.ErrFileTypeNotAllowed, fileName, uploadType, strings.Join(policy.Extensions, ", "))
+}
+
+// SniffUploadType 按文件头识别真实类型,检查类型是否允许以及与允许的扩展名是否一致,返回识别出的MIME类型
+// 识别结果的上级类型同样视为匹配,如 .jar 识别为 application/jar,其上级为 application/zip
+func SniffUploadType(policy domain.UploadPolicy, uploadType string, fileName string, path string) (string, error) {
+	detected, err := mimetype.DetectFile(path)
+	if err != nil {
+		return "", err
+	}
+	mimeType, _, _ := strings.Cut(detected.String(), ";")
+
+	allowed := false
+	for m := detected; m != nil && !allowed; m = m.Parent() {
+		for _, t := range policy.Types {
+			if m.Is(t) {
+				allowed = true
+				break
+			}
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("%w: content of %q was detected as %s, which is not allowed for %s",
+			domain.ErrFileTypeNotAllowed, fileName, mimeType, uploadType)
+	}
+
+	// 文件名可能同时匹配多个允许的扩展名(如 .tar.gz 与 .gz),任一与内容一致即可
+	name := strings.ToLower(fileName)
+	for _, ext := range policy.Extensions {
+		ext = strings.ToLower(ext)
+		if strings.HasSuffix(name, ext) && extensionMatches(detected, ext) {
+			return mimeType, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: content of %q was detected as %s, which does not match its extension",
+		domain.ErrFileTypeNotAllowed, fileName, mimeType)
+}
+
+// 识别结果或其上级类型与扩展名对应的类型一致
+func extensionMatches(detected *mimetype.MIME, ext string) bool {
+	types, known := extensionTypes[ext]
+	for m := detected; m != nil; m = m.Parent() {
+		if !known {
+			if m.Extension() == ext {
+				return true
+			}
+			continue
+		}
+		for _, t := range types {
+			if m.Is(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
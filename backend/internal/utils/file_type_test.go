@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"ModVerse/domain"
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testImagePolicy = domain.UploadPolicy{
+	Types:      []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+	Extensions: []string{".jpg", ".jpeg", ".png", ".gif", ".webp"},
+}
+
+var testModPolicy = domain.UploadPolicy{
+	Types: []string{"application/zip", "application/x-7z-compressed", "application/x-rar-compressed",
+		"application/gzip", "application/x-tar"},
+	Extensions: []string{".zip", ".jar", ".7z", ".rar", ".tar.gz", ".tgz", ".tar"},
+}
+
+func writeTestFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testJPEG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testPNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testZip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("mod/readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("hello"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testTar(t *testing.T) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "mod/readme.txt", Mode: 0o644, Size: 5}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte("hello"))
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testTarGz(t *testing.T) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write(testTar(t))
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 7z、rar只需文件头即可识别
+var (
+	test7z  = []byte("7z\xbc\xaf\x27\x1c\x00\x04 rest of archive")
+	testRar = []byte("Rar!\x1a\x07\x00 rest of archive")
+)
+
+func TestSniffUploadType(t *testing.T) {
+	tests := []struct {
+		policy   domain.UploadPolicy
+		fileName string
+		data     []byte
+		want     string
+	}{
+		{testImagePolicy, "photo.jpg", testJPEG(t), "image/jpeg"},
+		{testImagePolicy, "photo.JPEG", testJPEG(t), "image/jpeg"},
+		{testImagePolicy, "logo.png", testPNG(t), "image/png"},
+		{testModPolicy, "mod.zip", testZip(t), "application/zip"},
+		{testModPolicy, "mod.jar", testZip(t), "application/zip"},
+		{testModPolicy, "mod.tar.gz", testTarGz(t), "application/gzip"},
+		{testModPolicy, "mod.tgz", testTarGz(t), "application/gzip"},
+		{testModPolicy, "mod.tar", testTar(t), "application/x-tar"},
+		{testModPolicy, "mod.7z", test7z, "application/x-7z-compressed"},
+		{testModPolicy, "mod.rar", testRar, "application/x-rar-compressed"},
+	}
+
+	for _, tt := range tests {
+		got, err := SniffUploadType(tt.policy, "test", tt.fileName, writeTestFile(t, tt.data))
+		if err != nil || got != tt.want {
+			t.Errorf("SniffUploadType(%s) = %q, %v, want %q", tt.fileName, got, err, tt.want)
+		}
+	}
+}
+
+func TestSniffUploadTypeRejects(t *testing.T) {
+	tests := []struct {
+		policy   domain.UploadPolicy
+		fileName string
+		data     []byte
+	}{
+		// 内容与扩展名不一致
+		{testImagePolicy, "photo.jpg", testPNG(t)},
+		{testModPolicy, "mod.tgz", testZip(t)},
+		{testModPolicy, "mod.zip", testTarGz(t)},
+		// 内容类型不允许
+		{testModPolicy, "mod.zip", testPNG(t)},
+		{testImagePolicy, "photo.jpg", []byte("plain text content")},
+	}
+
+	for _, tt := range tests {
+		if _, err := SniffUploadType(tt.policy, "test", tt.fileName, writeTestFile(t, tt.data)); !errors.Is(err, domain.ErrFileTypeNotAllowed) {
+			t.Errorf("SniffUploadType(%s) error = %v, want ErrFileTypeNotAllowed", tt.fileName, err)
+		}
+	}
+}
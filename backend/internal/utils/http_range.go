@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
+// ParseRange 解析 Range 请求头中的单个字节区间(a-b、a-、-n),返回起始位置与长度
+// ok为false表示应忽略Range返回完整内容:不是字节区间、格式错误或请求了多个区间
+// 区间起点超出文件大小时返回 ErrRangeNotSatisfiable
+func ParseRange(header string, size int64) (start int64, length int64, ok bool, err error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, nil
+	}
+
+	// 后缀区间,取最后n个字节
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, nil
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, ErrRangeNotSatisfiable
+		}
+		n = min(n, size)
+		return size - n, n, true, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, nil
+	}
+
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, nil
+		}
+		end = min(end, size-1)
+	}
+
+	if start >= size {
+		return 0, 0, false, ErrRangeNotSatisfiable
+	}
+
+	return start, end - start + 1, true, nil
+}
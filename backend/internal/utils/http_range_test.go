@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		size   int64
+		start  int64
+		length int64
+		ok     bool
+		err    error
+	}{
+		{"bytes=0-99", 1000, 0, 100, true, nil},
+		{"bytes=100-", 1000, 100, 900, true, nil},
+		{"bytes=-100", 1000, 900, 100, true, nil},
+		{"bytes=-2000", 1000, 0, 1000, true, nil},
+		{"bytes=900-2000", 1000, 900, 100, true, nil},
+		{"bytes= 5-9", 10, 5, 5, true, nil},
+		// 忽略Range返回完整内容
+		{"items=0-9", 1000, 0, 0, false, nil},
+		{"bytes=0-9,20-29", 1000, 0, 0, false, nil},
+		{"bytes=9-0", 1000, 0, 0, false, nil},
+		{"bytes=a-b", 1000, 0, 0, false, nil},
+		{"bytes=5", 1000, 0, 0, false, nil},
+		// 无法满足
+		{"bytes=1000-", 1000, 0, 0, false, ErrRangeNotSatisfiable},
+		{"bytes=-0", 1000, 0, 0, false, ErrRangeNotSatisfiable},
+		{"bytes=-10", 0, 0, 0, false, ErrRangeNotSatisfiable},
+	} {
+		start, length, ok, err := ParseRange(tt.header, tt.size)
+		if start != tt.start || length != tt.length || ok != tt.ok || !errors.Is(err, tt.err) {
+			t.Errorf("ParseRange(%q, %d) = %d, %d, %t, %v, want %d, %d, %t, %v",
+				tt.header, tt.size, start, length, ok, err, tt.start, tt.length, tt.ok, tt.err)
+		}
+	}
+}
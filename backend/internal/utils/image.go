@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"ModVerse/domain"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/disintegration/imaging"
+)
+
+// 图片编码格式
+const (
+	ImageFormatWebP = "webp"
+	ImageFormatJPEG = "jpeg"
+)
+
+// 允许上传的图片格式,以文件内容识别,与扩展名无关
+var allowedImageFormats = map[string]struct{}{
+	"jpeg": {},
+	"png":  {},
+	"gif":  {},
+	"webp": {},
+}
+
+// DecodeImage 识别真实格式并解码图片,解码前先按头部信息检查像素数,避免超大图片耗尽内存
+// JPEG按EXIF方向旋转,解码后的图片不再携带任何元数据
+func DecodeImage(r io.ReadSeeker, maxPixels int) (image.Image, error) {
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, domain.ErrUnsupportedImage
+	}
+	if _, ok := allowedImageFormats[format]; !ok {
+		return nil, domain.ErrUnsupportedImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, domain.ErrImageTooLarge
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	img, err := imaging.Decode(r, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, domain.ErrUnsupportedImage
+	}
+
+	return img, nil
+}
+
+// FitImage 等比缩小到不超过指定宽高,较小的图片保持原尺寸
+func FitImage(img image.Image, width int, height int) image.Image {
+	return imaging.Fit(img, width, height, imaging.Lanczos)
+}
+
+// EncodeImage 按格式编码图片,JPEG不支持透明,透明区域填充白色
+// WebP使用纯Go的无损编码,不依赖cgo,照片通常比JPEG大,由调用方比较体积后取舍
+func EncodeImage(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case ImageFormatWebP:
+		return nativewebp.Encode(w, img, nil)
+	case ImageFormatJPEG:
+		bounds := img.Bounds()
+		flat := imaging.Overlay(imaging.New(bounds.Dx(), bounds.Dy(), color.White), img, image.Point{}, 1)
+		return jpeg.Encode(w, flat, &jpeg.Options{Quality: 85})
+	default:
+		return domain.ErrUnsupportedImage
+	}
+}
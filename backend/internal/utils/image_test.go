@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"ModVerse/domain"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeImageRoundTrip(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			src.Set(x, y, color.NRGBA{R: uint8(x * 6), G: uint8(y * 8), B: 128, A: 255})
+		}
+	}
+
+	for _, format := range []string{ImageFormatWebP, ImageFormatJPEG} {
+		var buf bytes.Buffer
+		if err := EncodeImage(&buf, src, format); err != nil {
+			t.Fatalf("EncodeImage(%s): %v", format, err)
+		}
+
+		img, err := DecodeImage(bytes.NewReader(buf.Bytes()), domain.ImageMaxPixels)
+		if err != nil {
+			t.Fatalf("DecodeImage(%s): %v", format, err)
+		}
+		if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 30 {
+			t.Errorf("%s bounds = %v, want 40x30", format, b)
+		}
+	}
+}
+
+func TestDecodeImageLimits(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeImage(&buf, image.NewNRGBA(image.Rect(0, 0, 20, 20)), ImageFormatWebP); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeImage(bytes.NewReader(buf.Bytes()), 100); !errors.Is(err, domain.ErrImageTooLarge) {
+		t.Errorf("DecodeImage over pixel limit error = %v, want ErrImageTooLarge", err)
+	}
+	if _, err := DecodeImage(bytes.NewReader([]byte("not an image")), domain.ImageMaxPixels); !errors.Is(err, domain.ErrUnsupportedImage) {
+		t.Errorf("DecodeImage garbage error = %v, want ErrUnsupportedImage", err)
+	}
+	if err := EncodeImage(&buf, image.NewNRGBA(image.Rect(0, 0, 1, 1)), "bmp"); !errors.Is(err, domain.ErrUnsupportedImage) {
+		t.Errorf("EncodeImage(bmp) error = %v, want ErrUnsupportedImage", err)
+	}
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// 前缀树,每个键对应若干条目(条目编号+匹配权重)
+// 每个节点保存子树中排名最高的topK个条目,查询时无需遍历整棵子树,结果与遍历顺序无关
+type PrefixTrie struct {
+	root *trieNode
+	topK int
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	entries  []TrieEntry // 键恰好在此结束的条目
+	top      []TrieEntry // 子树中得分最高的条目,按得分降序,同一条目只保留最高得分
+}
+
+type TrieEntry struct {
+	Index  int     // 条目编号,由调用方维护
+	Weight float64 // 该键命中时的匹配权重
+	Score  float64 // 排名得分,决定条目能否保留在节点的topK中
+}
+
+// NewPrefixTrie topK为每次查询最多需要的条目数
+func NewPrefixTrie(topK int) *PrefixTrie {
+	return &PrefixTrie{root: &trieNode{}, topK: topK}
+}
+
+// Insert 插入键,键统一转小写
+func (t *PrefixTrie) Insert(key string, entry TrieEntry) {
+	path := []*trieNode{t.root}
+	node := t.root
+	for _, r := range strings.ToLower(key) {
+		if node.children == nil {
+			node.children = make(map[rune]*trieNode)
+		}
+		child, ok := node.children[r]
+		if !ok {
+			child = &trieNode{}
+			node.children[r] = child
+		}
+		node = child
+		path = append(path, node)
+	}
+	node.entries = append(node.entries, entry)
+
+	// 从键的末端向根更新topK,祖先节点的topK包含子节点的全部候选,
+	// 条目在某个节点未能进入topK时在其祖先中也不会进入,可提前结束
+	for i := len(path) - 1; i >= 0; i-- {
+		if !path[i].offer(entry, t.topK) {
+			return
+		}
+	}
+}
+
+// 按得分降序插入topK,同分时按条目编号排序,返回是否有变化
+func (n *trieNode) offer(entry TrieEntry, k int) bool {
+	for i, e := range n.top {
+		if e.Index == entry.Index {
+			if e.Score >= entry.Score {
+				return false
+			}
+			n.top = append(n.top[:i], n.top[i+1:]...)
+			break
+		}
+	}
+
+	pos := sort.Search(len(n.top), func(i int) bool {
+		return rankBefore(entry, n.top[i])
+	})
+	if pos >= k {
+		return false
+	}
+
+	n.top = append(n.top, TrieEntry{})
+	copy(n.top[pos+1:], n.top[pos:])
+	n.top[pos] = entry
+	if len(n.top) > k {
+		n.top = n.top[:k]
+	}
+	return true
+}
+
+func rankBefore(a, b TrieEntry) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.Index < b.Index
+}
+
+// Search 查找以prefix开头的键对应的条目:子树中得分最高的topK个,以及键恰好为prefix的条目(可能需要完整匹配加权)
+// 同一条目命中多个键时只保留得分最高的一个
+func (t *PrefixTrie) Search(prefix string) []TrieEntry {
+	node := t.root
+	for _, r := range strings.ToLower(prefix) {
+		child, ok := node.children[r]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+
+	found := make([]TrieEntry, 0, len(node.top)+len(node.entries))
+	seen := make(map[int]int, cap(found))
+	for _, list := range [][]TrieEntry{node.top, node.entries} {
+		for _, e := range list {
+			if i, ok := seen[e.Index]; ok {
+				if found[i].Score < e.Score {
+					found[i] = e
+				}
+				continue
+			}
+			seen[e.Index] = len(found)
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+var pinyinArgs = pinyin.NewArgs()
+
+// PinyinKeys 返回名称的全拼与首字母,如"天气系统"返回"tianqixitong"与"tqxt"
+// 非汉字原样保留(转小写),不含汉字时返回空
+func PinyinKeys(name string) (full string, initials string) {
+	var fb, ib strings.Builder
+	hasHan := false
+	wordStart := true
+
+	for _, r := range name {
+		if unicode.Is(unicode.Han, r) {
+			py := pinyin.SinglePinyin(r, pinyinArgs)
+			if len(py) > 0 && py[0] != "" {
+				hasHan = true
+				fb.WriteString(py[0])
+				ib.WriteByte(py[0][0])
+			}
+			wordStart = true
+			continue
+		}
+
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			lr := unicode.ToLower(r)
+			fb.WriteRune(lr)
+			if wordStart {
+				ib.WriteRune(lr)
+			}
+			wordStart = false
+		} else {
+			wordStart = true
+		}
+	}
+
+	if !hasHan {
+		return "", ""
+	}
+	return fb.String(), ib.String()
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPrefixTrieTopK(t *testing.T) {
+	const k = 5
+	rng := rand.New(rand.NewSource(1))
+
+	type item struct {
+		key   string
+		entry TrieEntry
+	}
+	var items []item
+	trie := NewPrefixTrie(k)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("%c%c%c", 'a'+rng.Intn(3), 'a'+rng.Intn(3), 'a'+rng.Intn(26))
+		// 部分条目有多个键,检查按条目去重
+		for j := 0; j <= i%2; j++ {
+			e := TrieEntry{Index: i, Weight: 1, Score: float64(rng.Intn(100))}
+			items = append(items, item{key + strings.Repeat("x", j), e})
+			trie.Insert(key+strings.Repeat("x", j), e)
+		}
+	}
+
+	for _, prefix := range []string{"", "a", "ab", "abc", "cc"} {
+		// 按全部条目计算的期望结果
+		best := map[int]TrieEntry{}
+		for _, it := range items {
+			if strings.HasPrefix(it.key, prefix) {
+				if old, ok := best[it.entry.Index]; !ok || old.Score < it.entry.Score {
+					best[it.entry.Index] = it.entry
+				}
+			}
+		}
+		want := make([]TrieEntry, 0, len(best))
+		for _, e := range best {
+			want = append(want, e)
+		}
+		sort.Slice(want, func(i, j int) bool { return rankBefore(want[i], want[j]) })
+		want = want[:min(k, len(want))]
+
+		got := trie.Search(prefix)
+		sort.Slice(got, func(i, j int) bool { return rankBefore(got[i], got[j]) })
+		if len(got) < len(want) {
+			t.Fatalf("Search(%q) returned %d entries, want at least %d", prefix, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Search(%q)[%d] = %+v, want %+v", prefix, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestPrefixTrieExactEntries(t *testing.T) {
+	trie := NewPrefixTrie(1)
+	trie.Insert("map", TrieEntry{Index: 1, Score: 1})
+	trie.Insert("mapper", TrieEntry{Index: 2, Score: 5})
+	trie.Insert("Map", TrieEntry{Index: 3, Score: 2})
+
+	got := trie.Search("MAP")
+	indexes := map[int]bool{}
+	for _, e := range got {
+		indexes[e.Index] = true
+	}
+	// topK只有得分最高的2,键恰好为map的1与3也需返回,供调用方按完整匹配加权
+	for _, i := range []int{1, 2, 3} {
+		if !indexes[i] {
+			t.Errorf("Search(MAP) = %+v, missing entry %d", got, i)
+		}
+	}
+
+	if got := trie.Search("mapz"); got != nil {
+		t.Errorf("Search(mapz) = %+v, want nil", got)
+	}
+}
+
+func TestPinyinKeys(t *testing.T) {
+	tests := []struct {
+		name, full, initials string
+	}{
+		{"天气系统", "tianqixitong", "tqxt"},
+		{"Better 天气", "bettertianqi", "btq"},
+		{"Weather", "", ""},
+	}
+	for _, tt := range tests {
+		full, initials := PinyinKeys(tt.name)
+		if full != tt.full || initials != tt.initials {
+			t.Errorf("PinyinKeys(%q) = %q, %q, want %q, %q", tt.name, full, initials, tt.full, tt.initials)
+		}
+	}
+}
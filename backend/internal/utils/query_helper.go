@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"ModVerse/domain"
 	"strings"
 
 	"gorm.io/gorm"
@@ -45,3 +46,25 @@ func ApplyPaging(query *gorm.DB, page, pageSize int) *gorm.DB {
 	}
 	return query
 }
+
+// 整数区间条件
+func ApplyIntRange(query *gorm.DB, column string, r domain.IntRange) *gorm.DB {
+	if r.Min != nil {
+		query = query.Where(column+" >= ?", *r.Min)
+	}
+	if r.Max != nil {
+		query = query.Where(column+" <= ?", *r.Max)
+	}
+	return query
+}
+
+// 时间区间条件(左闭右开)
+func ApplyTimeRange(query *gorm.DB, column string, r domain.TimeRange) *gorm.DB {
+	if r.From != nil {
+		query = query.Where(column+" >= ?", *r.From)
+	}
+	if r.To != nil {
+		query = query.Where(column+" < ?", *r.To)
+	}
+	return query
+}
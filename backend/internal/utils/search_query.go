@@ -0,0 +1,438 @@
+package utils
+
+import (
+	"ModVerse/domain"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 模组结构化搜索语法
+//
+//	game:skyrim author:foo category:ui downloads:>1000 updated:<30d "exact phrase" -nsfw
+//
+// 支持的键: game, author, category(cat), status, downloads, likes, updated, created
+// 数值与日期支持 > >= < <= = 及 a..b 区间; 日期可写为 2006-01-02 或相对时长(h/d/w/m/y)
+// 前缀 - 表示排除; category 可用 a|b、a,b 或 category:a OR category:b 表示任一
+
+// 查询语句与各项取值的上限,超出时报错,避免数值与时间计算溢出
+const (
+	searchMaxLength   = 512                        // 查询语句的字符数
+	searchMaxNumber   = 1_000_000_000_000          // 下载量、点赞数
+	searchMaxDuration = 100 * 365 * 24 * time.Hour // 相对时长
+)
+
+// 查询语句解析错误,Pos为出错位置(从1开始的字符序号)
+type SearchParseError struct {
+	Pos     int
+	Message string
+}
+
+func (e *SearchParseError) Error() string {
+	return fmt.Sprintf("search query error at position %d: %s", e.Pos, e.Message)
+}
+
+type searchToken struct {
+	pos    int
+	negate bool
+	key    string
+	value  string
+	or     bool
+}
+
+// ParseModSearch 解析查询语句并合并到params中
+func ParseModSearch(input string, params *domain.ModQuery) error {
+	if n := len([]rune(input)); n > searchMaxLength {
+		return &SearchParseError{Pos: searchMaxLength + 1, Message: fmt.Sprintf("query is longer than %d characters", searchMaxLength)}
+	}
+
+	tokens, err := lexSearch(input)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, tok := range tokens {
+		// 合法的 OR 已在词法阶段合并,剩余的均为错误用法
+		if tok.or {
+			if i == 0 || i == len(tokens)-1 {
+				return &SearchParseError{Pos: tok.pos, Message: "OR must appear between two filters"}
+			}
+			return &SearchParseError{Pos: tok.pos, Message: "OR is only supported between category filters"}
+		}
+
+		if err := applySearchToken(tok, params, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applySearchToken(tok searchToken, params *domain.ModQuery, now time.Time) error {
+	if tok.value == "" {
+		return &SearchParseError{Pos: tok.pos, Message: "empty value"}
+	}
+
+	switch tok.key {
+	case "":
+		if tok.negate {
+			params.ExcludeKeywords = append(params.ExcludeKeywords, tok.value)
+		} else {
+			params.Keywords = append(params.Keywords, tok.value)
+		}
+	case "game":
+		if tok.negate {
+			params.ExcludeGames = append(params.ExcludeGames, tok.value)
+		} else {
+			params.GameName = tok.value
+		}
+	case "author":
+		if tok.negate {
+			params.ExcludeUserNames = append(params.ExcludeUserNames, tok.value)
+		} else {
+			params.UserName = tok.value
+		}
+	case "category", "cat":
+		values := splitSearchList(tok.value)
+		if len(values) == 0 {
+			return &SearchParseError{Pos: tok.pos, Message: "empty category list"}
+		}
+		if tok.negate {
+			params.ExcludeCategory = append(params.ExcludeCategory, values...)
+		} else {
+			params.Category = append(params.Category, values...)
+		}
+	case "status":
+		if tok.negate {
+			params.ExcludeStatus = append(params.ExcludeStatus, tok.value)
+		} else {
+			params.Status = tok.value
+		}
+	case "downloads", "likes":
+		if tok.negate {
+			return &SearchParseError{Pos: tok.pos, Message: "range filters cannot be negated"}
+		}
+		r, err := parseIntRange(tok)
+		if err != nil {
+			return err
+		}
+		if tok.key == "downloads" {
+			params.Downloads = r
+		} else {
+			params.Likes = r
+		}
+	case "updated", "created":
+		if tok.negate {
+			return &SearchParseError{Pos: tok.pos, Message: "range filters cannot be negated"}
+		}
+		r, err := parseTimeRange(tok, now)
+		if err != nil {
+			return err
+		}
+		if tok.key == "updated" {
+			params.Updated = r
+		} else {
+			params.Created = r
+		}
+	default:
+		return &SearchParseError{Pos: tok.pos, Message: fmt.Sprintf("unknown filter %q", tok.key)}
+	}
+
+	return nil
+}
+
+// 词法分析,返回带位置的词元
+func lexSearch(input string) ([]searchToken, error) {
+	runes := []rune(input)
+	var tokens []searchToken
+
+	i := 0
+	for i < len(runes) {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		tok := searchToken{pos: i + 1}
+
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			tok.negate = true
+			i++
+		}
+
+		// 短语
+		if runes[i] == '"' {
+			value, next, err := lexQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tok.value = value
+			tokens = append(tokens, tok)
+			i = next
+			continue
+		}
+
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != ':' && runes[i] != '"' {
+			i++
+		}
+		word := string(runes[start:i])
+
+		if i < len(runes) && runes[i] == ':' {
+			if word == "" {
+				return nil, &SearchParseError{Pos: i + 1, Message: "missing filter name before ':'"}
+			}
+			tok.key = strings.ToLower(word)
+			i++
+			if i < len(runes) && runes[i] == '"' {
+				value, next, err := lexQuoted(runes, i)
+				if err != nil {
+					return nil, err
+				}
+				tok.value = value
+				i = next
+			} else {
+				start = i
+				for i < len(runes) && !unicode.IsSpace(runes[i]) {
+					i++
+				}
+				tok.value = string(runes[start:i])
+			}
+			tokens = append(tokens, tok)
+			continue
+		}
+
+		if i < len(runes) && runes[i] == '"' {
+			return nil, &SearchParseError{Pos: i + 1, Message: "unexpected quote"}
+		}
+
+		if word == "OR" && !tok.negate {
+			tok.or = true
+		} else {
+			tok.value = word
+		}
+		tokens = append(tokens, tok)
+	}
+
+	// 将 category:a OR category:b 合并为一个分类条件
+	merged := tokens[:0]
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.or && len(merged) > 0 && i+1 < len(tokens) {
+			prev, next := merged[len(merged)-1], tokens[i+1]
+			if isCategoryKey(prev.key) && isCategoryKey(next.key) && !prev.negate && !next.negate {
+				merged[len(merged)-1].value = prev.value + "|" + next.value
+				i++
+				continue
+			}
+		}
+		merged = append(merged, tok)
+	}
+
+	return merged, nil
+}
+
+// 读取双引号包裹的内容,支持 \" 转义
+func lexQuoted(runes []rune, start int) (string, int, error) {
+	var b strings.Builder
+	i := start + 1
+	for i < len(runes) {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				b.WriteRune(runes[i+1])
+				i += 2
+				continue
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	return "", 0, &SearchParseError{Pos: start + 1, Message: "unterminated quote"}
+}
+
+func isCategoryKey(key string) bool {
+	return key == "category" || key == "cat"
+}
+
+func splitSearchList(value string) []string {
+	var values []string
+	for _, v := range strings.FieldsFunc(value, func(r rune) bool { return r == '|' || r == ',' }) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// 拆分比较运算符与操作数
+func splitComparator(value string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, op) {
+			return op, value[len(op):]
+		}
+	}
+	return "", value
+}
+
+func parseIntRange(tok searchToken) (domain.IntRange, error) {
+	var r domain.IntRange
+
+	parse := func(s string) (*int64, error) {
+		if s == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			return nil, &SearchParseError{Pos: tok.pos, Message: fmt.Sprintf("invalid number %q for %s", s, tok.key)}
+		}
+		if n > searchMaxNumber {
+			return nil, &SearchParseError{Pos: tok.pos, Message: fmt.Sprintf("%s cannot exceed %d", tok.key, int64(searchMaxNumber))}
+		}
+		return &n, nil
+	}
+
+	if lo, hi, ok := strings.Cut(tok.value, ".."); ok {
+		min, err := parse(lo)
+		if err != nil {
+			return r, err
+		}
+		max, err := parse(hi)
+		if err != nil {
+			return r, err
+		}
+		if min == nil && max == nil {
+			return r, &SearchParseError{Pos: tok.pos, Message: "empty range"}
+		}
+		if min != nil && max != nil && *min > *max {
+			return r, &SearchParseError{Pos: tok.pos, Message: "range lower bound exceeds upper bound"}
+		}
+		r.Min, r.Max = min, max
+		return r, nil
+	}
+
+	op, operand := splitComparator(tok.value)
+	n, err := parse(operand)
+	if err != nil {
+		return r, err
+	}
+	if n == nil {
+		return r, &SearchParseError{Pos: tok.pos, Message: fmt.Sprintf("missing number for %s", tok.key)}
+	}
+
+	switch op {
+	case ">":
+		v := *n + 1
+		r.Min = &v
+	case ">=":
+		r.Min = n
+	case "<":
+		if *n == 0 {
+			return r, &SearchParseError{Pos: tok.pos, Message: fmt.Sprintf("%s cannot be less than 0", tok.key)}
+		}
+		v := *n - 1
+		r.Max = &v
+	case "<=":
+		r.Max = n
+	default:
+		r.Min, r.Max = n, n
+	}
+	return r, nil
+}
+
+// 相对时长: 30d 2w 6m 1y 12h,不超过 searchMaxDuration
+func parseRelativeDuration(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	unit := map[byte]time.Duration{
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'm': 30 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}[s[len(s)-1]]
+	if unit == 0 || n > int64(searchMaxDuration/unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+func parseTimeRange(tok searchToken, now time.Time) (domain.TimeRange, error) {
+	var r domain.TimeRange
+
+	invalid := func(s string) error {
+		return &SearchParseError{Pos: tok.pos, Message: fmt.Sprintf("invalid date %q for %s, use 2006-01-02 or a duration like 30d", s, tok.key)}
+	}
+
+	if lo, hi, ok := strings.Cut(tok.value, ".."); ok {
+		if lo != "" {
+			from, err := time.ParseInLocation(time.DateOnly, lo, now.Location())
+			if err != nil {
+				return r, invalid(lo)
+			}
+			r.From = &from
+		}
+		if hi != "" {
+			to, err := time.ParseInLocation(time.DateOnly, hi, now.Location())
+			if err != nil {
+				return r, invalid(hi)
+			}
+			to = to.AddDate(0, 0, 1)
+			r.To = &to
+		}
+		if r.From == nil && r.To == nil {
+			return r, &SearchParseError{Pos: tok.pos, Message: "empty range"}
+		}
+		if r.From != nil && r.To != nil && !r.From.Before(*r.To) {
+			return r, &SearchParseError{Pos: tok.pos, Message: "range lower bound exceeds upper bound"}
+		}
+		return r, nil
+	}
+
+	op, operand := splitComparator(tok.value)
+
+	// 相对时长按"距今"理解: <30d 表示30天以内, >30d 表示30天以前
+	if d, ok := parseRelativeDuration(operand); ok {
+		t := now.Add(-d)
+		switch op {
+		case ">", ">=":
+			r.To = &t
+		case "", "<", "<=":
+			r.From = &t
+		default:
+			return r, &SearchParseError{Pos: tok.pos, Message: "'=' cannot be used with a relative duration"}
+		}
+		return r, nil
+	}
+
+	day, err := time.ParseInLocation(time.DateOnly, operand, now.Location())
+	if err != nil {
+		return r, invalid(operand)
+	}
+	next := day.AddDate(0, 0, 1)
+
+	switch op {
+	case ">":
+		r.From = &next
+	case ">=":
+		r.From = &day
+	case "<":
+		r.To = &day
+	case "<=":
+		r.To = &next
+	default:
+		r.From, r.To = &day, &next
+	}
+	return r, nil
+}
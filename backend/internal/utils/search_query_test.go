@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"ModVerse/domain"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func int64Ptr(n int64) *int64 { return &n }
+
+func TestParseModSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  domain.ModQuery
+	}{
+		{
+			name:  "keywords and phrase",
+			input: `magic "exact phrase" -nsfw`,
+			want: domain.ModQuery{
+				Keywords:        []string{"magic", "exact phrase"},
+				ExcludeKeywords: []string{"nsfw"},
+			},
+		},
+		{
+			name:  "filters",
+			input: `game:skyrim author:foo status:enable -game:fallout -author:bar -status:disable`,
+			want: domain.ModQuery{
+				GameName:         "skyrim",
+				UserName:         "foo",
+				Status:           "enable",
+				ExcludeGames:     []string{"fallout"},
+				ExcludeUserNames: []string{"bar"},
+				ExcludeStatus:    []string{"disable"},
+			},
+		},
+		{
+			name:  "quoted filter value",
+			input: `game:"The Elder Scrolls"`,
+			want:  domain.ModQuery{GameName: "The Elder Scrolls"},
+		},
+		{
+			name:  "category lists",
+			input: `category:ui|maps cat:a,b -category:nsfw`,
+			want: domain.ModQuery{
+				Category:        []string{"ui", "maps", "a", "b"},
+				ExcludeCategory: []string{"nsfw"},
+			},
+		},
+		{
+			name:  "category OR",
+			input: `category:ui OR cat:maps`,
+			want:  domain.ModQuery{Category: []string{"ui", "maps"}},
+		},
+		{
+			name:  "greater than",
+			input: `downloads:>1000`,
+			want:  domain.ModQuery{Downloads: domain.IntRange{Min: int64Ptr(1001)}},
+		},
+		{
+			name:  "less or equal",
+			input: `likes:<=5`,
+			want:  domain.ModQuery{Likes: domain.IntRange{Max: int64Ptr(5)}},
+		},
+		{
+			name:  "less than",
+			input: `likes:<5`,
+			want:  domain.ModQuery{Likes: domain.IntRange{Max: int64Ptr(4)}},
+		},
+		{
+			name:  "exact",
+			input: `downloads:10`,
+			want:  domain.ModQuery{Downloads: domain.IntRange{Min: int64Ptr(10), Max: int64Ptr(10)}},
+		},
+		{
+			name:  "interval",
+			input: `downloads:10..20 likes:5..`,
+			want: domain.ModQuery{
+				Downloads: domain.IntRange{Min: int64Ptr(10), Max: int64Ptr(20)},
+				Likes:     domain.IntRange{Min: int64Ptr(5)},
+			},
+		},
+		{
+			name:  "upper bound",
+			input: `downloads:>=1000000000000`,
+			want:  domain.ModQuery{Downloads: domain.IntRange{Min: int64Ptr(searchMaxNumber)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got domain.ModQuery
+			if err := ParseModSearch(tt.input, &got); err != nil {
+				t.Fatalf("ParseModSearch(%q) error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseModSearch(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseModSearchDates(t *testing.T) {
+	var q domain.ModQuery
+	before := time.Now()
+	if err := ParseModSearch(`updated:<30d created:>1y`, &q); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	if q.Updated.From == nil || q.Updated.To != nil {
+		t.Fatalf("updated:<30d = %+v, want only From", q.Updated)
+	}
+	if from := *q.Updated.From; from.Before(before.AddDate(0, 0, -30)) || from.After(after.AddDate(0, 0, -30)) {
+		t.Errorf("updated:<30d From = %v", from)
+	}
+	if q.Created.To == nil || q.Created.From != nil {
+		t.Fatalf("created:>1y = %+v, want only To", q.Created)
+	}
+
+	q = domain.ModQuery{}
+	if err := ParseModSearch(`updated:2024-01-01..2024-01-31 created:2024-02-01`, &q); err != nil {
+		t.Fatal(err)
+	}
+	day := func(s string) time.Time {
+		d, _ := time.ParseInLocation(time.DateOnly, s, time.Local)
+		return d
+	}
+	if !q.Updated.From.Equal(day("2024-01-01")) || !q.Updated.To.Equal(day("2024-02-01")) {
+		t.Errorf("updated range = %v..%v", q.Updated.From, q.Updated.To)
+	}
+	if !q.Created.From.Equal(day("2024-02-01")) || !q.Created.To.Equal(day("2024-02-02")) {
+		t.Errorf("created day = %v..%v", q.Created.From, q.Created.To)
+	}
+}
+
+func TestParseModSearchErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{`"unterminated`, 1},
+		{`game:skyrim foo:bar`, 13},
+		{`:value`, 1},
+		{`OR category:ui`, 1},
+		{`category:ui OR`, 13},
+		{`game:a OR game:b`, 8},
+		{`-downloads:>10`, 1},
+		{`downloads:abc`, 1},
+		{`downloads:-5`, 1},
+		{`downloads:20..10`, 1},
+		{`downloads:..`, 1},
+		{`likes:<0`, 1},
+		{`downloads:>1000000000001`, 1},
+		{`downloads:>9223372036854775807`, 1},
+		{`downloads:99999999999999999999`, 1},
+		{`updated:=30d`, 1},
+		{`updated:yesterday`, 1},
+		{`updated:<999999999999y`, 1},
+		{`updated:<9223372036854775807h`, 1},
+		{`updated:2024-02-01..2024-01-01`, 1},
+		{`game:`, 1},
+		{`foo"bar`, 4},
+		{strings.Repeat("a", searchMaxLength+1), searchMaxLength + 1},
+	}
+
+	for _, tt := range tests {
+		var q domain.ModQuery
+		err := ParseModSearch(tt.input, &q)
+		var parseErr *SearchParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("ParseModSearch(%q) error = %v, want *SearchParseError", tt.input, err)
+			continue
+		}
+		if parseErr.Pos != tt.pos {
+			t.Errorf("ParseModSearch(%q) position = %d, want %d (%v)", tt.input, parseErr.Pos, tt.pos, err)
+		}
+	}
+}
+
+func TestParseRelativeDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+		ok    bool
+	}{
+		{"12h", 12 * time.Hour, true},
+		{"30d", 30 * 24 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"1m", 30 * 24 * time.Hour, true},
+		{"100y", searchMaxDuration, true},
+		{"101y", 0, false},
+		{"9223372036854775807h", 0, false},
+		{"d", 0, false},
+		{"-1d", 0, false},
+		{"5s", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRelativeDuration(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRelativeDuration(%q) = %v, %t, want %v, %t", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 语义化版本 https://semver.org
+type Semver struct {
+	Major uint64
+	Minor uint64
+	Patch uint64
+	Pre   []string // 预发布标识,如 alpha.1 拆为 ["alpha","1"]
+	Build string   // 构建元数据,不参与比较
+}
+
+var ErrInvalidSemver = errors.New("invalid semantic version")
+
+// MaxVersionLength 规范化后版本号的最大长度,与 mod_versions.version 列一致
+const MaxVersionLength = 32
+
+// ParseSemver 严格解析版本号,允许前缀v
+func ParseSemver(s string) (*Semver, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	var v Semver
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		v.Build = s[i+1:]
+		s = s[:i]
+		if v.Build == "" || !validIdentifiers(v.Build, false) {
+			return nil, ErrInvalidSemver
+		}
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		pre := s[i+1:]
+		s = s[:i]
+		if pre == "" || !validIdentifiers(pre, true) {
+			return nil, ErrInvalidSemver
+		}
+		v.Pre = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidSemver
+	}
+	nums := [3]*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := parseNumericIdentifier(p)
+		if err != nil {
+			return nil, ErrInvalidSemver
+		}
+		*nums[i] = n
+	}
+
+	return &v, nil
+}
+
+// ParseSemverLenient 宽松解析历史版本号:
+// 允许前缀v/V、省略次版本或修订号(1.2 视为 1.2.0)、数字前导0、
+// 预发布标识以 _ 分隔或紧跟数字(1.0_beta、1.0b 视为 1.0.0-beta、1.0.0-b);超过三段数字仍视为非法
+func ParseSemverLenient(s string) (*Semver, error) {
+	s = strings.TrimSpace(s)
+	if v, err := ParseSemver(s); err == nil {
+		return v, nil
+	}
+
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+
+	var v Semver
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		v.Build = s[i+1:]
+		s = s[:i]
+		if v.Build == "" || !validIdentifiers(v.Build, false) {
+			return nil, ErrInvalidSemver
+		}
+	}
+
+	// 数字部分到第一个非数字非点字符为止
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+		end++
+	}
+	numbers := strings.TrimSuffix(s[:end], ".")
+	rest := s[len(numbers):]
+
+	parts := strings.Split(numbers, ".")
+	if numbers == "" || len(parts) > 3 {
+		return nil, ErrInvalidSemver
+	}
+	nums := [3]*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidSemver
+		}
+		*nums[i] = n
+	}
+
+	if rest != "" {
+		pre := strings.TrimLeft(rest, "-_.")
+		if pre == "" || len(rest)-len(pre) > 1 {
+			return nil, ErrInvalidSemver
+		}
+		pre = strings.ReplaceAll(pre, "_", ".")
+		if !validIdentifiers(pre, false) {
+			return nil, ErrInvalidSemver
+		}
+		for _, id := range strings.Split(pre, ".") {
+			// 数字标识去掉前导0
+			if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+				id = strconv.FormatUint(n, 10)
+			}
+			v.Pre = append(v.Pre, id)
+		}
+	}
+
+	return &v, nil
+}
+
+// NormalizeVersion 校验版本号并返回规范形式,lenient为true时接受历史格式
+func NormalizeVersion(s string, lenient bool) (string, error) {
+	parse := ParseSemver
+	if lenient {
+		parse = ParseSemverLenient
+	}
+
+	v, err := parse(s)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSemver, s)
+	}
+
+	normalized := v.String()
+	if len(normalized) > MaxVersionLength {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalidSemver, MaxVersionLength)
+	}
+	return normalized, nil
+}
+
+// CompareVersionStrings 按语义化版本比较两个版本号(宽松解析)
+// 无法解析的版本低于可解析的版本,两者都无法解析时返回0
+func CompareVersionStrings(a, b string) int {
+	av, aErr := ParseSemverLenient(a)
+	bv, bErr := ParseSemverLenient(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return av.Compare(bv)
+	case aErr == nil:
+		return 1
+	case bErr == nil:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// 数字标识不能有前导0
+func parseNumericIdentifier(s string) (uint64, error) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, ErrInvalidSemver
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, ErrInvalidSemver
+		}
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func validIdentifiers(s string, checkNumeric bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for _, r := range id {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if checkNumeric && numeric && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *Semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare 比较版本优先级,返回-1/0/1;预发布版本低于对应的正式版本
+func (v *Semver) Compare(o *Semver) int {
+	if c := cmpUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := cmpUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := cmpUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case len(v.Pre) == 0 && len(o.Pre) == 0:
+		return 0
+	case len(v.Pre) == 0:
+		return 1
+	case len(o.Pre) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.Pre) && i < len(o.Pre); i++ {
+		if c := comparePreIdentifier(v.Pre[i], o.Pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpUint(uint64(len(v.Pre)), uint64(len(o.Pre)))
+}
+
+// 数字标识按数值比较且低于字母标识,字母标识按ASCII比较
+func comparePreIdentifier(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmpUint(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// 版本范围,语法与npm一致:
+// 比较符 >=1.2.0 <2.0.0、插入符 ^1.2、波浪号 ~1.2.3、通配 1.x / *、连字符 1.0 - 2.0,多个范围以 || 连接
+type VersionRange struct {
+	sets [][]versionComparator // 任一组满足即匹配,组内比较条件需全部满足
+	raw  string
+}
+
+type versionComparator struct {
+	op string // > >= < <= =
+	v  Semver
+}
+
+type RangeParseError struct {
+	Range   string
+	Message string
+}
+
+func (e *RangeParseError) Error() string {
+	return fmt.Sprintf("invalid version range %q: %s", e.Range, e.Message)
+}
+
+// ParseVersionRange 解析版本范围,空串与*匹配所有正式版本
+func ParseVersionRange(s string) (*VersionRange, error) {
+	r := &VersionRange{raw: strings.TrimSpace(s)}
+
+	for _, part := range strings.Split(s, "||") {
+		set, err := parseComparatorSet(strings.TrimSpace(part))
+		if err != nil {
+			return nil, &RangeParseError{Range: s, Message: err.Error()}
+		}
+		r.sets = append(r.sets, set)
+	}
+
+	return r, nil
+}
+
+func (r *VersionRange) String() string {
+	if r.raw == "" {
+		return "*"
+	}
+	return r.raw
+}
+
+// Contains 判断版本是否在范围内
+// 预发布版本只有在同组内某个比较条件带有相同主次修订号的预发布标识时才匹配
+func (r *VersionRange) Contains(v *Semver) bool {
+	for _, set := range r.sets {
+		if setContains(set, v, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsPrerelease 同Contains,但预发布版本只要满足比较条件即匹配
+func (r *VersionRange) ContainsPrerelease(v *Semver) bool {
+	for _, set := range r.sets {
+		if setContains(set, v, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func setContains(set []versionComparator, v *Semver, includePrerelease bool) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+
+	if len(v.Pre) == 0 || includePrerelease {
+		return true
+	}
+	for _, c := range set {
+		if len(c.v.Pre) > 0 && c.v.Major == v.Major && c.v.Minor == v.Minor && c.v.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func (c versionComparator) matches(v *Semver) bool {
+	cmp := v.Compare(&c.v)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// 部分版本号,缺失或通配的部分为-1
+type partialVersion struct {
+	major, minor, patch int64
+	pre                 []string
+}
+
+func parsePartial(s string) (partialVersion, error) {
+	p := partialVersion{major: -1, minor: -1, patch: -1}
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		pre := s[i+1:]
+		s = s[:i]
+		if pre == "" || !validIdentifiers(pre, true) {
+			return p, fmt.Errorf("bad pre-release in %q", s)
+		}
+		p.pre = strings.Split(pre, ".")
+	}
+
+	if s == "" || s == "*" || s == "x" || s == "X" {
+		return p, nil
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return p, fmt.Errorf("too many version parts in %q", s)
+	}
+	nums := [3]*int64{&p.major, &p.minor, &p.patch}
+	wild := false
+	for i, part := range parts {
+		if part == "*" || part == "x" || part == "X" {
+			wild = true
+			continue
+		}
+		if wild {
+			return p, fmt.Errorf("number after wildcard in %q", s)
+		}
+		n, err := parseNumericIdentifier(part)
+		if err != nil {
+			return p, fmt.Errorf("bad version number %q", part)
+		}
+		*nums[i] = int64(n)
+	}
+	if p.pre != nil && p.patch < 0 {
+		return p, fmt.Errorf("pre-release requires a full version in %q", s)
+	}
+	return p, nil
+}
+
+func (p partialVersion) floor() Semver {
+	v := Semver{Pre: p.pre}
+	if p.major > 0 {
+		v.Major = uint64(p.major)
+	}
+	if p.minor > 0 {
+		v.Minor = uint64(p.minor)
+	}
+	if p.patch > 0 {
+		v.Patch = uint64(p.patch)
+	}
+	return v
+}
+
+// 下一个不兼容的版本(不含),用于 < 上界;"0" 预发布标识使上界排除该版本的预发布
+func upper(major, minor, patch uint64) Semver {
+	return Semver{Major: major, Minor: minor, Patch: patch, Pre: []string{"0"}}
+}
+
+func parseComparatorSet(s string) ([]versionComparator, error) {
+	if s == "" {
+		return []versionComparator{{op: ">=", v: Semver{}}}, nil
+	}
+
+	// 连字符范围
+	if left, right, ok := strings.Cut(s, " - "); ok {
+		lo, err := parsePartial(strings.TrimSpace(left))
+		if err != nil {
+			return nil, err
+		}
+		hi, err := parsePartial(strings.TrimSpace(right))
+		if err != nil {
+			return nil, err
+		}
+		set := []versionComparator{{op: ">=", v: lo.floor()}}
+		switch {
+		case hi.major < 0:
+		case hi.minor < 0:
+			set = append(set, versionComparator{op: "<", v: upper(uint64(hi.major)+1, 0, 0)})
+		case hi.patch < 0:
+			set = append(set, versionComparator{op: "<", v: upper(uint64(hi.major), uint64(hi.minor)+1, 0)})
+		default:
+			set = append(set, versionComparator{op: "<=", v: hi.floor()})
+		}
+		return set, nil
+	}
+
+	var set []versionComparator
+	fields := strings.Fields(s)
+	for i := 0; i < len(fields); i++ {
+		token := fields[i]
+		// 允许比较符与版本号之间有空格,如 ">= 1.2"
+		if isOperator(token) && i+1 < len(fields) {
+			i++
+			token += fields[i]
+		}
+
+		cs, err := parseComparator(token)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
+}
+
+func isOperator(s string) bool {
+	switch s {
+	case ">", ">=", "<", "<=", "=", "^", "~", "~>":
+		return true
+	}
+	return false
+}
+
+func parseComparator(token string) ([]versionComparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "~>", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(token, candidate) {
+			op = candidate
+			token = token[len(candidate):]
+			break
+		}
+	}
+
+	p, err := parsePartial(token)
+	if err != nil {
+		return nil, err
+	}
+	lo := p.floor()
+
+	switch op {
+	case "^":
+		switch {
+		case p.major < 0:
+			return []versionComparator{{op: ">=", v: Semver{}}}, nil
+		case p.major > 0 || p.minor < 0:
+			return []versionComparator{{op: ">=", v: lo}, {op: "<", v: upper(lo.Major+1, 0, 0)}}, nil
+		case p.minor > 0 || p.patch < 0:
+			return []versionComparator{{op: ">=", v: lo}, {op: "<", v: upper(0, lo.Minor+1, 0)}}, nil
+		default:
+			return []versionComparator{{op: ">=", v: lo}, {op: "<", v: upper(0, 0, lo.Patch+1)}}, nil
+		}
+	case "~", "~>":
+		switch {
+		case p.major < 0:
+			return []versionComparator{{op: ">=", v: Semver{}}}, nil
+		case p.minor < 0:
+			return []versionComparator{{op: ">=", v: lo}, {op: "<", v: upper(lo.Major+1, 0, 0)}}, nil
+		default:
+			return []versionComparator{{op: ">=", v: lo}, {op: "<", v: upper(lo.Major, lo.Minor+1, 0)}}, nil
+		}
+	case ">", "<=":
+		// 部分版本: >1.2 即 >=1.3.0,<=1.2 即 <1.3.0
+		switch {
+		case p.major < 0:
+			if op == ">" {
+				return nil, errors.New("nothing is greater than *")
+			}
+			return []versionComparator{{op: ">=", v: Semver{}}}, nil
+		case p.minor < 0:
+			return []versionComparator{{op: flipInclusive(op), v: upper(lo.Major+1, 0, 0)}}, nil
+		case p.patch < 0:
+			return []versionComparator{{op: flipInclusive(op), v: upper(lo.Major, lo.Minor+1, 0)}}, nil
+		}
+		return []versionComparator{{op: op, v: lo}}, nil
+	case ">=", "<":
+		if p.major < 0 {
+			if op == "<" {
+				return nil, errors.New("nothing is less than *")
+			}
+			return []versionComparator{{op: ">=", v: Semver{}}}, nil
+		}
+		if op == "<" && p.patch < 0 {
+			lo.Pre = []string{"0"}
+		}
+		return []versionComparator{{op: op, v: lo}}, nil
+	default:
+		// 无比较符或 =: 部分版本等同于通配
+		switch {
+		case p.major < 0:
+			return []versionComparator{{op: ">=", v: Semver{}}}, nil
+		case p.minor < 0:
+			return []versionComparator{{op: ">=", v: lo}, {op: "<", v: upper(lo.Major+1, 0, 0)}}, nil
+		case p.patch < 0:
+			return []versionComparator{{op: ">=", v: lo}, {op: "<", v: upper(lo.Major, lo.Minor+1, 0)}}, nil
+		}
+		return []versionComparator{{op: "=", v: lo}}, nil
+	}
+}
+
+// >1.2 → >=1.3.0-0, <=1.2 → <1.3.0-0
+func flipInclusive(op string) string {
+	if op == ">" {
+		return ">="
+	}
+	return "<"
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"v1.2.3", "1.2.3", true},
+		{"1.0.0-alpha.1", "1.0.0-alpha.1", true},
+		{"1.0.0-x-y.0+build.5", "1.0.0-x-y.0+build.5", true},
+		{"1.2", "", false},
+		{"01.2.3", "", false},
+		{"1.2.3-01", "", false},
+		{"1.2.3-", "", false},
+		{"1.2.3+", "", false},
+		{"1.2.3.4", "", false},
+		{"1.2.a", "", false},
+		{"1.2.3-al_pha", "", false},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseSemver(tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseSemver(%q) error = %v, want ok %t", tt.input, err, tt.ok)
+			continue
+		}
+		if tt.ok && v.String() != tt.want {
+			t.Errorf("ParseSemver(%q) = %s, want %s", tt.input, v, tt.want)
+		}
+	}
+}
+
+func TestParseSemverLenient(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"V1.2", "1.2.0", true},
+		{"2", "2.0.0", true},
+		{"01.02.03", "1.2.3", true},
+		{"1.0_beta", "1.0.0-beta", true},
+		{"1.0b", "1.0.0-b", true},
+		{"1.0-beta_02", "1.0.0-beta.2", true},
+		{"1.0.0+git.abc", "1.0.0+git.abc", true},
+		{"1.2.3.4", "", false},
+		{"beta", "", false},
+		{"1.0--beta", "", false},
+		{"1.0 beta", "", false},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseSemverLenient(tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseSemverLenient(%q) error = %v, want ok %t", tt.input, err, tt.ok)
+			continue
+		}
+		if tt.ok && v.String() != tt.want {
+			t.Errorf("ParseSemverLenient(%q) = %s, want %s", tt.input, v, tt.want)
+		}
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	// semver.org 第11条给出的优先级顺序
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := ParseSemver(ordered[i])
+			b, _ := ParseSemver(ordered[j])
+			want := cmpUint(uint64(i), uint64(j))
+			if got := a.Compare(b); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	a, _ := ParseSemver("1.0.0+a")
+	b, _ := ParseSemver("1.0.0+b")
+	if a.Compare(b) != 0 {
+		t.Error("build metadata must not affect precedence")
+	}
+
+	if CompareVersionStrings("1.10", "1.9") != 1 || CompareVersionStrings("foo", "0.0.1") != -1 || CompareVersionStrings("foo", "bar") != 0 {
+		t.Error("CompareVersionStrings ordering is wrong")
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	if v, err := NormalizeVersion("v1.2.3", false); err != nil || v != "1.2.3" {
+		t.Errorf("NormalizeVersion strict = %q, %v", v, err)
+	}
+	if _, err := NormalizeVersion("1.2", false); err == nil {
+		t.Error("NormalizeVersion strict accepted 1.2")
+	}
+	if v, err := NormalizeVersion("1.2", true); err != nil || v != "1.2.0" {
+		t.Errorf("NormalizeVersion lenient = %q, %v", v, err)
+	}
+	if _, err := NormalizeVersion("1.0.0-"+strings.Repeat("a", MaxVersionLength), false); !errors.Is(err, ErrInvalidSemver) {
+		t.Errorf("NormalizeVersion overlong error = %v, want ErrInvalidSemver", err)
+	}
+	// 宽松解析补全后超长同样拒绝
+	if _, err := NormalizeVersion("1-"+strings.Repeat("b", MaxVersionLength-5), true); !errors.Is(err, ErrInvalidSemver) {
+		t.Errorf("NormalizeVersion lenient overlong error = %v, want ErrInvalidSemver", err)
+	}
+}
+
+func TestVersionRangeContains(t *testing.T) {
+	tests := []struct {
+		rng string
+		in  []string
+		out []string
+	}{
+		{"", []string{"0.0.0", "5.1.2"}, []string{"1.0.0-beta"}},
+		{"*", []string{"1.0.0"}, []string{"1.0.0-rc.1"}},
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4", "1.2.2"}},
+		{"=1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{"1.x", []string{"1.0.0", "1.9.9"}, []string{"2.0.0", "0.9.0", "2.0.0-0"}},
+		{"1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+		{">1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"<1.2", []string{"1.1.9"}, []string{"1.2.0", "1.2.0-beta"}},
+		{">= 1.2.0 <2.0.0", []string{"1.2.0", "1.99.0"}, []string{"2.0.0", "1.1.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"2.0.0", "1.2.2", "2.0.0-alpha"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^0.x", []string{"0.9.0"}, []string{"1.0.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0"}},
+		{"~1", []string{"1.9.0"}, []string{"2.0.0"}},
+		{"~>1.2", []string{"1.2.5"}, []string{"1.3.0"}},
+		{"1.0 - 2.0", []string{"1.0.0", "2.0.9"}, []string{"2.1.0", "0.9.0"}},
+		{"1.0.0 - 2.0.0", []string{"2.0.0"}, []string{"2.0.1"}},
+		{"1.0.0 - 2", []string{"2.9.9"}, []string{"3.0.0"}},
+		{"^1.0.0 || ^3.0.0", []string{"1.5.0", "3.1.0"}, []string{"2.0.0"}},
+		{">=1.0.0-beta.2 <1.0.0", []string{"1.0.0-beta.2", "1.0.0-rc.1"}, []string{"1.0.0-beta.1", "1.0.0"}},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseVersionRange(tt.rng)
+		if err != nil {
+			t.Errorf("ParseVersionRange(%q) error: %v", tt.rng, err)
+			continue
+		}
+		for _, v := range tt.in {
+			sv, err := ParseSemver(v)
+			if err != nil {
+				t.Fatalf("ParseSemver(%q): %v", v, err)
+			}
+			if !r.Contains(sv) {
+				t.Errorf("%q should contain %s", tt.rng, v)
+			}
+		}
+		for _, v := range tt.out {
+			sv, err := ParseSemver(v)
+			if err != nil {
+				t.Fatalf("ParseSemver(%q): %v", v, err)
+			}
+			if r.Contains(sv) {
+				t.Errorf("%q should not contain %s", tt.rng, v)
+			}
+		}
+	}
+}
+
+func TestVersionRangePrerelease(t *testing.T) {
+	r, _ := ParseVersionRange("^1.0.0")
+	v, _ := ParseSemver("1.5.0-beta")
+	if r.Contains(v) {
+		t.Error("^1.0.0 Contains 1.5.0-beta, want false")
+	}
+	if !r.ContainsPrerelease(v) {
+		t.Error("^1.0.0 ContainsPrerelease 1.5.0-beta, want true")
+	}
+}
+
+func TestParseVersionRangeErrors(t *testing.T) {
+	for _, rng := range []string{
+		">*", "<*", "1.2.3.4", "1.x.3", "^1.2.a", "1.0.0-", "~1.2-beta", ">=01.0.0",
+	} {
+		if _, err := ParseVersionRange(rng); err == nil {
+			t.Errorf("ParseVersionRange(%q) succeeded, want error", rng)
+		} else if _, ok := err.(*RangeParseError); !ok {
+			t.Errorf("ParseVersionRange(%q) error type %T, want *RangeParseError", rng, err)
+		}
+	}
+}
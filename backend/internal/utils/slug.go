@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// Slugify 生成URL友好的标识: 小写字母数字以-连接,汉字转为拼音
+// 如 "UI 优化" 返回 "ui-you-hua"
+func Slugify(name string) string {
+	var b strings.Builder
+	sep := false
+
+	write := func(s string) {
+		if sep && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(s)
+		sep = false
+	}
+
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 && py[0] != "" {
+				sep = true
+				write(py[0])
+				sep = true
+			}
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			write(string(r))
+		default:
+			sep = true
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// 限速时每次读取的最大字节数,速度较低时按约100ms的数据量读取,使发送更平滑
+const throttleMaxChunk = 64 << 10
+const throttleMinChunk = 1 << 10
+
+// 重新获取速度的间隔,同一下载者的下载数变化后各下载在该时间内调整速度
+const throttleRateInterval = time.Second
+
+// ThrottledReader 按指定速度读取,Close时关闭底层内容并执行释放函数
+type ThrottledReader struct {
+	r       io.Reader
+	rateFn  func() int64
+	rate    int64
+	chunk   int
+	checked time.Time
+	start   time.Time
+	read    int64
+	release func()
+	once    sync.Once
+}
+
+// NewThrottledReader 创建限速读取,rate返回字节/秒,0为不限速;读取期间每秒重新获取一次,
+// 供多个下载共享同一速度上限时按当前下载数分配
+// r实现io.Closer时在Close时关闭,release在Close时执行一次,用于释放下载名额
+func NewThrottledReader(r io.Reader, rate func() int64, release func()) *ThrottledReader {
+	t := &ThrottledReader{
+		r:       r,
+		rateFn:  rate,
+		release: release,
+	}
+	t.setRate(rate())
+	return t
+}
+
+// 速度变化时从当前位置重新计时
+func (t *ThrottledReader) setRate(rate int64) {
+	t.checked = time.Now()
+	if rate == t.rate && !t.start.IsZero() {
+		return
+	}
+
+	t.rate = rate
+	t.start = time.Time{}
+	t.read = 0
+	t.chunk = throttleMaxChunk
+	if rate > 0 {
+		t.chunk = int(min(max(rate/10, throttleMinChunk), throttleMaxChunk))
+	}
+}
+
+func (t *ThrottledReader) Read(p []byte) (int, error) {
+	if time.Since(t.checked) >= throttleRateInterval {
+		t.setRate(t.rateFn())
+	}
+	if t.rate <= 0 {
+		return t.r.Read(p)
+	}
+
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	if len(p) > t.chunk {
+		p = p[:t.chunk]
+	}
+
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	// 已读取的字节数按速度应耗费的时间,超前时等待
+	expected := time.Duration(float64(t.read) / float64(t.rate) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return n, err
+}
+
+func (t *ThrottledReader) Close() error {
+	var err error
+	t.once.Do(func() {
+		if closer, ok := t.r.(io.Closer); ok {
+			err = closer.Close()
+		}
+		if t.release != nil {
+			t.release()
+		}
+	})
+	return err
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestThrottledReader(t *testing.T) {
+	const rate = 200 << 10
+	body := &closeRecorder{Reader: bytes.NewReader(make([]byte, rate/2))}
+	released := 0
+	r := NewThrottledReader(body, func() int64 { return rate }, func() { released++ })
+
+	started := time.Now()
+	n, err := io.Copy(io.Discard, r)
+	if err != nil || n != rate/2 {
+		t.Fatalf("Copy = %d, %v", n, err)
+	}
+	if elapsed := time.Since(started); elapsed < 400*time.Millisecond {
+		t.Errorf("read %d bytes at %d B/s in %v, want about 500ms", n, rate, elapsed)
+	}
+
+	r.Close()
+	r.Close()
+	if !body.closed || released != 1 {
+		t.Errorf("closed %t, released %d times", body.closed, released)
+	}
+}
+
+// 读取期间速度变化时按新的速度重新计时
+func TestThrottledReaderFollowsRate(t *testing.T) {
+	rate := int64(8 << 10)
+	r := NewThrottledReader(bytes.NewReader(make([]byte, 4<<20)), func() int64 { return rate }, nil)
+
+	if _, err := io.CopyN(io.Discard, r, 1<<10); err != nil {
+		t.Fatal(err)
+	}
+	rate = 0
+	time.Sleep(throttleRateInterval)
+
+	started := time.Now()
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > 200*time.Millisecond {
+		t.Errorf("read after the limit was lifted took %v", elapsed)
+	}
+}
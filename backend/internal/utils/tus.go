@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var errInvalidUploadMetadata = errors.New("invalid upload metadata")
+
+// ParseUploadMetadata 解析tus的Upload-Metadata头: 以逗号分隔的"键 base64值"对,值可省略
+func ParseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errInvalidUploadMetadata
+		}
+		if _, ok := metadata[key]; ok {
+			return nil, errInvalidUploadMetadata
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, errInvalidUploadMetadata
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -9,83 +11,120 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
-func UploadFile(file *multipart.FileHeader, path string) (string, error) {
-	// 获取文件扩展名
-	ext := filepath.Ext(file.Filename)
-
-	// 生成随机文件名
-	randomFileName, err := GenerateRandomFileName(ext)
+// SaveTemp 将上传文件写入临时目录并计算SHA-256,调用方负责删除临时文件
+func SaveTemp(file *multipart.FileHeader, tempDir string) (tempFile string, hash string, err error) {
+	src, err := file.Open()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
+	defer src.Close()
 
-	//检测目录是否存在，不存在则创建
-	if err := os.MkdirAll(path, os.ModePerm); err != nil {
-		return "", err
+	if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
+		return "", "", err
 	}
 
-	// 定义文件路径
-	filePath := filepath.Join(path, randomFileName)
-
-	// 打开上传的文件
-	src, err := file.Open()
+	tempName, err := GenerateRandomFileName(".part")
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	defer src.Close()
+	tempFile = filepath.Join(tempDir, tempName)
 
-	// 创建目标文件
-	dst, err := os.Create(filePath)
+	dst, err := os.Create(tempFile)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	defer dst.Close()
 
-	// 复制文件内容到目标位置
-	if _, err = io.Copy(dst, src); err != nil {
-		return "", err
+	hash, err = copyWithHash(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempFile)
+		return "", "", err
 	}
 
-	filePath = filepath.ToSlash(filePath)
+	return tempFile, hash, nil
+}
 
-	return filePath, nil
+// BlobKey 内容寻址的存储key: blobs/哈希前两位/哈希+扩展名,扩展名统一小写
+func BlobKey(hash string, ext string) string {
+	return "blobs/" + hash[:2] + "/" + hash + strings.ToLower(ext)
 }
 
-func UploadDownloadFile(file *multipart.FileHeader, path string) (string, error) {
-	//生成随机字符名8位，用作文件夹名字
-	randomFolderName, err := generateRandomString(8)
-	if err != nil {
-		return "", err
+// BlobKeyHash 从BlobKey生成的存储key中取出内容的SHA-256,其他key返回false
+func BlobKeyHash(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, "blobs/")
+	if !ok {
+		return "", false
 	}
-	//拼接文件夹
-	folderPath := filepath.Join(path, randomFolderName)
-	// 创建文件夹
-	if err := os.MkdirAll(folderPath, os.ModePerm); err != nil {
-		return "", err
+	dir, name, ok := strings.Cut(rest, "/")
+	if !ok || len(name) < sha256.Size*2 {
+		return "", false
 	}
-	//保存上传文件
-	filePath := filepath.Join(folderPath, file.Filename)
-	dst, err := os.Create(filePath)
-	if err != nil {
+
+	hash := name[:sha256.Size*2]
+	if ext := name[len(hash):]; ext != "" && (ext[0] != '.' || strings.Contains(ext, "/")) {
+		return "", false
+	}
+	if _, err := hex.DecodeString(hash); err != nil || hash != strings.ToLower(hash) || dir != hash[:2] {
+		return "", false
+	}
+	return hash, true
+}
+
+// MoveBlob 将文件移动到blob路径,目标已存在时删除源文件;返回是否新建了目标文件
+func MoveBlob(src string, key string) (bool, error) {
+	if _, err := os.Stat(key); err == nil {
+		return false, os.Remove(src)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(key), os.ModePerm); err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(src, key); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// 复制内容的同时计算SHA-256,避免再次读取文件
+func copyWithHash(dst io.Writer, src io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, hash), src); err != nil {
 		return "", err
 	}
-	defer dst.Close()
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
 
-	src, err := file.Open()
+// HashFile 计算本地文件的SHA-256
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return "", err
 	}
-	defer src.Close()
+	defer f.Close()
+
+	return HashReader(f)
+}
+
+// HashReader 读取全部内容并计算SHA-256
+func HashReader(r io.Reader) (string, error) {
+	return copyWithHash(io.Discard, r)
+}
 
-	if _, err := io.Copy(dst, src); err != nil {
+// DigestHeader 将十六进制SHA-256转换为 Digest 头的值(RFC 3230)
+func DigestHeader(sha256Hex string) (string, error) {
+	sum, err := hex.DecodeString(sha256Hex)
+	if err != nil {
 		return "", err
 	}
-	filePath = filepath.ToSlash(filePath)
-	// 返回文件路径
-	return filePath, nil
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum), nil
 }
 
 func GetFile(path string) (string, error) {
@@ -135,12 +174,3 @@ func GenerateRandomFileName(ext string) (string, error) {
 	randomPart := hex.EncodeToString(randomBytes)
 	return fmt.Sprintf("%d_%s%s", timestamp, randomPart, ext), nil
 }
-
-// 生成随机字符串
-func generateRandomString(length int) (string, error) {
-	bytes := make([]byte, length/2)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(bytes), nil
-}
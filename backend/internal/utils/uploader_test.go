@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBlobKeyHash(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		key string
+		ok  bool
+	}{
+		{BlobKey(hash, ".ZIP"), true},
+		{BlobKey(hash, ""), true},
+		{"blobs/cd/" + hash + ".zip", false},
+		{"blobs/AB/" + strings.ToUpper(hash) + ".zip", false},
+		{"blobs/ab/" + hash[:62] + ".zip", false},
+		{"blobs/ab/" + hash + "zip", false},
+		{"quarantine/ab/" + hash + ".zip", false},
+		{"mods/2024/01/file.zip", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := BlobKeyHash(tt.key)
+		if ok != tt.ok || ok && got != hash {
+			t.Errorf("BlobKeyHash(%q) = %q, %t, want ok %t", tt.key, got, ok, tt.ok)
+		}
+	}
+}
@@ -2,8 +2,13 @@ package repository
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/custom"
 	"ModVerse/internal/utils"
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -19,12 +24,191 @@ func NewCategoriesRepository(db *gorm.DB) domain.CategoriesRepository {
 	}
 }
 
+// AddCategories 创建分类并计算层级路径,关联的游戏须已存在
 func (r *CategoriesRepository) AddCategories(c context.Context, ca *domain.Categories) error {
-	return r.DB.WithContext(c).Create(ca).Error
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	parentPath := "/"
+	ca.Depth = 1
+	if ca.ParentID != 0 {
+		var parent domain.Categories
+		if err := tx.First(&parent, ca.ParentID).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if parent.AliasOfID != 0 {
+			tx.Rollback()
+			return errors.New("parent category is an alias")
+		}
+		if parent.Depth >= domain.CategoryMaxDepth {
+			tx.Rollback()
+			return fmt.Errorf("category depth cannot exceed %d", domain.CategoryMaxDepth)
+		}
+		parentPath = parent.Path
+		ca.Depth = parent.Depth + 1
+	}
+
+	if err := checkGamesExist(tx, ca.Games); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Omit("Games.*").Create(ca).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	ca.Path = parentPath + strconv.FormatUint(uint64(ca.ID), 10) + "/"
+	if err := tx.Model(ca).UpdateColumn("path", ca.Path).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func checkGamesExist(tx *gorm.DB, games []domain.Game) error {
+	if len(games) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(games))
+	for _, g := range games {
+		ids = append(ids, g.ID)
+	}
+
+	var total int64
+	if err := tx.Model(&domain.Game{}).Where("id IN ?", ids).Count(&total).Error; err != nil {
+		return err
+	}
+	if total != int64(len(ids)) {
+		return custom.DataNotExistError
+	}
+	return nil
+}
+
+// 替换分类关联的游戏,gameIDs为空表示对所有游戏可见
+func replaceCategoryGames(tx *gorm.DB, id uint, gameIDs []uint) error {
+	games := make([]domain.Game, 0, len(gameIDs))
+	for _, gid := range gameIDs {
+		games = append(games, domain.Game{Model: gorm.Model{ID: gid}})
+	}
+
+	if err := checkGamesExist(tx, games); err != nil {
+		return err
+	}
+
+	ca := domain.Categories{Model: gorm.Model{ID: id}}
+	return tx.Model(&ca).Omit("Games.*").Association("Games").Replace(games)
+}
+
+// CheckCategoriesForGame 父分类对游戏不可见时子树一并隐藏,因此需检查路径上的每一级
+func (r *CategoriesRepository) CheckCategoriesForGame(c context.Context, categories []domain.Categories, gameID uint) error {
+	ids := make([]uint, 0, len(categories)*domain.CategoryMaxDepth)
+	for _, ca := range categories {
+		ids = append(ids, categoryPathIDs(ca)...)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var links []struct {
+		CategoriesID uint
+		GameID       uint
+	}
+	if err := r.DB.WithContext(c).Table("category_games").
+		Select("categories_id, game_id").
+		Where("categories_id IN ?", ids).
+		Scan(&links).Error; err != nil {
+		return err
+	}
+
+	// 关联了游戏的分类及其是否关联了该游戏
+	scoped := make(map[uint]bool, len(links))
+	for _, l := range links {
+		scoped[l.CategoriesID] = scoped[l.CategoriesID] || l.GameID == gameID
+	}
+
+	var invalid []string
+	for _, ca := range categories {
+		for _, id := range categoryPathIDs(ca) {
+			if allowed, ok := scoped[id]; ok && !allowed {
+				invalid = append(invalid, ca.Name)
+				break
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w: %s", domain.ErrCategoryNotForGame, strings.Join(invalid, ", "))
+	}
+
+	return nil
+}
+
+// 分类路径上从根到自身的ID,路径为空的旧数据只包含自身
+func categoryPathIDs(ca domain.Categories) []uint {
+	var ids []uint
+	for _, part := range strings.Split(strings.Trim(ca.Path, "/"), "/") {
+		if id, err := strconv.ParseUint(part, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	if len(ids) == 0 {
+		ids = append(ids, ca.ID)
+	}
+	return ids
 }
 
 func (r *CategoriesRepository) DeleteCategories(c context.Context, id string) error {
-	return r.DB.WithContext(c).Where("id = ?", id).Delete(&domain.Categories{}).Error
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var children int64
+	if err := tx.Model(&domain.Categories{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if children > 0 {
+		tx.Rollback()
+		return errors.New("category has subcategories")
+	}
+
+	if err := tx.Exec("DELETE FROM mod_categories WHERE categories_id = ?", id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Exec("DELETE FROM category_games WHERE categories_id = ?", id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 同时删除指向该分类的别名
+	if err := tx.Where("id = ? OR alias_of_id = ?", id, id).Delete(&domain.Categories{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
 }
 
 func (r *CategoriesRepository) GetCategories(c context.Context, params *domain.CategoriesQuery) (*[]domain.CategoriesResponse, int64, error) {
@@ -34,6 +218,7 @@ func (r *CategoriesRepository) GetCategories(c context.Context, params *domain.C
 	categoriesAllowedFields := map[string]struct{}{
 		"created_at": {},
 		"name":       {},
+		"sort_order": {},
 	}
 
 	query := r.DB.WithContext(c).Model(&domain.Categories{})
@@ -46,6 +231,14 @@ func (r *CategoriesRepository) GetCategories(c context.Context, params *domain.C
 		query = query.Where("status = ?", params.Status)
 	}
 
+	if !params.WithAlias {
+		query = query.Where("alias_of_id = 0")
+	}
+
+	if params.GameID != 0 {
+		query = query.Where(categoriesForGame(r.DB, params.GameID))
+	}
+
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -79,6 +272,326 @@ func (r *CategoriesRepository) GetCategoriesByID(c context.Context, id string) (
 	return &categories, nil
 }
 
-func (r *CategoriesRepository) UpdateCategories(c context.Context, ca *domain.Categories) error {
-	return r.DB.WithContext(c).Model(ca).Updates(ca).Error
+func (r *CategoriesRepository) UpdateCategories(c context.Context, ca *domain.Categories, gameIDs []uint) error {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(ca).Omit("Games").Updates(ca).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if gameIDs != nil {
+		if err := replaceCategoryGames(tx, ca.ID, gameIDs); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// 包括已删除的分类,slug唯一索引对软删除记录同样生效
+func (r *CategoriesRepository) SlugExists(c context.Context, slug string) (bool, error) {
+	var total int64
+	if err := r.DB.WithContext(c).Unscoped().Model(&domain.Categories{}).Where("slug = ?", slug).Count(&total).Error; err != nil {
+		return false, err
+	}
+	return total > 0, nil
+}
+
+// ResolveCategories 按ID或名称/slug查找分类并解析别名,返回去重后的规范分类
+// 任一ID或名称不存在时返回DataNotExistError
+func (r *CategoriesRepository) ResolveCategories(c context.Context, ids []uint, names []string) (*[]domain.Categories, error) {
+	if len(ids) == 0 && len(names) == 0 {
+		return &[]domain.Categories{}, nil
+	}
+
+	var matched []domain.Categories
+	query := r.DB.WithContext(c).Model(&domain.Categories{})
+	switch {
+	case len(ids) > 0 && len(names) > 0:
+		query = query.Where("id IN ? OR name IN ? OR slug IN ?", ids, names, names)
+	case len(ids) > 0:
+		query = query.Where("id IN ?", ids)
+	default:
+		query = query.Where("name IN ? OR slug IN ?", names, names)
+	}
+	if err := query.Find(&matched).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]struct{}, len(matched)*3)
+	for _, ca := range matched {
+		found["id:"+strconv.FormatUint(uint64(ca.ID), 10)] = struct{}{}
+		found["name:"+ca.Name] = struct{}{}
+		found["name:"+ca.Slug] = struct{}{}
+	}
+	for _, id := range ids {
+		if _, ok := found["id:"+strconv.FormatUint(uint64(id), 10)]; !ok {
+			return nil, custom.DataNotExistError
+		}
+	}
+	for _, name := range names {
+		if _, ok := found["name:"+name]; !ok {
+			return nil, custom.DataNotExistError
+		}
+	}
+
+	canonicalIDs := make([]uint, 0, len(matched))
+	seen := make(map[uint]struct{}, len(matched))
+	for _, ca := range matched {
+		id := ca.ID
+		if ca.AliasOfID != 0 {
+			id = ca.AliasOfID
+		}
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			canonicalIDs = append(canonicalIDs, id)
+		}
+	}
+
+	var categories []domain.Categories
+	if err := r.DB.WithContext(c).Where("id IN ?", canonicalIDs).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	if len(categories) != len(canonicalIDs) {
+		return nil, custom.DataNotExistError
+	}
+
+	return &categories, nil
+}
+
+// MergeCategories 将源分类的模组并入目标分类,源分类及指向它的别名改为目标分类的别名
+func (r *CategoriesRepository) MergeCategories(c context.Context, sourceID uint, targetID uint) error {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var target domain.Categories
+	if err := tx.First(&target, targetID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if target.AliasOfID != 0 {
+		targetID = target.AliasOfID
+	}
+	if targetID == sourceID {
+		tx.Rollback()
+		return errors.New("cannot merge a category into itself")
+	}
+
+	var source domain.Categories
+	if err := tx.First(&source, sourceID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var children int64
+	if err := tx.Model(&domain.Categories{}).Where("parent_id = ?", sourceID).Count(&children).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if children > 0 {
+		tx.Rollback()
+		return errors.New("category has subcategories, move them first")
+	}
+
+	// 已同时拥有两个分类的模组由主键冲突忽略
+	if err := tx.Exec("INSERT IGNORE INTO mod_categories (mod_id, categories_id) SELECT mod_id, ? FROM mod_categories WHERE categories_id = ?", targetID, sourceID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Exec("DELETE FROM mod_categories WHERE categories_id = ?", sourceID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(&domain.Categories{}).Where("alias_of_id = ? OR id = ?", sourceID, sourceID).UpdateColumn("alias_of_id", targetID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// 对指定游戏可用的分类: 未关联任何游戏,或关联了该游戏
+func categoriesForGame(db *gorm.DB, gameID uint) *gorm.DB {
+	db = db.Session(&gorm.Session{NewDB: true})
+	return db.Where("NOT EXISTS (SELECT 1 FROM category_games cg WHERE cg.categories_id = categories.id)").
+		Or("EXISTS (SELECT 1 FROM category_games cg WHERE cg.categories_id = categories.id AND cg.game_id = ?)", gameID)
+}
+
+// GetCategoryTree 读取全部规范分类及模组数,gameID不为0时只包含该游戏可用的分类并只统计该游戏的模组
+// 返回按层级、排序值排列的扁平列表,由调用方组装成树
+func (r *CategoriesRepository) GetCategoryTree(c context.Context, gameID uint) (*[]domain.CategoryTreeNode, error) {
+	var nodes []domain.CategoryTreeNode
+
+	query := r.DB.WithContext(c).Model(&domain.Categories{}).Where("alias_of_id = 0")
+	if gameID != 0 {
+		query = query.Where(categoriesForGame(r.DB, gameID))
+	}
+	if err := query.Order("depth, sort_order, name").Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+
+	if len(nodes) == 0 {
+		return &nodes, nil
+	}
+
+	ids := make([]uint, 0, len(nodes))
+	index := make(map[uint]*domain.CategoryTreeNode, len(nodes))
+	for i := range nodes {
+		ids = append(ids, nodes[i].ID)
+		index[nodes[i].ID] = &nodes[i]
+	}
+
+	var links []struct {
+		CategoriesID uint
+		GameID       uint
+	}
+	if err := r.DB.WithContext(c).Table("category_games").
+		Select("categories_id, game_id").
+		Where("categories_id IN ?", ids).
+		Scan(&links).Error; err != nil {
+		return nil, err
+	}
+	for _, l := range links {
+		index[l.CategoriesID].GameIDs = append(index[l.CategoriesID].GameIDs, l.GameID)
+	}
+
+	type countRow struct {
+		ID    uint
+		Count int64
+	}
+
+	mods := r.DB.WithContext(c).Model(&domain.Mod{}).Select("id").Where("status = ?", "enable")
+	if gameID != 0 {
+		mods = mods.Where("game_id = ?", gameID)
+	}
+
+	var direct []countRow
+	if err := r.DB.WithContext(c).Table("mod_categories mc").
+		Select("mc.categories_id AS id, COUNT(*) AS count").
+		Where("mc.categories_id IN ? AND mc.mod_id IN (?)", ids, mods).
+		Group("mc.categories_id").
+		Scan(&direct).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range direct {
+		index[row.ID].ModCount = row.Count
+	}
+
+	// 子树模组数: 路径以祖先路径为前缀的分类均属于该子树,同一模组只计一次
+	var total []countRow
+	if err := r.DB.WithContext(c).Table("categories anc").
+		Select("anc.id AS id, COUNT(DISTINCT mc.mod_id) AS count").
+		Joins("JOIN categories d ON d.path LIKE CONCAT(anc.path, '%') AND d.deleted_at IS NULL").
+		Joins("JOIN mod_categories mc ON mc.categories_id = d.id").
+		Where("anc.id IN ? AND mc.mod_id IN (?)", ids, mods).
+		Group("anc.id").
+		Scan(&total).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range total {
+		index[row.ID].TotalModCount = row.Count
+	}
+
+	return &nodes, nil
+}
+
+// MoveCategory 将分类及其子树移动到新的父分类下
+// 模组关联的是分类ID,移动只改写子树的路径与层级,已归类的模组不受影响
+func (r *CategoriesRepository) MoveCategory(c context.Context, id uint, parentID uint, sortOrder int) error {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var node domain.Categories
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&node, id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if node.AliasOfID != 0 {
+		tx.Rollback()
+		return errors.New("cannot move an alias")
+	}
+
+	parentPath := "/"
+	newDepth := 1
+	if parentID != 0 {
+		var parent domain.Categories
+		if err := tx.First(&parent, parentID).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if parent.AliasOfID != 0 {
+			tx.Rollback()
+			return errors.New("parent category is an alias")
+		}
+		if strings.HasPrefix(parent.Path, node.Path) {
+			tx.Rollback()
+			return errors.New("cannot move a category into its own subtree")
+		}
+		parentPath = parent.Path
+		newDepth = parent.Depth + 1
+	}
+
+	var maxDepth int
+	if err := tx.Model(&domain.Categories{}).
+		Select("COALESCE(MAX(depth), 0)").
+		Where("path LIKE ?", utils.EscapeLike(node.Path)+"%").
+		Scan(&maxDepth).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if newDepth+maxDepth-node.Depth > domain.CategoryMaxDepth {
+		tx.Rollback()
+		return fmt.Errorf("category depth cannot exceed %d", domain.CategoryMaxDepth)
+	}
+
+	newPath := parentPath + strconv.FormatUint(uint64(node.ID), 10) + "/"
+
+	// 包括已软删除的子分类,保持路径一致
+	if err := tx.Exec("UPDATE categories SET path = CONCAT(?, SUBSTRING(path, ?)), depth = depth + ? WHERE path LIKE ?",
+		newPath, len(node.Path)+1, newDepth-node.Depth, utils.EscapeLike(node.Path)+"%").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(&node).UpdateColumns(map[string]any{
+		"parent_id":  parentID,
+		"sort_order": sortOrder,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
 }
@@ -49,15 +49,26 @@ func (r *commentRepository) GetCommentsWithReplies(c context.Context, modID stri
 	return &comments, total, nil
 }
 
-func (r *commentRepository) GetAllComments(c context.Context, params domain.CommentQuery) (*[]domain.CommentResponse, int64, error) {
+func (r *commentRepository) GetAllComments(c context.Context, params domain.CommentQuery) (*[]domain.CommentResponse, int64, string, error) {
 	var comments []domain.CommentResponse
-	var total int64
+	var total int64 = domain.TotalUnknown
 
 	commentsAllowedFields := map[string]struct{}{
 		"created_at": {},
 		"name":       {},
 	}
 
+	sortField := utils.SafeSortField(params.Sort, commentsAllowedFields, "created_at")
+	orderDirection := utils.SafeOrderDirection(params.Order)
+
+	cursor, err := utils.DecodeCursor(params.Cursor, commentsAllowedFields)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if cursor != nil {
+		sortField, orderDirection = cursor.Sort, cursor.Order
+	}
+
 	query := r.DB.WithContext(c).Model(&domain.Comment{}).Joins("User")
 
 	if params.Content != "" {
@@ -68,29 +79,39 @@ func (r *commentRepository) GetAllComments(c context.Context, params domain.Comm
 		query = query.Where("user.user_name = ?", params.UserName)
 	}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
+	if utils.NeedTotal(params.Total, cursor) {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
 
-	if total == 0 {
-		return &[]domain.CommentResponse{}, 0, nil
+		if total == 0 {
+			return &[]domain.CommentResponse{}, 0, "", nil
+		}
 	}
 
-	sortField := utils.SafeSortField(params.Sort, commentsAllowedFields, "created_at")
-	orderDirection := utils.SafeOrderDirection(params.Order)
-
 	query = query.Order(clause.OrderByColumn{
-		Column: clause.Column{Name: sortField},
+		Column: clause.Column{Table: "comments", Name: sortField},
+		Desc:   orderDirection == "desc",
+	}).Order(clause.OrderByColumn{
+		Column: clause.Column{Table: "comments", Name: "id"},
 		Desc:   orderDirection == "desc",
 	})
 
-	query = utils.ApplyPaging(query, params.Page, params.PageSize)
+	query, err = utils.ApplyCursorPaging(query, cursor, params.Page, params.PageSize, "comments."+sortField, "comments.id")
+	if err != nil {
+		return nil, 0, "", err
+	}
 
 	if err := query.Model(&domain.Comment{}).Find(&comments).Error; err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
-	return &comments, total, nil
+	nextCursor, err := utils.NextCursor(comments, sortField, orderDirection, params.PageSize, cursor != nil)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return &comments, total, nextCursor, nil
 }
 
 func (r *commentRepository) GetCommentByID(c context.Context, id string) (*domain.CommentResponse, error) {
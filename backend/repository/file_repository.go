@@ -2,9 +2,15 @@ package repository
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/custom"
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type storageFileRepository struct {
@@ -17,13 +23,266 @@ func NewStorageFileRepository(db *gorm.DB) domain.StorageFileRepository {
 	}
 }
 
-func (r *storageFileRepository) CreateStorageFile(c context.Context, sf *domain.StorageFile) error {
-	if err := r.DB.WithContext(c).Create(sf).Error; err != nil {
+// CreateStorageFile 创建文件记录并增加所指向blob的引用数
+func (r *storageFileRepository) CreateStorageFile(c context.Context, sf *domain.StorageFile, quota int64) error {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if quota > 0 {
+		if err := checkQuota(tx, sf, quota); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Create(sf).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := retainStorageBlob(tx, sf); err != nil {
+		tx.Rollback()
 		return err
 	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	return nil
 }
 
+// 锁定用户行后计算用量,同一用户并发的上传依次检查,已通过的记录提交后才计入下一次检查
+// 记录本身与图片版本中用户已持有的内容不增加用量
+func checkQuota(tx *gorm.DB, sf *domain.StorageFile, quota int64) error {
+	var user domain.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, sf.UserID).Error; err != nil {
+		return err
+	}
+
+	held, err := userBlobs(tx.Where("user_id = ?", sf.UserID))
+	if err != nil {
+		return err
+	}
+	h, ok := held[sf.UserID]
+	if !ok {
+		h = &heldBlobs{}
+	}
+
+	var needed int64
+	for key, size := range fileBlobs(sf) {
+		if _, ok := h.sizes[key]; !ok {
+			needed += size
+		}
+	}
+	if needed == 0 {
+		return nil
+	}
+
+	used := h.used()
+	if used+needed > quota {
+		return fmt.Errorf("%w: %d of %d bytes used, the upload needs %d more", domain.ErrQuotaExceeded, used, quota, needed)
+	}
+
+	return nil
+}
+
+// 增加文件及其图片版本的blob引用数,blob不存在时创建;未记录哈希的旧文件不参与引用计数
+func retainStorageBlob(tx *gorm.DB, sf *domain.StorageFile) error {
+	if sf.SHA256 == "" {
+		return nil
+	}
+
+	if err := retainBlob(tx, sf.FileKey, sf.SHA256, sf.FileSize); err != nil {
+		return err
+	}
+
+	for _, v := range sf.Variants {
+		if err := retainBlob(tx, v.FileKey, v.SHA256, v.FileSize); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func retainBlob(tx *gorm.DB, key string, sha256 string, size int64) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_key"}},
+		DoUpdates: clause.Assignments(map[string]any{"ref_count": gorm.Expr("ref_count + 1"), "updated_at": gorm.Expr("NOW()")}),
+	}).Create(&domain.StorageBlob{
+		FileKey:  key,
+		SHA256:   sha256,
+		FileSize: size,
+		RefCount: 1,
+	}).Error
+}
+
+// 删除文件记录并减少blob引用数,返回引用归零、需要从磁盘删除的文件路径
+// 没有blob记录的旧文件在没有其他记录使用同一路径时删除
+func releaseStorageFiles(tx *gorm.DB, ids []uint) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var files []domain.StorageFile
+	if err := tx.Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	if err := tx.Delete(&domain.StorageFile{}, ids).Error; err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]int, len(files))
+	keys := make([]string, 0, len(files))
+	addRef := func(key string) {
+		if _, ok := refs[key]; !ok {
+			keys = append(keys, key)
+		}
+		refs[key]++
+	}
+	for _, sf := range files {
+		addRef(sf.FileKey)
+		for _, v := range sf.Variants {
+			addRef(v.FileKey)
+		}
+	}
+
+	return releaseBlobRefs(tx, keys, refs)
+}
+
+// 按路径减少blob引用数,返回引用归零、需要删除的对象路径
+func releaseBlobRefs(tx *gorm.DB, keys []string, refs map[string]int) ([]string, error) {
+	var orphans []string
+	for _, key := range keys {
+		var blob domain.StorageBlob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("file_key = ?", key).First(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			var remaining int64
+			if err := tx.Model(&domain.StorageFile{}).Where("file_key = ?", key).Count(&remaining).Error; err != nil {
+				return nil, err
+			}
+			if remaining == 0 {
+				orphans = append(orphans, key)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if blob.RefCount <= refs[key] {
+			if err := tx.Delete(&blob).Error; err != nil {
+				return nil, err
+			}
+			orphans = append(orphans, key)
+			continue
+		}
+
+		if err := tx.Model(&blob).UpdateColumn("ref_count", gorm.Expr("ref_count - ?", refs[key])).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return orphans, nil
+}
+
+// ReserveBlob 写入对象前先占用blob的一个引用,之后并发的删除不会回收该对象,
+// 记录创建完成(无论成功与否)后调用 ReleaseBlob 归还
+func (r *storageFileRepository) ReserveBlob(c context.Context, key string, sha256 string, size int64) error {
+	return retainBlob(r.DB.WithContext(c), key, sha256, size)
+}
+
+// ReleaseBlob 归还 ReserveBlob 占用的引用,返回引用归零、需要删除的对象路径
+func (r *storageFileRepository) ReleaseBlob(c context.Context, key string) ([]string, error) {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	orphans, err := releaseBlobRefs(tx, []string{key}, map[string]int{key: 1})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return orphans, nil
+}
+
+// DeleteUnreferencedObject 确认路径没有blob记录与文件记录后执行del删除对象
+// 查询锁定blob记录,不存在时锁定唯一索引上的间隙(InnoDB默认的可重复读隔离级别),
+// 并发的 ReserveBlob 需等待删除完成,之后会重新写入对象
+func (r *storageFileRepository) DeleteUnreferencedObject(c context.Context, key string, del func() error) error {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var blobs []domain.StorageBlob
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("file_key = ?", key).Find(&blobs).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	files, err := countKeyFiles(tx, key)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 已被重新引用
+	if len(blobs) > 0 || files > 0 {
+		tx.Rollback()
+		return nil
+	}
+
+	if err := del(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// 清除文件的临时标记,被引用的文件不再由存储清理删除
+func claimStorageFiles(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Model(&domain.StorageFile{}).Where("id IN ? AND is_temp = ?", ids, true).UpdateColumn("is_temp", false).Error
+}
+
+// 只清除userID上传的文件的临时标记,其他用户的上传不会因被引用而保留
+func claimUserFiles(tx *gorm.DB, ids []uint, userID uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Model(&domain.StorageFile{}).Where("id IN ? AND user_id = ? AND is_temp = ?", ids, userID, true).UpdateColumn("is_temp", false).Error
+}
+
+// ClaimStorageFiles 文件被游戏或用户资料引用后清除临时标记
+func (r *storageFileRepository) ClaimStorageFiles(c context.Context, ids []uint) error {
+	return claimStorageFiles(r.DB.WithContext(c), ids)
+}
+
 func (r *storageFileRepository) GetStorageFile(c context.Context, id string) (*domain.StorageFile, error) {
 	var sf domain.StorageFile
 	if err := r.DB.WithContext(c).First(&sf, id).Error; err != nil {
@@ -32,6 +291,79 @@ func (r *storageFileRepository) GetStorageFile(c context.Context, id string) (*d
 	return &sf, nil
 }
 
+func (r *storageFileRepository) IsModFileKey(c context.Context, key string) (bool, error) {
+	var files int64
+	if err := r.DB.WithContext(c).Model(&domain.StorageFile{}).
+		Where("file_key = ? AND upload_type = ?", key, domain.UploadModFile).
+		Count(&files).Error; err != nil {
+		return false, err
+	}
+	return files > 0, nil
+}
+
+// HasKey 路径是否属于某个blob或文件记录,图片版本均有blob记录,只走索引查询
+func (r *storageFileRepository) HasKey(c context.Context, key string) (bool, error) {
+	db := r.DB.WithContext(c)
+
+	var blobs int64
+	if err := db.Model(&domain.StorageBlob{}).Where("file_key = ?", key).Count(&blobs).Error; err != nil {
+		return false, err
+	}
+	if blobs > 0 {
+		return true, nil
+	}
+
+	var files int64
+	if err := db.Model(&domain.StorageFile{}).Where("file_key = ?", key).Count(&files).Error; err != nil {
+		return false, err
+	}
+	return files > 0, nil
+}
+
+func (r *storageFileRepository) GetStorageFileByKey(c context.Context, fileKey string) (*domain.StorageFile, error) {
+	var sf domain.StorageFile
+	err := r.DB.WithContext(c).Where("file_key = ?", fileKey).First(&sf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, custom.DataNotExistError
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sf, nil
+}
+
+// GetStorageFilesAfter 按ID顺序分批读取文件记录
+func (r *storageFileRepository) GetStorageFilesAfter(c context.Context, afterID uint, limit int) (*[]domain.StorageFile, error) {
+	var sfs []domain.StorageFile
+	if err := r.DB.WithContext(c).Where("id > ?", afterID).Order("id").Limit(limit).Find(&sfs).Error; err != nil {
+		return nil, err
+	}
+	return &sfs, nil
+}
+
+// GetStorageBlobsAfter 按ID顺序分批读取blob
+func (r *storageFileRepository) GetStorageBlobsAfter(c context.Context, afterID uint, limit int) (*[]domain.StorageBlob, error) {
+	return blobsAfter(r.DB.WithContext(c), afterID, limit)
+}
+
+// GetUnhashedKeysAfter 按路径顺序分批读取未记录哈希的文件路径
+func (r *storageFileRepository) GetUnhashedKeysAfter(c context.Context, afterKey string, limit int) ([]string, error) {
+	var keys []string
+	if err := r.DB.WithContext(c).Model(&domain.StorageFile{}).
+		Where("sha256 = ? AND file_key > ?", "", afterKey).
+		Distinct("file_key").Order("file_key").Limit(limit).
+		Pluck("file_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *storageFileRepository) UpdateStorageFileHash(c context.Context, fileKey string, sha256 string) error {
+	return r.DB.WithContext(c).Model(&domain.StorageFile{}).
+		Where("file_key = ? AND sha256 = ?", fileKey, "").
+		UpdateColumn("sha256", sha256).Error
+}
+
 func (r *storageFileRepository) GetStorageFiles(c context.Context) (*[]domain.StorageFile, error) {
 	var sfs []domain.StorageFile
 	if err := r.DB.WithContext(c).Find(&sfs).Error; err != nil {
@@ -40,10 +372,157 @@ func (r *storageFileRepository) GetStorageFiles(c context.Context) (*[]domain.St
 	return &sfs, nil
 }
 
-func (r *storageFileRepository) DeleteStorageFile(c context.Context, id string) error {
+// DeleteStorageFile 删除文件记录,返回引用归零、需要从存储删除的文件路径(含图片版本)
+func (r *storageFileRepository) DeleteStorageFile(c context.Context, id string) ([]string, error) {
+	parseID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	orphans, err := releaseStorageFiles(tx, []uint{uint(parseID)})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return orphans, nil
+}
+
+// GetScanningKeys 等待扫描的文件路径,同一路径的多条记录只扫描一次,先上传的先扫描
+func (r *storageFileRepository) GetScanningKeys(c context.Context, limit int) ([]string, error) {
+	var keys []string
+	if err := r.DB.WithContext(c).Model(&domain.StorageFile{}).
+		Where("scan_status = ?", domain.ScanStatusScanning).
+		Group("file_key").
+		Order("MIN(id)").
+		Limit(limit).
+		Pluck("file_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// GetInfectedFileByHash 按内容哈希查找已隔离的文件,扩展名不同的相同内容同样命中
+func (r *storageFileRepository) GetInfectedFileByHash(c context.Context, sha256 string) (*domain.StorageFile, error) {
 	var sf domain.StorageFile
-	if err := r.DB.WithContext(c).Delete(&sf, id).Error; err != nil {
+	err := r.DB.WithContext(c).Where("sha256 = ? AND scan_status = ?", sha256, domain.ScanStatusInfected).First(&sf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, custom.DataNotExistError
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sf, nil
+}
+
+func (r *storageFileRepository) GetStorageFilesByKey(c context.Context, fileKey string) (*[]domain.StorageFile, error) {
+	var sfs []domain.StorageFile
+	if err := r.DB.WithContext(c).Where("file_key = ?", fileKey).Order("id").Find(&sfs).Error; err != nil {
+		return nil, err
+	}
+	return &sfs, nil
+}
+
+// SetScanResult 更新同一路径下全部文件记录的扫描结果并重新汇总所属版本的状态
+// 感染文件移入隔离目录后newKey与fileKey不同,记录与blob的路径一并更新,原下载地址失效
+func (r *storageFileRepository) SetScanResult(c context.Context, fileKey string, newKey string, status string, signature string) (*[]domain.ScanAffectedVersion, error) {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var fileIDs []uint
+	if err := tx.Model(&domain.StorageFile{}).Where("file_key = ?", fileKey).Pluck("id", &fileIDs).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	updates := map[string]any{
+		"scan_status":    status,
+		"scan_signature": signature,
+		"scanned_at":     time.Now(),
+	}
+	if newKey != fileKey {
+		updates["file_key"] = newKey
+		updates["url"] = ""
+	}
+	if err := tx.Model(&domain.StorageFile{}).Where("file_key = ?", fileKey).UpdateColumns(updates).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if newKey != fileKey {
+		if err := moveBlob(tx, fileKey, newKey); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	var versions []domain.ScanAffectedVersion
+	if len(fileIDs) > 0 {
+		if err := tx.Model(&domain.ModVersion{}).
+			Select("DISTINCT mod_versions.id, mod_versions.mod_id, mod_versions.version").
+			Joins("JOIN mod_version_files f ON f.mod_version_id = mod_versions.id AND f.deleted_at IS NULL").
+			Where("f.file_id IN ?", fileIDs).
+			Scan(&versions).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	versionIDs := make([]uint, 0, len(versions))
+	for _, v := range versions {
+		versionIDs = append(versionIDs, v.ID)
+	}
+	if err := refreshVersionScanStatus(tx, versionIDs); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return &versions, nil
+}
+
+// 将blob改到新路径,新路径已有blob时(相同内容此前已被隔离)合并引用数,避免违反file_key的唯一索引
+func moveBlob(tx *gorm.DB, fileKey string, newKey string) error {
+	var blob domain.StorageBlob
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("file_key = ?", fileKey).First(&blob).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
 		return err
 	}
-	return nil
+
+	var existing domain.StorageBlob
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("file_key = ?", newKey).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Model(&blob).UpdateColumn("file_key", newKey).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&existing).UpdateColumn("ref_count", gorm.Expr("ref_count + ?", blob.RefCount)).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&blob).Error
 }
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"context"
+	"errors"
+	"testing"
+)
+
+// 未记录哈希的文件不参与blob引用计数,只检查配额
+func TestCreateStorageFileChecksQuota(t *testing.T) {
+	db := newGCTestDB(t)
+	if err := db.AutoMigrate(&domain.User{}); err != nil {
+		t.Fatal(err)
+	}
+	user := domain.User{UserName: "uploader", Email: "uploader@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewStorageFileRepository(db)
+	ctx := context.Background()
+	create := func(key string, size int64) error {
+		return repo.CreateStorageFile(ctx, &domain.StorageFile{UserID: uint64(user.ID), FileKey: key, FileSize: size, ScanStatus: domain.ScanStatusClean}, 100)
+	}
+
+	if err := create("blobs/aa/first.zip", 60); err != nil {
+		t.Fatal(err)
+	}
+	// 检查时已计入先提交的记录,先前的检查通过不代表仍有空间
+	if err := create("blobs/bb/second.zip", 60); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Errorf("create over quota error = %v, want ErrQuotaExceeded", err)
+	}
+	// 已持有的内容不增加用量
+	if err := create("blobs/aa/first.zip", 60); err != nil {
+		t.Errorf("create duplicate content error = %v", err)
+	}
+	if err := create("blobs/cc/third.zip", 40); err != nil {
+		t.Errorf("create within quota error = %v", err)
+	}
+
+	var count int64
+	db.Model(&domain.StorageFile{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 3 {
+		t.Errorf("created %d files, want 3", count)
+	}
+}
+
+// 图片版本同样计入用量,同一内容只计一次
+func TestCreateStorageFileChargesVariants(t *testing.T) {
+	db := newGCTestDB(t)
+	if err := db.AutoMigrate(&domain.User{}); err != nil {
+		t.Fatal(err)
+	}
+	user := domain.User{UserName: "uploader", Email: "uploader@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewStorageFileRepository(db)
+	ctx := context.Background()
+	create := func(key string, thumb string) error {
+		return repo.CreateStorageFile(ctx, &domain.StorageFile{
+			UserID:     uint64(user.ID),
+			FileKey:    key,
+			FileSize:   30,
+			ScanStatus: domain.ScanStatusClean,
+			Variants:   []domain.StorageFileVariant{{FileKey: thumb, FileSize: 20}},
+		}, 100)
+	}
+
+	if err := create("blobs/aa/first.jpg", "blobs/ab/first.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	// 已用50,新图片及其版本共需50
+	if err := create("blobs/bb/second.jpg", "blobs/bc/second.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := create("blobs/cc/third.jpg", "blobs/cd/third.jpg"); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Errorf("create over quota error = %v, want ErrQuotaExceeded", err)
+	}
+
+	usage, err := NewQuotaRepository(db).GetUserUsage(ctx, uint64(user.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Used != 100 || usage.Files != 2 {
+		t.Errorf("usage = %d bytes in %d files, want 100 bytes in 2 files", usage.Used, usage.Files)
+	}
+}
+
+// 版本只能使用模组上传者自己上传的模组文件
+func TestCreateModVersionChecksFileOwner(t *testing.T) {
+	db := newGCTestDB(t)
+	ctx := context.Background()
+
+	mod := domain.Mod{Name: "mod", UserID: 1}
+	if err := db.Create(&mod).Error; err != nil {
+		t.Fatal(err)
+	}
+	files := []domain.StorageFile{
+		{FileKey: "blobs/aa/own.zip", UserID: 1, UploadType: domain.UploadModFile, IsTemp: true},
+		{FileKey: "blobs/bb/other.zip", UserID: 2, UploadType: domain.UploadModFile, IsTemp: true},
+		{FileKey: "blobs/cc/cover.webp", UserID: 1, UploadType: domain.UploadModCover, IsTemp: true},
+	}
+	for i := range files {
+		files[i].ScanStatus = domain.ScanStatusClean
+		if err := db.Create(&files[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := NewModVersionRepository(db)
+	create := func(version string, fileID uint) error {
+		return repo.CreateModVersion(ctx, &domain.ModVersion{
+			ModID:   mod.ID,
+			Version: version,
+			Channel: domain.ChannelStable,
+			Files:   []domain.ModVersionFile{{FileID: fileID, Role: domain.FileRoleMain}},
+		})
+	}
+
+	if err := create("1.0.0", files[1].ID); !errors.Is(err, custom.DataNotExistError) {
+		t.Errorf("other user's upload error = %v, want DataNotExistError", err)
+	}
+	if err := create("1.0.0", files[2].ID); !errors.Is(err, custom.DataNotExistError) {
+		t.Errorf("image upload error = %v, want DataNotExistError", err)
+	}
+	if err := create("1.0.0", files[0].ID); err != nil {
+		t.Fatalf("own mod file error = %v", err)
+	}
+
+	var claimed domain.StorageFile
+	db.First(&claimed, files[0].ID)
+	var other domain.StorageFile
+	db.First(&other, files[1].ID)
+	if claimed.IsTemp || !other.IsTemp {
+		t.Errorf("is_temp own %t, other %t, want false, true", claimed.IsTemp, other.IsTemp)
+	}
+}
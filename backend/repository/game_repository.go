@@ -32,7 +32,7 @@ func (r *gameRepository) GetGame(c context.Context, id string) (*domain.GameResp
 	if err := r.DB.WithContext(c).
 		Model(&domain.Game{}).
 		Preload("LogoFile", func(db *gorm.DB) *gorm.DB {
-			return db.Model(&domain.StorageFile{}).Select("id, url")
+			return db.Model(&domain.StorageFile{}).Select("id, url, variants")
 		}).
 		First(&game, id).Error; err != nil {
 		return nil, err
@@ -78,7 +78,7 @@ func (r *gameRepository) GetGames(c context.Context, params *domain.GameQuery) (
 
 	if err := query.
 		Preload("LogoFile", func(db *gorm.DB) *gorm.DB {
-			return db.Model(&domain.StorageFile{}).Select("id, url")
+			return db.Model(&domain.StorageFile{}).Select("id, url, variants")
 		}).
 		Find(&apiGames).Error; err != nil {
 		return nil, 0, err
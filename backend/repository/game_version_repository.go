@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gameVersionRepository struct {
+	DB *gorm.DB
+}
+
+func NewGameVersionRepository(db *gorm.DB) domain.GameVersionRepository {
+	return &gameVersionRepository{
+		DB: db,
+	}
+}
+
+func (r *gameVersionRepository) CreateGameVersion(c context.Context, gv *domain.GameVersion) error {
+	return r.DB.WithContext(c).Create(gv).Error
+}
+
+// GetGameVersions 按发布日期从新到旧返回游戏版本
+func (r *gameVersionRepository) GetGameVersions(c context.Context, gameID uint) (*[]domain.GameVersionResponse, error) {
+	var versions []domain.GameVersionResponse
+
+	if err := r.DB.WithContext(c).Model(&domain.GameVersion{}).
+		Where("game_id = ?", gameID).
+		Order("release_date DESC, id DESC").
+		Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	return &versions, nil
+}
+
+func (r *gameVersionRepository) DeleteGameVersion(c context.Context, id string) error {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Exec("DELETE FROM mod_version_game_versions WHERE game_version_id = ?", id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Where("game_version_id = ?", id).Delete(&domain.CompatibilityReport{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 直接删除而不是软删除,名称的唯一索引不包含deleted_at,删除后同名版本可以重新添加
+	if err := tx.Unscoped().Delete(&domain.GameVersion{}, id).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// 游戏版本须全部属于模组版本所在模组的游戏
+func checkModVersionGameVersions(tx *gorm.DB, modVersionID uint, gameVersionIDs []uint) error {
+	if len(gameVersionIDs) == 0 {
+		return nil
+	}
+
+	var total int64
+	if err := tx.Model(&domain.GameVersion{}).
+		Joins("JOIN mods ON mods.game_id = game_versions.game_id AND mods.deleted_at IS NULL").
+		Joins("JOIN mod_versions ON mod_versions.mod_id = mods.id AND mod_versions.deleted_at IS NULL").
+		Where("mod_versions.id = ? AND game_versions.id IN ?", modVersionID, gameVersionIDs).
+		Count(&total).Error; err != nil {
+		return err
+	}
+	if total != int64(len(gameVersionIDs)) {
+		return custom.DataNotExistError
+	}
+
+	return nil
+}
+
+// 游戏版本须全部属于该模组的游戏
+func checkModGameVersions(tx *gorm.DB, modID uint, gameVersionIDs []uint) error {
+	if len(gameVersionIDs) == 0 {
+		return nil
+	}
+
+	var total int64
+	if err := tx.Model(&domain.GameVersion{}).
+		Joins("JOIN mods ON mods.game_id = game_versions.game_id AND mods.deleted_at IS NULL").
+		Where("mods.id = ? AND game_versions.id IN ?", modID, gameVersionIDs).
+		Count(&total).Error; err != nil {
+		return err
+	}
+	if total != int64(len(gameVersionIDs)) {
+		return custom.DataNotExistError
+	}
+
+	return nil
+}
+
+// SetModVersionGameVersions 替换模组版本声明支持的游戏版本
+func (r *gameVersionRepository) SetModVersionGameVersions(c context.Context, modVersionID uint, gameVersionIDs []uint) error {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := checkModVersionGameVersions(tx, modVersionID, gameVersionIDs); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	gameVersions := make([]domain.GameVersion, 0, len(gameVersionIDs))
+	for _, id := range gameVersionIDs {
+		gameVersions = append(gameVersions, domain.GameVersion{Model: gorm.Model{ID: id}})
+	}
+
+	mv := domain.ModVersion{Model: gorm.Model{ID: modVersionID}}
+	if err := tx.Model(&mv).Omit("GameVersions.*").Association("GameVersions").Replace(gameVersions); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// GetCompatibilityPairs 统计模组各版本与游戏版本组合的声明情况与用户反馈
+func (r *gameVersionRepository) GetCompatibilityPairs(c context.Context, modID uint) (*[]domain.CompatibilityPair, error) {
+	type pairKey struct {
+		modVersionID  uint
+		gameVersionID uint
+	}
+
+	var declared []struct {
+		ModVersionID  uint
+		GameVersionID uint
+	}
+	if err := r.DB.WithContext(c).Table("mod_version_game_versions j").
+		Select("j.mod_version_id, j.game_version_id").
+		Joins("JOIN mod_versions mv ON mv.id = j.mod_version_id AND mv.deleted_at IS NULL").
+		Where("mv.mod_id = ?", modID).
+		Scan(&declared).Error; err != nil {
+		return nil, err
+	}
+
+	var reported []domain.CompatibilityPair
+	if err := r.DB.WithContext(c).Model(&domain.CompatibilityReport{}).
+		Select("compatibility_reports.mod_version_id, compatibility_reports.game_version_id, "+
+			"SUM(CASE WHEN compatibility_reports.works THEN 1 ELSE 0 END) AS works, "+
+			"SUM(CASE WHEN compatibility_reports.works THEN 0 ELSE 1 END) AS broken").
+		Joins("JOIN mod_versions mv ON mv.id = compatibility_reports.mod_version_id AND mv.deleted_at IS NULL").
+		Where("mv.mod_id = ?", modID).
+		Group("compatibility_reports.mod_version_id, compatibility_reports.game_version_id").
+		Scan(&reported).Error; err != nil {
+		return nil, err
+	}
+
+	pairs := make([]domain.CompatibilityPair, 0, len(declared)+len(reported))
+	index := make(map[pairKey]int, len(declared)+len(reported))
+	for _, d := range declared {
+		index[pairKey{d.ModVersionID, d.GameVersionID}] = len(pairs)
+		pairs = append(pairs, domain.CompatibilityPair{
+			ModVersionID:  d.ModVersionID,
+			GameVersionID: d.GameVersionID,
+			Declared:      true,
+		})
+	}
+	for _, p := range reported {
+		if i, ok := index[pairKey{p.ModVersionID, p.GameVersionID}]; ok {
+			pairs[i].Works = p.Works
+			pairs[i].Broken = p.Broken
+			continue
+		}
+		pairs = append(pairs, p)
+	}
+
+	return &pairs, nil
+}
+
+func (r *gameVersionRepository) GetModGameID(c context.Context, modID uint) (uint, error) {
+	var mod domain.Mod
+
+	if err := r.DB.WithContext(c).Select("id, game_id").First(&mod, modID).Error; err != nil {
+		return 0, err
+	}
+
+	return mod.GameID, nil
+}
+
+// SaveCompatibilityReport 保存用户反馈,同一用户重复提交时覆盖
+func (r *gameVersionRepository) SaveCompatibilityReport(c context.Context, report *domain.CompatibilityReport) error {
+	db := r.DB.WithContext(c)
+
+	if err := checkModVersionGameVersions(db, report.ModVersionID, []uint{report.GameVersionID}); err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "mod_version_id"}, {Name: "game_version_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{"works": report.Works, "updated_at": gorm.Expr("NOW()"), "deleted_at": nil}),
+	}).Create(report).Error
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"ModVerse/domain"
+	"context"
+	"strconv"
+	"testing"
+)
+
+// 删除的版本名称可以重新添加
+func TestDeleteGameVersionFreesName(t *testing.T) {
+	db := newGCTestDB(t)
+	if err := db.AutoMigrate(&domain.GameVersion{}, &domain.CompatibilityReport{}); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewGameVersionRepository(db)
+	ctx := context.Background()
+
+	gv := domain.GameVersion{GameID: 1, Name: "1.20"}
+	if err := repo.CreateGameVersion(ctx, &gv); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteGameVersion(ctx, strconv.FormatUint(uint64(gv.ID), 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateGameVersion(ctx, &domain.GameVersion{GameID: 1, Name: "1.20"}); err != nil {
+		t.Errorf("recreate deleted version error = %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type manifestRepository struct {
+	DB *gorm.DB
+}
+
+func NewManifestRepository(db *gorm.DB) domain.ManifestRepository {
+	return &manifestRepository{
+		DB: db,
+	}
+}
+
+// GetFileManifests 按fileIDs的顺序返回文件的清单,没有清单的文件跳过
+func (r *manifestRepository) GetFileManifests(c context.Context, fileIDs []uint) ([]domain.ModManifest, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+
+	var files []domain.StorageFile
+	if err := r.DB.WithContext(c).Select("id, manifest").
+		Where("id IN ? AND manifest IS NOT NULL", fileIDs).
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]*domain.ModManifest, len(files))
+	for _, sf := range files {
+		byID[sf.ID] = sf.Manifest
+	}
+
+	manifests := make([]domain.ModManifest, 0, len(files))
+	for _, id := range fileIDs {
+		if m := byID[id]; m != nil {
+			manifests = append(manifests, *m)
+		}
+	}
+
+	return manifests, nil
+}
+
+// MatchModNames 清单中的标识多为 fabric-api、jei 这样的形式,与模组名称的Slug比较
+// 先在数据库中按空格、下划线替换为-后的小写名称筛选,再按Slug确认
+func (r *manifestRepository) MatchModNames(c context.Context, gameID uint, names []string) (map[string]uint, error) {
+	matched := make(map[string]uint, len(names))
+	if len(names) == 0 {
+		return matched, nil
+	}
+
+	candidates := make([]string, 0, len(names)*2)
+	for _, name := range names {
+		candidates = append(candidates, utils.Slugify(name), strings.ToLower(name))
+	}
+
+	var mods []struct {
+		ID   uint
+		Name string
+	}
+	if err := r.DB.WithContext(c).Model(&domain.Mod{}).
+		Select("id, name").
+		Where("game_id = ? AND LOWER(REPLACE(REPLACE(name, '_', '-'), ' ', '-')) IN ?", gameID, candidates).
+		Order("id").
+		Find(&mods).Error; err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		slug := utils.Slugify(name)
+		for _, mod := range mods {
+			if utils.Slugify(mod.Name) == slug {
+				matched[name] = mod.ID
+				break
+			}
+		}
+	}
+
+	return matched, nil
+}
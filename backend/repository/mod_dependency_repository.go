@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"ModVerse/domain"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type modDependencyRepository struct {
+	DB *gorm.DB
+}
+
+func NewModDependencyRepository(db *gorm.DB) domain.ModDependencyRepository {
+	return &modDependencyRepository{
+		DB: db,
+	}
+}
+
+func (r *modDependencyRepository) GetDependencies(c context.Context, versionIDs []uint) (*[]domain.ModDependencyResponse, error) {
+	var deps []domain.ModDependencyResponse
+
+	if len(versionIDs) == 0 {
+		return &deps, nil
+	}
+
+	if err := r.DB.WithContext(c).Model(&domain.ModDependency{}).
+		Scopes(withDependencyModName).
+		Where("mod_dependencies.mod_version_id IN ?", versionIDs).
+		Order("mod_dependencies.id").
+		Find(&deps).Error; err != nil {
+		return nil, err
+	}
+
+	return &deps, nil
+}
+
+// 附带被依赖模组的名称
+func withDependencyModName(db *gorm.DB) *gorm.DB {
+	return db.Select("mod_dependencies.*, mods.name AS mod_name").
+		Joins("LEFT JOIN mods ON mods.id = mod_dependencies.depends_on_mod_id AND mods.deleted_at IS NULL")
+}
+
+// SetDependencies 替换版本声明的全部依赖
+func (r *modDependencyRepository) SetDependencies(c context.Context, versionID uint, deps []domain.ModDependency) error {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Unscoped().Where("mod_version_id = ?", versionID).Delete(&domain.ModDependency{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(deps) > 0 {
+		for i := range deps {
+			deps[i].ModVersionID = versionID
+		}
+		if err := tx.Create(&deps).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func versionNodeQuery(db *gorm.DB) *gorm.DB {
+	return db.Model(&domain.ModVersion{}).
+		Select("mod_versions.id, mod_versions.mod_id, mod_versions.version, mod_versions.yanked, mods.name AS mod_name, mods.status AS mod_status").
+		Joins("JOIN mods ON mods.id = mod_versions.mod_id AND mods.deleted_at IS NULL")
+}
+
+func (r *modDependencyRepository) GetVersionNode(c context.Context, versionID uint) (*domain.VersionNode, error) {
+	var node domain.VersionNode
+
+	if err := versionNodeQuery(r.DB.WithContext(c)).
+		Where("mod_versions.id = ?", versionID).
+		Take(&node).Error; err != nil {
+		return nil, err
+	}
+
+	return &node, nil
+}
+
+// GetModVersionNodes 读取模组的全部版本,按发布先后倒序
+func (r *modDependencyRepository) GetModVersionNodes(c context.Context, modIDs []uint) (*[]domain.VersionNode, error) {
+	var nodes []domain.VersionNode
+
+	if len(modIDs) == 0 {
+		return &nodes, nil
+	}
+
+	if err := versionNodeQuery(r.DB.WithContext(c)).
+		Where("mod_versions.mod_id IN ?", modIDs).
+		Order("mod_versions.id DESC").
+		Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+
+	return &nodes, nil
+}
+
+// GetDependents 查询依赖该模组的其他模组版本(不含已撤回的版本),types为空时返回所有类型
+func (r *modDependencyRepository) GetDependents(c context.Context, modID uint, types []string) (*[]domain.DependentResponse, error) {
+	var dependents []domain.DependentResponse
+
+	query := r.DB.WithContext(c).Model(&domain.ModDependency{}).
+		Select("mods.id AS mod_id, mods.name AS mod_name, mod_versions.id AS mod_version_id, mod_versions.version, mod_dependencies.version_range, mod_dependencies.type").
+		Joins("JOIN mod_versions ON mod_versions.id = mod_dependencies.mod_version_id AND mod_versions.deleted_at IS NULL AND mod_versions.yanked = false").
+		Joins("JOIN mods ON mods.id = mod_versions.mod_id AND mods.deleted_at IS NULL").
+		Where("mod_dependencies.depends_on_mod_id = ? AND mods.id <> ?", modID, modID)
+
+	if len(types) > 0 {
+		query = query.Where("mod_dependencies.type IN ?", types)
+	}
+
+	if err := query.Order("mods.id, mod_versions.id DESC").Scan(&dependents).Error; err != nil {
+		return nil, err
+	}
+
+	return &dependents, nil
+}
+
+func (r *modDependencyRepository) CountMods(c context.Context, modIDs []uint) (int64, error) {
+	var total int64
+
+	if err := r.DB.WithContext(c).Model(&domain.Mod{}).Where("id IN ?", modIDs).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
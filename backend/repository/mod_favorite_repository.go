@@ -47,9 +47,9 @@ func (m *modFavoriteRepository) DeleteModFavorite(c context.Context, userID stri
 	return nil
 }
 
-func (m *modFavoriteRepository) GetModFavorites(c context.Context, userID string, params *domain.ModFavoriteQuery) (*[]domain.ModFavoriteResponse, int64, error) {
+func (m *modFavoriteRepository) GetModFavorites(c context.Context, userID string, params *domain.ModFavoriteQuery) (*[]domain.ModFavoriteResponse, int64, string, error) {
 	var modFavorites []domain.ModFavoriteResponse
-	var total int64
+	var total int64 = domain.TotalUnknown
 
 	modAllowedFields := map[string]struct{}{
 		"likes":           {},
@@ -58,6 +58,17 @@ func (m *modFavoriteRepository) GetModFavorites(c context.Context, userID string
 		"last_update":     {},
 	}
 
+	sortField := utils.SafeSortField(params.Sort, modAllowedFields, "last_update")
+	orderDirection := utils.SafeOrderDirection(params.Order)
+
+	cursor, err := utils.DecodeCursor(params.Cursor, modAllowedFields)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if cursor != nil {
+		sortField, orderDirection = cursor.Sort, cursor.Order
+	}
+
 	query := m.DB.WithContext(c).Model(&domain.ModFavorite{}).Joins("Mod").Where("mod_favorites.user_id = ?", userID)
 
 	if params.Name != "" {
@@ -68,27 +79,37 @@ func (m *modFavoriteRepository) GetModFavorites(c context.Context, userID string
 		query = query.Where("mod.game_id = ?", params.GameID)
 	}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
+	if utils.NeedTotal(params.Total, cursor) {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
 
-	if total == 0 {
-		return &[]domain.ModFavoriteResponse{}, 0, nil
+		if total == 0 {
+			return &[]domain.ModFavoriteResponse{}, 0, "", nil
+		}
 	}
 
-	sortField := "mod." + utils.SafeSortField(params.Sort, modAllowedFields, "last_update")
-	orderDirection := utils.SafeOrderDirection(params.Order)
-
 	query = query.Order(clause.OrderByColumn{
-		Column: clause.Column{Name: sortField},
+		Column: clause.Column{Name: "mod." + sortField},
+		Desc:   orderDirection == "desc",
+	}).Order(clause.OrderByColumn{
+		Column: clause.Column{Table: "mod_favorites", Name: "id"},
 		Desc:   orderDirection == "desc",
 	})
 
-	query = utils.ApplyPaging(query, params.Page, params.PageSize)
+	query, err = utils.ApplyCursorPaging(query, cursor, params.Page, params.PageSize, "mod."+sortField, "mod_favorites.id")
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	if err := query.Model(&domain.ModFavorite{}).Joins("Mod.CoverFile").Joins("Mod.Game").Preload("Mod.Categories", preloadModCategories).Find(&modFavorites).Error; err != nil {
+		return nil, 0, "", err
+	}
 
-	if err := query.Model(&domain.ModFavorite{}).Joins("Mod.CoverFile").Joins("Mod.Game").Find(&modFavorites).Error; err != nil {
-		return nil, 0, err
+	nextCursor, err := utils.NextCursor(modFavorites, sortField, orderDirection, params.PageSize, cursor != nil)
+	if err != nil {
+		return nil, 0, "", err
 	}
 
-	return &modFavorites, total, nil
+	return &modFavorites, total, nextCursor, nil
 }
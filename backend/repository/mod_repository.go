@@ -4,6 +4,7 @@ import (
 	"ModVerse/domain"
 	"ModVerse/internal/utils"
 	"context"
+	"strconv"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -27,18 +28,34 @@ func (m *modRepository) CreateMod(c context.Context, mod *domain.Mod, modVersion
 		}
 	}()
 
-	if err := tx.Create(mod).Error; err != nil {
+	// 只写入关联表,不改动分类本身
+	if err := tx.Omit("Categories.*").Create(mod).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
 	modVersion.ModID = mod.ID
 
+	if err := checkVersionFiles(tx, modVersion.Files, mod.UserID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err := tx.Create(modVersion).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if err := claimUserFiles(tx, append(versionFileIDs(modVersion.Files), mod.CoverID), mod.UserID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := refreshVersionScanStatus(tx, []uint{modVersion.ID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err := tx.Model(&domain.Game{}).Where("id = ?", mod.GameID).UpdateColumn("mod_nums", gorm.Expr("mod_nums + ?", 1)).Error; err != nil {
 		tx.Rollback()
 		return err
@@ -52,7 +69,8 @@ func (m *modRepository) CreateMod(c context.Context, mod *domain.Mod, modVersion
 	return nil
 }
 
-func (m *modRepository) DeleteMod(c context.Context, id uint) error {
+// DeleteMod 删除模组及其全部版本、版本文件与封面,返回引用归零、需要从磁盘删除的文件路径
+func (m *modRepository) DeleteMod(c context.Context, id uint) ([]string, error) {
 	tx := m.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -60,110 +78,319 @@ func (m *modRepository) DeleteMod(c context.Context, id uint) error {
 		}
 	}()
 
-	if err := tx.Select("File").Delete(&domain.ModVersion{}, "mod_id = ?", id).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
-
 	var mod domain.Mod
 	mod.ID = id
 
 	if err := tx.First(&mod).Error; err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
+	}
+
+	versionFiles := tx.Model(&domain.ModVersionFile{}).
+		Where("mod_version_id IN (?)", tx.Model(&domain.ModVersion{}).Select("id").Where("mod_id = ?", id))
+
+	var fileIDs []uint
+	if err := versionFiles.Session(&gorm.Session{}).Pluck("file_id", &fileIDs).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := versionFiles.Session(&gorm.Session{}).Delete(&domain.ModVersionFile{}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Delete(&domain.ModVersion{}, "mod_id = ?", id).Error; err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	if err := tx.Select(clause.Associations).Delete(mod).Error; err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
+	}
+
+	// 版本与模组删除后再释放文件,封面随模组一起删除
+	if mod.CoverID != 0 {
+		fileIDs = append(fileIDs, mod.CoverID)
+	}
+	orphans, err := releaseStorageFiles(tx, fileIDs)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	if err := tx.Model(&domain.Game{}).Where("game_id = ?", mod.GameID).UpdateColumn("mod_nums", gorm.Expr("mod_nums - ?", 1)).Error; err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
 	}
 
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
 	}
 
-	return nil
+	return orphans, nil
 }
 
 func (m *modRepository) GetMod(c context.Context, id string) (*domain.Mod, error) {
 	var mod domain.Mod
 
-	if err := m.DB.WithContext(c).Preload("User.UserProfile.AvatarFile").Preload("Game").Preload("CoverFile").First(&mod, id).Error; err != nil {
+	if err := m.DB.WithContext(c).Preload("User.UserProfile.AvatarFile").Preload("Game").Preload("CoverFile").Preload("Categories").First(&mod, id).Error; err != nil {
 		return nil, err
 	}
 	return &mod, nil
 }
 
-func (m *modRepository) GetMods(c context.Context, params *domain.ModQuery) (*[]domain.ModResponse, int64, error) {
+func (m *modRepository) GetMods(c context.Context, params *domain.ModQuery) (*[]domain.ModResponse, int64, string, error) {
 	var apiMods []domain.ModResponse
-	var total int64
+	var total int64 = domain.TotalUnknown
 	modAllowedFields := map[string]struct{}{
-		"likes":          {},
-		"name":           {},
-		"total_download": {},
-		"last_update":    {},
-		"created_at":     {},
+		"likes":           {},
+		"name":            {},
+		"total_downloads": {},
+		"last_update":     {},
+		"created_at":      {},
+	}
+
+	sortField := utils.SafeSortField(params.Sort, modAllowedFields, "name")
+	orderDirection := utils.SafeOrderDirection(params.Order)
+
+	cursor, err := utils.DecodeCursor(params.Cursor, modAllowedFields)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if cursor != nil {
+		sortField, orderDirection = cursor.Sort, cursor.Order
 	}
 
 	query := m.DB.WithContext(c).Model(&domain.Mod{}).Joins("User").Joins("Game")
 
-	if params.Name != "" {
-		query = query.Where("mods.name LIKE ?", "%"+utils.EscapeLike(params.Name)+"%")
+	query = applyModFilters(query, params, "")
+
+	if utils.NeedTotal(params.Total, cursor) {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
+
+		if total == 0 {
+			return &[]domain.ModResponse{}, 0, "", nil
+		}
 	}
 
-	if params.GameID != 0 {
-		query = query.Where("mods.game_id = ?", params.GameID)
+	query = query.Order(clause.OrderByColumn{
+		Column: clause.Column{Table: "mods", Name: sortField},
+		Desc:   orderDirection == "desc",
+	}).Order(clause.OrderByColumn{
+		Column: clause.Column{Table: "mods", Name: "id"},
+		Desc:   orderDirection == "desc",
+	})
+
+	query, err = utils.ApplyCursorPaging(query, cursor, params.Page, params.PageSize, "mods."+sortField, "mods.id")
+	if err != nil {
+		return nil, 0, "", err
 	}
 
-	if len(params.Category) > 0 {
-		query = query.Where("category IN (?)", params.Category)
+	if err := query.Joins("CoverFile").Preload("Categories", preloadModCategories).Find(&apiMods).Error; err != nil {
+		return nil, 0, "", err
 	}
 
-	if params.UserID != "" {
-		query = query.Where("mods.user_id =?", params.UserID)
+	nextCursor, err := utils.NextCursor(apiMods, sortField, orderDirection, params.PageSize, cursor != nil)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return &apiMods, total, nextCursor, nil
+}
+
+// 统计各分面的模组数量,每个分面的统计不受其自身筛选条件影响
+func (m *modRepository) GetModFacets(c context.Context, params *domain.ModQuery) (*domain.ModFacets, error) {
+	facets := domain.ModFacets{
+		Game:     []domain.FacetCount{},
+		Category: []domain.FacetCount{},
+		Status:   []domain.FacetCount{},
+	}
+
+	columns := []struct {
+		facet  string
+		group  string
+		value  string
+		label  string
+		join   string
+		target *[]domain.FacetCount
+	}{
+		{domain.FacetGame, "mods.game_id", "mods.game_id", "MAX(game.name)", "", &facets.Game},
+		{domain.FacetCategory, "cat.id", "MAX(cat.slug)", "MAX(cat.name)",
+			"JOIN mod_categories AS mc ON mc.mod_id = mods.id JOIN categories AS cat ON cat.id = mc.categories_id AND cat.deleted_at IS NULL",
+			&facets.Category},
+		{domain.FacetStatus, "mods.status", "mods.status", "mods.status", "", &facets.Status},
+	}
+
+	for _, col := range columns {
+		query := m.DB.WithContext(c).Model(&domain.Mod{}).
+			Joins("LEFT JOIN users AS user ON user.id = mods.user_id").
+			Joins("LEFT JOIN games AS game ON game.id = mods.game_id")
+
+		if col.join != "" {
+			query = query.Joins(col.join)
+		}
+
+		query = applyModFilters(query, params, col.facet)
+
+		if err := query.
+			Select(col.value + " AS value, " + col.label + " AS label, COUNT(*) AS count").
+			Group(col.group).
+			Order("count DESC").
+			Limit(domain.FacetLimit).
+			Scan(col.target).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &facets, nil
+}
+
+func (m *modRepository) UpdateMod(c context.Context, mod *domain.Mod, categories []domain.Categories) error {
+	tx := m.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Omit("Categories").Updates(mod).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if categories != nil {
+		if err := tx.Model(mod).Omit("Categories.*").Association("Categories").Replace(categories); err != nil {
+			tx.Rollback()
+			return err
+		}
 	}
 
-	if params.Status != "" {
-		query = query.Where("mods.status =?", params.Status)
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// 预加载模组分类时只查询展示所需字段
+func preloadModCategories(db *gorm.DB) *gorm.DB {
+	return db.Model(&domain.Categories{}).Select("categories.id, categories.name, categories.slug")
+}
+
+// 属于任一分类或其子分类(名称或slug,别名解析到规范分类)的模组ID子查询
+func modsInCategories(db *gorm.DB, names []string) *gorm.DB {
+	db = db.Session(&gorm.Session{NewDB: true})
+	canonical := db.Model(&domain.Categories{}).
+		Select("CASE WHEN alias_of_id > 0 THEN alias_of_id ELSE id END").
+		Where("name IN ? OR slug IN ?", names, names)
+
+	subtree := db.Table("categories d").
+		Select("d.id").
+		Joins("JOIN categories anc ON d.path LIKE CONCAT(anc.path, '%')").
+		Where("anc.id IN (?) AND d.deleted_at IS NULL", canonical)
+
+	return db.Table("mod_categories").Select("mod_id").Where("categories_id IN (?)", subtree)
+}
+
+// 有未删除、未撤回的版本声明支持该游戏版本(名称或ID)的模组ID子查询,gameID不为0时只匹配该游戏的版本
+func modsSupportingGameVersion(db *gorm.DB, gameVersion string, gameID uint) *gorm.DB {
+	db = db.Session(&gorm.Session{NewDB: true})
+	query := db.Table("mod_versions mv").
+		Select("mv.mod_id").
+		Joins("JOIN mod_version_game_versions j ON j.mod_version_id = mv.id").
+		Joins("JOIN game_versions gv ON gv.id = j.game_version_id AND gv.deleted_at IS NULL").
+		Where("mv.deleted_at IS NULL AND mv.yanked = false")
+
+	// 纯数字同时按ID匹配,避免MySQL将"1.20"隐式转换为数字1
+	if id, err := strconv.ParseUint(gameVersion, 10, 64); err == nil {
+		query = query.Where("(gv.name = ? OR gv.id = ?)", gameVersion, id)
+	} else {
+		query = query.Where("gv.name = ?", gameVersion)
+	}
+
+	if gameID != 0 {
+		query = query.Where("gv.game_id = ?", gameID)
+	}
+
+	return query
+}
+
+// 应用模组列表的筛选条件,skipFacet指定的分面(game/category/status)条件不参与筛选
+func applyModFilters(query *gorm.DB, params *domain.ModQuery, skipFacet string) *gorm.DB {
+	if params.Name != "" {
+		query = query.Where("mods.name LIKE ?", "%"+utils.EscapeLike(params.Name)+"%")
+	}
+
+	if params.UserID != "" {
+		query = query.Where("mods.user_id =?", params.UserID)
 	}
 
 	if params.UserName != "" {
 		query = query.Where("user.user_name LIKE ?", "%"+utils.EscapeLike(params.UserName)+"%")
 	}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	for _, name := range params.ExcludeUserNames {
+		query = query.Where("user.user_name NOT LIKE ?", "%"+utils.EscapeLike(name)+"%")
 	}
 
-	if total == 0 {
-		return &[]domain.ModResponse{}, 0, nil
+	for _, keyword := range params.Keywords {
+		like := "%" + utils.EscapeLike(keyword) + "%"
+		query = query.Where("(mods.name LIKE ? OR mods.description LIKE ?)", like, like)
 	}
 
-	sortField := utils.SafeSortField(params.Sort, modAllowedFields, "name")
-	orderDirection := utils.SafeOrderDirection(params.Order)
+	for _, keyword := range params.ExcludeKeywords {
+		like := "%" + utils.EscapeLike(keyword) + "%"
+		query = query.Where("NOT (mods.name LIKE ? OR mods.description LIKE ? OR mods.id IN (?))", like, like, modsInCategories(query, []string{keyword}))
+	}
 
-	query = query.Order(clause.OrderByColumn{
-		Column: clause.Column{Name: sortField},
-		Desc:   orderDirection == "desc",
-	})
+	if skipFacet != domain.FacetGame {
+		if params.GameID != 0 {
+			query = query.Where("mods.game_id = ?", params.GameID)
+		}
 
-	query = utils.ApplyPaging(query, params.Page, params.PageSize)
+		if params.GameName != "" {
+			query = query.Where("game.name LIKE ?", "%"+utils.EscapeLike(params.GameName)+"%")
+		}
 
-	if err := query.Joins("CoverFile").Find(&apiMods).Error; err != nil {
-		return nil, 0, err
+		for _, name := range params.ExcludeGames {
+			query = query.Where("game.name NOT LIKE ?", "%"+utils.EscapeLike(name)+"%")
+		}
 	}
-	return &apiMods, total, nil
-}
 
-func (m *modRepository) UpdateMod(c context.Context, mod *domain.Mod) error {
-	if err := m.DB.WithContext(c).Updates(mod).Error; err != nil {
-		return err
+	if params.GameVersion != "" {
+		query = query.Where("mods.id IN (?)", modsSupportingGameVersion(query, params.GameVersion, params.GameID))
 	}
-	return nil
+
+	if skipFacet != domain.FacetCategory {
+		if len(params.Category) > 0 {
+			query = query.Where("mods.id IN (?)", modsInCategories(query, params.Category))
+		}
+
+		if len(params.ExcludeCategory) > 0 {
+			query = query.Where("mods.id NOT IN (?)", modsInCategories(query, params.ExcludeCategory))
+		}
+	}
+
+	if skipFacet != domain.FacetStatus {
+		if params.Status != "" {
+			query = query.Where("mods.status =?", params.Status)
+		}
+
+		if len(params.ExcludeStatus) > 0 {
+			query = query.Where("mods.status NOT IN (?)", params.ExcludeStatus)
+		}
+	}
+
+	query = utils.ApplyIntRange(query, "mods.total_downloads", params.Downloads)
+	query = utils.ApplyIntRange(query, "mods.likes", params.Likes)
+	query = utils.ApplyTimeRange(query, "mods.last_update", params.Updated)
+	query = utils.ApplyTimeRange(query, "mods.created_at", params.Created)
+
+	return query
 }
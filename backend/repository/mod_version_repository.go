@@ -2,7 +2,9 @@ package repository
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/custom"
 	"context"
+	"errors"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -26,7 +28,45 @@ func (m *modVersionRepository) CreateModVersion(c context.Context, mv *domain.Mo
 		}
 	}()
 
-	if err := tx.Create(mv).Error; err != nil {
+	gameVersionIDs := make([]uint, 0, len(mv.GameVersions))
+	for _, gv := range mv.GameVersions {
+		gameVersionIDs = append(gameVersionIDs, gv.ID)
+	}
+	if err := checkModGameVersions(tx, mv.ModID, gameVersionIDs); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 版本的发布者为模组的上传者
+	var owner domain.Mod
+	if err := tx.Select("id", "user_id").First(&owner, mv.ModID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return custom.DataNotExistError
+		}
+		return err
+	}
+
+	if err := checkVersionFiles(tx, mv.Files, owner.UserID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Omit("GameVersions.*").Create(mv).Error; err != nil {
+		tx.Rollback()
+		// 并发发布同一版本号时由唯一索引拦截
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return custom.VersionExistError
+		}
+		return err
+	}
+
+	if err := claimUserFiles(tx, versionFileIDs(mv.Files), owner.UserID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := refreshVersionScanStatus(tx, []uint{mv.ID}); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -49,8 +89,146 @@ func (m *modVersionRepository) CreateModVersion(c context.Context, mv *domain.Mo
 	return nil
 }
 
-func (m *modVersionRepository) DeleteModVersion(c context.Context, id string) error {
-	return m.DB.WithContext(c).Select(clause.Associations).Delete(&domain.ModVersion{}, id).Error
+// 文件须存在、未被隔离且未被其他版本使用
+func versionFileIDs(files []domain.ModVersionFile) []uint {
+	fileIDs := make([]uint, 0, len(files))
+	for _, f := range files {
+		fileIDs = append(fileIDs, f.FileID)
+	}
+	return fileIDs
+}
+
+// 版本文件需为发布者userID上传的模组文件,其他用户的上传与图片按不存在处理
+func checkVersionFiles(tx *gorm.DB, files []domain.ModVersionFile, userID uint64) error {
+	fileIDs := versionFileIDs(files)
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	var total int64
+	if err := tx.Model(&domain.StorageFile{}).
+		Where("id IN ? AND user_id = ? AND upload_type = ?", fileIDs, userID, domain.UploadModFile).
+		Count(&total).Error; err != nil {
+		return err
+	}
+	if total != int64(len(fileIDs)) {
+		return custom.DataNotExistError
+	}
+
+	var infected int64
+	if err := tx.Model(&domain.StorageFile{}).Where("id IN ? AND scan_status = ?", fileIDs, domain.ScanStatusInfected).Count(&infected).Error; err != nil {
+		return err
+	}
+	if infected > 0 {
+		return domain.ErrFileInfected
+	}
+
+	var used int64
+	if err := tx.Model(&domain.ModVersionFile{}).Where("file_id IN ?", fileIDs).Count(&used).Error; err != nil {
+		return err
+	}
+	if used > 0 {
+		return errors.New("file is already attached to another version")
+	}
+
+	return nil
+}
+
+// 汇总版本扫描状态时的严重程度
+var scanStatusRank = map[string]int{
+	domain.ScanStatusClean:    0,
+	domain.ScanStatusScanning: 1,
+	domain.ScanStatusFailed:   2,
+	domain.ScanStatusInfected: 3,
+}
+
+// 按版本文件的扫描状态汇总版本状态,取最严重的文件状态,没有文件时为clean
+func refreshVersionScanStatus(tx *gorm.DB, versionIDs []uint) error {
+	if len(versionIDs) == 0 {
+		return nil
+	}
+
+	var rows []struct {
+		ModVersionID uint
+		ScanStatus   string
+	}
+	if err := tx.Table("mod_version_files f").
+		Select("f.mod_version_id, s.scan_status").
+		Joins("JOIN storage_files s ON s.id = f.file_id").
+		Where("f.mod_version_id IN ? AND f.deleted_at IS NULL", versionIDs).
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	statuses := make(map[uint]string, len(versionIDs))
+	for _, id := range versionIDs {
+		statuses[id] = domain.ScanStatusClean
+	}
+	for _, row := range rows {
+		if scanStatusRank[row.ScanStatus] > scanStatusRank[statuses[row.ModVersionID]] {
+			statuses[row.ModVersionID] = row.ScanStatus
+		}
+	}
+
+	grouped := make(map[string][]uint, len(scanStatusRank))
+	for id, status := range statuses {
+		grouped[status] = append(grouped[status], id)
+	}
+	for status, ids := range grouped {
+		if err := tx.Model(&domain.ModVersion{}).Where("id IN ?", ids).UpdateColumn("scan_status", status).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteModVersion 删除版本及其文件、依赖声明与游戏版本声明
+// 返回引用归零、需要从磁盘删除的文件路径
+func (m *modVersionRepository) DeleteModVersion(c context.Context, id string) ([]string, error) {
+	tx := m.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var mv domain.ModVersion
+	if err := tx.Preload("Files").First(&mv, id).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	fileIDs := versionFileIDs(mv.Files)
+
+	if err := tx.Select(clause.Associations).Delete(&mv).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	orphans, err := releaseStorageFiles(tx, fileIDs)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return orphans, nil
+}
+
+// 按顺序预加载版本文件
+func preloadVersionFiles(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Files", func(db *gorm.DB) *gorm.DB {
+			return db.Model(&domain.ModVersionFile{}).Order("sort_order, id")
+		}).
+		Preload("Files.File", func(db *gorm.DB) *gorm.DB {
+			return db.Model(&domain.StorageFile{}).Select("id,file_name,file_size,sha256,url,file_key")
+		})
 }
 
 func (m *modVersionRepository) GetModVersions(c context.Context, modID string) (*[]domain.ModVersionResponse, int64, error) {
@@ -68,8 +246,15 @@ func (m *modVersionRepository) GetModVersions(c context.Context, modID string) (
 	}
 
 	if err := query.
-		Preload("File", func(db *gorm.DB) *gorm.DB {
-			return db.Model(&domain.StorageFile{}).Select("id,file_name,file_size,url,file_key")
+		Scopes(preloadVersionFiles).
+		Preload("Dependencies", func(db *gorm.DB) *gorm.DB {
+			return db.Model(&domain.ModDependency{}).Scopes(withDependencyModName).Order("mod_dependencies.id")
+		}).
+		Preload("GameVersions", func(db *gorm.DB) *gorm.DB {
+			return db.Model(&domain.GameVersion{}).Order("release_date DESC")
+		}).
+		Preload("YankLogs", func(db *gorm.DB) *gorm.DB {
+			return db.Model(&domain.ModVersionYankLog{}).Order("id DESC")
 		}).
 		Find(&modVersions).Error; err != nil {
 		return nil, 0, err
@@ -81,13 +266,137 @@ func (m *modVersionRepository) GetModVersions(c context.Context, modID string) (
 func (m *modVersionRepository) GetModVersion(c context.Context, id string) (*domain.ModVersionResponse, error) {
 	var modVersion domain.ModVersionResponse
 
-	if err := m.DB.WithContext(c).Model(&domain.ModVersion{}).Joins("File").First(&modVersion, id).Error; err != nil {
+	if err := m.DB.WithContext(c).Model(&domain.ModVersion{}).Scopes(preloadVersionFiles).First(&modVersion, id).Error; err != nil {
 		return nil, err
 	}
 
 	return &modVersion, nil
 }
 
+func (m *modVersionRepository) GetVersionNumbers(c context.Context, modID uint) ([]string, error) {
+	var versions []string
+
+	// 包含已删除的版本,删除的版本号不可重新使用
+	if err := m.DB.WithContext(c).Unscoped().Model(&domain.ModVersion{}).Where("mod_id = ?", modID).Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// GetUpdateVersions 一次取出多个模组的全部版本(含已撤回)及文件与支持的游戏版本,供批量更新检查
+// 查询次数固定,与模组数量无关
+func (m *modVersionRepository) GetUpdateVersions(c context.Context, modIDs []uint) (*[]domain.ModVersionResponse, error) {
+	var modVersions []domain.ModVersionResponse
+
+	if len(modIDs) == 0 {
+		return &modVersions, nil
+	}
+
+	// 已删除或禁用的模组不提供更新
+	if err := m.DB.WithContext(c).Model(&domain.ModVersion{}).
+		Scopes(preloadVersionFiles).
+		Select("mod_versions.*").
+		Joins("JOIN mods ON mods.id = mod_versions.mod_id AND mods.deleted_at IS NULL AND mods.status = ?", "enable").
+		Where("mod_versions.mod_id IN ?", modIDs).
+		Preload("GameVersions", func(db *gorm.DB) *gorm.DB {
+			return db.Model(&domain.GameVersion{})
+		}).
+		Find(&modVersions).Error; err != nil {
+		return nil, err
+	}
+
+	return &modVersions, nil
+}
+
+// SetYanked 撤回或恢复版本并记录原因
+func (m *modVersionRepository) SetYanked(c context.Context, id uint, log *domain.ModVersionYankLog) error {
+	tx := m.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(&domain.ModVersion{}).Where("id = ?", id).UpdateColumns(map[string]any{
+		"yanked":      log.Yanked,
+		"yank_reason": log.Reason,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	log.ModVersionID = id
+	if err := tx.Create(log).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// GetVersionContents 版本各文件的压缩包清单,按文件顺序排列
+func (m *modVersionRepository) GetVersionContents(c context.Context, id string) (*[]domain.ModVersionContents, error) {
+	db := m.DB.WithContext(c)
+
+	if err := db.Select("id").First(&domain.ModVersion{}, id).Error; err != nil {
+		return nil, err
+	}
+
+	var files []domain.ModVersionFile
+	if err := db.Where("mod_version_id = ?", id).
+		Order("sort_order, id").
+		Preload("File", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, file_name")
+		}).
+		Preload("File.Archive").
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	contents := make([]domain.ModVersionContents, 0, len(files))
+	for _, f := range files {
+		contents = append(contents, domain.ModVersionContents{
+			FileID:      f.FileID,
+			FileName:    f.File.FileName,
+			Role:        f.Role,
+			DisplayName: f.DisplayName,
+			Archive:     f.File.Archive,
+		})
+	}
+
+	return &contents, nil
+}
+
+func (m *modVersionRepository) GetVersionOwner(c context.Context, id string) (uint64, error) {
+	var owners []uint64
+	if err := m.DB.WithContext(c).Model(&domain.ModVersion{}).
+		Joins("JOIN mods ON mods.id = mod_versions.mod_id").
+		Where("mod_versions.id = ?", id).
+		Pluck("mods.user_id", &owners).Error; err != nil {
+		return 0, err
+	}
+	if len(owners) == 0 {
+		return 0, custom.DataNotExistError
+	}
+
+	return owners[0], nil
+}
+
+func (m *modVersionRepository) HasPublishedMods(c context.Context, userID uint64) (bool, error) {
+	var total int64
+	if err := m.DB.WithContext(c).Model(&domain.Mod{}).Where("user_id = ?", userID).Limit(1).Count(&total).Error; err != nil {
+		return false, err
+	}
+
+	return total > 0, nil
+}
+
 func (m *modVersionRepository) GetDB() *gorm.DB {
 	return m.DB
 }
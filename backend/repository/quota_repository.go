@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"ModVerse/domain"
+	"cmp"
+	"context"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+type quotaRepository struct {
+	DB *gorm.DB
+}
+
+func NewQuotaRepository(db *gorm.DB) domain.QuotaRepository {
+	return &quotaRepository{
+		DB: db,
+	}
+}
+
+// 统计用量时每批读取的记录数
+const usageBatchSize = 1000
+
+// 用户持有的不同内容及记录数
+type heldBlobs struct {
+	sizes map[string]int64
+	files int64
+}
+
+func (h *heldBlobs) used() int64 {
+	var used int64
+	for _, size := range h.sizes {
+		used += size
+	}
+	return used
+}
+
+// 按用户汇总持有的内容,图片的各版本同样计入;同一用户的多条记录指向同一内容时只计一次,不同用户上传相同内容各自计入
+// 版本保存在JSON列中,按批读取后在内存中按key去重
+func userBlobs(db *gorm.DB) (map[uint64]*heldBlobs, error) {
+	held := make(map[uint64]*heldBlobs)
+	var files []domain.StorageFile
+	err := db.Model(&domain.StorageFile{}).Select("id", "user_id", "file_key", "file_size", "variants").
+		FindInBatches(&files, usageBatchSize, func(_ *gorm.DB, _ int) error {
+			for _, sf := range files {
+				h, ok := held[sf.UserID]
+				if !ok {
+					h = &heldBlobs{sizes: make(map[string]int64)}
+					held[sf.UserID] = h
+				}
+				h.files++
+				for key, size := range fileBlobs(&sf) {
+					h.sizes[key] = max(h.sizes[key], size)
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return held, nil
+}
+
+// 记录本身及其图片版本占用的内容
+func fileBlobs(sf *domain.StorageFile) map[string]int64 {
+	blobs := map[string]int64{sf.FileKey: sf.FileSize}
+	for _, v := range sf.Variants {
+		blobs[v.FileKey] = max(blobs[v.FileKey], v.FileSize)
+	}
+	return blobs
+}
+
+func (r *quotaRepository) GetUserUsage(c context.Context, userID uint64) (*domain.StorageUsage, error) {
+	db := r.DB.WithContext(c)
+
+	var user domain.User
+	if err := db.Select("id", "user_name", "role", "storage_quota").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	usage := domain.StorageUsage{
+		UserID:        user.ID,
+		UserName:      user.UserName,
+		Role:          user.Role,
+		QuotaOverride: user.StorageQuota,
+	}
+	held, err := userBlobs(db.Where("user_id = ?", userID))
+	if err != nil {
+		return nil, err
+	}
+	if h, ok := held[userID]; ok {
+		usage.Used = h.used()
+		usage.Files = h.files
+	}
+
+	return &usage, nil
+}
+
+// GetTopUsage 按用量从高到低排列的用户
+func (r *quotaRepository) GetTopUsage(c context.Context, limit int) (*[]domain.StorageUsage, error) {
+	db := r.DB.WithContext(c)
+
+	held, err := userBlobs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make([]domain.StorageUsage, 0, len(held))
+	for userID, h := range held {
+		usages = append(usages, domain.StorageUsage{UserID: userID, Used: h.used(), Files: h.files})
+	}
+	slices.SortFunc(usages, func(a, b domain.StorageUsage) int {
+		return cmp.Or(cmp.Compare(b.Used, a.Used), cmp.Compare(a.UserID, b.UserID))
+	})
+	if len(usages) > limit {
+		usages = usages[:limit]
+	}
+
+	ids := make([]uint64, 0, len(usages))
+	for _, usage := range usages {
+		ids = append(ids, usage.UserID)
+	}
+	var users []domain.User
+	if err := db.Select("id", "user_name", "role", "storage_quota").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint64]*domain.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	for i := range usages {
+		if user, ok := byID[usages[i].UserID]; ok {
+			usages[i].UserName = user.UserName
+			usages[i].Role = user.Role
+			usages[i].QuotaOverride = user.StorageQuota
+		}
+	}
+
+	return &usages, nil
+}
+
+func (r *quotaRepository) SetUserQuota(c context.Context, userID uint64, quota *int64) error {
+	db := r.DB.WithContext(c)
+
+	var user domain.User
+	if err := db.Select("id").First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&user).UpdateColumn("storage_quota", quota).Error
+}
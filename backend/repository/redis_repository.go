@@ -4,6 +4,7 @@ import (
 	"ModVerse/domain"
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -41,6 +42,11 @@ func (r *redisRepository) SetValue(c context.Context, key string, value any, tim
 	return nil
 }
 
+// SetValueNX 键不存在时设置值,返回是否设置成功,可用作简单的分布式锁
+func (r *redisRepository) SetValueNX(c context.Context, key string, value any, time time.Duration) (bool, error) {
+	return r.RedisDB.SetNX(c, key, value, time).Result()
+}
+
 // DeleteValue 删除指定键
 func (r *redisRepository) DeleteValue(c context.Context, key string) error {
 	if err := r.RedisDB.Del(c, key).Err(); err != nil {
@@ -50,6 +56,60 @@ func (r *redisRepository) DeleteValue(c context.Context, key string) error {
 	return nil
 }
 
+// 值相同时续期,返回1表示仍持有
+var extendValueScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// 值相同时删除
+var deleteValueIfScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// ExtendValue 键的值仍为value时重新设置过期时间,返回是否续期成功
+func (r *redisRepository) ExtendValue(c context.Context, key string, value string, time time.Duration) (bool, error) {
+	n, err := extendValueScript.Run(c, r.RedisDB, []string{key}, value, time.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// DeleteValueIf 键的值仍为value时删除,已过期后被他人重新设置的键不受影响
+func (r *redisRepository) DeleteValueIf(c context.Context, key string, value string) error {
+	return deleteValueIfScript.Run(c, r.RedisDB, []string{key}, value).Err()
+}
+
+// AddSlot 在有序集合中添加一个名额,分数为添加时间,返回添加后有效的名额数
+// 超过ttl的名额视为进程异常退出未释放,添加时一并删除;集合整体的过期时间只用于清理不再使用的键
+func (r *redisRepository) AddSlot(c context.Context, key string, member string, ttl time.Duration) (int64, error) {
+	now := time.Now()
+	pipe := r.RedisDB.TxPipeline()
+	pipe.ZRemRangeByScore(c, key, "-inf", strconv.FormatInt(now.Add(-ttl).UnixMilli(), 10))
+	pipe.ZAdd(c, key, redis.Z{Score: float64(now.UnixMilli()), Member: member})
+	card := pipe.ZCard(c, key)
+	pipe.Expire(c, key, ttl)
+	if _, err := pipe.Exec(c); err != nil {
+		return 0, err
+	}
+	return card.Val(), nil
+}
+
+// RemoveSlot 释放名额
+func (r *redisRepository) RemoveSlot(c context.Context, key string, member string) error {
+	return r.RedisDB.ZRem(c, key, member).Err()
+}
+
+// CountSlots 未超过ttl的名额数
+func (r *redisRepository) CountSlots(c context.Context, key string, ttl time.Duration) (int64, error) {
+	since := strconv.FormatInt(time.Now().Add(-ttl).UnixMilli(), 10)
+	return r.RedisDB.ZCount(c, key, since, "+inf").Result()
+}
+
 // 添加到列表
 func (r *redisRepository) AddItem(c context.Context, key string, value any) error {
 	err := r.RedisDB.RPush(c, key, value).Err()
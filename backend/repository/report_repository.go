@@ -37,14 +37,25 @@ func (r *reportRepository) GetByID(ctx context.Context, id string) (*domain.Repo
 	return &report, nil
 }
 
-func (r *reportRepository) List(ctx context.Context, params *domain.ReportQuery) (*[]domain.ReportResponse, int64, error) {
-	var reports *[]domain.ReportResponse
-	var total int64
+func (r *reportRepository) List(ctx context.Context, params *domain.ReportQuery) (*[]domain.ReportResponse, int64, string, error) {
+	var reports []domain.ReportResponse
+	var total int64 = domain.TotalUnknown
 
 	reportAllowedFields := map[string]struct{}{
 		"created_at": {},
 	}
 
+	sortField := utils.SafeSortField(params.Sort, reportAllowedFields, "created_at")
+	orderDirection := utils.SafeOrderDirection(params.Order)
+
+	cursor, err := utils.DecodeCursor(params.Cursor, reportAllowedFields)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if cursor != nil {
+		sortField, orderDirection = cursor.Sort, cursor.Order
+	}
+
 	query := r.db.WithContext(ctx).Model(&domain.Report{}).Joins("Reporter")
 
 	// 应用过滤条件
@@ -71,28 +82,39 @@ func (r *reportRepository) List(ctx context.Context, params *domain.ReportQuery)
 	}
 
 	// 获取总数
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
-
-	if total == 0 {
-		return &[]domain.ReportResponse{}, 0, nil
+	if utils.NeedTotal(params.Total, cursor) {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
+
+		if total == 0 {
+			return &[]domain.ReportResponse{}, 0, "", nil
+		}
 	}
-	sortField := utils.SafeSortField(params.Sort, reportAllowedFields, "created_at")
-	orderDirection := utils.SafeOrderDirection(params.Order)
 
 	query = query.Order(clause.OrderByColumn{
-		Column: clause.Column{Name: sortField},
+		Column: clause.Column{Table: "reports", Name: sortField},
+		Desc:   orderDirection == "desc",
+	}).Order(clause.OrderByColumn{
+		Column: clause.Column{Table: "reports", Name: "id"},
 		Desc:   orderDirection == "desc",
 	})
 
-	query = utils.ApplyPaging(query, params.Page, params.PageSize)
+	query, err = utils.ApplyCursorPaging(query, cursor, params.Page, params.PageSize, "reports."+sortField, "reports.id")
+	if err != nil {
+		return nil, 0, "", err
+	}
 
 	if err := query.Find(&reports).Error; err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
+	}
+
+	nextCursor, err := utils.NextCursor(reports, sortField, orderDirection, params.PageSize, cursor != nil)
+	if err != nil {
+		return nil, 0, "", err
 	}
 
-	return reports, total, nil
+	return &reports, total, nextCursor, nil
 }
 
 func (r *reportRepository) DeleteReport(ctx context.Context, id string) error {
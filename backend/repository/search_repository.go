@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"ModVerse/domain"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type searchRepository struct {
+	DB *gorm.DB
+}
+
+func NewSearchRepository(db *gorm.DB) domain.SearchRepository {
+	return &searchRepository{
+		DB: db,
+	}
+}
+
+// 读取联想词数据: 已启用的模组、游戏以及发布过模组的作者
+func (r *searchRepository) GetSuggestSources(c context.Context) (*[]domain.SuggestSource, error) {
+	var mods, games, authors []domain.SuggestSource
+
+	if err := r.DB.WithContext(c).Model(&domain.Mod{}).
+		Select("id, name, likes * 5 + total_downloads AS popularity").
+		Where("status = ?", "enable").
+		Scan(&mods).Error; err != nil {
+		return nil, err
+	}
+
+	if err := r.DB.WithContext(c).Model(&domain.Game{}).
+		Select("id, name, mod_nums * 100 AS popularity").
+		Scan(&games).Error; err != nil {
+		return nil, err
+	}
+
+	if err := r.DB.WithContext(c).Model(&domain.Mod{}).
+		Select("users.id AS id, users.user_name AS name, SUM(mods.likes * 5 + mods.total_downloads) AS popularity").
+		Joins("JOIN users ON users.id = mods.user_id AND users.deleted_at IS NULL").
+		Where("mods.status = ?", "enable").
+		Group("users.id, users.user_name").
+		Scan(&authors).Error; err != nil {
+		return nil, err
+	}
+
+	sources := make([]domain.SuggestSource, 0, len(mods)+len(games)+len(authors))
+	for _, s := range mods {
+		s.Type = domain.SuggestTypeMod
+		sources = append(sources, s)
+	}
+	for _, s := range games {
+		s.Type = domain.SuggestTypeGame
+		sources = append(sources, s)
+	}
+	for _, s := range authors {
+		s.Type = domain.SuggestTypeAuthor
+		sources = append(sources, s)
+	}
+
+	return &sources, nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type storageGCRepository struct {
+	DB *gorm.DB
+}
+
+func NewStorageGCRepository(db *gorm.DB) domain.StorageGCRepository {
+	return &storageGCRepository{
+		DB: db,
+	}
+}
+
+// 没有被模组封面、版本文件、游戏Logo或用户头像引用的文件
+func unreferencedFiles(db *gorm.DB) *gorm.DB {
+	return db.
+		Where("NOT EXISTS (SELECT 1 FROM mods WHERE mods.cover_id = storage_files.id AND mods.deleted_at IS NULL)").
+		Where("NOT EXISTS (SELECT 1 FROM mod_version_files f WHERE f.file_id = storage_files.id AND f.deleted_at IS NULL)").
+		Where("NOT EXISTS (SELECT 1 FROM games WHERE games.logo_id = storage_files.id AND games.deleted_at IS NULL)").
+		Where("NOT EXISTS (SELECT 1 FROM user_profiles p WHERE p.avatar_id = storage_files.id AND p.deleted_at IS NULL)")
+}
+
+// GetExpiredTempFiles 隔离的感染文件保留给管理员处理,不作为过期的临时文件
+func (r *storageGCRepository) GetExpiredTempFiles(c context.Context, before time.Time, afterID uint, limit int) (*[]domain.StorageFile, error) {
+	var sfs []domain.StorageFile
+	query := r.DB.WithContext(c).Model(&domain.StorageFile{}).
+		Where("is_temp = ? AND created_at < ? AND id > ?", true, before, afterID).
+		Where("scan_status <> ?", domain.ScanStatusInfected)
+
+	if err := unreferencedFiles(query).Order("id").Limit(limit).Find(&sfs).Error; err != nil {
+		return nil, err
+	}
+	return &sfs, nil
+}
+
+// ReleaseTempFile 锁定记录后再次确认仍是未被引用的临时文件,期间已被引用时返回 custom.DataNotExistError
+func (r *storageGCRepository) ReleaseTempFile(c context.Context, id uint) ([]string, error) {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var ids []uint
+	query := tx.Model(&domain.StorageFile{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND is_temp = ?", id, true)
+	if err := unreferencedFiles(query).Pluck("id", &ids).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if len(ids) == 0 {
+		tx.Rollback()
+		return nil, custom.DataNotExistError
+	}
+
+	orphans, err := releaseStorageFiles(tx, ids)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return orphans, nil
+}
+
+func (r *storageGCRepository) GetBlobsAfter(c context.Context, afterID uint, limit int) (*[]domain.StorageBlob, error) {
+	return blobsAfter(r.DB.WithContext(c), afterID, limit)
+}
+
+// 按ID顺序分批读取blob,清理与完整性校验共用
+func blobsAfter(db *gorm.DB, afterID uint, limit int) (*[]domain.StorageBlob, error) {
+	var blobs []domain.StorageBlob
+	if err := db.Where("id > ?", afterID).Order("id").Limit(limit).Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	return &blobs, nil
+}
+
+// 使用该路径的未删除文件记录数,图片版本的路径保存在variants中
+func countKeyFiles(tx *gorm.DB, key string) (int64, error) {
+	var total int64
+	err := tx.Model(&domain.StorageFile{}).
+		Where("file_key = ? OR JSON_CONTAINS(variants, JSON_OBJECT('file_key', ?))", key, key).
+		Count(&total).Error
+	return total, err
+}
+
+func (r *storageGCRepository) DeleteUnreferencedBlob(c context.Context, key string) (bool, error) {
+	tx := r.DB.WithContext(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var blob domain.StorageBlob
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("file_key = ?", key).First(&blob).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	total, err := countKeyFiles(tx, key)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if total > 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	if err := tx.Delete(&blob).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *storageGCRepository) IsKeyReferenced(c context.Context, key string) (bool, error) {
+	db := r.DB.WithContext(c)
+
+	var blobs int64
+	if err := db.Model(&domain.StorageBlob{}).Where("file_key = ?", key).Count(&blobs).Error; err != nil {
+		return false, err
+	}
+	if blobs > 0 {
+		return true, nil
+	}
+
+	total, err := countKeyFiles(db, key)
+	if err != nil {
+		return false, err
+	}
+	return total > 0, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 按当前模型建表,即MigrateModVersionFiles之后的结构,mod_versions已没有file_id列
+func newGCTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger:                                   logger.Discard,
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&domain.StorageFile{}, &domain.StorageBlob{}, &domain.Mod{}, &domain.ModVersion{},
+		&domain.ModVersionFile{}, &domain.Game{}, &domain.UserProfile{}); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasColumn("mod_versions", "file_id") {
+		t.Fatal("mod_versions still has file_id")
+	}
+	return db
+}
+
+func TestStorageGCTempFilesOnMigratedSchema(t *testing.T) {
+	db := newGCTestDB(t)
+	ctx := context.Background()
+	old := time.Now().Add(-48 * time.Hour)
+
+	files := []domain.StorageFile{
+		{FileKey: "blobs/aa/unused.zip", IsTemp: true},
+		{FileKey: "blobs/bb/attached.zip", IsTemp: true},
+		{FileKey: "blobs/cc/cover.png", IsTemp: true},
+		{FileKey: "blobs/dd/infected.zip", IsTemp: true, ScanStatus: domain.ScanStatusInfected},
+	}
+	for i := range files {
+		files[i].CreatedAt = old
+		if files[i].ScanStatus == "" {
+			files[i].ScanStatus = domain.ScanStatusClean
+		}
+		if err := db.Create(&files[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&domain.StorageBlob{FileKey: files[i].FileKey, SHA256: "x", RefCount: 1}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&domain.ModVersionFile{ModVersionID: 1, FileID: files[1].ID, Role: domain.FileRoleMain}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&domain.Mod{Name: "m", Content: "{}", CoverID: files[2].ID, LastUpdate: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewStorageGCRepository(db)
+	expired, err := repo.GetExpiredTempFiles(ctx, time.Now().Add(-24*time.Hour), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*expired) != 1 || (*expired)[0].ID != files[0].ID {
+		t.Fatalf("expired temp files = %+v, want only %d", *expired, files[0].ID)
+	}
+
+	orphans, err := repo.ReleaseTempFile(ctx, files[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || orphans[0] != files[0].FileKey {
+		t.Errorf("orphans = %v, want [%s]", orphans, files[0].FileKey)
+	}
+
+	// 已被版本引用的文件不能释放
+	if _, err := repo.ReleaseTempFile(ctx, files[1].ID); !errors.Is(err, custom.DataNotExistError) {
+		t.Errorf("ReleaseTempFile(attached) error = %v, want DataNotExistError", err)
+	}
+}
@@ -93,9 +93,9 @@ func (r *userRepository) ReadUserByNameOrEmail(c context.Context, name string) (
 }
 
 // 查询用户
-func (r *userRepository) ReadUsers(c context.Context, params *domain.UserQuery) (*[]domain.UsersResponse, int64, error) {
+func (r *userRepository) ReadUsers(c context.Context, params *domain.UserQuery) (*[]domain.UsersResponse, int64, string, error) {
 	var apiUsers []domain.UsersResponse
-	var total int64
+	var total int64 = domain.TotalUnknown
 
 	// 允许的排序字段白名单
 	userAllowedFields := map[string]struct{}{
@@ -104,6 +104,18 @@ func (r *userRepository) ReadUsers(c context.Context, params *domain.UserQuery)
 		"created_at": {},
 	}
 
+	// 处理排序,游标中的排序优先
+	sortField := utils.SafeSortField(params.Sort, userAllowedFields, "user_name")
+	orderDirection := utils.SafeOrderDirection(params.Order)
+
+	cursor, err := utils.DecodeCursor(params.Cursor, userAllowedFields)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if cursor != nil {
+		sortField, orderDirection = cursor.Sort, cursor.Order
+	}
+
 	//查询的表
 	query := r.DB.WithContext(c).Model(&domain.User{})
 
@@ -125,31 +137,40 @@ func (r *userRepository) ReadUsers(c context.Context, params *domain.UserQuery)
 	}
 
 	// 查询总数
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
+	if utils.NeedTotal(params.Total, cursor) {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
 
-	if total == 0 {
-		return &[]domain.UsersResponse{}, 0, nil
+		if total == 0 {
+			return &[]domain.UsersResponse{}, 0, "", nil
+		}
 	}
 
-	// 处理排序
-	sortField := utils.SafeSortField(params.Sort, userAllowedFields, "user_name")
-	orderDirection := utils.SafeOrderDirection(params.Order)
-
 	query = query.Order(clause.OrderByColumn{
 		Column: clause.Column{Name: sortField},
 		Desc:   orderDirection == "desc",
+	}).Order(clause.OrderByColumn{
+		Column: clause.Column{Name: "id"},
+		Desc:   orderDirection == "desc",
 	})
 
-	query = utils.ApplyPaging(query, params.Page, params.PageSize)
+	query, err = utils.ApplyCursorPaging(query, cursor, params.Page, params.PageSize, sortField, "id")
+	if err != nil {
+		return nil, 0, "", err
+	}
 
 	// 执行查询
 	if err := query.Find(&apiUsers).Error; err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
+	}
+
+	nextCursor, err := utils.NextCursor(apiUsers, sortField, orderDirection, params.PageSize, cursor != nil)
+	if err != nil {
+		return nil, 0, "", err
 	}
 
-	return &apiUsers, total, nil
+	return &apiUsers, total, nextCursor, nil
 }
 
 // 删除用户
@@ -170,9 +191,10 @@ func (r *userRepository) ReadUserWithMod(c context.Context, id string) (*domain.
 		Joins("UserProfile.AvatarFile").
 		Preload("Mod", func(db *gorm.DB) *gorm.DB {
 			return db.Model(&domain.Mod{}).Select(
-				"mods.id,mods.name,mods.description,mods.game_id,mods.cover_id,mods.total_downloads,mods.likes,mods.user_id,mods.category,mods.last_update").
+				"mods.id,mods.name,mods.description,mods.game_id,mods.cover_id,mods.total_downloads,mods.likes,mods.user_id,mods.last_update").
 				Joins("CoverFile").Joins("Game")
 		}).
+		Preload("Mod.Categories", preloadModCategories).
 		First(&user, id).Error; err != nil {
 		return nil, err
 	}
@@ -187,9 +209,10 @@ func (r *userRepository) ReadUserByNameWithMod(c context.Context, name string) (
 		Joins("UserProfile.AvatarFile").
 		Preload("Mod", func(db *gorm.DB) *gorm.DB {
 			return db.Model(&domain.Mod{}).Select(
-				"mods.id,mods.name,mods.description,mods.game_id,mods.cover_id,mods.total_downloads,mods.likes,mods.user_id,mods.category,mods.last_update").
+				"mods.id,mods.name,mods.description,mods.game_id,mods.cover_id,mods.total_downloads,mods.likes,mods.user_id,mods.last_update").
 				Joins("CoverFile").Joins("Game")
 		}).
+		Preload("Mod.Categories", preloadModCategories).
 		Where("user_name = ?", name).
 		First(&user).Error; err != nil {
 		return nil, err
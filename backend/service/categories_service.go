@@ -2,7 +2,10 @@ package service
 
 import (
 	"ModVerse/domain"
+	"ModVerse/internal/utils"
 	"context"
+	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -24,6 +27,12 @@ func (s *categoriesService) AddCategories(c context.Context, ca *domain.Categori
 	ctx, cancel := context.WithTimeout(c, s.timeout)
 	defer cancel()
 
+	slug, err := s.uniqueSlug(ctx, ca.Slug, ca.Name)
+	if err != nil {
+		return err
+	}
+	ca.Slug = slug
+
 	if err := s.caRepo.AddCategories(ctx, ca); err != nil {
 		return err
 	}
@@ -59,6 +68,118 @@ func (s *categoriesService) GetCategories(c context.Context, params *domain.Cate
 	return s.caRepo.GetCategories(c, params)
 }
 
-func (s *categoriesService) UpdateCategories(c context.Context, ca *domain.Categories) error {
-	return s.caRepo.UpdateCategories(c, ca)
+// UpdateCategories 更新分类,gameIDs为nil时不修改关联的游戏
+func (s *categoriesService) UpdateCategories(c context.Context, ca *domain.Categories, gameIDs []uint) error {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.caRepo.UpdateCategories(ctx, ca, gameIDs)
+}
+
+// GetCategoryTree 返回分类树,子节点按排序值、名称排列
+// 父分类对该游戏不可见时,其子树一并隐藏
+func (s *categoriesService) GetCategoryTree(c context.Context, params *domain.CategoryTreeQuery) ([]*domain.CategoryTreeNode, error) {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	nodes, err := s.caRepo.GetCategoryTree(ctx, params.GameID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 节点已按层级排序,父节点总在子节点之前
+	index := make(map[uint]*domain.CategoryTreeNode, len(*nodes))
+	roots := make([]*domain.CategoryTreeNode, 0)
+	for i := range *nodes {
+		node := &(*nodes)[i]
+		node.Children = []*domain.CategoryTreeNode{}
+		if node.GameIDs == nil {
+			node.GameIDs = []uint{}
+		}
+
+		if node.ParentID == 0 {
+			roots = append(roots, node)
+		} else if parent, ok := index[node.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			continue
+		}
+		index[node.ID] = node
+	}
+
+	return roots, nil
+}
+
+// MoveCategory 移动分类子树
+func (s *categoriesService) MoveCategory(c context.Context, id string, req *domain.MoveCategoryRequest) error {
+	parseID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.caRepo.MoveCategory(ctx, uint(parseID), req.ParentID, req.SortOrder)
+}
+
+// MergeCategories 合并分类,源分类保留为目标分类的别名
+func (s *categoriesService) MergeCategories(c context.Context, sourceID string, targetID uint) error {
+	parseID, err := strconv.ParseUint(sourceID, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.caRepo.MergeCategories(ctx, uint(parseID), targetID)
+}
+
+// AddAlias 为分类添加别名,别名指向规范分类
+func (s *categoriesService) AddAlias(c context.Context, id string, alias *domain.Categories) error {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	ca, err := s.caRepo.GetCategoriesByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	alias.AliasOfID = ca.ID
+	if ca.AliasOfID != 0 {
+		alias.AliasOfID = ca.AliasOfID
+	}
+	alias.Status = ca.Status
+
+	slug, err := s.uniqueSlug(ctx, alias.Slug, alias.Name)
+	if err != nil {
+		return err
+	}
+	alias.Slug = slug
+
+	return s.caRepo.AddCategories(ctx, alias)
+}
+
+// 生成不重复的slug,冲突时追加序号
+func (s *categoriesService) uniqueSlug(c context.Context, slug string, name string) (string, error) {
+	base := utils.Slugify(slug)
+	if base == "" {
+		base = utils.Slugify(name)
+	}
+	if base == "" {
+		base = "category"
+	}
+
+	candidate := base
+	for i := 2; ; i++ {
+		exists, err := s.caRepo.SlugExists(c, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
 }
@@ -41,7 +41,7 @@ func (s *commentService) GetCommentsWithReplies(c context.Context, modID string)
 	return s.commentRepo.GetCommentsWithReplies(ctx, modID)
 }
 
-func (s *commentService) GetAllComments(c context.Context, params domain.CommentQuery) (*[]domain.CommentResponse, int64, error) {
+func (s *commentService) GetAllComments(c context.Context, params domain.CommentQuery) (*[]domain.CommentResponse, int64, string, error) {
 	ctx, cancel := context.WithTimeout(c, s.timeout)
 	defer cancel()
 
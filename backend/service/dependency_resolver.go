@@ -0,0 +1,501 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"context"
+	"sort"
+)
+
+// 解析深度与模组数上限,防止异常数据导致无限展开
+// 回溯搜索尝试的候选版本数超过上限时放弃搜索,按贪心结果报告冲突
+const (
+	resolveMaxDepth = 32
+	resolveMaxMods  = 500
+	resolveMaxSteps = 10000
+)
+
+// 依赖解析器分两步:
+//  1. 回溯搜索: 按广度优先处理依赖,每个模组从高到低尝试满足已知约束的版本,后续约束无法满足时回退到上一个选择
+//  2. 展开: 从根版本出发深度优先展开,每个模组优先使用搜索得到的版本,生成依赖树、环与冲突报告
+//
+// 搜索无解时展开阶段选取满足当前全部约束的最高版本,已选版本不满足后续约束时记为冲突
+type dependencyResolver struct {
+	ctx  context.Context
+	repo domain.ModDependencyRepository
+
+	candidates  map[uint][]versionCandidate // 模组ID -> 候选版本,优先级从高到低
+	deps        map[uint][]domain.ModDependencyResponse
+	selected    map[uint]*domain.ResolvedDependency
+	constraints map[uint][]*utils.VersionRange
+	stack       []uint
+	onStack     map[uint]bool
+
+	// 回溯搜索的状态与结果,solution为模组ID -> 版本ID,无解时为nil
+	assigned    map[uint]*versionCandidate
+	searchRange map[uint][]*utils.VersionRange
+	steps       int
+	err         error
+	solution    map[uint]uint
+
+	incompatible []pendingIncompatible
+	conflicts    []domain.DependencyConflict
+	cycles       [][]uint
+}
+
+// 搜索中待处理的依赖声明
+type searchEdge struct {
+	dep      domain.ModDependencyResponse
+	r        *utils.VersionRange
+	depth    int
+	optional bool
+}
+
+type versionCandidate struct {
+	node   domain.VersionNode
+	semver *utils.Semver // 无法解析为语义化版本时为nil
+}
+
+type pendingIncompatible struct {
+	dep   domain.ModDependencyResponse
+	r     *utils.VersionRange
+	owner uint
+}
+
+func newDependencyResolver(ctx context.Context, repo domain.ModDependencyRepository) *dependencyResolver {
+	return &dependencyResolver{
+		ctx:         ctx,
+		repo:        repo,
+		candidates:  make(map[uint][]versionCandidate),
+		deps:        make(map[uint][]domain.ModDependencyResponse),
+		selected:    make(map[uint]*domain.ResolvedDependency),
+		constraints: make(map[uint][]*utils.VersionRange),
+		onStack:     make(map[uint]bool),
+	}
+}
+
+func (r *dependencyResolver) resolve(root *domain.VersionNode) (*domain.DependencyResolution, error) {
+	if err := r.solve(root); err != nil {
+		return nil, err
+	}
+
+	r.selected[root.ModID] = &domain.ResolvedDependency{VersionNode: *root}
+
+	if err := r.visit(root, 0, false); err != nil {
+		return nil, err
+	}
+
+	// 不兼容声明在全部版本选定后统一检查
+	for _, p := range r.incompatible {
+		if sel, ok := r.selected[p.dep.DependsOnModID]; ok && versionInRange(sel.Version, p.r) {
+			r.conflicts = append(r.conflicts, domain.DependencyConflict{
+				ModID:        p.dep.DependsOnModID,
+				ModName:      p.dep.ModName,
+				Type:         domain.ConflictIncompatible,
+				VersionRange: p.r.String(),
+				RequiredBy:   p.owner,
+				Selected:     sel.Version,
+			})
+		}
+	}
+
+	resolved := make([]domain.ResolvedDependency, 0, len(r.selected)-1)
+	for modID, sel := range r.selected {
+		if modID != root.ModID {
+			resolved = append(resolved, *sel)
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		if resolved[i].Depth != resolved[j].Depth {
+			return resolved[i].Depth < resolved[j].Depth
+		}
+		return resolved[i].ModID < resolved[j].ModID
+	})
+
+	if r.conflicts == nil {
+		r.conflicts = []domain.DependencyConflict{}
+	}
+	if r.cycles == nil {
+		r.cycles = [][]uint{}
+	}
+
+	return &domain.DependencyResolution{
+		Root:      *root,
+		Resolved:  resolved,
+		Conflicts: r.conflicts,
+		Cycles:    r.cycles,
+		OK:        len(r.conflicts) == 0,
+	}, nil
+}
+
+func (r *dependencyResolver) visit(node *domain.VersionNode, depth int, optional bool) error {
+	if depth >= resolveMaxDepth || len(r.selected) >= resolveMaxMods {
+		return nil
+	}
+
+	r.stack = append(r.stack, node.ModID)
+	r.onStack[node.ModID] = true
+	defer func() {
+		r.stack = r.stack[:len(r.stack)-1]
+		delete(r.onStack, node.ModID)
+	}()
+
+	deps, err := r.dependencies(node.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, dep := range deps {
+		vr := dependencyRange(dep)
+
+		if dep.Type == domain.DependencyIncompatible {
+			r.incompatible = append(r.incompatible, pendingIncompatible{dep: dep, r: vr, owner: node.ID})
+			continue
+		}
+
+		if r.onStack[dep.DependsOnModID] {
+			r.recordCycle(dep.DependsOnModID)
+		}
+
+		r.constraints[dep.DependsOnModID] = append(r.constraints[dep.DependsOnModID], vr)
+		depOptional := optional || dep.Type == domain.DependencyOptional
+
+		if sel, ok := r.selected[dep.DependsOnModID]; ok {
+			if !versionInRange(sel.Version, vr) {
+				r.conflicts = append(r.conflicts, domain.DependencyConflict{
+					ModID:        dep.DependsOnModID,
+					ModName:      dep.ModName,
+					Type:         domain.ConflictUnsatisfiable,
+					VersionRange: vr.String(),
+					RequiredBy:   node.ID,
+					Selected:     sel.Version,
+				})
+				continue
+			}
+			sel.RequiredBy = append(sel.RequiredBy, node.ID)
+			if !depOptional {
+				sel.Optional = false
+			}
+			continue
+		}
+
+		candidate, hasVersions, err := r.pick(dep.DependsOnModID, depOptional)
+		if err != nil {
+			return err
+		}
+		if candidate == nil {
+			// 可选依赖无可用版本时直接跳过
+			if depOptional {
+				continue
+			}
+			conflictType := domain.ConflictUnsatisfiable
+			if !hasVersions {
+				conflictType = domain.ConflictMissing
+			}
+			r.conflicts = append(r.conflicts, domain.DependencyConflict{
+				ModID:        dep.DependsOnModID,
+				ModName:      dep.ModName,
+				Type:         conflictType,
+				VersionRange: vr.String(),
+				RequiredBy:   node.ID,
+			})
+			continue
+		}
+
+		r.selected[dep.DependsOnModID] = &domain.ResolvedDependency{
+			VersionNode: candidate.node,
+			Optional:    depOptional,
+			Depth:       depth + 1,
+			RequiredBy:  []uint{node.ID},
+		}
+
+		if err := r.visit(&candidate.node, depth+1, depOptional); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 记录从栈中modID位置到栈顶再回到modID的环
+func (r *dependencyResolver) recordCycle(modID uint) {
+	for i, id := range r.stack {
+		if id == modID {
+			cycle := append([]uint{}, r.stack[i:]...)
+			r.cycles = append(r.cycles, append(cycle, modID))
+			return
+		}
+	}
+}
+
+// 选取满足该模组全部约束的版本,hasVersions表示模组是否存在可用版本
+// 搜索有解时使用解中的版本,解中没有的可选依赖跳过;否则选取满足约束的最高版本
+func (r *dependencyResolver) pick(modID uint, optional bool) (*versionCandidate, bool, error) {
+	candidates, err := r.loadCandidates(modID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if r.solution != nil {
+		versionID, ok := r.solution[modID]
+		if !ok && optional {
+			return nil, len(candidates) > 0, nil
+		}
+		if ok {
+			for i := range candidates {
+				if candidates[i].node.ID == versionID && inAllRanges(&candidates[i], r.constraints[modID]) {
+					return &candidates[i], true, nil
+				}
+			}
+		}
+	}
+
+	for i := range candidates {
+		if inAllRanges(&candidates[i], r.constraints[modID]) {
+			return &candidates[i], true, nil
+		}
+	}
+
+	return nil, len(candidates) > 0, nil
+}
+
+func inAllRanges(c *versionCandidate, ranges []*utils.VersionRange) bool {
+	for _, vr := range ranges {
+		if !candidateInRange(c, vr) {
+			return false
+		}
+	}
+	return true
+}
+
+// 版本的依赖声明,按版本缓存
+func (r *dependencyResolver) dependencies(versionID uint) ([]domain.ModDependencyResponse, error) {
+	if deps, ok := r.deps[versionID]; ok {
+		return deps, nil
+	}
+
+	deps, err := r.repo.GetDependencies(r.ctx, []uint{versionID})
+	if err != nil {
+		return nil, err
+	}
+
+	r.deps[versionID] = *deps
+	return *deps, nil
+}
+
+// 历史数据中的非法范围按任意版本处理
+func dependencyRange(dep domain.ModDependencyResponse) *utils.VersionRange {
+	vr, err := utils.ParseVersionRange(dep.VersionRange)
+	if err != nil {
+		vr, _ = utils.ParseVersionRange("*")
+	}
+	return vr
+}
+
+// 回溯搜索一组同时满足全部必需依赖、且不违反不兼容声明的版本,结果保存在solution中
+func (r *dependencyResolver) solve(root *domain.VersionNode) error {
+	sv, _ := utils.ParseSemverLenient(root.Version)
+	r.assigned = map[uint]*versionCandidate{root.ModID: {node: *root, semver: sv}}
+	r.searchRange = make(map[uint][]*utils.VersionRange)
+
+	queue, err := r.searchEdges(nil, root.ID, 0, false)
+	if err != nil {
+		return err
+	}
+
+	found := r.search(queue, 0)
+	if r.err != nil {
+		return r.err
+	}
+	if found {
+		r.solution = make(map[uint]uint, len(r.assigned))
+		for modID, c := range r.assigned {
+			r.solution[modID] = c.node.ID
+		}
+	}
+
+	return nil
+}
+
+// 将版本的依赖声明追加到待处理队列,返回新的队列(不修改原队列的底层数组)
+func (r *dependencyResolver) searchEdges(queue []searchEdge, versionID uint, depth int, optional bool) ([]searchEdge, error) {
+	deps, err := r.dependencies(versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	next := make([]searchEdge, len(queue), len(queue)+len(deps))
+	copy(next, queue)
+	for _, dep := range deps {
+		// 不兼容声明在选定版本时检查
+		if dep.Type == domain.DependencyIncompatible {
+			continue
+		}
+		next = append(next, searchEdge{
+			dep:      dep,
+			r:        dependencyRange(dep),
+			depth:    depth,
+			optional: optional || dep.Type == domain.DependencyOptional,
+		})
+	}
+	return next, nil
+}
+
+// 处理队列中第i个依赖,返回剩余依赖能否全部满足
+func (r *dependencyResolver) search(queue []searchEdge, i int) bool {
+	if r.err != nil || r.steps > resolveMaxSteps {
+		return false
+	}
+	if i == len(queue) {
+		return true
+	}
+
+	e := queue[i]
+	modID := e.dep.DependsOnModID
+	if e.depth >= resolveMaxDepth {
+		return r.search(queue, i+1)
+	}
+
+	// 已选版本只需检查范围,不满足时由上层换用其他版本
+	if sel, ok := r.assigned[modID]; ok {
+		return candidateInRange(sel, e.r) && r.search(queue, i+1)
+	}
+	if len(r.assigned) >= resolveMaxMods {
+		return r.search(queue, i+1)
+	}
+
+	candidates, err := r.loadCandidates(modID)
+	if err != nil {
+		r.err = err
+		return false
+	}
+
+	// 可选依赖未被选中时其范围仍约束之后的选择
+	r.searchRange[modID] = append(r.searchRange[modID], e.r)
+	defer func() {
+		r.searchRange[modID] = r.searchRange[modID][:len(r.searchRange[modID])-1]
+	}()
+
+	for ci := range candidates {
+		c := &candidates[ci]
+		if !inAllRanges(c, r.searchRange[modID]) {
+			continue
+		}
+		incompatible, err := r.violatesIncompatible(c)
+		if err != nil {
+			r.err = err
+			return false
+		}
+		if incompatible {
+			continue
+		}
+
+		r.steps++
+		next, err := r.searchEdges(queue, c.node.ID, e.depth+1, e.optional)
+		if err != nil {
+			r.err = err
+			return false
+		}
+
+		r.assigned[modID] = c
+		if r.search(next, i+1) {
+			return true
+		}
+		delete(r.assigned, modID)
+
+		if r.err != nil || r.steps > resolveMaxSteps {
+			return false
+		}
+	}
+
+	// 可选依赖没有可用版本时跳过
+	return e.optional && r.search(queue, i+1)
+}
+
+// 候选版本是否命中已选版本的不兼容声明,或其不兼容声明命中已选版本
+func (r *dependencyResolver) violatesIncompatible(c *versionCandidate) (bool, error) {
+	own, err := r.dependencies(c.node.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, dep := range own {
+		if dep.Type != domain.DependencyIncompatible {
+			continue
+		}
+		if sel, ok := r.assigned[dep.DependsOnModID]; ok && candidateInRange(sel, dependencyRange(dep)) {
+			return true, nil
+		}
+	}
+
+	for _, sel := range r.assigned {
+		deps, err := r.dependencies(sel.node.ID)
+		if err != nil {
+			return false, err
+		}
+		for _, dep := range deps {
+			if dep.Type == domain.DependencyIncompatible && dep.DependsOnModID == c.node.ModID && candidateInRange(c, dependencyRange(dep)) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (r *dependencyResolver) loadCandidates(modID uint) ([]versionCandidate, error) {
+	if list, ok := r.candidates[modID]; ok {
+		return list, nil
+	}
+
+	nodes, err := r.repo.GetModVersionNodes(r.ctx, []uint{modID})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]versionCandidate, 0, len(*nodes))
+	for _, n := range *nodes {
+		// 未启用的模组不可下载,已撤回的版本不参与解析
+		if n.ModStatus != "enable" || n.Yanked {
+			continue
+		}
+		sv, _ := utils.ParseSemverLenient(n.Version)
+		list = append(list, versionCandidate{node: n, semver: sv})
+	}
+	sortCandidates(list)
+
+	r.candidates[modID] = list
+	return list, nil
+}
+
+// 语义化版本从高到低在前,无法解析的版本按发布先后排在后面
+func sortCandidates(list []versionCandidate) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].semver, list[j].semver
+		switch {
+		case a != nil && b != nil:
+			return a.Compare(b) > 0
+		case a != nil:
+			return true
+		case b != nil:
+			return false
+		default:
+			return list[i].node.ID > list[j].node.ID
+		}
+	})
+}
+
+func candidateInRange(c *versionCandidate, vr *utils.VersionRange) bool {
+	if c.semver == nil {
+		return vr.String() == "*"
+	}
+	return vr.Contains(c.semver)
+}
+
+// 非语义化版本号只匹配任意版本范围
+func versionInRange(version string, vr *utils.VersionRange) bool {
+	sv, err := utils.ParseSemverLenient(version)
+	if err != nil {
+		return vr.String() == "*"
+	}
+	return vr.Contains(sv)
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"context"
+	"testing"
+)
+
+// 内存中的依赖数据,版本ID = 模组ID*100 + 序号
+type fakeDependencyRepo struct {
+	domain.ModDependencyRepository
+	nodes []domain.VersionNode
+	deps  map[uint][]domain.ModDependencyResponse
+}
+
+func (f *fakeDependencyRepo) GetDependencies(c context.Context, versionIDs []uint) (*[]domain.ModDependencyResponse, error) {
+	var list []domain.ModDependencyResponse
+	for _, id := range versionIDs {
+		list = append(list, f.deps[id]...)
+	}
+	return &list, nil
+}
+
+func (f *fakeDependencyRepo) GetModVersionNodes(c context.Context, modIDs []uint) (*[]domain.VersionNode, error) {
+	var list []domain.VersionNode
+	for _, n := range f.nodes {
+		for _, id := range modIDs {
+			if n.ModID == id {
+				list = append(list, n)
+			}
+		}
+	}
+	return &list, nil
+}
+
+func (f *fakeDependencyRepo) version(modID uint, seq uint, version string) uint {
+	id := modID*100 + seq
+	f.nodes = append(f.nodes, domain.VersionNode{ID: id, ModID: modID, Version: version, ModStatus: "enable"})
+	return id
+}
+
+func (f *fakeDependencyRepo) depend(versionID uint, modID uint, versionRange string, depType string) {
+	if f.deps == nil {
+		f.deps = make(map[uint][]domain.ModDependencyResponse)
+	}
+	f.deps[versionID] = append(f.deps[versionID], domain.ModDependencyResponse{
+		ModVersionID:   versionID,
+		DependsOnModID: modID,
+		VersionRange:   versionRange,
+		Type:           depType,
+	})
+}
+
+func resolveFor(t *testing.T, repo *fakeDependencyRepo, rootID uint) *domain.DependencyResolution {
+	t.Helper()
+	var root *domain.VersionNode
+	for i := range repo.nodes {
+		if repo.nodes[i].ID == rootID {
+			root = &repo.nodes[i]
+		}
+	}
+	result, err := newDependencyResolver(context.Background(), repo).resolve(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func selectedVersions(result *domain.DependencyResolution) map[uint]string {
+	selected := make(map[uint]string)
+	for _, r := range result.Resolved {
+		selected[r.ModID] = r.Version
+	}
+	return selected
+}
+
+const (
+	modRoot = 1
+	modA    = 2
+	modB    = 3
+	modC    = 4
+	modD    = 5
+)
+
+// 贪心选择A的最高版本会要求C 2.x,与B要求的C 1.x冲突,需要回退A
+func TestResolveBacktracks(t *testing.T) {
+	repo := &fakeDependencyRepo{}
+	root := repo.version(modRoot, 1, "1.0.0")
+	a2 := repo.version(modA, 2, "2.0.0")
+	a1 := repo.version(modA, 1, "1.0.0")
+	b1 := repo.version(modB, 1, "1.0.0")
+	repo.version(modC, 2, "2.0.0")
+	repo.version(modC, 1, "1.0.0")
+
+	repo.depend(root, modA, "*", domain.DependencyRequired)
+	repo.depend(root, modB, "*", domain.DependencyRequired)
+	repo.depend(a2, modC, "^2", domain.DependencyRequired)
+	repo.depend(a1, modC, "^1", domain.DependencyRequired)
+	repo.depend(b1, modC, "^1", domain.DependencyRequired)
+
+	result := resolveFor(t, repo, root)
+	if !result.OK {
+		t.Fatalf("resolution failed: %+v", result.Conflicts)
+	}
+	got := selectedVersions(result)
+	want := map[uint]string{modA: "1.0.0", modB: "1.0.0", modC: "1.0.0"}
+	for modID, v := range want {
+		if got[modID] != v {
+			t.Errorf("mod %d resolved to %q, want %q", modID, got[modID], v)
+		}
+	}
+}
+
+// 根版本声明与D 2.x不兼容,A接受任意D,应选择D 1.x
+func TestResolveAvoidsIncompatible(t *testing.T) {
+	repo := &fakeDependencyRepo{}
+	root := repo.version(modRoot, 1, "1.0.0")
+	a1 := repo.version(modA, 1, "1.0.0")
+	repo.version(modD, 2, "2.0.0")
+	repo.version(modD, 1, "1.0.0")
+
+	repo.depend(root, modA, "^1", domain.DependencyRequired)
+	repo.depend(root, modD, "^2", domain.DependencyIncompatible)
+	repo.depend(a1, modD, "*", domain.DependencyRequired)
+
+	result := resolveFor(t, repo, root)
+	if !result.OK {
+		t.Fatalf("resolution failed: %+v", result.Conflicts)
+	}
+	if got := selectedVersions(result)[modD]; got != "1.0.0" {
+		t.Errorf("mod D resolved to %q, want 1.0.0", got)
+	}
+}
+
+func TestResolveReportsConflicts(t *testing.T) {
+	repo := &fakeDependencyRepo{}
+	root := repo.version(modRoot, 1, "1.0.0")
+	a1 := repo.version(modA, 1, "1.0.0")
+	b1 := repo.version(modB, 1, "1.0.0")
+	repo.version(modC, 2, "2.0.0")
+	repo.version(modC, 1, "1.0.0")
+
+	repo.depend(root, modA, "*", domain.DependencyRequired)
+	repo.depend(root, modB, "*", domain.DependencyRequired)
+	repo.depend(root, modD, "*", domain.DependencyRequired)
+	repo.depend(a1, modC, "^2", domain.DependencyRequired)
+	repo.depend(b1, modC, "^1", domain.DependencyRequired)
+
+	result := resolveFor(t, repo, root)
+	if result.OK {
+		t.Fatal("resolution succeeded, want conflicts")
+	}
+
+	types := make(map[uint]string)
+	for _, c := range result.Conflicts {
+		types[c.ModID] = c.Type
+	}
+	if types[modC] != domain.ConflictUnsatisfiable {
+		t.Errorf("mod C conflict = %q, want %q", types[modC], domain.ConflictUnsatisfiable)
+	}
+	if types[modD] != domain.ConflictMissing {
+		t.Errorf("mod D conflict = %q, want %q", types[modD], domain.ConflictMissing)
+	}
+}
+
+// 可选依赖没有满足范围的版本时跳过,不影响解析结果
+func TestResolveOptionalAndCycles(t *testing.T) {
+	repo := &fakeDependencyRepo{}
+	root := repo.version(modRoot, 1, "1.0.0")
+	a1 := repo.version(modA, 1, "1.0.0")
+	repo.version(modB, 1, "1.0.0")
+
+	repo.depend(root, modA, "*", domain.DependencyRequired)
+	repo.depend(root, modB, "^2", domain.DependencyOptional)
+	repo.depend(a1, modRoot, "*", domain.DependencyRequired)
+
+	result := resolveFor(t, repo, root)
+	if !result.OK {
+		t.Fatalf("resolution failed: %+v", result.Conflicts)
+	}
+	if _, ok := selectedVersions(result)[modB]; ok {
+		t.Error("optional dependency without a matching version was selected")
+	}
+	if len(result.Cycles) != 1 {
+		t.Errorf("cycles = %v, want one cycle", result.Cycles)
+	}
+}
@@ -2,7 +2,6 @@ package service
 
 import (
 	"ModVerse/domain"
-	"ModVerse/internal/utils"
 	"context"
 	"fmt"
 	"strconv"
@@ -13,14 +12,16 @@ type gameService struct {
 	gameRepo  domain.GameRepository
 	redisRepo domain.RedisRepository
 	sfRepo    domain.StorageFileRepository
+	storage   domain.Storage
 	timeout   time.Duration
 }
 
-func NewGameService(r domain.GameRepository, rd domain.RedisRepository, sf domain.StorageFileRepository, t time.Duration) domain.GameService {
+func NewGameService(r domain.GameRepository, rd domain.RedisRepository, sf domain.StorageFileRepository, st domain.Storage, t time.Duration) domain.GameService {
 	return &gameService{
 		gameRepo:  r,
 		redisRepo: rd,
 		sfRepo:    sf,
+		storage:   st,
 		timeout:   t,
 	}
 }
@@ -29,7 +30,11 @@ func (s *gameService) CreateGame(c context.Context, game *domain.Game) error {
 	ctx, cancel := context.WithTimeout(c, s.timeout)
 	defer cancel()
 
-	return s.gameRepo.CreateGame(ctx, game)
+	if err := s.gameRepo.CreateGame(ctx, game); err != nil {
+		return err
+	}
+
+	return s.sfRepo.ClaimStorageFiles(ctx, []uint{game.LogoID})
 }
 
 func (s *gameService) GetGame(c context.Context, id string) (*domain.GameResponse, error) {
@@ -67,17 +72,15 @@ func (s *gameService) DeleteGame(c context.Context, id string) error {
 		return err
 	}
 
-	file, err := s.sfRepo.GetStorageFile(ctx, fmt.Sprint(game.LogoID))
+	orphans, err := s.sfRepo.DeleteStorageFile(ctx, fmt.Sprint(game.LogoID))
 	if err != nil {
 		return err
 	}
 
-	if err := s.sfRepo.DeleteStorageFile(ctx, fmt.Sprint(game.LogoID)); err != nil {
-		return err
-	}
-
-	if err := utils.DeleteFile(file.FileKey); err != nil {
-		return err
+	for _, orphan := range orphans {
+		if err := deleteUnreferenced(ctx, s.sfRepo, s.storage, orphan); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -105,18 +108,20 @@ func (s *gameService) UpdateGame(c context.Context, id string, req *domain.Updat
 		return err
 	}
 
+	if err := s.sfRepo.ClaimStorageFiles(ctx, []uint{req.NewLogoID}); err != nil {
+		return err
+	}
+
 	if req.OldLogoID != 0 && req.NewLogoID != req.OldLogoID {
-		oldLogoFile, err := s.sfRepo.GetStorageFile(ctx, fmt.Sprint(req.OldLogoID))
+		orphans, err := s.sfRepo.DeleteStorageFile(ctx, fmt.Sprint(req.OldLogoID))
 		if err != nil {
 			return err
 		}
 
-		if err := s.sfRepo.DeleteStorageFile(ctx, fmt.Sprint(req.OldLogoID)); err != nil {
-			return err
-		}
-
-		if err := utils.DeleteFile(oldLogoFile.FileKey); err != nil {
-			return err
+		for _, orphan := range orphans {
+			if err := deleteUnreferenced(ctx, s.sfRepo, s.storage, orphan); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"context"
+	"strconv"
+	"time"
+)
+
+type gameVersionService struct {
+	gameVersionRepo domain.GameVersionRepository
+	modVersionRepo  domain.ModVersionRepository
+	timeout         time.Duration
+}
+
+func NewGameVersionService(gvr domain.GameVersionRepository, mvr domain.ModVersionRepository, timeout time.Duration) domain.GameVersionService {
+	return &gameVersionService{
+		gameVersionRepo: gvr,
+		modVersionRepo:  mvr,
+		timeout:         timeout,
+	}
+}
+
+func (s *gameVersionService) CreateGameVersion(c context.Context, gameID string, gv *domain.GameVersion) error {
+	parseID, err := strconv.ParseUint(gameID, 10, 64)
+	if err != nil {
+		return err
+	}
+	gv.GameID = uint(parseID)
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.gameVersionRepo.CreateGameVersion(ctx, gv)
+}
+
+func (s *gameVersionService) GetGameVersions(c context.Context, gameID string) (*[]domain.GameVersionResponse, error) {
+	parseID, err := strconv.ParseUint(gameID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.gameVersionRepo.GetGameVersions(ctx, uint(parseID))
+}
+
+func (s *gameVersionService) DeleteGameVersion(c context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.gameVersionRepo.DeleteGameVersion(ctx, id)
+}
+
+func (s *gameVersionService) CheckVersionOwner(c context.Context, id string, userID string, role string) error {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return checkVersionOwner(ctx, s.modVersionRepo, id, userID, role)
+}
+
+func (s *gameVersionService) SetModVersionGameVersions(c context.Context, modVersionID string, gameVersionIDs []uint) error {
+	parseID, err := strconv.ParseUint(modVersionID, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.gameVersionRepo.SetModVersionGameVersions(ctx, uint(parseID), gameVersionIDs)
+}
+
+// GetCompatibilityMatrix 生成模组各版本对游戏各版本的兼容性矩阵
+func (s *gameVersionService) GetCompatibilityMatrix(c context.Context, modID string) (*domain.CompatibilityMatrix, error) {
+	parseID, err := strconv.ParseUint(modID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	gameID, err := s.gameVersionRepo.GetModGameID(ctx, uint(parseID))
+	if err != nil {
+		return nil, err
+	}
+
+	gameVersions, err := s.gameVersionRepo.GetGameVersions(ctx, gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	modVersions, _, err := s.modVersionRepo.GetModVersions(ctx, modID)
+	if err != nil {
+		return nil, err
+	}
+	// 矩阵包含全部渠道,不含已撤回的版本
+	versions, err := filterVersions(*modVersions, &domain.ModVersionQuery{Channel: domain.ChannelAll}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, err := s.gameVersionRepo.GetCompatibilityPairs(ctx, uint(parseID))
+	if err != nil {
+		return nil, err
+	}
+
+	type pairKey struct {
+		modVersionID  uint
+		gameVersionID uint
+	}
+	index := make(map[pairKey]*domain.CompatibilityPair, len(*pairs))
+	for i := range *pairs {
+		p := &(*pairs)[i]
+		index[pairKey{p.ModVersionID, p.GameVersionID}] = p
+	}
+
+	rows := make([]domain.CompatibilityRow, 0, len(versions))
+	for _, v := range versions {
+		row := domain.CompatibilityRow{
+			ModVersionID: v.ID,
+			Version:      v.Version,
+			Cells:        make([]domain.CompatibilityCell, 0, len(*gameVersions)),
+		}
+		for _, gv := range *gameVersions {
+			cell := domain.CompatibilityCell{GameVersionID: gv.ID}
+			if p, ok := index[pairKey{v.ID, gv.ID}]; ok {
+				cell.Declared = p.Declared
+				cell.Works = p.Works
+				cell.Broken = p.Broken
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		rows = append(rows, row)
+	}
+
+	return &domain.CompatibilityMatrix{
+		GameVersions: *gameVersions,
+		Rows:         rows,
+	}, nil
+}
+
+// ReportCompatibility 提交可用/不可用反馈,同一用户对同一组合重复提交时覆盖
+func (s *gameVersionService) ReportCompatibility(c context.Context, modVersionID string, userID string, req *domain.CompatibilityReportRequest) error {
+	parseVersionID, err := strconv.ParseUint(modVersionID, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	parseUserID, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.gameVersionRepo.SaveCompatibilityReport(ctx, &domain.CompatibilityReport{
+		ModVersionID:  uint(parseVersionID),
+		GameVersionID: req.GameVersionID,
+		UserID:        uint(parseUserID),
+		Works:         req.Works,
+	})
+}
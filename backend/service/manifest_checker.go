@@ -0,0 +1,187 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"context"
+	"strings"
+)
+
+// 按上传时提取的清单预填发布信息,并检查作者填写的内容是否与清单一致,模组与版本的发布共用
+type manifestChecker struct {
+	manifestRepo    domain.ManifestRepository
+	gameVersionRepo domain.GameVersionRepository
+}
+
+func (m *manifestChecker) prefill(c context.Context, manifest *domain.ModManifest) (*domain.ManifestPrefill, error) {
+	prefill := &domain.ManifestPrefill{
+		Manifest:       manifest,
+		Name:           manifest.Name,
+		GameVersionIDs: []uint{},
+		Dependencies:   []domain.DependencyRequest{},
+		Unmatched:      []domain.ManifestDependency{},
+	}
+	if v, err := utils.NormalizeVersion(manifest.Version, true); err == nil {
+		prefill.Version = v
+	}
+
+	gameVersions, _, err := m.matchGameVersions(c, manifest)
+	if err != nil {
+		return nil, err
+	}
+	prefill.GameVersionIDs = append(prefill.GameVersionIDs, gameVersions...)
+
+	matched, err := m.matchDependencies(c, manifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range manifest.Dependencies {
+		modID, ok := matched[dep.ModID]
+		if !ok {
+			prefill.Unmatched = append(prefill.Unmatched, dep)
+			continue
+		}
+
+		// 无法解析的范围(如未转换的Maven范围)不预填
+		versionRange := dep.VersionRange
+		if _, err := utils.ParseVersionRange(versionRange); err != nil {
+			versionRange = "*"
+		}
+		prefill.Dependencies = append(prefill.Dependencies, domain.DependencyRequest{
+			ModID:        modID,
+			VersionRange: versionRange,
+			Type:         dep.Type,
+		})
+	}
+
+	return prefill, nil
+}
+
+// 满足清单游戏版本范围的游戏版本ID,以及该游戏全部版本的名称;范围为空或无法解析时不匹配
+func (m *manifestChecker) matchGameVersions(c context.Context, manifest *domain.ModManifest) ([]uint, map[uint]string, error) {
+	if manifest.GameVersionRange == "" {
+		return nil, nil, nil
+	}
+	vr, err := utils.ParseVersionRange(manifest.GameVersionRange)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	gameVersions, err := m.gameVersionRepo.GetGameVersions(c, manifest.GameID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ids []uint
+	names := make(map[uint]string, len(*gameVersions))
+	for _, gv := range *gameVersions {
+		names[gv.ID] = gv.Name
+		if sv, err := utils.ParseSemverLenient(gv.Name); err == nil && vr.Contains(sv) {
+			ids = append(ids, gv.ID)
+		}
+	}
+
+	return ids, names, nil
+}
+
+func (m *manifestChecker) matchDependencies(c context.Context, manifest *domain.ModManifest) (map[string]uint, error) {
+	names := make([]string, 0, len(manifest.Dependencies))
+	for _, dep := range manifest.Dependencies {
+		names = append(names, dep.ModID)
+	}
+
+	return m.manifestRepo.MatchModNames(c, manifest.GameID, names)
+}
+
+// 主文件中第一个属于该游戏的清单
+func (m *manifestChecker) versionManifest(c context.Context, gameID uint, files []domain.ModVersionFile) (*domain.ModManifest, error) {
+	fileIDs := make([]uint, 0, len(files))
+	for _, f := range files {
+		if f.Role == domain.FileRoleMain {
+			fileIDs = append(fileIDs, f.FileID)
+		}
+	}
+
+	manifests, err := m.manifestRepo.GetFileManifests(c, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range manifests {
+		if manifests[i].GameID == gameID {
+			return &manifests[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// mismatches 比较作者填写的名称(发布版本时为空)、版本号、游戏版本与依赖和清单的差异
+// 清单中的必需依赖在站内存在但未填写时提示,站内不存在的依赖无法填写,不提示
+// checkDeps为false时不比较依赖,创建模组时不填写依赖
+func (m *manifestChecker) mismatches(c context.Context, gameID uint, name string, mv *domain.ModVersion, deps []domain.DependencyRequest, checkDeps bool, gameVersionIDs []uint) ([]domain.ManifestMismatch, error) {
+	manifest, err := m.versionManifest(c, gameID, mv.Files)
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+
+	var mismatches []domain.ManifestMismatch
+
+	if name != "" && manifest.Name != "" && utils.Slugify(name) != utils.Slugify(manifest.Name) {
+		mismatches = append(mismatches, domain.ManifestMismatch{Field: "name", Manifest: manifest.Name, Entered: name})
+	}
+
+	if manifest.Version != "" && !sameVersion(mv.Version, manifest.Version) {
+		mismatches = append(mismatches, domain.ManifestMismatch{Field: "version", Manifest: manifest.Version, Entered: mv.Version})
+	}
+
+	supported, names, err := m.matchGameVersions(c, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if names != nil {
+		inRange := make(map[uint]bool, len(supported))
+		for _, id := range supported {
+			inRange[id] = true
+		}
+		for _, id := range gameVersionIDs {
+			if !inRange[id] {
+				mismatches = append(mismatches, domain.ManifestMismatch{Field: "game_versions", Manifest: manifest.GameVersionRange, Entered: names[id]})
+			}
+		}
+	}
+
+	if !checkDeps {
+		return mismatches, nil
+	}
+
+	matched, err := m.matchDependencies(c, manifest)
+	if err != nil {
+		return nil, err
+	}
+	entered := make(map[uint]bool, len(deps))
+	for _, dep := range deps {
+		entered[dep.ModID] = true
+	}
+	for _, dep := range manifest.Dependencies {
+		modID, ok := matched[dep.ModID]
+		if !ok || dep.Type != domain.DependencyRequired || entered[modID] {
+			continue
+		}
+		mismatches = append(mismatches, domain.ManifestMismatch{
+			Field:    "dependencies",
+			Manifest: strings.TrimSpace(dep.ModID + " " + dep.VersionRange),
+		})
+	}
+
+	return mismatches, nil
+}
+
+// 两者都能解析时按语义化版本比较,如 1.2 与 1.2.0 相同,否则比较原文
+func sameVersion(a, b string) bool {
+	va, errA := utils.ParseSemverLenient(a)
+	vb, errB := utils.ParseSemverLenient(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return va.Compare(vb) == 0
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"context"
+	"testing"
+)
+
+type fakeManifestRepo struct {
+	manifest domain.ModManifest
+	mods     map[string]uint
+}
+
+func (f *fakeManifestRepo) GetFileManifests(c context.Context, fileIDs []uint) ([]domain.ModManifest, error) {
+	return []domain.ModManifest{f.manifest}, nil
+}
+
+func (f *fakeManifestRepo) MatchModNames(c context.Context, gameID uint, names []string) (map[string]uint, error) {
+	matched := make(map[string]uint)
+	for _, name := range names {
+		if id, ok := f.mods[name]; ok {
+			matched[name] = id
+		}
+	}
+	return matched, nil
+}
+
+func newTestManifestChecker() *manifestChecker {
+	return &manifestChecker{manifestRepo: &fakeManifestRepo{
+		manifest: domain.ModManifest{
+			GameID:  1,
+			Name:    "Example Mod",
+			Version: "1.2.0",
+			Dependencies: []domain.ManifestDependency{
+				{ModID: "fabric-api", VersionRange: "*", Type: domain.DependencyRequired},
+				{ModID: "modmenu", VersionRange: "*", Type: domain.DependencyOptional},
+				{ModID: "unknown", VersionRange: "*", Type: domain.DependencyRequired},
+			},
+		},
+		mods: map[string]uint{"fabric-api": 7, "modmenu": 8},
+	}}
+}
+
+func mismatchFields(mismatches []domain.ManifestMismatch) map[string]int {
+	fields := make(map[string]int)
+	for _, m := range mismatches {
+		fields[m.Field]++
+	}
+	return fields
+}
+
+// 创建模组时不填写依赖,不比较依赖
+func TestMismatchesSkipsDependenciesOnCreateMod(t *testing.T) {
+	mv := &domain.ModVersion{Version: "1.2", Files: []domain.ModVersionFile{{FileID: 1, Role: domain.FileRoleMain}}}
+
+	mismatches, err := newTestManifestChecker().mismatches(context.Background(), 1, "example-mod", mv, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("mismatches = %+v, want none", mismatches)
+	}
+}
+
+// 只提示站内存在但未填写的必需依赖
+func TestMismatchesReportsMissingRequiredDependencies(t *testing.T) {
+	mv := &domain.ModVersion{Version: "1.3.0", Files: []domain.ModVersionFile{{FileID: 1, Role: domain.FileRoleMain}}}
+	checker := newTestManifestChecker()
+
+	mismatches, err := checker.mismatches(context.Background(), 1, "", mv, nil, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := mismatchFields(mismatches)
+	if fields["version"] != 1 || fields["dependencies"] != 1 || len(mismatches) != 2 {
+		t.Errorf("mismatches = %+v", mismatches)
+	}
+
+	mismatches, err = checker.mismatches(context.Background(), 1, "", mv, []domain.DependencyRequest{{ModID: 7}}, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mismatchFields(mismatches)["dependencies"] != 0 {
+		t.Errorf("mismatches with dependency entered = %+v", mismatches)
+	}
+}
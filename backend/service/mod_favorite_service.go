@@ -98,7 +98,7 @@ func (m *modFavoriteService) DeleteModFavorite(c context.Context, userID string,
 	return nil
 }
 
-func (m *modFavoriteService) GetModFavorites(c context.Context, userID string, params *domain.ModFavoriteQuery) (*[]domain.ModFavoriteResponse, int64, error) {
+func (m *modFavoriteService) GetModFavorites(c context.Context, userID string, params *domain.ModFavoriteQuery) (*[]domain.ModFavoriteResponse, int64, string, error) {
 	ctx, cancel := context.WithTimeout(c, m.timeout)
 	defer cancel()
 	return m.modFavoriteRepo.GetModFavorites(ctx, userID, params)
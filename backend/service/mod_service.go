@@ -4,30 +4,75 @@ import (
 	"ModVerse/domain"
 	"ModVerse/internal/utils"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const modFacetsCacheKeyPrefix = "facets:mods:"
+const modFacetsCacheTTL = time.Minute
+
 type modService struct {
 	modRepo        domain.ModRepository
 	modVersionRepo domain.ModVersionRepository
+	categoriesRepo domain.CategoriesRepository
 	redisRepo      domain.RedisRepository
+	sfRepo         domain.StorageFileRepository
+	storage        domain.Storage
+	manifest       *manifestChecker
 	timeout        time.Duration
 }
 
-func NewModService(r domain.ModRepository, mvr domain.ModVersionRepository, rd domain.RedisRepository, timeout time.Duration) domain.ModService {
+func NewModService(r domain.ModRepository, mvr domain.ModVersionRepository, car domain.CategoriesRepository, gvr domain.GameVersionRepository, mfr domain.ManifestRepository, rd domain.RedisRepository, sf domain.StorageFileRepository, st domain.Storage, timeout time.Duration) domain.ModService {
 	return &modService{
 		modRepo:        r,
 		redisRepo:      rd,
+		sfRepo:         sf,
+		storage:        st,
 		timeout:        timeout,
 		modVersionRepo: mvr,
+		categoriesRepo: car,
+		manifest:       &manifestChecker{manifestRepo: mfr, gameVersionRepo: gvr},
 	}
 }
 
-func (m *modService) CreateMod(c context.Context, mod *domain.Mod, modVersion *domain.ModVersion, userID string) error {
+func (m *modService) CreateMod(c context.Context, mod *domain.Mod, modVersion *domain.ModVersion, files []domain.ModVersionFileRequest, categoryIDs []uint, categoryNames []string) error {
+	if len(categoryIDs) == 0 && len(categoryNames) == 0 {
+		return errors.New("at least one category is required")
+	}
+
+	versionFiles, err := buildVersionFiles(files)
+	if err != nil {
+		return err
+	}
+	modVersion.Files = versionFiles
+
 	ctx, cancel := context.WithTimeout(c, m.timeout)
 	defer cancel()
 
+	categories, err := m.categoriesRepo.ResolveCategories(ctx, categoryIDs, categoryNames)
+	if err != nil {
+		return err
+	}
+	if err := m.categoriesRepo.CheckCategoriesForGame(ctx, *categories, mod.GameID); err != nil {
+		return err
+	}
+	mod.Categories = *categories
+
+	// 与文件清单不一致时只记录,由作者确认
+	mismatches, err := m.manifest.mismatches(ctx, mod.GameID, mod.Name, modVersion, nil, false, nil)
+	if err != nil {
+		return err
+	}
+	modVersion.Mismatches = mismatches
+
 	return m.modRepo.CreateMod(ctx, mod, modVersion)
 }
 
@@ -38,41 +83,125 @@ func (m *modService) GetMod(c context.Context, id string) (*domain.Mod, error) {
 	return m.modRepo.GetMod(ctx, id)
 }
 
-func (m *modService) GetMods(c context.Context, params *domain.ModQuery) (*[]domain.ModResponse, int64, error) {
+func (m *modService) GetMods(c context.Context, params *domain.ModQuery) (*[]domain.ModResponse, int64, string, error) {
+	query, err := parseModQuery(params)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
 	ctx, cancel := context.WithTimeout(c, m.timeout)
 	defer cancel()
 
-	return m.modRepo.GetMods(ctx, params)
+	return m.modRepo.GetMods(ctx, query)
 }
 
-func (m *modService) DeleteMod(c context.Context, id string) error {
+// GetModFacets 获取分面统计,结果按规范化后的查询条件短暂缓存
+func (m *modService) GetModFacets(c context.Context, params *domain.ModQuery) (*domain.ModFacets, error) {
+	query, err := parseModQuery(params)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(c, m.timeout)
 	defer cancel()
 
-	modVersions, _, err := m.modVersionRepo.GetModVersions(ctx, id)
+	key := modFacetsCacheKey(params)
+	if cached, err := m.redisRepo.GetValue(ctx, key); err == nil {
+		var facets domain.ModFacets
+		if err := json.Unmarshal([]byte(cached), &facets); err == nil {
+			return &facets, nil
+		}
+	}
+
+	facets, err := m.modRepo.GetModFacets(ctx, query)
 	if err != nil {
-		return err
+		return nil, err
 	}
+
+	if data, err := json.Marshal(facets); err == nil {
+		if err := m.redisRepo.SetValue(ctx, key, data, modFacetsCacheTTL); err != nil {
+			log.Printf("cache mod facets failed: %v", err)
+		}
+	}
+
+	return facets, nil
+}
+
+func (m *modService) DeleteMod(c context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
 	// 将字符串id转换为uint类型
 	idUint, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
 		return err
 	}
-	//数据库删除
-	if err := m.modRepo.DeleteMod(ctx, uint(idUint)); err != nil {
+	//数据库删除,返回不再被引用的文件
+	orphans, err := m.modRepo.DeleteMod(ctx, uint(idUint))
+	if err != nil {
 		return err
 	}
-	//删除文件
-	for _, modVersion := range *modVersions {
-		return utils.DeleteFile(modVersion.File.FileKey)
+	//数据库记录已删除,删除不再被引用的文件,失败只记录日志,由存储清理回收
+	for _, key := range orphans {
+		if err := deleteUnreferenced(ctx, m.sfRepo, m.storage, key); err != nil {
+			log.Printf("delete mod file %s failed: %v", key, err)
+		}
 	}
 
 	return nil
 }
 
-func (m *modService) UpdateMod(c context.Context, mod *domain.Mod) error {
+// 解析结构化查询语句,返回合并后的查询条件副本
+func parseModQuery(params *domain.ModQuery) (*domain.ModQuery, error) {
+	query := *params
+	if query.Q != "" {
+		if err := utils.ParseModSearch(query.Q, &query); err != nil {
+			return nil, err
+		}
+	}
+	return &query, nil
+}
+
+// 分面缓存键,忽略分页排序、空白差异与分类顺序
+func modFacetsCacheKey(params *domain.ModQuery) string {
+	categories := slices.Clone(params.Category)
+	sort.Strings(categories)
+
+	normalized := strings.Join([]string{
+		strings.Join(strings.Fields(params.Q), " "),
+		strings.ToLower(strings.TrimSpace(params.Name)),
+		strings.Join(categories, "|"),
+		strconv.FormatUint(uint64(params.GameID), 10),
+		params.UserID,
+		strings.ToLower(strings.TrimSpace(params.UserName)),
+		params.Status,
+		strings.TrimSpace(params.GameVersion),
+	}, "\x00")
+
+	sum := sha1.Sum([]byte(normalized))
+	return modFacetsCacheKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+func (m *modService) UpdateMod(c context.Context, mod *domain.Mod, categoryIDs []uint) error {
 	ctx, cancel := context.WithTimeout(c, m.timeout)
 	defer cancel()
 
-	return m.modRepo.UpdateMod(ctx, mod)
+	var categories []domain.Categories
+	if len(categoryIDs) > 0 {
+		resolved, err := m.categoriesRepo.ResolveCategories(ctx, categoryIDs, nil)
+		if err != nil {
+			return err
+		}
+
+		current, err := m.modRepo.GetMod(ctx, strconv.FormatUint(uint64(mod.ID), 10))
+		if err != nil {
+			return err
+		}
+		if err := m.categoriesRepo.CheckCategoriesForGame(ctx, *resolved, current.GameID); err != nil {
+			return err
+		}
+		categories = *resolved
+	}
+
+	return m.modRepo.UpdateMod(ctx, mod, categories)
 }
@@ -1,33 +1,168 @@
 package service
 
 import (
+	"ModVerse/bootstrap"
 	"ModVerse/domain"
+	"ModVerse/internal/custom"
 	"ModVerse/internal/utils"
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type modVersionService struct {
 	modVersionRepo domain.ModVersionRepository
+	dependencyRepo domain.ModDependencyRepository
 	redisRepo      domain.RedisRepository
+	sfRepo         domain.StorageFileRepository
+	storage        domain.Storage
+	manifest       *manifestChecker
+	env            *bootstrap.Env
 	timeout        time.Duration
 }
 
-func NewModVersionService(r domain.ModVersionRepository, rd domain.RedisRepository, timeout time.Duration) domain.ModVersionService {
+func NewModVersionService(r domain.ModVersionRepository, dr domain.ModDependencyRepository, gvr domain.GameVersionRepository, mfr domain.ManifestRepository, rd domain.RedisRepository, sf domain.StorageFileRepository, st domain.Storage, env *bootstrap.Env, timeout time.Duration) domain.ModVersionService {
 	return &modVersionService{
 		modVersionRepo: r,
+		dependencyRepo: dr,
 		redisRepo:      rd,
+		sfRepo:         sf,
+		storage:        st,
+		manifest:       &manifestChecker{manifestRepo: mfr, gameVersionRepo: gvr},
+		env:            env,
 		timeout:        timeout,
 	}
 }
 
-func (m *modVersionService) CreateModVersion(c context.Context, mv *domain.ModVersion) error {
+func (m *modVersionService) CreateModVersion(c context.Context, mv *domain.ModVersion, files []domain.ModVersionFileRequest, deps []domain.DependencyRequest, gameVersionIDs []uint) error {
+	versionFiles, err := buildVersionFiles(files)
+	if err != nil {
+		return err
+	}
+	mv.Files = versionFiles
+
 	ctx, cancel := context.WithTimeout(c, m.timeout)
 	defer cancel()
+
+	channel, err := versionChannel(mv.Channel, mv.Version)
+	if err != nil {
+		return err
+	}
+	mv.Channel = channel
+
+	versions, err := m.modVersionRepo.GetVersionNumbers(ctx, mv.ModID)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v == mv.Version || utils.CompareVersionStrings(v, mv.Version) == 0 && isSemver(v) {
+			return custom.VersionExistError
+		}
+	}
+
+	dependencies, err := m.buildDependencies(ctx, mv.ModID, deps)
+	if err != nil {
+		return err
+	}
+	mv.Dependencies = dependencies
+
+	// 游戏版本的归属在创建事务中校验
+	for _, id := range gameVersionIDs {
+		mv.GameVersions = append(mv.GameVersions, domain.GameVersion{Model: gorm.Model{ID: id}})
+	}
+
+	// 与文件清单不一致时只记录,由作者确认
+	gameID, err := m.manifest.gameVersionRepo.GetModGameID(ctx, mv.ModID)
+	if err != nil {
+		return err
+	}
+	mismatches, err := m.manifest.mismatches(ctx, gameID, "", mv, deps, true, gameVersionIDs)
+	if err != nil {
+		return err
+	}
+	mv.Mismatches = mismatches
+
 	return m.modVersionRepo.CreateModVersion(ctx, mv)
 }
 
-func (m *modVersionService) DeleteModVersion(c context.Context, id string) error {
+// 校验文件列表并转换为模型:角色合法、文件不重复、至少包含一个主文件,顺序即排序
+func buildVersionFiles(files []domain.ModVersionFileRequest) ([]domain.ModVersionFile, error) {
+	if len(files) == 0 {
+		return nil, errors.New("at least one file is required")
+	}
+
+	result := make([]domain.ModVersionFile, 0, len(files))
+	seen := make(map[uint]struct{}, len(files))
+	hasMain := false
+
+	for i, f := range files {
+		if _, ok := seen[f.FileID]; ok {
+			return nil, fmt.Errorf("duplicate file %d", f.FileID)
+		}
+		seen[f.FileID] = struct{}{}
+
+		role := f.Role
+		switch role {
+		case "":
+			role = domain.FileRoleMain
+		case domain.FileRoleMain, domain.FileRoleOptional, domain.FileRolePatch, domain.FileRoleDocs:
+		default:
+			return nil, fmt.Errorf("unknown file role %q", f.Role)
+		}
+		if role == domain.FileRoleMain {
+			hasMain = true
+		}
+
+		result = append(result, domain.ModVersionFile{
+			FileID:      f.FileID,
+			Role:        role,
+			DisplayName: strings.TrimSpace(f.DisplayName),
+			SortOrder:   i,
+		})
+	}
+
+	if !hasMain {
+		return nil, errors.New("at least one main file is required")
+	}
+
+	return result, nil
+}
+
+// 校验发布渠道,未指定时按预发布标识推断: 含alpha为alpha,其他预发布为beta,正式版本为stable
+func versionChannel(channel string, version string) (string, error) {
+	if channel != "" {
+		if rank := domain.ChannelRank(channel); rank < 0 || channel == domain.ChannelAll {
+			return "", fmt.Errorf("%w: %q", domain.ErrUnknownChannel, channel)
+		}
+		return channel, nil
+	}
+
+	sv, err := utils.ParseSemverLenient(version)
+	if err != nil || len(sv.Pre) == 0 {
+		return domain.ChannelStable, nil
+	}
+	for _, id := range sv.Pre {
+		if strings.Contains(strings.ToLower(id), "alpha") {
+			return domain.ChannelAlpha, nil
+		}
+	}
+	return domain.ChannelBeta, nil
+}
+
+func isSemver(version string) bool {
+	_, err := utils.ParseSemverLenient(version)
+	return err == nil
+}
+
+// DeleteModVersion 删除版本,force为false时若其他模组必需该版本则拒绝
+func (m *modVersionService) DeleteModVersion(c context.Context, id string, force bool) error {
 	ctx, cancel := context.WithTimeout(c, m.timeout)
 	defer cancel()
 
@@ -36,27 +171,188 @@ func (m *modVersionService) DeleteModVersion(c context.Context, id string) error
 		return err
 	}
 
-	if err := m.modVersionRepo.DeleteModVersion(ctx, id); err != nil {
+	if !force {
+		if err := m.checkRequiredBy(ctx, modVersion.ID); err != nil {
+			return err
+		}
+	}
+
+	orphans, err := m.modVersionRepo.DeleteModVersion(ctx, id)
+	if err != nil {
 		return err
 	}
 
-	if err := utils.DeleteFile(modVersion.File.FileKey); err != nil {
-		return nil
+	// 数据库记录已删除,只删除不再被引用的文件,失败只记录日志
+	for _, key := range orphans {
+		if err := deleteUnreferenced(ctx, m.sfRepo, m.storage, key); err != nil {
+			log.Printf("delete mod version file %s failed: %v", key, err)
+		}
 	}
 
 	return nil
 }
 
-func (m *modVersionService) GetModVersions(c context.Context, modID string) (*[]domain.ModVersionResponse, int64, error) {
+// GetModVersions 按语义化版本从高到低返回版本列表,可按版本范围筛选
+// 无法解析的历史版本号排在最后,按发布时间倒序
+func (m *modVersionService) GetModVersions(c context.Context, modID string, params *domain.ModVersionQuery) (*[]domain.ModVersionResponse, int64, error) {
 	ctx, cancel := context.WithTimeout(c, m.timeout)
 	defer cancel()
-	return m.modVersionRepo.GetModVersions(ctx, modID)
+
+	modVersions, _, err := m.modVersionRepo.GetModVersions(ctx, modID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	list, err := filterVersions(*modVersions, params, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range list {
+		m.setDownloadURLs(list[i].Files)
+		setMainFile(&list[i])
+	}
+
+	return &list, int64(len(list)), nil
 }
 
-func (m *modVersionService) UpdateCount(c context.Context, id string, modID string) (int64, error) {
+// GetLatestVersion 返回满足范围的最高版本,未指定prerelease时不含预发布版本
+func (m *modVersionService) GetLatestVersion(c context.Context, modID string, params *domain.ModVersionQuery) (*domain.ModVersionResponse, error) {
 	ctx, cancel := context.WithTimeout(c, m.timeout)
 	defer cancel()
 
+	modVersions, _, err := m.modVersionRepo.GetModVersions(ctx, modID)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := filterVersions(*modVersions, params, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, custom.DataNotExistError
+	}
+	m.setDownloadURLs(list[0].Files)
+	setMainFile(&list[0])
+
+	return &list[0], nil
+}
+
+// 存储地址只能通过签名访问,列表中的下载地址替换为记录下载并重定向到签名地址的版本下载接口
+// 已隔离的文件没有下载地址,保持为空
+func (m *modVersionService) setDownloadURLs(files []domain.ModVersionFileResponse) {
+	for i := range files {
+		f := &files[i]
+		if f.File.URL == "" {
+			continue
+		}
+		f.File.URL = fmt.Sprintf("%s:%s/api/mod_version/%d/download?file_id=%d", m.env.App.Host, m.env.App.Port, f.ModVersionID, f.ID)
+	}
+}
+
+// 设置兼容旧客户端的 file 字段为版本的主文件
+func setMainFile(v *domain.ModVersionResponse) {
+	if f := downloadFile(v, ""); f != nil {
+		file := f.File
+		v.File = &file
+	}
+}
+
+// 版本是否声明支持该游戏版本(名称或ID)
+func supportsGameVersion(v *domain.ModVersionResponse, gameVersion string) bool {
+	for _, gv := range v.GameVersions {
+		if gv.Name == gameVersion || strconv.FormatUint(uint64(gv.ID), 10) == gameVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// 筛选并排序版本;stable为true且未要求预发布时排除预发布版本,同时排除未通过扫描、不可下载的版本
+// 默认只返回stable渠道且未撤回的版本,params为nil时不做任何筛选
+func filterVersions(versions []domain.ModVersionResponse, params *domain.ModVersionQuery, stable bool) ([]domain.ModVersionResponse, error) {
+	var vr *utils.VersionRange
+	prerelease := true
+	channelRank := domain.ChannelRank(domain.ChannelAll)
+	includeYanked := true
+	if params != nil {
+		channel := params.Channel
+		if channel == "" {
+			channel = domain.ChannelStable
+		}
+		channelRank = domain.ChannelRank(channel)
+		if channelRank < 0 {
+			return nil, fmt.Errorf("%w: %q", domain.ErrUnknownChannel, params.Channel)
+		}
+		includeYanked = params.IncludeYanked
+	}
+	if params != nil {
+		if params.Range != "" {
+			r, err := utils.ParseVersionRange(params.Range)
+			if err != nil {
+				return nil, err
+			}
+			vr = r
+			prerelease = params.Prerelease
+		}
+		if stable {
+			// 非stable渠道本身即包含预发布版本
+			prerelease = params.Prerelease || channelRank > domain.ChannelRank(domain.ChannelStable)
+		}
+	} else if stable {
+		prerelease = false
+	}
+
+	list := make([]domain.ModVersionResponse, 0, len(versions))
+	for _, v := range versions {
+		if v.Yanked && !includeYanked || domain.ChannelRank(v.Channel) > channelRank {
+			continue
+		}
+		if stable && v.ScanStatus != domain.ScanStatusClean {
+			continue
+		}
+
+		sv, err := utils.ParseSemverLenient(v.Version)
+		switch {
+		case err != nil:
+			// 历史版本号只在未限定范围时保留
+			if vr != nil && vr.String() != "*" {
+				continue
+			}
+		case vr != nil:
+			if prerelease && !vr.ContainsPrerelease(sv) || !prerelease && !vr.Contains(sv) {
+				continue
+			}
+		case !prerelease && len(sv.Pre) > 0:
+			continue
+		}
+		if params != nil && params.GameVersion != "" && !supportsGameVersion(&v, params.GameVersion) {
+			continue
+		}
+		list = append(list, v)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if c := utils.CompareVersionStrings(list[i].Version, list[j].Version); c != 0 {
+			return c > 0
+		}
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+
+	return list, nil
+}
+
+// UpdateCount 记录下载,fileID不为空时同时记录该文件的下载量
+func (m *modVersionService) UpdateCount(c context.Context, id string, modID string, fileID string) (int64, error) {
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	if fileID != "" {
+		if err := m.redisRepo.Increment(ctx, "mod_version_file", fileID, "downloads"); err != nil {
+			return 0, err
+		}
+	}
+
 	if err := m.redisRepo.Increment(ctx, "mod_version", id, "downloads"); err != nil {
 		return 0, err
 	}
@@ -74,3 +370,509 @@ func (m *modVersionService) UpdateCount(c context.Context, id string, modID stri
 
 	return count, nil
 }
+
+// 同一IP(登录时为同一用户)在该时间内重复下载同一版本只计一次下载量
+const downloadCountWindow = time.Hour
+const downloadSeenKeyPrefix = "downloads:seen:"
+
+func (m *modVersionService) GetDownloadURL(c context.Context, modVersion *domain.ModVersionResponse, fileID string, clientIP string, userID string) (string, error) {
+	file := downloadFile(modVersion, fileID)
+	if file == nil {
+		return "", custom.DataNotExistError
+	}
+	// 已隔离的文件没有下载地址
+	if file.File.URL == "" {
+		return "", domain.ErrFileInfected
+	}
+
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	grant, err := m.downloadGrant(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	grant.Yanked = modVersion.Yanked
+
+	downloader := "ip:" + clientIP
+	if grant.UserID != 0 {
+		downloader = "user:" + userID
+	}
+
+	id := strconv.FormatUint(uint64(modVersion.ID), 10)
+	seenKey := downloadSeenKeyPrefix + id + ":" + downloader
+	first, err := m.redisRepo.SetValueNX(ctx, seenKey, 1, downloadCountWindow)
+	if err != nil {
+		return "", err
+	}
+	if first {
+		if _, err := m.UpdateCount(ctx, id, strconv.FormatUint(uint64(modVersion.ModID), 10), strconv.FormatUint(uint64(file.ID), 10)); err != nil {
+			return "", err
+		}
+	}
+
+	return utils.SignDownloadURL(file.File.URL, file.File.FileKey, grant)
+}
+
+// 未登录为匿名下载,发布过模组的用户为作者,其他登录用户为普通用户
+func (m *modVersionService) downloadGrant(c context.Context, userID string) (domain.DownloadGrant, error) {
+	if userID == "" {
+		return domain.DownloadGrant{Tier: domain.DownloadTierAnonymous}, nil
+	}
+
+	parseID, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return domain.DownloadGrant{}, err
+	}
+
+	author, err := m.modVersionRepo.HasPublishedMods(c, parseID)
+	if err != nil {
+		return domain.DownloadGrant{}, err
+	}
+	if author {
+		return domain.DownloadGrant{Tier: domain.DownloadTierAuthor, UserID: parseID}, nil
+	}
+
+	return domain.DownloadGrant{Tier: domain.DownloadTierUser, UserID: parseID}, nil
+}
+
+// 按版本文件ID选择文件,未指定时选择第一个主文件,没有主文件时选择第一个文件
+func downloadFile(modVersion *domain.ModVersionResponse, fileID string) *domain.ModVersionFileResponse {
+	for i := range modVersion.Files {
+		f := &modVersion.Files[i]
+		if fileID != "" && strconv.FormatUint(uint64(f.ID), 10) == fileID {
+			return f
+		}
+		if fileID == "" && (f.Role == domain.FileRoleMain || f.Role == "") {
+			return f
+		}
+	}
+
+	if fileID == "" && len(modVersion.Files) > 0 {
+		return &modVersion.Files[0]
+	}
+	return nil
+}
+
+// 校验依赖声明并转换为模型:类型与范围合法、不依赖自身、不重复、被依赖模组存在
+func (m *modVersionService) buildDependencies(c context.Context, modID uint, deps []domain.DependencyRequest) ([]domain.ModDependency, error) {
+	if len(deps) == 0 {
+		return nil, nil
+	}
+
+	result := make([]domain.ModDependency, 0, len(deps))
+	seen := make(map[uint]struct{}, len(deps))
+	modIDs := make([]uint, 0, len(deps))
+
+	for _, d := range deps {
+		if d.ModID == modID {
+			return nil, errors.New("a mod cannot depend on itself")
+		}
+		if _, ok := seen[d.ModID]; ok {
+			return nil, fmt.Errorf("duplicate dependency on mod %d", d.ModID)
+		}
+		seen[d.ModID] = struct{}{}
+		modIDs = append(modIDs, d.ModID)
+
+		depType := d.Type
+		switch depType {
+		case "":
+			depType = domain.DependencyRequired
+		case domain.DependencyRequired, domain.DependencyOptional, domain.DependencyIncompatible:
+		default:
+			return nil, fmt.Errorf("unknown dependency type %q", d.Type)
+		}
+
+		vr, err := utils.ParseVersionRange(d.VersionRange)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, domain.ModDependency{
+			DependsOnModID: d.ModID,
+			VersionRange:   vr.String(),
+			Type:           depType,
+		})
+	}
+
+	total, err := m.dependencyRepo.CountMods(c, modIDs)
+	if err != nil {
+		return nil, err
+	}
+	if total != int64(len(modIDs)) {
+		return nil, custom.DataNotExistError
+	}
+
+	return result, nil
+}
+
+// SetDependencies 替换版本的依赖声明
+func (m *modVersionService) SetDependencies(c context.Context, id string, deps []domain.DependencyRequest) error {
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	modVersion, err := m.modVersionRepo.GetModVersion(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	node, err := m.dependencyRepo.GetVersionNode(ctx, modVersion.ID)
+	if err != nil {
+		return err
+	}
+
+	dependencies, err := m.buildDependencies(ctx, node.ModID, deps)
+	if err != nil {
+		return err
+	}
+
+	return m.dependencyRepo.SetDependencies(ctx, node.ID, dependencies)
+}
+
+// ResolveDependencies 解析版本的完整依赖树,报告冲突与循环依赖
+func (m *modVersionService) CheckVersionOwner(c context.Context, id string, userID string, role string) error {
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	return checkVersionOwner(ctx, m.modVersionRepo, id, userID, role)
+}
+
+// 只有模组的上传者或管理员可以修改版本
+func checkVersionOwner(c context.Context, repo domain.ModVersionRepository, id string, userID string, role string) error {
+	if role == "admin" {
+		return nil
+	}
+
+	owner, err := repo.GetVersionOwner(c, id)
+	if err != nil {
+		return err
+	}
+	if userID == "" || strconv.FormatUint(owner, 10) != userID {
+		return domain.ErrNotVersionOwner
+	}
+
+	return nil
+}
+
+func (m *modVersionService) ResolveDependencies(c context.Context, id string) (*domain.DependencyResolution, error) {
+	parseID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	root, err := m.dependencyRepo.GetVersionNode(ctx, uint(parseID))
+	if err != nil {
+		return nil, err
+	}
+
+	return newDependencyResolver(ctx, m.dependencyRepo).resolve(root)
+}
+
+// GetDependents 查询依赖该模组的其他模组版本
+func (m *modVersionService) GetDependents(c context.Context, modID string) (*[]domain.DependentResponse, error) {
+	parseID, err := strconv.ParseUint(modID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	return m.dependencyRepo.GetDependents(ctx, uint(parseID), nil)
+}
+
+// 其他模组的必需依赖只能由该版本满足时返回VersionRequiredError
+func (m *modVersionService) checkRequiredBy(c context.Context, versionID uint) error {
+	node, err := m.dependencyRepo.GetVersionNode(c, versionID)
+	if err != nil {
+		return err
+	}
+
+	dependents, err := m.dependencyRepo.GetDependents(c, node.ModID, []string{domain.DependencyRequired})
+	if err != nil {
+		return err
+	}
+	if len(*dependents) == 0 {
+		return nil
+	}
+
+	versions, err := m.dependencyRepo.GetModVersionNodes(c, []uint{node.ModID})
+	if err != nil {
+		return err
+	}
+
+	var blocked []domain.DependentResponse
+	for _, d := range *dependents {
+		vr, err := utils.ParseVersionRange(d.VersionRange)
+		if err != nil || !versionInRange(node.Version, vr) {
+			continue
+		}
+
+		satisfied := false
+		for _, v := range *versions {
+			if v.ID != node.ID && !v.Yanked && versionInRange(v.Version, vr) {
+				satisfied = true
+				break
+			}
+		}
+		if !satisfied {
+			blocked = append(blocked, d)
+		}
+	}
+
+	if len(blocked) > 0 {
+		return &domain.VersionRequiredError{Dependents: blocked}
+	}
+	return nil
+}
+
+func (m *modVersionService) GetModVersion(c context.Context, id string) (*domain.ModVersionResponse, error) {
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	return m.modVersionRepo.GetModVersion(ctx, id)
+}
+
+// GetManifestPrefill 按上传文件的清单生成发布表单的预填内容
+func (m *modVersionService) GetManifestPrefill(c context.Context, fileID string, userID string, role string) (*domain.ManifestPrefill, error) {
+	parseID, err := strconv.ParseUint(fileID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	// 只有上传者与管理员可以读取,其他用户的文件按不存在处理
+	if role != "admin" {
+		sf, err := m.sfRepo.GetStorageFile(ctx, fileID)
+		if err != nil || strconv.FormatUint(sf.UserID, 10) != userID {
+			return nil, custom.DataNotExistError
+		}
+	}
+
+	manifests, err := m.manifest.manifestRepo.GetFileManifests(ctx, []uint{uint(parseID)})
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, custom.DataNotExistError
+	}
+
+	return m.manifest.prefill(ctx, &manifests[0])
+}
+
+func (m *modVersionService) GetVersionContents(c context.Context, id string) (*[]domain.ModVersionContents, error) {
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	return m.modVersionRepo.GetVersionContents(ctx, id)
+}
+
+// YankModVersion 撤回版本,保留记录与文件;其他模组必需该版本时需force
+func (m *modVersionService) YankModVersion(c context.Context, id string, userID string, reason string, force bool) error {
+	return m.setYanked(c, id, userID, reason, true, force)
+}
+
+// UnyankModVersion 恢复已撤回的版本
+func (m *modVersionService) UnyankModVersion(c context.Context, id string, userID string, reason string) error {
+	return m.setYanked(c, id, userID, reason, false, true)
+}
+
+func (m *modVersionService) setYanked(c context.Context, id string, userID string, reason string, yanked bool, force bool) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return errors.New("reason is required")
+	}
+	if len(reason) > domain.MaxYankReasonLength {
+		return domain.ErrYankReasonTooLong
+	}
+
+	parseUserID, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	modVersion, err := m.modVersionRepo.GetModVersion(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if modVersion.Yanked == yanked {
+		if yanked {
+			return domain.ErrVersionYanked
+		}
+		return domain.ErrVersionNotYanked
+	}
+
+	if yanked && !force {
+		if err := m.checkRequiredBy(ctx, modVersion.ID); err != nil {
+			return err
+		}
+	}
+
+	return m.modVersionRepo.SetYanked(ctx, modVersion.ID, &domain.ModVersionYankLog{
+		UserID: uint(parseUserID),
+		Yanked: yanked,
+		Reason: reason,
+	})
+}
+
+// 更新日志摘要的最大字符数
+const changeLogExcerptLength = 280
+
+// 截取更新日志开头作为摘要
+func changeLogExcerpt(changeLog string) string {
+	changeLog = strings.TrimSpace(changeLog)
+	runes := []rune(changeLog)
+	if len(runes) <= changeLogExcerptLength {
+		return changeLog
+	}
+	return strings.TrimSpace(string(runes[:changeLogExcerptLength])) + "…"
+}
+
+// CheckUpdates 批量检查已安装模组的可用更新
+// 所有模组的版本一次查出后在内存中按渠道与游戏版本筛选,条目数量不影响查询次数
+func (m *modVersionService) CheckUpdates(c context.Context, req *domain.UpdateCheckRequest) (*[]domain.UpdateCheckResult, error) {
+	if len(req.Items) > domain.UpdateCheckMaxItems {
+		return nil, fmt.Errorf("%w: at most %d", domain.ErrTooManyUpdateItems, domain.UpdateCheckMaxItems)
+	}
+
+	params := &domain.ModVersionQuery{
+		Channel:     req.Channel,
+		GameVersion: req.GameVersion,
+	}
+	if params.Channel == "" {
+		params.Channel = domain.ChannelStable
+	}
+	if domain.ChannelRank(params.Channel) < 0 {
+		return nil, fmt.Errorf("%w: %q", domain.ErrUnknownChannel, req.Channel)
+	}
+
+	modIDs := make([]uint, 0, len(req.Items))
+	seen := make(map[uint]struct{}, len(req.Items))
+	for _, item := range req.Items {
+		if _, ok := seen[item.ModID]; ok {
+			continue
+		}
+		seen[item.ModID] = struct{}{}
+		modIDs = append(modIDs, item.ModID)
+	}
+
+	ctx, cancel := context.WithTimeout(c, m.timeout)
+	defer cancel()
+
+	modVersions, err := m.modVersionRepo.GetUpdateVersions(ctx, modIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byMod := make(map[uint][]domain.ModVersionResponse, len(modIDs))
+	for _, v := range *modVersions {
+		byMod[v.ModID] = append(byMod[v.ModID], v)
+	}
+
+	// 同一模组的最新版本只计算一次
+	latest := make(map[uint]*domain.ModVersionResponse, len(byMod))
+	for modID, versions := range byMod {
+		list, err := filterVersions(versions, params, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) > 0 {
+			latest[modID] = &list[0]
+		}
+	}
+
+	results := make([]domain.UpdateCheckResult, 0, len(req.Items))
+	for _, item := range req.Items {
+		result := domain.UpdateCheckResult{ModID: item.ModID}
+
+		versions, ok := byMod[item.ModID]
+		if !ok {
+			result.Error = "mod not found or has no versions"
+			results = append(results, result)
+			continue
+		}
+
+		if installed := installedVersion(versions, &item); installed != nil {
+			result.InstalledID = installed.ID
+			result.InstalledVersion = installed.Version
+			result.InstalledYanked = installed.Yanked
+		} else if item.FileHash != "" && strings.TrimSpace(item.Version) == "" && hasUnhashedFiles(versions) {
+			// 早期上传的文件在校验任务补全哈希前无法按哈希识别
+			result.Error = "file hashes of this mod are not available yet, report the installed version instead"
+		} else if item.FileHash != "" || item.Version != "" {
+			result.Error = "installed version not recognized"
+		}
+
+		if v, ok := latest[item.ModID]; ok {
+			m.setDownloadURLs(v.Files)
+			result.Latest = &domain.UpdateCandidate{
+				ID:        v.ID,
+				Version:   v.Version,
+				Channel:   v.Channel,
+				ChangeLog: changeLogExcerpt(v.ChangeLog),
+				CreatedAt: v.CreatedAt,
+				Files:     v.Files,
+			}
+			// 已安装版本被撤回时,即使最新版本号不高于它也提示更换
+			result.UpdateAvailable = result.InstalledID != 0 && v.ID != result.InstalledID &&
+				(utils.CompareVersionStrings(v.Version, result.InstalledVersion) > 0 || result.InstalledYanked)
+		}
+
+		results = append(results, result)
+	}
+
+	return &results, nil
+}
+
+// 按文件哈希或版本号找到已安装的版本,哈希未匹配时再按版本号查找
+func installedVersion(versions []domain.ModVersionResponse, item *domain.UpdateCheckItem) *domain.ModVersionResponse {
+	if hash := strings.ToLower(strings.TrimSpace(item.FileHash)); hash != "" {
+		for i := range versions {
+			for _, f := range versions[i].Files {
+				if f.File.SHA256 != "" && f.File.SHA256 == hash {
+					return &versions[i]
+				}
+			}
+		}
+	}
+
+	version := strings.TrimSpace(item.Version)
+	if version == "" {
+		return nil
+	}
+	for i := range versions {
+		if versions[i].Version == version {
+			return &versions[i]
+		}
+	}
+	// 客户端上报的版本号格式可能不同,如 v1.2 与 1.2.0
+	if isSemver(version) {
+		for i := range versions {
+			if isSemver(versions[i].Version) && utils.CompareVersionStrings(versions[i].Version, version) == 0 {
+				return &versions[i]
+			}
+		}
+	}
+	return nil
+}
+
+// 是否有尚未记录哈希的文件
+func hasUnhashedFiles(versions []domain.ModVersionResponse) bool {
+	for _, v := range versions {
+		for _, f := range v.Files {
+			if f.File.SHA256 == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
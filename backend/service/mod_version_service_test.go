@@ -0,0 +1,100 @@
+package service
+
+import (
+	"ModVerse/bootstrap"
+	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeVersionRepo struct {
+	domain.ModVersionRepository
+	versions []domain.ModVersionResponse
+}
+
+func (f *fakeVersionRepo) GetModVersions(c context.Context, modID string) (*[]domain.ModVersionResponse, int64, error) {
+	versions := append([]domain.ModVersionResponse(nil), f.versions...)
+	return &versions, int64(len(versions)), nil
+}
+
+// 列表中的下载地址为版本下载接口,不下发需要签名的存储地址
+func TestGetModVersionsReturnsDownloadLinks(t *testing.T) {
+	env := &bootstrap.Env{}
+	env.App.Host = "http://localhost"
+	env.App.Port = "3000"
+	m := &modVersionService{
+		modVersionRepo: &fakeVersionRepo{versions: []domain.ModVersionResponse{{
+			ID:         5,
+			Version:    "1.0.0",
+			Channel:    domain.ChannelStable,
+			ScanStatus: domain.ScanStatusClean,
+			Files: []domain.ModVersionFileResponse{
+				{ID: 11, ModVersionID: 5, Role: domain.FileRoleMain, File: domain.DownloadFileResponse{URL: "http://localhost:3000/api/download/blobs/ab/abc.zip?filename=mod.zip"}},
+				{ID: 12, ModVersionID: 5, Role: "docs", File: domain.DownloadFileResponse{}},
+			},
+		}}},
+		env:     env,
+		timeout: time.Second,
+	}
+
+	list, _, err := m.GetModVersions(context.Background(), "1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := (*list)[0]
+	const want = "http://localhost:3000/api/mod_version/5/download?file_id=11"
+	if got := v.Files[0].File.URL; got != want {
+		t.Errorf("file url = %q, want %q", got, want)
+	}
+	if v.File == nil || v.File.URL != want {
+		t.Errorf("main file = %+v, want url %q", v.File, want)
+	}
+	if got := v.Files[1].File.URL; got != "" {
+		t.Errorf("quarantined file url = %q, want empty", got)
+	}
+}
+
+func (f *fakeVersionRepo) GetVersionOwner(c context.Context, id string) (uint64, error) {
+	for _, v := range f.versions {
+		if strconv.FormatUint(uint64(v.ID), 10) == id {
+			return 3, nil
+		}
+	}
+	return 0, custom.DataNotExistError
+}
+
+// 修改游戏版本与修改版本其他内容使用同一所有者检查
+func TestGameVersionCheckVersionOwner(t *testing.T) {
+	s := &gameVersionService{
+		modVersionRepo: &fakeVersionRepo{versions: []domain.ModVersionResponse{{ID: 5}}},
+		timeout:        time.Second,
+	}
+	ctx := context.Background()
+
+	if err := s.CheckVersionOwner(ctx, "5", "3", "user"); err != nil {
+		t.Errorf("owner check error = %v", err)
+	}
+	if err := s.CheckVersionOwner(ctx, "5", "4", "user"); !errors.Is(err, domain.ErrNotVersionOwner) {
+		t.Errorf("other user error = %v, want ErrNotVersionOwner", err)
+	}
+	if err := s.CheckVersionOwner(ctx, "5", "4", "admin"); err != nil {
+		t.Errorf("admin error = %v", err)
+	}
+	if err := s.CheckVersionOwner(ctx, "6", "3", "user"); !errors.Is(err, custom.DataNotExistError) {
+		t.Errorf("missing version error = %v, want DataNotExistError", err)
+	}
+}
+
+// 条目数上限由UpdateCheckMaxItems统一限制,超出时在查询前拒绝
+func TestCheckUpdatesLimitsItems(t *testing.T) {
+	m := &modVersionService{timeout: time.Second}
+	req := &domain.UpdateCheckRequest{Items: make([]domain.UpdateCheckItem, domain.UpdateCheckMaxItems+1)}
+
+	if _, err := m.CheckUpdates(context.Background(), req); !errors.Is(err, domain.ErrTooManyUpdateItems) {
+		t.Errorf("CheckUpdates error = %v, want ErrTooManyUpdateItems", err)
+	}
+}
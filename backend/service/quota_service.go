@@ -0,0 +1,102 @@
+package service
+
+import (
+	"ModVerse/bootstrap"
+	"ModVerse/domain"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type quotaService struct {
+	quotaRepo domain.QuotaRepository
+	env       *bootstrap.Env
+	timeout   time.Duration
+}
+
+func NewQuotaService(r domain.QuotaRepository, env *bootstrap.Env, timeout time.Duration) domain.QuotaService {
+	return &quotaService{
+		quotaRepo: r,
+		env:       env,
+		timeout:   timeout,
+	}
+}
+
+// 单独设置的配额优先于角色配置
+func (s *quotaService) applyQuota(usage *domain.StorageUsage) {
+	if usage.QuotaOverride != nil {
+		usage.Quota = *usage.QuotaOverride
+		return
+	}
+	usage.Quota = bootstrap.RoleQuota(s.env, usage.Role)
+}
+
+func (s *quotaService) GetUsage(c context.Context, userID string) (*domain.StorageUsage, error) {
+	parseID, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	usage, err := s.quotaRepo.GetUserUsage(ctx, parseID)
+	if err != nil {
+		return nil, err
+	}
+	s.applyQuota(usage)
+
+	return usage, nil
+}
+
+// CheckQuota 按上传前声明的大小检查,相同内容是否已存储在接收完成前无法得知,按新内容计算
+// 检查与创建记录之间并发的上传可能同时通过,创建记录时按返回的配额在事务中再检查一次
+func (s *quotaService) CheckQuota(c context.Context, userID uint64, size int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	usage, err := s.quotaRepo.GetUserUsage(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	s.applyQuota(usage)
+
+	if usage.Quota > 0 && usage.Used+size > usage.Quota {
+		return 0, fmt.Errorf("%w: %d of %d bytes used, the upload needs %d more", domain.ErrQuotaExceeded, usage.Used, usage.Quota, size)
+	}
+
+	return usage.Quota, nil
+}
+
+func (s *quotaService) GetTopConsumers(c context.Context, limit int) (*[]domain.StorageUsage, error) {
+	if limit <= 0 {
+		limit = domain.QuotaTopDefault
+	}
+	limit = min(limit, domain.QuotaTopMax)
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	usages, err := s.quotaRepo.GetTopUsage(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range *usages {
+		s.applyQuota(&(*usages)[i])
+	}
+
+	return usages, nil
+}
+
+func (s *quotaService) SetUserQuota(c context.Context, userID string, quota *int64) error {
+	parseID, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.quotaRepo.SetUserQuota(ctx, parseID, quota)
+}
@@ -63,7 +63,7 @@ func (s *reportService) GetReport(c context.Context, id string) (*domain.ReportR
 	return report, nil
 }
 
-func (s *reportService) GetReports(c context.Context, params *domain.ReportQuery) (*[]domain.ReportResponse, int64, error) {
+func (s *reportService) GetReports(c context.Context, params *domain.ReportQuery) (*[]domain.ReportResponse, int64, string, error) {
 	ctx, cancel := context.WithTimeout(c, s.timeout)
 	defer cancel()
 
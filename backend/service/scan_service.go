@@ -0,0 +1,208 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const scanLockKeyPrefix = "uploads:scan:lock:"
+const scanPendingLockKey = "uploads:scan:pending"
+
+// 扫描单个文件的锁,需覆盖扫描与隔离的全部时间
+const scanLockTTL = 30 * time.Minute
+
+// 每轮补扫的文件数,上传后立即扫描失败(如clamd不可用)的文件由补扫重试
+const scanPendingBatch = 20
+
+type scanService struct {
+	storageFileRepo domain.StorageFileRepository
+	reportRepo      domain.ReportRepository
+	redisRepo       domain.RedisRepository
+	storage         domain.Storage
+	scanner         domain.Scanner
+	timeout         time.Duration
+}
+
+func NewScanService(r domain.StorageFileRepository, rr domain.ReportRepository, rd domain.RedisRepository, st domain.Storage, sc domain.Scanner, timeout time.Duration) domain.ScanService {
+	return &scanService{
+		storageFileRepo: r,
+		reportRepo:      rr,
+		redisRepo:       rd,
+		storage:         st,
+		scanner:         sc,
+		timeout:         timeout,
+	}
+}
+
+// ScanFile 扫描存储中的文件并记录结果,同一路径的全部记录共享结果
+// 发现恶意内容时将文件移入隔离目录,并为受影响的模组(或未使用的文件)创建举报供管理员处理
+// 超过扫描后端大小限制的文件记为无法扫描,同样举报,不再重试
+func (s *scanService) ScanFile(c context.Context, fileKey string) error {
+	locked, err := s.redisRepo.SetValueNX(c, scanLockKeyPrefix+fileKey, 1, scanLockTTL)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer s.redisRepo.DeleteValue(context.Background(), scanLockKeyPrefix+fileKey)
+
+	result, err := s.scanObject(c, fileKey)
+	if errors.Is(err, domain.ErrScanTooLarge) {
+		return s.markUnscannable(c, fileKey, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !result.Infected {
+		ctx, cancel := context.WithTimeout(c, s.timeout)
+		defer cancel()
+
+		_, err := s.storageFileRepo.SetScanResult(ctx, fileKey, fileKey, domain.ScanStatusClean, "")
+		return err
+	}
+
+	// 先复制到隔离目录,记录更新成功后再删除原文件,更新失败时原文件保留,补扫时重试
+	quarantineKey := domain.QuarantinePrefix + fileKey
+	if err := s.copyObject(c, fileKey, quarantineKey); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	files, err := s.storageFileRepo.GetStorageFilesByKey(ctx, fileKey)
+	if err != nil {
+		return err
+	}
+
+	versions, err := s.storageFileRepo.SetScanResult(ctx, fileKey, quarantineKey, domain.ScanStatusInfected, result.Signature)
+	if err != nil {
+		return err
+	}
+
+	// 期间有新的上传引用原路径时保留原文件,该记录仍为扫描中,由补扫处理
+	if err := deleteUnreferenced(ctx, s.storageFileRepo, s.storage, fileKey); err != nil {
+		log.Printf("scan: delete %s after quarantine: %v", fileKey, err)
+	}
+
+	log.Printf("scan: %s quarantined, signature %s", fileKey, result.Signature)
+	s.reportFiles(ctx, *files, *versions, fmt.Sprintf("malware scan: %s found in", result.Signature))
+
+	return nil
+}
+
+// 记录无法扫描的结果,文件保留原位置,下载由扫描状态拦截
+func (s *scanService) markUnscannable(c context.Context, fileKey string, scanErr error) error {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	files, err := s.storageFileRepo.GetStorageFilesByKey(ctx, fileKey)
+	if err != nil {
+		return err
+	}
+
+	versions, err := s.storageFileRepo.SetScanResult(ctx, fileKey, fileKey, domain.ScanStatusFailed, "")
+	if err != nil {
+		return err
+	}
+
+	log.Printf("scan: %s could not be scanned: %v", fileKey, scanErr)
+	s.reportFiles(ctx, *files, *versions, "malware scan: size limit exceeded for")
+
+	return nil
+}
+
+func (s *scanService) scanObject(c context.Context, key string) (*domain.ScanResult, error) {
+	r, err := s.storage.Get(c, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return s.scanner.Scan(c, r)
+}
+
+// 相同内容此前已被隔离时覆盖为同样的内容
+func (s *scanService) copyObject(c context.Context, key string, newKey string) error {
+	obj, err := s.storage.Stat(c, key)
+	if err != nil {
+		return err
+	}
+
+	r, err := s.storage.Get(c, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return s.storage.Put(c, newKey, r, obj.Size, "application/octet-stream")
+}
+
+// 每个受影响的模组创建一条举报,文件尚未被版本使用时举报文件本身,reason为举报原因的开头
+func (s *scanService) reportFiles(c context.Context, files []domain.StorageFile, versions []domain.ScanAffectedVersion, reason string) {
+	var names []string
+	for _, sf := range files {
+		names = append(names, sf.FileName)
+	}
+	fileNames := strings.Join(names, ", ")
+
+	var reports []domain.Report
+	reported := make(map[uint]bool, len(versions))
+	for _, v := range versions {
+		if reported[v.ModID] {
+			continue
+		}
+		reported[v.ModID] = true
+		reports = append(reports, domain.Report{
+			Type:   "mod",
+			Target: v.ModID,
+			Reason: fmt.Sprintf("%s %s (version %s)", reason, fileNames, v.Version),
+		})
+	}
+	if len(reports) == 0 {
+		for _, sf := range files {
+			reports = append(reports, domain.Report{
+				Type:   domain.ReportTypeFile,
+				Target: sf.ID,
+				Reason: fmt.Sprintf("%s %s uploaded by user %d", reason, sf.FileName, sf.UserID),
+			})
+		}
+	}
+
+	for i := range reports {
+		if err := s.reportRepo.Create(c, &reports[i]); err != nil {
+			log.Printf("scan: failed to report %s: %v", fileNames, err)
+		}
+	}
+}
+
+// ScanPending 补扫仍处于扫描中的文件,多个实例同时运行时只有一个执行
+func (s *scanService) ScanPending(c context.Context) error {
+	locked, err := s.redisRepo.SetValueNX(c, scanPendingLockKey, 1, scanLockTTL)
+	if err != nil || !locked {
+		return err
+	}
+	defer s.redisRepo.DeleteValue(context.Background(), scanPendingLockKey)
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	keys, err := s.storageFileRepo.GetScanningKeys(ctx, scanPendingBatch)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := s.ScanFile(c, key); err != nil {
+			log.Printf("scan: %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeScanner struct {
+	result *domain.ScanResult
+	err    error
+}
+
+func (f *fakeScanner) Scan(c context.Context, r io.Reader) (*domain.ScanResult, error) {
+	io.Copy(io.Discard, r)
+	return f.result, f.err
+}
+
+type fakeLockRepo struct {
+	domain.RedisRepository
+	locks map[string]bool
+}
+
+func (f *fakeLockRepo) SetValueNX(c context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	if f.locks[key] {
+		return false, nil
+	}
+	f.locks[key] = true
+	return true, nil
+}
+
+func (f *fakeLockRepo) SetValue(c context.Context, key string, value any, ttl time.Duration) error {
+	return nil
+}
+
+func (f *fakeLockRepo) DeleteValue(c context.Context, key string) error {
+	delete(f.locks, key)
+	return nil
+}
+
+type fakeReportRepo struct {
+	domain.ReportRepository
+	reports []domain.Report
+}
+
+func (f *fakeReportRepo) Create(c context.Context, report *domain.Report) error {
+	f.reports = append(f.reports, *report)
+	return nil
+}
+
+// 同一路径下的文件记录与扫描结果
+type fakeScanRepo struct {
+	domain.StorageFileRepository
+	files     map[string][]domain.StorageFile
+	resultErr error
+}
+
+func (f *fakeScanRepo) GetStorageFilesByKey(c context.Context, key string) (*[]domain.StorageFile, error) {
+	files := f.files[key]
+	return &files, nil
+}
+
+func (f *fakeScanRepo) SetScanResult(c context.Context, key string, newKey string, status string, signature string) (*[]domain.ScanAffectedVersion, error) {
+	if f.resultErr != nil {
+		return nil, f.resultErr
+	}
+	files := f.files[key]
+	delete(f.files, key)
+	for i := range files {
+		files[i].FileKey = newKey
+		files[i].ScanStatus = status
+		files[i].ScanSignature = signature
+	}
+	f.files[newKey] = append(f.files[newKey], files...)
+	return &[]domain.ScanAffectedVersion{}, nil
+}
+
+func (f *fakeScanRepo) DeleteUnreferencedObject(c context.Context, key string, del func() error) error {
+	if len(f.files[key]) > 0 {
+		return nil
+	}
+	return del()
+}
+
+func newScanTestService(t *testing.T, scanner domain.Scanner, key string) (*scanService, *fakeScanRepo, *fakeReportRepo, string) {
+	t.Helper()
+	root := t.TempDir()
+	store := storage.NewLocalStorage(root, "")
+	if err := store.Put(context.Background(), key, strings.NewReader("payload"), 7, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &fakeScanRepo{files: map[string][]domain.StorageFile{
+		key: {{FileKey: key, FileName: "mod.zip", UserID: 3, ScanStatus: domain.ScanStatusScanning}},
+	}}
+	reports := &fakeReportRepo{}
+	s := &scanService{
+		storageFileRepo: repo,
+		reportRepo:      reports,
+		redisRepo:       &fakeLockRepo{locks: map[string]bool{}},
+		storage:         store,
+		scanner:         scanner,
+		timeout:         time.Second,
+	}
+	return s, repo, reports, root
+}
+
+func exists(root string, key string) bool {
+	_, err := os.Stat(filepath.Join(root, key))
+	return err == nil
+}
+
+func TestScanFileQuarantinesInfected(t *testing.T) {
+	const key = "blobs/ab/abc.zip"
+	s, repo, reports, root := newScanTestService(t, &fakeScanner{result: &domain.ScanResult{Infected: true, Signature: "Eicar"}}, key)
+
+	if err := s.ScanFile(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+
+	quarantineKey := domain.QuarantinePrefix + key
+	if files := repo.files[quarantineKey]; len(files) != 1 || files[0].ScanStatus != domain.ScanStatusInfected {
+		t.Errorf("files at quarantine key = %+v", files)
+	}
+	if exists(root, key) || !exists(root, quarantineKey) {
+		t.Errorf("object not moved: original %t, quarantine %t", exists(root, key), exists(root, quarantineKey))
+	}
+	if len(reports.reports) != 1 || !strings.Contains(reports.reports[0].Reason, "Eicar") {
+		t.Errorf("reports = %+v", reports.reports)
+	}
+}
+
+// 记录更新失败时原文件保留,补扫时可以重试
+func TestScanFileKeepsObjectWhenResultFails(t *testing.T) {
+	const key = "blobs/ab/abc.zip"
+	s, repo, _, root := newScanTestService(t, &fakeScanner{result: &domain.ScanResult{Infected: true, Signature: "Eicar"}}, key)
+	repo.resultErr = errors.New("duplicate key")
+
+	if err := s.ScanFile(context.Background(), key); err == nil {
+		t.Fatal("ScanFile succeeded, want error")
+	}
+	if !exists(root, key) {
+		t.Error("original object deleted although the scan result was not recorded")
+	}
+	if repo.files[key][0].ScanStatus != domain.ScanStatusScanning {
+		t.Errorf("scan status = %s, want scanning", repo.files[key][0].ScanStatus)
+	}
+}
+
+// 超过clamd大小限制的文件记为无法扫描并举报,不再留在扫描中
+func TestScanFileMarksOversizeFailed(t *testing.T) {
+	const key = "blobs/cd/big.zip"
+	tooLarge := fmt.Errorf("%w: clamd: INSTREAM size limit exceeded. ERROR", domain.ErrScanTooLarge)
+	s, repo, reports, root := newScanTestService(t, &fakeScanner{err: tooLarge}, key)
+
+	if err := s.ScanFile(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if files := repo.files[key]; len(files) != 1 || files[0].ScanStatus != domain.ScanStatusFailed {
+		t.Errorf("files = %+v, want failed", files)
+	}
+	if !exists(root, key) {
+		t.Error("unscannable object should stay in place")
+	}
+	if len(reports.reports) != 1 || reports.reports[0].Type != domain.ReportTypeFile {
+		t.Errorf("reports = %+v", reports.reports)
+	}
+}
+
+func TestScanFileRetriesOnScannerError(t *testing.T) {
+	const key = "blobs/cd/other.zip"
+	s, repo, reports, _ := newScanTestService(t, &fakeScanner{err: errors.New("connection refused")}, key)
+
+	if err := s.ScanFile(context.Background(), key); err == nil {
+		t.Fatal("ScanFile succeeded, want error")
+	}
+	if repo.files[key][0].ScanStatus != domain.ScanStatusScanning || len(reports.reports) != 0 {
+		t.Errorf("scanner outage changed state: %+v, reports %d", repo.files[key], len(reports.reports))
+	}
+}
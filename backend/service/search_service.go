@@ -0,0 +1,183 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/utils"
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	suggestDefaultLimit  = 10
+	suggestMaxLimit      = 50
+	suggestRefreshPeriod = 5 * time.Minute
+)
+
+// 匹配权重: 完整匹配 > 名称前缀 > 词首前缀 > 拼音/首字母前缀
+const (
+	weightExact   = 4.0
+	weightPrefix  = 3.0
+	weightWord    = 2.0
+	weightPinyin  = 1.5
+	weightInitial = 1.0
+)
+
+// 联想词索引快照,刷新时整体替换
+type suggestIndex struct {
+	trie    *utils.PrefixTrie
+	sources []domain.SuggestSource
+}
+
+type searchService struct {
+	searchRepo domain.SearchRepository
+	timeout    time.Duration
+
+	mu     sync.RWMutex
+	index  *suggestIndex
+	stopCh chan struct{}
+}
+
+func NewSearchService(r domain.SearchRepository, timeout time.Duration) domain.SearchService {
+	return &searchService{
+		searchRepo: r,
+		timeout:    timeout,
+		index:      &suggestIndex{trie: utils.NewPrefixTrie(suggestMaxLimit)},
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start 立即构建索引并定时从数据库刷新
+func (s *searchService) Start(c context.Context) {
+	go func() {
+		if err := s.Refresh(c); err != nil {
+			log.Printf("suggest index refresh failed: %v", err)
+		}
+
+		ticker := time.NewTicker(suggestRefreshPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Refresh(c); err != nil {
+					log.Printf("suggest index refresh failed: %v", err)
+				}
+			case <-s.stopCh:
+				return
+			case <-c.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定时刷新
+func (s *searchService) Stop() {
+	close(s.stopCh)
+}
+
+// Refresh 从数据库重建联想词索引
+func (s *searchService) Refresh(c context.Context) error {
+	// 全量读取耗时较长,不使用请求超时
+	sources, err := s.searchRepo.GetSuggestSources(c)
+	if err != nil {
+		return err
+	}
+
+	index := &suggestIndex{
+		trie:    utils.NewPrefixTrie(suggestMaxLimit),
+		sources: *sources,
+	}
+
+	for i, src := range index.sources {
+		name := strings.TrimSpace(src.Name)
+		if name == "" {
+			continue
+		}
+
+		insert := func(key string, weight float64) {
+			index.trie.Insert(key, utils.TrieEntry{Index: i, Weight: weight, Score: suggestScore(weight, src.Popularity)})
+		}
+
+		insert(name, weightPrefix)
+
+		// 名称中的每个单词也可作为前缀命中
+		words := strings.Fields(name)
+		for _, w := range words[1:] {
+			insert(w, weightWord)
+		}
+
+		full, initials := utils.PinyinKeys(name)
+		if full != "" {
+			insert(full, weightPinyin)
+			insert(initials, weightInitial)
+		}
+	}
+
+	s.mu.Lock()
+	s.index = index
+	s.mu.Unlock()
+
+	return nil
+}
+
+// 热度取对数,避免热门条目压过更贴近输入的结果
+func suggestScore(weight float64, popularity int64) float64 {
+	return weight*10 + math.Log1p(math.Max(float64(popularity), 0))
+}
+
+func (s *searchService) Suggest(c context.Context, params *domain.SuggestQuery) (*[]domain.SuggestResponse, error) {
+	prefix := strings.TrimSpace(params.Q)
+	if prefix == "" {
+		return &[]domain.SuggestResponse{}, nil
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = suggestDefaultLimit
+	}
+	if limit > suggestMaxLimit {
+		limit = suggestMaxLimit
+	}
+
+	s.mu.RLock()
+	index := s.index
+	s.mu.RUnlock()
+
+	found := index.trie.Search(prefix)
+
+	results := make([]domain.SuggestResponse, 0, len(found))
+	for _, e := range found {
+		src := index.sources[e.Index]
+
+		score := e.Score
+		if strings.EqualFold(strings.TrimSpace(src.Name), prefix) {
+			score = suggestScore(weightExact, src.Popularity)
+		}
+
+		results = append(results, domain.SuggestResponse{
+			Type:  src.Type,
+			ID:    src.ID,
+			Name:  src.Name,
+			Score: math.Round(score*100) / 100,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Name < results[j].Name
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return &results, nil
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+type storageGCService struct {
+	gcRepo          domain.StorageGCRepository
+	storageFileRepo domain.StorageFileRepository
+	redisRepo       domain.RedisRepository
+	storage         domain.Storage
+	gracePeriod     time.Duration
+	timeout         time.Duration
+}
+
+func NewStorageGCService(r domain.StorageGCRepository, sfr domain.StorageFileRepository, rd domain.RedisRepository, st domain.Storage, gracePeriod time.Duration, timeout time.Duration) domain.StorageGCService {
+	return &storageGCService{
+		gcRepo:          r,
+		storageFileRepo: sfr,
+		redisRepo:       rd,
+		storage:         st,
+		gracePeriod:     gracePeriod,
+		timeout:         timeout,
+	}
+}
+
+const gcReportKey = "files:gc:report"
+const gcLockKey = "files:gc:lock"
+const gcLockTTL = 6 * time.Hour
+const gcReportTTL = 7 * 24 * time.Hour
+const gcBatchSize = 200
+
+// StartGC 在后台执行一次存储清理,同一时间只运行一个任务,进度与结果通过GetGCReport查看
+func (s *storageGCService) StartGC(c context.Context, dryRun bool) error {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	locked, err := s.redisRepo.SetValueNX(ctx, gcLockKey, 1, gcLockTTL)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return domain.ErrGCRunning
+	}
+
+	report := newGCReport(dryRun)
+	if err := s.saveGCReport(ctx, report); err != nil {
+		s.redisRepo.DeleteValue(ctx, gcLockKey)
+		return err
+	}
+
+	go s.runGC(report)
+
+	return nil
+}
+
+// RunGC 定时清理,已有任务运行时跳过
+func (s *storageGCService) RunGC(c context.Context) error {
+	locked, err := s.redisRepo.SetValueNX(c, gcLockKey, 1, gcLockTTL)
+	if err != nil || !locked {
+		return err
+	}
+
+	s.runGC(newGCReport(false))
+	return nil
+}
+
+func newGCReport(dryRun bool) *domain.GCReport {
+	return &domain.GCReport{
+		Status:         domain.FileVerifyRunning,
+		DryRun:         dryRun,
+		StartedAt:      time.Now(),
+		TempFiles:      []domain.GCFile{},
+		Blobs:          []domain.GCObject{},
+		StrayObjects:   []domain.GCObject{},
+		MissingObjects: []domain.GCFile{},
+	}
+}
+
+func (s *storageGCService) runGC(report *domain.GCReport) {
+	ctx := context.Background()
+	defer s.redisRepo.DeleteValue(ctx, gcLockKey)
+
+	if err := s.collect(ctx, report); err != nil {
+		report.Status = domain.FileVerifyFailed
+		report.Error = err.Error()
+	} else {
+		report.Status = domain.FileVerifyFinished
+	}
+	finishedAt := time.Now()
+	report.FinishedAt = &finishedAt
+
+	if err := s.saveGCReport(ctx, report); err != nil {
+		log.Printf("save storage gc report failed: %v", err)
+	}
+	log.Printf("storage gc %s (dry run: %t): temp files %d, blobs %d, stray objects %d, missing objects %d, freed %d bytes",
+		report.Status, report.DryRun, len(report.TempFiles), len(report.Blobs), len(report.StrayObjects), len(report.MissingObjects), report.FreedBytes)
+}
+
+// 依次清理过期的临时上传、没有记录使用的blob与存储中没有记录的对象,并找出记录存在但对象已丢失的文件
+// 只清理保留期之前的内容,避免与正在进行的上传冲突
+func (s *storageGCService) collect(ctx context.Context, report *domain.GCReport) error {
+	before := report.StartedAt.Add(-s.gracePeriod)
+
+	if err := s.collectTempFiles(ctx, report, before); err != nil {
+		return err
+	}
+
+	objects, err := s.listObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	live, err := s.checkFiles(ctx, report, objects)
+	if err != nil {
+		return err
+	}
+
+	blobKeys, err := s.collectBlobs(ctx, report, live, before)
+	if err != nil {
+		return err
+	}
+
+	return s.collectStrayObjects(ctx, report, objects, live, blobKeys, before)
+}
+
+func (s *storageGCService) collectTempFiles(ctx context.Context, report *domain.GCReport, before time.Time) error {
+	var afterID uint
+	for {
+		batchCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		files, err := s.gcRepo.GetExpiredTempFiles(batchCtx, before, afterID, gcBatchSize)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if len(*files) == 0 {
+			return nil
+		}
+
+		for _, sf := range *files {
+			afterID = sf.ID
+			if !report.DryRun {
+				released, err := s.releaseTempFile(ctx, sf.ID)
+				if err != nil {
+					return err
+				}
+				if !released {
+					continue
+				}
+			}
+			report.TempFiles = append(report.TempFiles, gcFile(&sf, sf.FileKey))
+			report.FreedBytes += sf.FileSize
+		}
+
+		if err := s.saveGCReport(ctx, report); err != nil {
+			log.Printf("save storage gc report failed: %v", err)
+		}
+	}
+}
+
+// 删除临时文件记录及不再被引用的对象,文件在此期间被引用时返回false
+func (s *storageGCService) releaseTempFile(ctx context.Context, id uint) (bool, error) {
+	releaseCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	orphans, err := s.gcRepo.ReleaseTempFile(releaseCtx, id)
+	cancel()
+	if errors.Is(err, custom.DataNotExistError) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, key := range orphans {
+		s.deleteObject(ctx, key)
+	}
+
+	return true, nil
+}
+
+// 删除失败的对象没有记录,下次清理时作为无记录的对象处理
+func (s *storageGCService) deleteObject(ctx context.Context, key string) {
+	if err := deleteUnreferenced(ctx, s.storageFileRepo, s.storage, key); err != nil {
+		log.Printf("storage gc: delete %s: %v", key, err)
+	}
+}
+
+func (s *storageGCService) listObjects(ctx context.Context) (map[string]*domain.StorageObject, error) {
+	objects := make(map[string]*domain.StorageObject)
+	err := s.storage.List(ctx, "", func(obj *domain.StorageObject) error {
+		if !gcExcluded(obj.Key) {
+			objects[obj.Key] = obj
+		}
+		return nil
+	})
+	return objects, err
+}
+
+func gcExcluded(key string) bool {
+	for _, prefix := range domain.GCExcludedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 核对全部文件记录(含图片版本)对应的对象是否存在,返回仍被记录使用的路径
+// 本次任务开始后创建的记录不核对,其对象可能不在列出的结果中
+func (s *storageGCService) checkFiles(ctx context.Context, report *domain.GCReport, objects map[string]*domain.StorageObject) (map[string]bool, error) {
+	live := make(map[string]bool)
+
+	var afterID uint
+	for {
+		batchCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		files, err := s.storageFileRepo.GetStorageFilesAfter(batchCtx, afterID, gcBatchSize)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if len(*files) == 0 {
+			return live, nil
+		}
+
+		for _, sf := range *files {
+			afterID = sf.ID
+
+			keys := []string{sf.FileKey}
+			for _, v := range sf.Variants {
+				keys = append(keys, v.FileKey)
+			}
+
+			for _, key := range keys {
+				live[key] = true
+				if sf.CreatedAt.After(report.StartedAt) {
+					continue
+				}
+
+				exists, err := s.objectExists(ctx, key, objects)
+				if err != nil {
+					return nil, err
+				}
+				if !exists {
+					report.MissingObjects = append(report.MissingObjects, gcFile(&sf, key))
+				}
+			}
+		}
+	}
+}
+
+// 按列出的结果判断,未列出的目录(如隔离目录)逐个查询
+func (s *storageGCService) objectExists(ctx context.Context, key string, objects map[string]*domain.StorageObject) (bool, error) {
+	if !gcExcluded(key) {
+		_, ok := objects[key]
+		return ok, nil
+	}
+
+	if _, err := s.storage.Stat(ctx, key); err != nil {
+		if errors.Is(err, domain.ErrObjectNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// 清理没有文件记录使用、且保留期内没有变动的blob,返回全部blob的路径
+func (s *storageGCService) collectBlobs(ctx context.Context, report *domain.GCReport, live map[string]bool, before time.Time) (map[string]bool, error) {
+	blobKeys := make(map[string]bool)
+
+	var afterID uint
+	for {
+		batchCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		blobs, err := s.gcRepo.GetBlobsAfter(batchCtx, afterID, gcBatchSize)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if len(*blobs) == 0 {
+			return blobKeys, nil
+		}
+
+		for _, blob := range *blobs {
+			afterID = blob.ID
+			blobKeys[blob.FileKey] = true
+			if live[blob.FileKey] || blob.UpdatedAt.After(before) {
+				continue
+			}
+
+			if !report.DryRun {
+				deleteCtx, cancel := context.WithTimeout(ctx, s.timeout)
+				deleted, err := s.gcRepo.DeleteUnreferencedBlob(deleteCtx, blob.FileKey)
+				cancel()
+				if err != nil {
+					return nil, err
+				}
+				if !deleted {
+					continue
+				}
+				s.deleteObject(ctx, blob.FileKey)
+			}
+
+			report.Blobs = append(report.Blobs, domain.GCObject{
+				Key:     blob.FileKey,
+				Size:    blob.FileSize,
+				ModTime: blob.UpdatedAt,
+			})
+			report.FreedBytes += blob.FileSize
+		}
+
+		if err := s.saveGCReport(ctx, report); err != nil {
+			log.Printf("save storage gc report failed: %v", err)
+		}
+	}
+}
+
+// 清理存储中既没有blob也没有文件记录的对象,如写入后创建记录失败或删除记录后删除文件失败留下的对象
+// 以及旧版本上传路径下已没有记录使用的文件
+func (s *storageGCService) collectStrayObjects(ctx context.Context, report *domain.GCReport, objects map[string]*domain.StorageObject,
+	live map[string]bool, blobKeys map[string]bool, before time.Time) error {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		if !live[key] && !blobKeys[key] && objects[key].ModTime.Before(before) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		obj := objects[key]
+		if !report.DryRun {
+			checkCtx, cancel := context.WithTimeout(ctx, s.timeout)
+			referenced, err := s.gcRepo.IsKeyReferenced(checkCtx, key)
+			cancel()
+			if err != nil {
+				return err
+			}
+			if referenced {
+				continue
+			}
+			s.deleteObject(ctx, key)
+		}
+
+		report.StrayObjects = append(report.StrayObjects, domain.GCObject{
+			Key:     obj.Key,
+			Size:    obj.Size,
+			ModTime: obj.ModTime,
+		})
+		report.FreedBytes += obj.Size
+	}
+
+	return nil
+}
+
+func gcFile(sf *domain.StorageFile, key string) domain.GCFile {
+	return domain.GCFile{
+		ID:        sf.ID,
+		UserID:    sf.UserID,
+		FileKey:   key,
+		FileName:  sf.FileName,
+		FileSize:  sf.FileSize,
+		CreatedAt: sf.CreatedAt,
+	}
+}
+
+func (s *storageGCService) saveGCReport(c context.Context, report *domain.GCReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return s.redisRepo.SetValue(c, gcReportKey, data, gcReportTTL)
+}
+
+// GetGCReport 最近一次存储清理的报告
+func (s *storageGCService) GetGCReport(c context.Context) (*domain.GCReport, error) {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	data, err := s.redisRepo.GetValue(ctx, gcReportKey)
+	if err != nil {
+		return nil, custom.DataNotExistError
+	}
+
+	var report domain.GCReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
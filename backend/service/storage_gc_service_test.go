@@ -0,0 +1,117 @@
+package service
+
+import (
+	"ModVerse/domain"
+	"ModVerse/internal/storage"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 没有临时文件与blob的清理仓库,referenced为仍有记录的路径
+type fakeGCRepo struct {
+	domain.StorageGCRepository
+	referenced map[string]bool
+}
+
+func (f *fakeGCRepo) GetExpiredTempFiles(c context.Context, before time.Time, afterID uint, limit int) (*[]domain.StorageFile, error) {
+	return &[]domain.StorageFile{}, nil
+}
+
+func (f *fakeGCRepo) GetBlobsAfter(c context.Context, afterID uint, limit int) (*[]domain.StorageBlob, error) {
+	return &[]domain.StorageBlob{}, nil
+}
+
+func (f *fakeGCRepo) IsKeyReferenced(c context.Context, key string) (bool, error) {
+	return f.referenced[key], nil
+}
+
+type fakeGCFileRepo struct {
+	domain.StorageFileRepository
+	files []domain.StorageFile
+}
+
+func (f *fakeGCFileRepo) GetStorageFilesAfter(c context.Context, afterID uint, limit int) (*[]domain.StorageFile, error) {
+	var batch []domain.StorageFile
+	for _, sf := range f.files {
+		if sf.ID > afterID {
+			batch = append(batch, sf)
+		}
+	}
+	return &batch, nil
+}
+
+func (f *fakeGCFileRepo) DeleteUnreferencedObject(c context.Context, key string, del func() error) error {
+	return del()
+}
+
+// 旧版本上传路径下的对象同样参与对账,隔离目录与分片上传目录不处理
+func TestStorageGCReconcilesLegacyKeys(t *testing.T) {
+	root := t.TempDir()
+	store := storage.NewLocalStorage(root, "")
+	keys := []string{
+		"blobs/aa/live.zip",
+		"blobs/bb/stray.zip",
+		"mods/2023/05/legacy-live.zip",
+		"mods/2023/05/legacy-stray.zip",
+		"quarantine/blobs/cc/bad.zip",
+		"tmp/tus/upload.part",
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for _, key := range keys {
+		if err := store.Put(context.Background(), key, strings.NewReader("x"), 1, ""); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(filepath.Join(root, key), old, old)
+	}
+
+	files := &fakeGCFileRepo{files: []domain.StorageFile{
+		{FileKey: "blobs/aa/live.zip"},
+		{FileKey: "mods/2023/05/legacy-live.zip"},
+		{FileKey: "mods/2023/05/legacy-missing.zip"},
+	}}
+	for i := range files.files {
+		files.files[i].ID = uint(i + 1)
+		files.files[i].CreatedAt = old
+	}
+
+	s := &storageGCService{
+		gcRepo:          &fakeGCRepo{},
+		storageFileRepo: files,
+		storage:         store,
+		gracePeriod:     24 * time.Hour,
+		timeout:         time.Second,
+	}
+
+	report := newGCReport(false)
+	if err := s.collect(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+
+	var stray []string
+	for _, obj := range report.StrayObjects {
+		stray = append(stray, obj.Key)
+	}
+	sort.Strings(stray)
+	if want := []string{"blobs/bb/stray.zip", "mods/2023/05/legacy-stray.zip"}; strings.Join(stray, ",") != strings.Join(want, ",") {
+		t.Errorf("stray objects = %v, want %v", stray, want)
+	}
+	if len(report.MissingObjects) != 1 || report.MissingObjects[0].FileKey != "mods/2023/05/legacy-missing.zip" {
+		t.Errorf("missing objects = %+v", report.MissingObjects)
+	}
+
+	for _, key := range []string{"blobs/aa/live.zip", "mods/2023/05/legacy-live.zip", "quarantine/blobs/cc/bad.zip", "tmp/tus/upload.part"} {
+		if _, err := os.Stat(filepath.Join(root, key)); err != nil {
+			t.Errorf("%s removed: %v", key, err)
+		}
+	}
+	for _, key := range stray {
+		if _, err := os.Stat(filepath.Join(root, key)); !os.IsNotExist(err) {
+			t.Errorf("stray object %s not removed", key)
+		}
+	}
+}
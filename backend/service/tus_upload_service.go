@@ -0,0 +1,349 @@
+package service
+
+import (
+	"ModVerse/bootstrap"
+	"ModVerse/domain"
+	"ModVerse/internal/custom"
+	"ModVerse/internal/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tusUploadKeyPrefix = "uploads:tus:"
+const tusLockKeyPrefix = "uploads:tus:lock:"
+const tusCleanupLockKey = "uploads:tus:cleanup"
+
+// 未完成的上传超过该时间没有新的分片即过期,每次写入分片后顺延
+const tusUploadTTL = 24 * time.Hour
+
+// 写入分片与完成上传的锁,完成时需要写入存储后端,留出足够时间
+const tusLockTTL = 10 * time.Minute
+
+// 过期分片文件的清理间隔
+const tusCleanupInterval = time.Hour
+
+// 分片文件目录
+func (s *uploadService) tusDir() string {
+	return filepath.Join(bootstrap.StorageRoot(s.env), "tmp", "tus")
+}
+
+func (s *uploadService) tusPartPath(id string) string {
+	return filepath.Join(s.tusDir(), id+".part")
+}
+
+// CreateTusUpload 创建上传会话并预先创建空的分片文件
+func (s *uploadService) CreateTusUpload(c context.Context, userID string, req *domain.CreateTusUploadRequest) (*domain.TusUpload, error) {
+	parseID, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Length < 0 || req.Length > domain.TusMaxSize {
+		return nil, domain.ErrTusSizeExceeded
+	}
+
+	metadata, err := utils.ParseUploadMetadata(req.Metadata)
+	if err != nil {
+		return nil, domain.ErrTusInvalidMetadata
+	}
+
+	uploadType := metadata["type"]
+	if uploadType == "" {
+		uploadType = req.UploadType
+	}
+	gameID := req.GameID
+	if v, ok := metadata["game_id"]; ok {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, domain.ErrTusInvalidMetadata
+		}
+		gameID = uint(id)
+	}
+	fileName := filepath.Base(metadata["filename"])
+	if fileName == "." || fileName == "/" {
+		fileName = "upload"
+	}
+
+	// 创建时即按声明的长度与文件名检查,内容类型在上传完成后识别
+	if _, err := s.checkUpload(uploadType, fileName, req.Length); err != nil {
+		return nil, err
+	}
+	// 配额在创建时按声明的长度检查一次,完成时按实际大小再检查
+	if _, err := s.quotaService.CheckQuota(c, parseID, req.Length); err != nil {
+		return nil, err
+	}
+
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return nil, err
+	}
+
+	upload := &domain.TusUpload{
+		ID:         hex.EncodeToString(randomBytes),
+		UserID:     parseID,
+		UploadType: uploadType,
+		GameID:     gameID,
+		FileName:   fileName,
+		SHA256:     strings.ToLower(metadata["sha256"]),
+		Metadata:   req.Metadata,
+		Length:     req.Length,
+		ExpiresAt:  time.Now().Add(tusUploadTTL),
+	}
+
+	if err := os.MkdirAll(s.tusDir(), os.ModePerm); err != nil {
+		return nil, err
+	}
+	part, err := os.Create(s.tusPartPath(upload.ID))
+	if err != nil {
+		return nil, err
+	}
+	part.Close()
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	if err := s.saveTusUpload(ctx, upload); err != nil {
+		os.Remove(s.tusPartPath(upload.ID))
+		return nil, err
+	}
+
+	s.cleanupTusUploads(ctx)
+
+	// 空文件无需上传分片,直接完成
+	if upload.Length == 0 {
+		if err := s.finishTusUpload(c, upload); err != nil {
+			return nil, err
+		}
+	}
+
+	return upload, nil
+}
+
+// GetTusUpload 查询上传进度,只能查询自己的上传
+func (s *uploadService) GetTusUpload(c context.Context, id string, userID string) (*domain.TusUpload, error) {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.loadTusUpload(ctx, id, userID)
+}
+
+// WriteTusChunk 在offset处追加分片,offset须等于已上传的字节数
+// 写满声明的长度后校验哈希并转为存储文件
+func (s *uploadService) WriteTusChunk(c context.Context, id string, userID string, offset int64, chunk []byte) (*domain.TusUpload, error) {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	upload, err := s.loadTusUpload(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := s.redisRepo.SetValueNX(ctx, tusLockKeyPrefix+id, 1, tusLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, domain.ErrTusUploadLocked
+	}
+	defer s.redisRepo.DeleteValue(context.Background(), tusLockKeyPrefix+id)
+
+	// 加锁后重新读取,避免使用并发请求写入前的进度
+	upload, err = s.loadTusUpload(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.FileID != 0 {
+		return nil, domain.ErrTusUploadFinished
+	}
+	if offset != upload.Offset {
+		return nil, domain.ErrTusOffsetMismatch
+	}
+	if offset+int64(len(chunk)) > upload.Length {
+		return nil, domain.ErrTusSizeExceeded
+	}
+
+	if err := s.appendTusPart(upload, chunk); err != nil {
+		return nil, err
+	}
+
+	upload.Offset += int64(len(chunk))
+	upload.ExpiresAt = time.Now().Add(tusUploadTTL)
+	if err := s.saveTusUpload(ctx, upload); err != nil {
+		return nil, err
+	}
+
+	if upload.Offset == upload.Length {
+		if err := s.finishTusUpload(c, upload); err != nil {
+			return nil, err
+		}
+	}
+
+	return upload, nil
+}
+
+// 截断到已确认的长度后追加,丢弃上次写入后未记录进度的内容
+func (s *uploadService) appendTusPart(upload *domain.TusUpload, chunk []byte) error {
+	part, err := os.OpenFile(s.tusPartPath(upload.ID), os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		return custom.DataNotExistError
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := part.Truncate(upload.Offset); err != nil {
+		part.Close()
+		return err
+	}
+	if _, err := part.Seek(upload.Offset, io.SeekStart); err != nil {
+		part.Close()
+		return err
+	}
+	if _, err := part.Write(chunk); err != nil {
+		part.Close()
+		return err
+	}
+
+	return part.Close()
+}
+
+// 校验哈希并将分片文件转为存储文件,哈希不一致时丢弃整个上传
+func (s *uploadService) finishTusUpload(c context.Context, upload *domain.TusUpload) error {
+	partPath := s.tusPartPath(upload.ID)
+
+	hash, err := utils.HashFile(partPath)
+	if err != nil {
+		return err
+	}
+	if upload.SHA256 != "" && upload.SHA256 != hash {
+		s.removeTusUpload(c, upload.ID)
+		return domain.ErrTusChecksumMismatch
+	}
+
+	sf := &domain.StorageFile{
+		UserID:   upload.UserID,
+		FileName: upload.FileName,
+		FileSize: upload.Length,
+		SHA256:   hash,
+	}
+	if err := s.saveUpload(c, sf, partPath, upload.UploadType, upload.GameID); err != nil {
+		return err
+	}
+	os.Remove(partPath)
+
+	// 保留会话到过期,客户端可通过查询拿到文件ID
+	upload.FileID = sf.ID
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.saveTusUpload(ctx, upload)
+}
+
+// DeleteTusUpload 终止上传并删除已上传的分片,已完成的上传只删除会话
+func (s *uploadService) DeleteTusUpload(c context.Context, id string, userID string) error {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	if _, err := s.loadTusUpload(ctx, id, userID); err != nil {
+		return err
+	}
+
+	locked, err := s.redisRepo.SetValueNX(ctx, tusLockKeyPrefix+id, 1, tusLockTTL)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return domain.ErrTusUploadLocked
+	}
+	defer s.redisRepo.DeleteValue(context.Background(), tusLockKeyPrefix+id)
+
+	return s.removeTusUpload(ctx, id)
+}
+
+func (s *uploadService) removeTusUpload(c context.Context, id string) error {
+	if err := os.Remove(s.tusPartPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return s.redisRepo.DeleteValue(c, tusUploadKeyPrefix+id)
+}
+
+// 会话不存在、已过期或不属于该用户时均视为不存在
+func (s *uploadService) loadTusUpload(c context.Context, id string, userID string) (*domain.TusUpload, error) {
+	data, err := s.redisRepo.GetValue(c, tusUploadKeyPrefix+id)
+	if err != nil {
+		return nil, custom.DataNotExistError
+	}
+
+	var upload domain.TusUpload
+	if err := json.Unmarshal([]byte(data), &upload); err != nil {
+		return nil, err
+	}
+
+	if strconv.FormatUint(upload.UserID, 10) != userID {
+		return nil, custom.DataNotExistError
+	}
+
+	return &upload, nil
+}
+
+func (s *uploadService) saveTusUpload(c context.Context, upload *domain.TusUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	return s.redisRepo.SetValue(c, tusUploadKeyPrefix+upload.ID, data, time.Until(upload.ExpiresAt))
+}
+
+// 会话过期后Redis中的记录自动删除,分片文件在创建新上传时顺带清理,每个间隔最多执行一次
+func (s *uploadService) cleanupTusUploads(c context.Context) {
+	locked, err := s.redisRepo.SetValueNX(c, tusCleanupLockKey, 1, tusCleanupInterval)
+	if err != nil || !locked {
+		return
+	}
+
+	go func() {
+		entries, err := os.ReadDir(s.tusDir())
+		if err != nil {
+			log.Printf("tus cleanup: %v", err)
+			return
+		}
+
+		ctx := context.Background()
+		var removed int
+		for _, entry := range entries {
+			id, ok := strings.CutSuffix(entry.Name(), ".part")
+			if !ok {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < tusUploadTTL {
+				continue
+			}
+
+			// 会话仍存在说明正在完成或刚刚续期,留到下次
+			if _, err := s.redisRepo.GetValue(ctx, tusUploadKeyPrefix+id); err == nil {
+				continue
+			}
+
+			if err := os.Remove(filepath.Join(s.tusDir(), entry.Name())); err == nil {
+				removed++
+			}
+		}
+
+		if removed > 0 {
+			log.Printf("tus cleanup: removed %d expired uploads", removed)
+		}
+	}()
+}
@@ -3,24 +3,45 @@ package service
 import (
 	"ModVerse/bootstrap"
 	"ModVerse/domain"
+	"ModVerse/internal/custom"
 	"ModVerse/internal/utils"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
 	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 )
 
 type uploadService struct {
 	storageFileRepo domain.StorageFileRepository
+	redisRepo       domain.RedisRepository
+	storage         domain.Storage
+	scanService     domain.ScanService // 未配置扫描后端时为nil
+	quotaService    domain.QuotaService
+	metadata        domain.MetadataRegistry
 	env             *bootstrap.Env
 	timeout         time.Duration
 }
 
-func NewUploadService(r domain.StorageFileRepository, env *bootstrap.Env, t time.Duration) domain.UploadService {
+func NewUploadService(r domain.StorageFileRepository, rd domain.RedisRepository, st domain.Storage, ss domain.ScanService, qs domain.QuotaService, mr domain.MetadataRegistry, env *bootstrap.Env, t time.Duration) domain.UploadService {
 	return &uploadService{
 		storageFileRepo: r,
+		redisRepo:       rd,
+		storage:         st,
+		scanService:     ss,
+		quotaService:    qs,
+		metadata:        mr,
 		env:             env,
 		timeout:         t,
 	}
@@ -28,113 +49,367 @@ func NewUploadService(r domain.StorageFileRepository, env *bootstrap.Env, t time
 
 const api = "/api/data/"
 const download = "/api/download/"
-const modPath = "../../storage/mods/"
-const userPath = "../../storage/users/"
-const gamePath = "../../storage/games/"
-const postPath = "../../storage/posts/"
 
-func formatPath(path string) string {
-	index := strings.Index(path, "storage")
-	result := path[index+len("storage")+1:]
-
-	return result
-}
-
-func (s *uploadService) UploadPostImage(c context.Context, file *multipart.FileHeader, id string) (string, error) {
-	path, err := utils.UploadFile(file, postPath+id)
+// 保存上传文件并创建记录,相同内容的文件共享同一份存储
+// 下载地址带上原始文件名,供下载时还原文件名
+func (s *uploadService) storeFile(c context.Context, file *multipart.FileHeader, id string, uploadType string, gameID uint) (*domain.StorageFile, error) {
+	//id转为uint64类型
+	parseID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	//id转为uint64类型
-	parseID, err := strconv.ParseUint(id, 10, 64)
+	// 写入临时文件前先按声明的大小检查,超出限制或配额的不占用磁盘
+	if _, err := s.checkUpload(uploadType, file.Filename, file.Size); err != nil {
+		return nil, err
+	}
+	if _, err := s.quotaService.CheckQuota(c, parseID, file.Size); err != nil {
+		return nil, err
+	}
+
+	tempFile, hash, err := utils.SaveTemp(file, filepath.Join(bootstrap.StorageRoot(s.env), "tmp"))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer os.Remove(tempFile)
 
 	sf := &domain.StorageFile{
 		UserID:   parseID,
-		FileKey:  path,
 		FileName: file.Filename,
 		FileSize: file.Size,
-		MIMEType: "image/*",
-		URL:      s.env.App.Host + ":" + s.env.App.Port + api + formatPath(path),
+		SHA256:   hash,
 	}
 
-	ctx, cancel := context.WithTimeout(c, s.timeout)
-	defer cancel()
+	return sf, s.saveUpload(c, sf, tempFile, uploadType, gameID)
+}
 
-	if err := s.storageFileRepo.CreateStorageFile(ctx, sf); err != nil {
-		utils.DeleteFile(path)
-		return "", err
+// 按上传类型的限制检查大小与扩展名,在接收文件内容前即可调用
+func (s *uploadService) checkUpload(uploadType string, fileName string, size int64) (domain.UploadPolicy, error) {
+	policy, ok := bootstrap.UploadPolicy(s.env, uploadType)
+	if !ok {
+		return policy, domain.ErrInvalidUploadType
 	}
 
-	return sf.URL, nil
+	if err := utils.CheckUploadSize(policy, uploadType, size); err != nil {
+		return policy, err
+	}
+	if err := utils.CheckUploadExtension(policy, uploadType, fileName); err != nil {
+		return policy, err
+	}
+
+	return policy, nil
 }
 
-func (s *uploadService) UploadFile(c context.Context, file *multipart.FileHeader, id string, uploadType string) (uint, error) {
-	var p string
-	var mimeType string
-	var newPath string
+// 按上传类型保存已计算哈希的临时文件,临时文件由调用方删除
+// 先按文件内容识别真实类型,图片经过处理后保存,其他文件记录识别出的MIME类型
+// gameID不为0时按该游戏注册的提取器读取压缩包内的清单
+func (s *uploadService) saveUpload(c context.Context, sf *domain.StorageFile, tempFile string, uploadType string, gameID uint) error {
+	prefix, isImage, err := uploadTarget(uploadType)
+	if err != nil {
+		return err
+	}
 
-	switch uploadType {
-	case "mod_file":
-		mimeType = "application/zip"
-		p = modPath + id
-	case "mod_cover":
-		mimeType = "image/*"
-		p = modPath + id
-	case "game_logo":
-		mimeType = "image/*"
-		p = gamePath + id
-	case "user_avatar":
-		mimeType = "image/*"
-		p = userPath + id
-	default:
-		return 0, errors.New("invalid upload type")
+	policy, err := s.checkUpload(uploadType, sf.FileName, sf.FileSize)
+	if err != nil {
+		return err
 	}
 
-	if uploadType == "mod_file" {
-		path, err := utils.UploadDownloadFile(file, p)
+	quota, err := s.quotaService.CheckQuota(c, sf.UserID, sf.FileSize)
+	if err != nil {
+		return err
+	}
+
+	mimeType, err := utils.SniffUploadType(policy, uploadType, sf.FileName, tempFile)
+	if err != nil {
+		return err
+	}
+
+	// 被模组、版本、游戏或用户资料引用前为临时文件,超过保留期由存储清理删除
+	// 文章图片只通过地址引用,不作为临时文件
+	sf.IsTemp = uploadType != domain.UploadPostImage
+	sf.UploadType = uploadType
+	sf.ScanStatus = domain.ScanStatusClean
+	if isImage {
+		return s.saveImage(c, sf, tempFile, quota)
+	}
+
+	// 压缩包在保存前逐个解压检查,清单随文件记录一起保存
+	archive, err := utils.InspectArchive(tempFile, mimeType, sf.FileName, bootstrap.ArchiveLimits(s.env))
+	if err != nil {
+		return err
+	}
+
+	sf.MIMEType = mimeType
+	sf.Archive = archive
+	if archive != nil && gameID != 0 {
+		manifest, err := s.metadata.Extract(gameID, tempFile, archive.Format)
 		if err != nil {
-			return 0, err
+			// 清单只用于预填,读取失败不影响上传
+			log.Printf("metadata: %s: %v", sf.FileName, err)
 		}
-		newPath = path
-	} else {
-		path, err := utils.UploadFile(file, p)
-		if err != nil {
-			return 0, err
+		sf.Manifest = manifest
+	}
+	// 图片经过重新编码,其他文件在扫描完成前不可下载;相同内容已被隔离时直接拒绝
+	if s.scanService != nil {
+		if err := s.checkInfected(c, sf.SHA256); err != nil {
+			return err
 		}
-		newPath = path
+		sf.ScanStatus = domain.ScanStatusScanning
+	}
+	if err := s.saveBlob(c, sf, tempFile, mimeType, prefix, quota); err != nil {
+		return err
 	}
 
-	parseID, err := strconv.ParseUint(id, 10, 64)
+	if sf.ScanStatus == domain.ScanStatusScanning {
+		go s.scanUpload(sf.FileKey)
+	}
+	return nil
+}
+
+func (s *uploadService) checkInfected(c context.Context, sha256 string) error {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	infected, err := s.storageFileRepo.GetInfectedFileByHash(ctx, sha256)
+	if errors.Is(err, custom.DataNotExistError) {
+		return nil
+	}
 	if err != nil {
-		return 0, err
+		return err
 	}
+	return fmt.Errorf("%w: content matches a file flagged as %s", domain.ErrFileInfected, infected.ScanSignature)
+}
 
-	sf := &domain.StorageFile{
-		UserID:   parseID,
-		FileKey:  newPath,
-		FileName: file.Filename,
-		FileSize: file.Size,
-		MIMEType: mimeType,
-		URL:      s.env.App.Host + ":" + s.env.App.Port + api + formatPath(newPath),
+// 上传完成后立即扫描,失败的由定时补扫重试
+func (s *uploadService) scanUpload(key string) {
+	if err := s.scanService.ScanFile(context.Background(), key); err != nil {
+		log.Printf("scan: %s: %v", key, err)
 	}
+}
 
-	if uploadType == "mod_file" {
-		sf.URL = s.env.App.Host + ":" + s.env.App.Port + download + formatPath(newPath)
+// 将临时文件写入存储并创建记录,quota为0时不限制
+func (s *uploadService) saveBlob(c context.Context, sf *domain.StorageFile, tempFile string, contentType string, prefix string, quota int64) error {
+	f, err := os.Open(tempFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	key := utils.BlobKey(sf.SHA256, filepath.Ext(sf.FileName))
+	created, err := s.putBlob(c, key, sf.SHA256, f, sf.FileSize, contentType)
+	if err != nil {
+		return err
+	}
+	defer s.releaseBlob(c, key)
+
+	sf.FileKey = key
+	sf.URL = s.fileURL(prefix, key)
+	if prefix == download {
+		sf.URL += "?filename=" + url.QueryEscape(sf.FileName)
 	}
 
 	ctx, cancel := context.WithTimeout(c, s.timeout)
 	defer cancel()
 
-	if err := s.storageFileRepo.CreateStorageFile(ctx, sf); err != nil {
-		utils.DeleteFile(newPath)
-		return 0, err
+	// 相同内容已扫描通过时沿用结果
+	if !created && sf.ScanStatus == domain.ScanStatusScanning {
+		if existing, err := s.storageFileRepo.GetStorageFileByKey(ctx, key); err == nil && existing.ScanStatus == domain.ScanStatusClean {
+			sf.ScanStatus = domain.ScanStatusClean
+		}
+	}
+
+	// 创建失败时由releaseBlob删除没有其他记录使用的对象
+	return s.storageFileRepo.CreateStorageFile(ctx, sf, quota)
+}
+
+func (s *uploadService) fileURL(prefix string, key string) string {
+	return s.env.App.Host + ":" + s.env.App.Port + prefix + key
+}
+
+// 相同内容已存储时跳过写入,返回是否新写入了对象
+// 判断前先占用blob的一个引用,并发删除同一内容时不会回收该对象;成功时调用方在创建记录后调用releaseBlob归还
+// 写入大文件耗时较长,不使用数据库操作的超时时间
+func (s *uploadService) putBlob(c context.Context, key string, sha256 string, r io.Reader, size int64, contentType string) (bool, error) {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	err := s.storageFileRepo.ReserveBlob(ctx, key, sha256, size)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := s.storage.Stat(c, key); err == nil {
+		return false, nil
+	} else if !errors.Is(err, domain.ErrObjectNotExist) {
+		s.releaseBlob(c, key)
+		return false, err
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	if err := s.storage.Put(c, key, r, size, contentType); err != nil {
+		s.releaseBlob(c, key)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// 归还putBlob占用的引用,引用归零(记录创建失败且没有其他记录使用)时删除对象,失败只记录日志
+func (s *uploadService) releaseBlob(c context.Context, key string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c), s.timeout)
+	defer cancel()
+
+	orphans, err := s.storageFileRepo.ReleaseBlob(ctx, key)
+	if err != nil {
+		log.Printf("release blob %s failed: %v", key, err)
+		return
+	}
+	for _, orphan := range orphans {
+		if err := deleteUnreferenced(ctx, s.storageFileRepo, s.storage, orphan); err != nil {
+			log.Printf("delete blob %s failed: %v", orphan, err)
+		}
 	}
+}
 
-	return sf.ID, nil
+// 删除引用归零的对象,删除前在数据库中确认期间没有新的上传重新引用同一内容
+func deleteUnreferenced(ctx context.Context, repo domain.StorageFileRepository, store domain.Storage, key string) error {
+	return repo.DeleteUnreferencedObject(ctx, key, func() error {
+		return store.Delete(ctx, key)
+	})
+}
+
+// 图片版本规格,按最大宽高等比缩小
+var imageVariants = []struct {
+	name   string
+	width  int
+	height int
+}{
+	{domain.ImageVariantThumb, 160, 160},
+	{domain.ImageVariantCard, 480, 480},
+	{domain.ImageVariantFull, 1920, 1920},
+}
+
+// 每个版本都生成JPEG,JPEG的full版本作为记录本身的文件;WebP为无损编码,仅在比JPEG小时保存
+var imageFormats = []string{utils.ImageFormatJPEG, utils.ImageFormatWebP}
+
+var imageExtensions = map[string]string{
+	utils.ImageFormatWebP: ".webp",
+	utils.ImageFormatJPEG: ".jpg",
+}
+
+// 不保存原图:校验真实格式与尺寸后重新编码,去除EXIF、GPS等元数据,并生成各尺寸版本
+func (s *uploadService) saveImage(c context.Context, sf *domain.StorageFile, tempFile string, quota int64) error {
+	f, err := os.Open(tempFile)
+	if err != nil {
+		return err
+	}
+	img, err := utils.DecodeImage(f, domain.ImageMaxPixels)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// 无论记录是否创建成功都归还占用的引用,失败时没有其他记录使用的对象随之删除
+	var reserved []string
+	defer func() {
+		for _, key := range reserved {
+			s.releaseBlob(c, key)
+		}
+	}()
+
+	variants := make([]domain.StorageFileVariant, 0, len(imageVariants)*len(imageFormats))
+	for _, spec := range imageVariants {
+		resized := utils.FitImage(img, spec.width, spec.height)
+
+		encoded := make(map[string]*bytes.Buffer, len(imageFormats))
+		for _, format := range imageFormats {
+			var buf bytes.Buffer
+			if err := utils.EncodeImage(&buf, resized, format); err != nil {
+				return err
+			}
+			encoded[format] = &buf
+		}
+
+		for _, format := range imageFormats {
+			data := encoded[format].Bytes()
+			if format == utils.ImageFormatWebP && len(data) >= encoded[utils.ImageFormatJPEG].Len() {
+				continue
+			}
+
+			variant, err := s.putImageVariant(c, resized, spec.name, format, data)
+			if err != nil {
+				return err
+			}
+			reserved = append(reserved, variant.FileKey)
+			variants = append(variants, *variant)
+
+			if spec.name == domain.ImageVariantFull && format == utils.ImageFormatJPEG {
+				sf.FileKey = variant.FileKey
+				sf.FileSize = variant.FileSize
+				sf.SHA256 = variant.SHA256
+				sf.URL = variant.URL
+			}
+		}
+	}
+	sf.MIMEType = "image/jpeg"
+	sf.Variants = variants
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.storageFileRepo.CreateStorageFile(ctx, sf, quota)
+}
+
+// 保存一个已编码的图片版本,占用的blob引用由调用方归还
+func (s *uploadService) putImageVariant(c context.Context, img image.Image, name string, format string, data []byte) (*domain.StorageFileVariant, error) {
+	hash, err := utils.HashReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	key := utils.BlobKey(hash, imageExtensions[format])
+	if _, err := s.putBlob(c, key, hash, bytes.NewReader(data), int64(len(data)), "image/"+format); err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	return &domain.StorageFileVariant{
+		Name:     name,
+		Format:   format,
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		FileKey:  key,
+		FileSize: int64(len(data)),
+		SHA256:   hash,
+		URL:      s.fileURL(api, key),
+	}, nil
+}
+
+func (s *uploadService) UploadPostImage(c context.Context, file *multipart.FileHeader, id string) (string, error) {
+	sf, err := s.storeFile(c, file, id, domain.UploadPostImage, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return sf.URL, nil
+}
+
+// 上传类型对应的访问路径,图片类上传需经过图片处理
+func uploadTarget(uploadType string) (prefix string, isImage bool, err error) {
+	switch uploadType {
+	case domain.UploadModFile:
+		return download, false, nil
+	case domain.UploadModCover, domain.UploadGameLogo, domain.UploadUserAvatar, domain.UploadPostImage:
+		return api, true, nil
+	default:
+		return "", false, domain.ErrInvalidUploadType
+	}
+}
+
+func (s *uploadService) UploadFile(c context.Context, file *multipart.FileHeader, id string, uploadType string, gameID uint) (*domain.StorageFile, error) {
+	return s.storeFile(c, file, id, uploadType, gameID)
 }
 
 func (s *uploadService) GetFile(c context.Context, id string) (*domain.StorageFile, error) {
@@ -155,18 +430,244 @@ func (s *uploadService) RemoveFile(c context.Context, id string) error {
 	ctx, cancel := context.WithTimeout(c, s.timeout)
 	defer cancel()
 
-	sf, err := s.storageFileRepo.GetStorageFile(ctx, id)
+	orphans, err := s.storageFileRepo.DeleteStorageFile(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if err := s.storageFileRepo.DeleteStorageFile(ctx, id); err != nil {
+	// 其他记录仍引用同一内容时保留文件
+	for _, orphan := range orphans {
+		if err := deleteUnreferenced(ctx, s.storageFileRepo, s.storage, orphan); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const fileVerifyReportKey = "files:verify:report"
+
+// 任务运行期间定期续期锁,进程异常退出后锁在TTL后失效
+const fileVerifyLockKey = "files:verify:lock"
+const fileVerifyLockTTL = 2 * time.Minute
+const fileVerifyReportTTL = 7 * 24 * time.Hour
+const fileVerifyBatchSize = 200
+
+// StartVerifyFiles 在后台重新计算全部存储内容的SHA-256并与记录比对
+// 同一时间只运行一个任务,进度与结果通过GetVerifyReport查看
+func (s *uploadService) StartVerifyFiles(c context.Context) error {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	// 锁的值为本次任务的随机标识,续期与释放前比对,锁过期后被其他任务取得时不再操作
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	owner := hex.EncodeToString(token)
+
+	locked, err := s.redisRepo.SetValueNX(ctx, fileVerifyLockKey, owner, fileVerifyLockTTL)
+	if err != nil {
 		return err
 	}
+	if !locked {
+		return domain.ErrFileVerifyRunning
+	}
 
-	if err := utils.DeleteFile(sf.FileKey); err != nil {
+	report := &domain.FileVerifyReport{
+		Status:     domain.FileVerifyRunning,
+		StartedAt:  time.Now(),
+		Missing:    []domain.FileVerifyIssue{},
+		Mismatched: []domain.FileVerifyIssue{},
+	}
+	if err := s.saveVerifyReport(ctx, report); err != nil {
+		s.redisRepo.DeleteValueIf(ctx, fileVerifyLockKey, owner)
 		return err
 	}
 
+	go s.verifyFiles(report, owner)
+
 	return nil
 }
+
+func (s *uploadService) verifyFiles(report *domain.FileVerifyReport, owner string) {
+	ctx := context.Background()
+	defer s.redisRepo.DeleteValueIf(ctx, fileVerifyLockKey, owner)
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		s.refreshVerifyLock(owner, stop)
+		close(stopped)
+	}()
+	// 停止续期后再释放锁
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	if err := s.verifyBatches(ctx, report); err != nil {
+		report.Status = domain.FileVerifyFailed
+		report.Error = err.Error()
+	} else {
+		report.Status = domain.FileVerifyFinished
+	}
+	finishedAt := time.Now()
+	report.FinishedAt = &finishedAt
+
+	if err := s.saveVerifyReport(ctx, report); err != nil {
+		log.Printf("save file verify report failed: %v", err)
+	}
+	log.Printf("file verify %s: checked %d, missing %d, mismatched %d, backfilled %d",
+		report.Status, report.Checked, len(report.Missing), len(report.Mismatched), report.Backfilled)
+}
+
+// 每隔TTL的三分之一续期一次校验任务的锁,直到stop关闭;锁已不属于本任务时停止续期
+func (s *uploadService) refreshVerifyLock(owner string, stop <-chan struct{}) {
+	ticker := time.NewTicker(fileVerifyLockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+			held, err := s.redisRepo.ExtendValue(ctx, fileVerifyLockKey, owner, fileVerifyLockTTL)
+			cancel()
+			if err != nil {
+				log.Printf("refresh file verify lock failed: %v", err)
+				continue
+			}
+			if !held {
+				log.Printf("file verify lock lost, stop refreshing")
+				return
+			}
+		}
+	}
+}
+
+// 先逐个校验blob,再为未记录哈希的旧文件补录哈希
+func (s *uploadService) verifyBatches(ctx context.Context, report *domain.FileVerifyReport) error {
+	var afterID uint
+	for {
+		batchCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		blobs, err := s.storageFileRepo.GetStorageBlobsAfter(batchCtx, afterID, fileVerifyBatchSize)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if len(*blobs) == 0 {
+			break
+		}
+
+		for _, blob := range *blobs {
+			afterID = blob.ID
+			s.verifyBlob(ctx, &blob, report)
+		}
+
+		// 每批保存一次进度
+		if err := s.saveVerifyReport(ctx, report); err != nil {
+			log.Printf("save file verify report failed: %v", err)
+		}
+	}
+
+	var afterKey string
+	for {
+		batchCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		keys, err := s.storageFileRepo.GetUnhashedKeysAfter(batchCtx, afterKey, fileVerifyBatchSize)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		for _, key := range keys {
+			afterKey = key
+			s.backfillHash(ctx, key, report)
+		}
+
+		if err := s.saveVerifyReport(ctx, report); err != nil {
+			log.Printf("save file verify report failed: %v", err)
+		}
+	}
+}
+
+func (s *uploadService) verifyBlob(ctx context.Context, blob *domain.StorageBlob, report *domain.FileVerifyReport) {
+	report.Checked++
+	issue := domain.FileVerifyIssue{ID: blob.ID, FileKey: blob.FileKey, Expected: blob.SHA256}
+
+	actual, err := s.hashObject(ctx, blob.FileKey)
+	if err != nil {
+		if !errors.Is(err, domain.ErrObjectNotExist) {
+			issue.Error = err.Error()
+		}
+		report.Missing = append(report.Missing, issue)
+		return
+	}
+
+	if actual != blob.SHA256 {
+		issue.Actual = actual
+		report.Mismatched = append(report.Mismatched, issue)
+	}
+}
+
+// 未记录哈希的旧文件不在blob表中,计算后补录到同一路径的全部记录
+func (s *uploadService) backfillHash(ctx context.Context, key string, report *domain.FileVerifyReport) {
+	report.Checked++
+
+	actual, err := s.hashObject(ctx, key)
+	if err != nil {
+		issue := domain.FileVerifyIssue{FileKey: key}
+		if !errors.Is(err, domain.ErrObjectNotExist) {
+			issue.Error = err.Error()
+		}
+		report.Missing = append(report.Missing, issue)
+		return
+	}
+
+	updateCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if err := s.storageFileRepo.UpdateStorageFileHash(updateCtx, key, actual); err != nil {
+		log.Printf("backfill hash of %s failed: %v", key, err)
+		return
+	}
+	report.Backfilled++
+}
+
+func (s *uploadService) hashObject(ctx context.Context, key string) (string, error) {
+	r, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	return utils.HashReader(r)
+}
+
+func (s *uploadService) saveVerifyReport(c context.Context, report *domain.FileVerifyReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return s.redisRepo.SetValue(c, fileVerifyReportKey, data, fileVerifyReportTTL)
+}
+
+// GetVerifyReport 最近一次文件校验的报告
+func (s *uploadService) GetVerifyReport(c context.Context) (*domain.FileVerifyReport, error) {
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	data, err := s.redisRepo.GetValue(ctx, fileVerifyReportKey)
+	if err != nil {
+		return nil, custom.DataNotExistError
+	}
+
+	var report domain.FileVerifyReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
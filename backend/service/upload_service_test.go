@@ -0,0 +1,280 @@
+package service
+
+import (
+	"ModVerse/bootstrap"
+	"ModVerse/domain"
+	"ModVerse/internal/storage"
+	"ModVerse/internal/utils"
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 只记录blob引用数的文件记录仓库,onCreate模拟创建记录期间的并发操作
+type fakeBlobRepo struct {
+	domain.StorageFileRepository
+	refs     map[string]int
+	onCreate func()
+	failWith error
+}
+
+func (f *fakeBlobRepo) ReserveBlob(c context.Context, key string, sha256 string, size int64) error {
+	f.refs[key]++
+	return nil
+}
+
+func (f *fakeBlobRepo) release(key string) []string {
+	f.refs[key]--
+	if f.refs[key] <= 0 {
+		delete(f.refs, key)
+		return []string{key}
+	}
+	return nil
+}
+
+func (f *fakeBlobRepo) ReleaseBlob(c context.Context, key string) ([]string, error) {
+	return f.release(key), nil
+}
+
+func (f *fakeBlobRepo) DeleteUnreferencedObject(c context.Context, key string, del func() error) error {
+	if f.refs[key] > 0 {
+		return nil
+	}
+	return del()
+}
+
+func (f *fakeBlobRepo) GetStorageFileByKey(c context.Context, key string) (*domain.StorageFile, error) {
+	return nil, errors.New("not found")
+}
+
+func (f *fakeBlobRepo) CreateStorageFile(c context.Context, sf *domain.StorageFile, quota int64) error {
+	if f.onCreate != nil {
+		f.onCreate()
+	}
+	if f.failWith != nil {
+		return f.failWith
+	}
+	f.refs[sf.FileKey]++
+	return nil
+}
+
+func newBlobTestService(t *testing.T, repo *fakeBlobRepo) (*uploadService, string) {
+	t.Helper()
+	root := t.TempDir()
+	return &uploadService{
+		storageFileRepo: repo,
+		storage:         storage.NewLocalStorage(root, ""),
+		env:             &bootstrap.Env{},
+		timeout:         time.Second,
+	}, root
+}
+
+func writeTemp(t *testing.T, content string) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := utils.HashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, hash
+}
+
+// 判断对象已存在后,另一条记录被删除使引用归零,对象不能被回收
+func TestSaveBlobSurvivesConcurrentRelease(t *testing.T) {
+	repo := &fakeBlobRepo{refs: map[string]int{}}
+	s, root := newBlobTestService(t, repo)
+
+	tempFile, hash := writeTemp(t, "mod content")
+	key := utils.BlobKey(hash, ".zip")
+
+	// 已有一条记录引用同一内容
+	if err := s.storage.Put(context.Background(), key, mustOpen(t, tempFile), 11, ""); err != nil {
+		t.Fatal(err)
+	}
+	repo.refs[key] = 1
+
+	repo.onCreate = func() {
+		for _, orphan := range repo.release(key) {
+			if err := deleteUnreferenced(context.Background(), repo, s.storage, orphan); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	sf := &domain.StorageFile{FileName: "mod.zip", FileSize: 11, SHA256: hash}
+	if err := s.saveBlob(context.Background(), sf, tempFile, "", download, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if repo.refs[key] != 1 {
+		t.Errorf("ref count = %d, want 1", repo.refs[key])
+	}
+	if _, err := os.Stat(filepath.Join(root, key)); err != nil {
+		t.Errorf("object removed while still referenced: %v", err)
+	}
+}
+
+func TestSaveBlobRemovesObjectOnCreateFailure(t *testing.T) {
+	repo := &fakeBlobRepo{refs: map[string]int{}, failWith: errors.New("insert failed")}
+	s, root := newBlobTestService(t, repo)
+
+	tempFile, hash := writeTemp(t, "other content")
+	key := utils.BlobKey(hash, ".zip")
+
+	sf := &domain.StorageFile{FileName: "mod.zip", FileSize: 13, SHA256: hash}
+	if err := s.saveBlob(context.Background(), sf, tempFile, "", download, 0); err == nil {
+		t.Fatal("saveBlob succeeded, want error")
+	}
+
+	if _, ok := repo.refs[key]; ok {
+		t.Errorf("reservation not released: %d", repo.refs[key])
+	}
+	if _, err := os.Stat(filepath.Join(root, key)); !os.IsNotExist(err) {
+		t.Errorf("object left behind after failed create: %v", err)
+	}
+}
+
+func mustOpen(t *testing.T, path string) *os.File {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+type fakeQuotaService struct {
+	domain.QuotaService
+	err error
+}
+
+func (f *fakeQuotaService) CheckQuota(c context.Context, userID uint64, size int64) (int64, error) {
+	return 0, f.err
+}
+
+func multipartFile(t *testing.T, name string, content string) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	w.Close()
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+// 超出配额时在写入临时文件前拒绝
+func TestStoreFileChecksQuotaBeforeSaving(t *testing.T) {
+	repo := &fakeBlobRepo{refs: map[string]int{}}
+	s, root := newBlobTestService(t, repo)
+	s.env.Storage.Root = root
+	s.quotaService = &fakeQuotaService{err: domain.ErrQuotaExceeded}
+
+	_, err := s.storeFile(context.Background(), multipartFile(t, "mod.zip", "mod content"), "1", domain.UploadModFile, 0)
+	if !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("storeFile error = %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "tmp")); !os.IsNotExist(err) {
+		t.Errorf("temp directory written before the quota check: %v", err)
+	}
+}
+
+// 按blob校验内容的文件记录仓库
+type fakeVerifyRepo struct {
+	domain.StorageFileRepository
+	blobs    []domain.StorageBlob
+	unhashed []string
+	hashes   map[string]string
+}
+
+func (f *fakeVerifyRepo) GetStorageBlobsAfter(c context.Context, afterID uint, limit int) (*[]domain.StorageBlob, error) {
+	var blobs []domain.StorageBlob
+	for _, blob := range f.blobs {
+		if blob.ID > afterID && len(blobs) < limit {
+			blobs = append(blobs, blob)
+		}
+	}
+	return &blobs, nil
+}
+
+func (f *fakeVerifyRepo) GetUnhashedKeysAfter(c context.Context, afterKey string, limit int) ([]string, error) {
+	var keys []string
+	for _, key := range f.unhashed {
+		if key > afterKey && len(keys) < limit {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeVerifyRepo) UpdateStorageFileHash(c context.Context, fileKey string, sha256 string) error {
+	f.hashes[fileKey] = sha256
+	return nil
+}
+
+// 每个blob只校验一次,未记录哈希的旧文件补录
+func TestVerifyBatchesChecksBlobs(t *testing.T) {
+	root := t.TempDir()
+	store := storage.NewLocalStorage(root, "")
+	ctx := context.Background()
+	put := func(key string, content string) string {
+		if err := store.Put(ctx, key, bytes.NewReader([]byte(content)), int64(len(content)), "application/octet-stream"); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := utils.HashReader(bytes.NewReader([]byte(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	goodHash := put("blobs/aa/good.jpg", "good")
+	put("blobs/bb/changed.webp", "changed")
+	legacyHash := put("files/legacy.zip", "legacy")
+
+	repo := &fakeVerifyRepo{
+		blobs: []domain.StorageBlob{
+			{ID: 1, FileKey: "blobs/aa/good.jpg", SHA256: goodHash},
+			{ID: 2, FileKey: "blobs/bb/changed.webp", SHA256: goodHash},
+			{ID: 3, FileKey: "blobs/cc/missing.jpg", SHA256: goodHash},
+		},
+		unhashed: []string{"files/legacy.zip"},
+		hashes:   map[string]string{},
+	}
+	s := &uploadService{storageFileRepo: repo, storage: store, redisRepo: &fakeLockRepo{locks: map[string]bool{}}, timeout: time.Second}
+
+	report := &domain.FileVerifyReport{}
+	if err := s.verifyBatches(ctx, report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Checked != 4 || report.Backfilled != 1 {
+		t.Errorf("checked %d, backfilled %d, want 4 and 1", report.Checked, report.Backfilled)
+	}
+	if len(report.Mismatched) != 1 || report.Mismatched[0].ID != 2 {
+		t.Errorf("mismatched = %+v, want blob 2", report.Mismatched)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].ID != 3 {
+		t.Errorf("missing = %+v, want blob 3", report.Missing)
+	}
+	if repo.hashes["files/legacy.zip"] != legacyHash {
+		t.Errorf("backfilled hash = %q, want %q", repo.hashes["files/legacy.zip"], legacyHash)
+	}
+}
@@ -3,7 +3,6 @@ package service
 import (
 	"ModVerse/bootstrap"
 	"ModVerse/domain"
-	"ModVerse/internal/utils"
 	"context"
 	"fmt"
 	"strconv"
@@ -15,13 +14,14 @@ type userService struct {
 	redisRepo   domain.RedisRepository
 	profileRepo domain.UserProfileRepository
 	sfRepo      domain.StorageFileRepository
+	storage     domain.Storage
 	timeout     time.Duration
 	env         *bootstrap.Env
 }
 
 func NewUserService(r domain.UserRepository, rd domain.RedisRepository,
 	p domain.UserProfileRepository, t time.Duration, env *bootstrap.Env,
-	sf domain.StorageFileRepository) domain.UserService {
+	sf domain.StorageFileRepository, st domain.Storage) domain.UserService {
 	return &userService{
 		userRepo:    r,
 		redisRepo:   rd,
@@ -29,6 +29,7 @@ func NewUserService(r domain.UserRepository, rd domain.RedisRepository,
 		env:         env,
 		profileRepo: p,
 		sfRepo:      sf,
+		storage:     st,
 	}
 }
 
@@ -67,18 +68,20 @@ func (s *userService) UpdateProfile(c context.Context, requestBody *domain.Updat
 		return err
 	}
 
+	if err := s.sfRepo.ClaimStorageFiles(ctx, []uint{requestBody.NewAvatarID}); err != nil {
+		return err
+	}
+
 	if requestBody.OldAvatarID != 0 && requestBody.NewAvatarID != requestBody.OldAvatarID {
-		oldAvatarFile, err := s.sfRepo.GetStorageFile(ctx, fmt.Sprint(requestBody.OldAvatarID))
+		orphans, err := s.sfRepo.DeleteStorageFile(ctx, fmt.Sprint(requestBody.OldAvatarID))
 		if err != nil {
 			return err
 		}
 
-		if err := s.sfRepo.DeleteStorageFile(ctx, fmt.Sprint(requestBody.OldAvatarID)); err != nil {
-			return err
-		}
-
-		if err := utils.DeleteFile(oldAvatarFile.FileKey); err != nil {
-			return err
+		for _, orphan := range orphans {
+			if err := deleteUnreferenced(ctx, s.sfRepo, s.storage, orphan); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -117,16 +120,16 @@ func (s *userService) GetUserByNameWithMod(c context.Context, name string) (*dom
 	return user, nil
 }
 
-func (s *userService) GetUsers(c context.Context, params *domain.UserQuery) (*[]domain.UsersResponse, int64, error) {
+func (s *userService) GetUsers(c context.Context, params *domain.UserQuery) (*[]domain.UsersResponse, int64, string, error) {
 	ctx, cancel := context.WithTimeout(c, s.timeout)
 	defer cancel()
 
-	user, total, err := s.userRepo.ReadUsers(ctx, params)
+	user, total, nextCursor, err := s.userRepo.ReadUsers(ctx, params)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
-	return user, total, nil
+	return user, total, nextCursor, nil
 }
 
 func (s *userService) DeleteUser(c context.Context, id string) error {